@@ -0,0 +1,82 @@
+package config
+
+import (
+	"flag"
+	"github.com/caarlos0/env/v10"
+	"log"
+	"os"
+	"time"
+)
+
+type Config struct {
+	RunAddr        string        `env:"RUN_ADDRESS"`
+	LogLevel       string        `env:"LOG_LEVEL"`
+	RulesFile      string        `env:"RULES_FILE"`
+	StatusStep     time.Duration `env:"STATUS_STEP"`
+	MinLatency     time.Duration `env:"MIN_LATENCY"`
+	MaxLatency     time.Duration `env:"MAX_LATENCY"`
+	RateLimit      int           `env:"RATE_LIMIT"`
+	AutoRegister   bool          `env:"AUTO_REGISTER"`
+	DefaultAccrual string        `env:"DEFAULT_ACCRUAL"`
+}
+
+func New() *Config {
+
+	flagCfg := &Config{}
+	flag.StringVar(&flagCfg.RunAddr, "a", ":8080", "run address")
+	flag.StringVar(&flagCfg.LogLevel, "l", "debug", "log level")
+	flag.StringVar(&flagCfg.RulesFile, "f", "", "path to a JSON file with reward rules")
+	flag.DurationVar(&flagCfg.StatusStep, "s", 2*time.Second, "time an order spends in REGISTERED and in PROCESSING")
+	flag.DurationVar(&flagCfg.MinLatency, "min-latency", 0, "minimum simulated response latency")
+	flag.DurationVar(&flagCfg.MaxLatency, "max-latency", 0, "maximum simulated response latency")
+	flag.IntVar(&flagCfg.RateLimit, "rps", 0, "requests per minute before answering 429, 0 disables the limit")
+	flag.BoolVar(&flagCfg.AutoRegister, "auto", true, "register unknown orders on first request")
+	flag.StringVar(&flagCfg.DefaultAccrual, "d", "100", "accrual of auto-registered orders")
+	flag.Parse()
+
+	envCfg := &Config{}
+	if err := env.Parse(envCfg); err != nil {
+		log.Fatalf("Failed to parse env config: %v", err)
+	}
+
+	cfg := &Config{}
+	cfg.RunAddr = envCfg.RunAddr
+	cfg.LogLevel = envCfg.LogLevel
+	cfg.RulesFile = envCfg.RulesFile
+	cfg.StatusStep = envCfg.StatusStep
+	cfg.MinLatency = envCfg.MinLatency
+	cfg.MaxLatency = envCfg.MaxLatency
+	cfg.RateLimit = envCfg.RateLimit
+	cfg.DefaultAccrual = envCfg.DefaultAccrual
+	if cfg.RunAddr == "" {
+		cfg.RunAddr = flagCfg.RunAddr
+	}
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = flagCfg.LogLevel
+	}
+	if cfg.RulesFile == "" {
+		cfg.RulesFile = flagCfg.RulesFile
+	}
+	if cfg.StatusStep == 0 {
+		cfg.StatusStep = flagCfg.StatusStep
+	}
+	if cfg.MinLatency == 0 {
+		cfg.MinLatency = flagCfg.MinLatency
+	}
+	if cfg.MaxLatency == 0 {
+		cfg.MaxLatency = flagCfg.MaxLatency
+	}
+	if cfg.RateLimit == 0 {
+		cfg.RateLimit = flagCfg.RateLimit
+	}
+	if _, ok := os.LookupEnv("AUTO_REGISTER"); ok {
+		cfg.AutoRegister = envCfg.AutoRegister
+	} else {
+		cfg.AutoRegister = flagCfg.AutoRegister
+	}
+	if cfg.DefaultAccrual == "" {
+		cfg.DefaultAccrual = flagCfg.DefaultAccrual
+	}
+
+	return cfg
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/vindosVP/loyalty-system/cmd/accrual/config"
+	"github.com/vindosVP/loyalty-system/internal/accrual/emulator"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"go.uber.org/zap"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	log.Print("Starting accrual system emulator")
+	cfg := config.New()
+	err := logger.Initialize(cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	logger.Log.Info("Configuration loaded",
+		zap.String("Run address", cfg.RunAddr),
+		zap.String("Rules file", cfg.RulesFile),
+		zap.Duration("Status step", cfg.StatusStep),
+		zap.Int("Rate limit", cfg.RateLimit),
+		zap.Bool("Auto register", cfg.AutoRegister),
+	)
+
+	defaultAccrual, err := money.Parse(cfg.DefaultAccrual)
+	if err != nil {
+		logger.Log.Fatal("Failed to parse default accrual", zap.Error(err))
+	}
+	var rules []*emulator.Rule
+	if cfg.RulesFile != "" {
+		rules, err = emulator.LoadRules(cfg.RulesFile)
+		if err != nil {
+			logger.Log.Fatal("Failed to load rules", zap.Error(err))
+		}
+	}
+	e := emulator.New(emulator.Config{
+		StatusStep:     cfg.StatusStep,
+		MinLatency:     cfg.MinLatency,
+		MaxLatency:     cfg.MaxLatency,
+		RateLimit:      cfg.RateLimit,
+		AutoRegister:   cfg.AutoRegister,
+		DefaultAccrual: defaultAccrual,
+	}, rules)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	srv := &http.Server{Addr: cfg.RunAddr, Handler: e.Handler()}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Log.Error("Failed to shut down server", zap.Error(err))
+		}
+	}()
+
+	logger.Log.Info("Server started", zap.String("Address", cfg.RunAddr))
+	err = srv.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Log.Fatal("Failed to run server", zap.Error(err))
+	}
+}
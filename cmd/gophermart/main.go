@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"github.com/vindosVP/loyalty-system/cmd/gophermart/config"
 	"github.com/vindosVP/loyalty-system/internal/server"
 	"github.com/vindosVP/loyalty-system/pkg/logger"
 	"go.uber.org/zap"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -21,7 +26,23 @@ func main() {
 		zap.String("Database URI", cfg.DBURI),
 		zap.String("Accrual system address", cfg.AccrualSysAddr),
 	)
-	err = server.Run(cfg)
+	if flag.Arg(0) == "migrate" {
+		err = runMigrate(cfg, flag.Args()[1:])
+		if err != nil {
+			logger.Log.Fatal("Failed to run migrations", zap.Error(err))
+		}
+		return
+	}
+	if flag.Arg(0) == "grant-role" {
+		err = runGrantRole(cfg, flag.Args()[1:])
+		if err != nil {
+			logger.Log.Fatal("Failed to grant role", zap.Error(err))
+		}
+		return
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = server.Run(ctx, cfg)
 	if err != nil {
 		logger.Log.Fatal("Failed to run server", zap.Error(err))
 	}
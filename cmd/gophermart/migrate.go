@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/cmd/gophermart/config"
+	"github.com/vindosVP/loyalty-system/internal/database"
+	"github.com/vindosVP/loyalty-system/internal/repos/memory"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	errUnknownMigrateCommand = errors.New("usage: gophermart migrate up|down|status")
+	errNoPersistentDatabase  = errors.New("the in-memory database doesn't persist, configure PostgreSQL or SQLite")
+)
+
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errUnknownMigrateCommand
+	}
+	if cfg.DBURI == memory.URI {
+		return errNoPersistentDatabase
+	}
+	ctx := context.Background()
+	m, closeDB, err := openMigrator(ctx, cfg.DBURI)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	switch args[0] {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		return m.Down(ctx)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("m.Status: %w", err)
+		}
+		return printMigrationStatus(os.Stdout, statuses)
+	default:
+		return errUnknownMigrateCommand
+	}
+}
+
+// migrator is implemented by database.Migrator and database.SQLiteMigrator.
+type migrator interface {
+	Up(ctx context.Context) error
+	Down(ctx context.Context) error
+	Status(ctx context.Context) ([]*database.MigrationStatus, error)
+}
+
+func openMigrator(ctx context.Context, dbURI string) (migrator, func(), error) {
+	if database.IsSQLite(dbURI) {
+		db, err := database.ConnectSQLite(ctx, dbURI)
+		if err != nil {
+			return nil, nil, fmt.Errorf("database.ConnectSQLite: %w", err)
+		}
+		m, err := database.NewSQLiteMigrator(db)
+		if err != nil {
+			db.Close()
+			return nil, nil, fmt.Errorf("database.NewSQLiteMigrator: %w", err)
+		}
+		return m, func() { db.Close() }, nil
+	}
+	pool, err := database.Connect(ctx, dbURI)
+	if err != nil {
+		return nil, nil, fmt.Errorf("database.Connect: %w", err)
+	}
+	m, err := database.NewMigrator(pool)
+	if err != nil {
+		pool.Close()
+		return nil, nil, fmt.Errorf("database.NewMigrator: %w", err)
+	}
+	return m, pool.Close, nil
+}
+
+func printMigrationStatus(out io.Writer, statuses []*database.MigrationStatus) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.Applied {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Migration.Version, s.Migration.Name, appliedAt)
+	}
+	return w.Flush()
+}
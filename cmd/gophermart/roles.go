@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/cmd/gophermart/config"
+	"github.com/vindosVP/loyalty-system/internal/database"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/repos"
+	"github.com/vindosVP/loyalty-system/internal/repos/memory"
+	"github.com/vindosVP/loyalty-system/internal/repos/sqlite"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"strings"
+)
+
+var errGrantRoleUsage = fmt.Errorf("usage: gophermart grant-role <login> <%s>", strings.Join(models.Roles, "|"))
+
+// runGrantRole grants a role from the command line. It's the only way to
+// create the first admin, since the admin API requires one.
+func runGrantRole(cfg *config.Config, args []string) error {
+	if len(args) != 2 || !models.ValidRole(args[1]) {
+		return errGrantRoleUsage
+	}
+	if cfg.DBURI == memory.URI {
+		return errNoPersistentDatabase
+	}
+	ctx := context.Background()
+	var users storage.UserRepo
+	if database.IsSQLite(cfg.DBURI) {
+		db, err := database.NewSQLite(ctx, cfg.DBURI)
+		if err != nil {
+			return fmt.Errorf("database.NewSQLite: %w", err)
+		}
+		defer db.Close()
+		users = sqlite.NewUserRepo(db)
+	} else {
+		pool, err := database.New(ctx, cfg.DBURI)
+		if err != nil {
+			return fmt.Errorf("database.New: %w", err)
+		}
+		defer pool.Close()
+		users = repos.NewUserRepo(pool)
+	}
+
+	found, err := users.AddRole(ctx, args[0], args[1])
+	if err != nil {
+		return fmt.Errorf("AddRole: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("%s: %w", args[0], storage.ErrUserNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"github.com/vindosVP/loyalty-system/internal/metrics"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StatusRegistered = "REGISTERED"
+	StatusInvalid    = "INVALID"
+	StatusProcessing = "PROCESSING"
+	StatusProcessed  = "PROCESSED"
+)
+
+const defaultRetryAfter = time.Minute
+
+var (
+	ErrTooManyRequests    = errors.New("too many requests")
+	ErrUnavailable        = errors.New("accrual system unavailable")
+	ErrOrderNotRegistered = errors.New("order is not registered in accrual system")
+)
+
+// RetryAfterError is returned when the accrual system answers 429 Too Many Requests.
+type RetryAfterError struct {
+	Delay time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", ErrTooManyRequests, e.Delay)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return ErrTooManyRequests
+}
+
+type Order struct {
+	Order   string       `json:"order"`
+	Status  string       `json:"status"`
+	Accrual money.Amount `json:"accrual,omitempty"`
+}
+
+type Client struct {
+	serverAddress string
+	client        *resty.Client
+}
+
+func NewClient(serverAddress string) *Client {
+	return &Client{
+		serverAddress: strings.TrimRight(serverAddress, "/"),
+		client:        resty.New().SetTransport(otelhttp.NewTransport(http.DefaultTransport)),
+	}
+}
+
+// GetOrder asks the accrual system for the state of an order. It returns
+// ErrOrderNotRegistered on 204, a *RetryAfterError on 429 and wraps
+// ErrUnavailable on 5xx and network errors.
+func (c *Client) GetOrder(ctx context.Context, number int) (*Order, error) {
+	var order Order
+	url := fmt.Sprintf("%s/api/orders/%s", c.serverAddress, strconv.Itoa(number))
+	start := time.Now()
+	resp, err := c.client.R().SetContext(ctx).SetResult(&order).Get(url)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		metrics.AccrualRequestDuration.WithLabelValues("error").Observe(time.Since(start).Seconds())
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	metrics.AccrualRequestDuration.WithLabelValues(strconv.Itoa(resp.StatusCode())).Observe(time.Since(start).Seconds())
+	switch {
+	case resp.StatusCode() == http.StatusTooManyRequests:
+		metrics.AccrualRateLimited.Inc()
+		return nil, &RetryAfterError{Delay: parseRetryAfter(resp.Header().Get("Retry-After"), time.Now())}
+	case resp.StatusCode() == http.StatusNoContent:
+		return nil, ErrOrderNotRegistered
+	case resp.StatusCode() >= http.StatusInternalServerError:
+		return nil, fmt.Errorf("%w: status code %d", ErrUnavailable, resp.StatusCode())
+	case resp.StatusCode() != http.StatusOK:
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode())
+	}
+	return &order, nil
+}
+
+// Ping checks that the accrual system answers HTTP requests. Any response
+// counts, the status code isn't checked.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.client.R().SetContext(ctx).Head(c.serverAddress + "/")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return nil
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return defaultRetryAfter
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return defaultRetryAfter
+}
@@ -0,0 +1,157 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/metrics"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_GetOrder(t *testing.T) {
+	type want struct {
+		order      *Order
+		err        error
+		retryAfter time.Duration
+	}
+
+	tests := []struct {
+		name   string
+		status int
+		header map[string]string
+		body   string
+		want   want
+	}{
+		{
+			name:   "processed",
+			status: http.StatusOK,
+			header: map[string]string{"Content-Type": "application/json"},
+			body:   `{"order": "12345678903", "status": "PROCESSED", "accrual": 729.98}`,
+			want: want{
+				order: &Order{Order: "12345678903", Status: StatusProcessed, Accrual: money.MustParse("729.98")},
+			},
+		},
+		{
+			name:   "too many requests",
+			status: http.StatusTooManyRequests,
+			header: map[string]string{"Retry-After": "5"},
+			want:   want{err: ErrTooManyRequests, retryAfter: 5 * time.Second},
+		},
+		{
+			name:   "not registered",
+			status: http.StatusNoContent,
+			want:   want{err: ErrOrderNotRegistered},
+		},
+		{
+			name:   "internal error",
+			status: http.StatusInternalServerError,
+			want:   want{err: ErrUnavailable},
+		},
+		{
+			name:   "bad gateway",
+			status: http.StatusBadGateway,
+			want:   want{err: ErrUnavailable},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/orders/12345678903", r.URL.Path)
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			order, err := NewClient(srv.URL+"/").GetOrder(context.Background(), 12345678903)
+			if tt.want.err != nil {
+				assert.ErrorIs(t, err, tt.want.err)
+				var retryAfter *RetryAfterError
+				if errors.As(err, &retryAfter) {
+					assert.Equal(t, tt.want.retryAfter, retryAfter.Delay)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.order, order)
+		})
+	}
+}
+
+func TestClient_GetOrder_NetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	_, err := NewClient(srv.URL).GetOrder(context.Background(), 12345678903)
+	assert.ErrorIs(t, err, ErrUnavailable)
+}
+
+func TestClient_GetOrder_Metrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	rateLimited := testutil.ToFloat64(metrics.AccrualRateLimited)
+	_, err := NewClient(srv.URL).GetOrder(context.Background(), 12345678903)
+	assert.ErrorIs(t, err, ErrTooManyRequests)
+	assert.Equal(t, rateLimited+1, testutil.ToFloat64(metrics.AccrualRateLimited))
+}
+
+func TestClient_GetOrder_PropagatesTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("traceparent"), "01000000000000000000000000000000")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.URL).GetOrder(trace.ContextWithSpanContext(context.Background(), sc), 12345678903)
+	assert.ErrorIs(t, err, ErrOrderNotRegistered)
+}
+
+func TestClient_Ping(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	assert.NoError(t, NewClient(srv.URL).Ping(context.Background()))
+	srv.Close()
+	assert.ErrorIs(t, NewClient(srv.URL).Ping(context.Background()), ErrUnavailable)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{name: "seconds", header: "60", want: time.Minute},
+		{name: "zero", header: "0", want: 0},
+		{name: "http date", header: "Fri, 01 Mar 2024 12:00:30 GMT", want: 30 * time.Second},
+		{name: "http date in the past", header: "Fri, 01 Mar 2024 11:00:00 GMT", want: 0},
+		{name: "missing", header: "", want: defaultRetryAfter},
+		{name: "invalid", header: "soon", want: defaultRetryAfter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseRetryAfter(tt.header, now))
+		})
+	}
+}
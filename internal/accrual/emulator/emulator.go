@@ -0,0 +1,163 @@
+package emulator
+
+import (
+	"errors"
+	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/vindosVP/loyalty-system/internal/accrual"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrOrderAlreadyRegistered = errors.New("order already registered")
+	ErrRuleAlreadyExists      = errors.New("rule already exists")
+)
+
+type Config struct {
+	// StatusStep is how long an order stays REGISTERED and then PROCESSING
+	// before its final status is reported.
+	StatusStep time.Duration
+	MinLatency time.Duration
+	MaxLatency time.Duration
+	// RateLimit is the number of GET requests allowed per minute, 0 disables it.
+	RateLimit int
+	// AutoRegister makes unknown orders look as if they had been registered on
+	// first request with DefaultAccrual, so gophermart works without a
+	// separate registration step.
+	AutoRegister   bool
+	DefaultAccrual money.Amount
+}
+
+type order struct {
+	number       string
+	goods        []*Good
+	accrual      money.Amount
+	invalid      bool
+	registeredAt time.Time
+}
+
+type Emulator struct {
+	cfg   Config
+	now   func() time.Time
+	sleep func(time.Duration)
+
+	mu          sync.Mutex
+	rules       []*Rule
+	orders      map[string]*order
+	windowStart time.Time
+	windowCount int
+}
+
+func New(cfg Config, rules []*Rule) *Emulator {
+	return &Emulator{
+		cfg:    cfg,
+		now:    time.Now,
+		sleep:  time.Sleep,
+		rules:  rules,
+		orders: make(map[string]*order),
+	}
+}
+
+func (e *Emulator) AddRule(rule *Rule) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range e.rules {
+		if r.Match == rule.Match {
+			return ErrRuleAlreadyExists
+		}
+	}
+	e.rules = append(e.rules, rule)
+	return nil
+}
+
+// RegisterOrder calculates the accrual of an order right away; the result is
+// only revealed once the order has gone through the status progression.
+func (e *Emulator) RegisterOrder(number string, goods []*Good) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.orders[number]; ok {
+		return ErrOrderAlreadyRegistered
+	}
+	accrual, ok := calculate(e.rules, goods)
+	e.orders[number] = &order{
+		number:       number,
+		goods:        goods,
+		accrual:      accrual,
+		invalid:      !ok || goluhn.Validate(number) != nil,
+		registeredAt: e.now(),
+	}
+	return nil
+}
+
+// GetOrder returns the current state of an order or nil if it is unknown.
+func (e *Emulator) GetOrder(number string) *accrual.Order {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	o, ok := e.orders[number]
+	if !ok {
+		if !e.cfg.AutoRegister {
+			return nil
+		}
+		o = &order{
+			number:       number,
+			accrual:      e.cfg.DefaultAccrual,
+			invalid:      goluhn.Validate(number) != nil,
+			registeredAt: e.now(),
+		}
+		e.orders[number] = o
+	}
+
+	elapsed := e.now().Sub(o.registeredAt)
+	switch {
+	case elapsed < e.cfg.StatusStep:
+		return &accrual.Order{Order: o.number, Status: accrual.StatusRegistered}
+	case elapsed < 2*e.cfg.StatusStep:
+		return &accrual.Order{Order: o.number, Status: accrual.StatusProcessing}
+	case o.invalid:
+		return &accrual.Order{Order: o.number, Status: accrual.StatusInvalid}
+	default:
+		return &accrual.Order{Order: o.number, Status: accrual.StatusProcessed, Accrual: o.accrual}
+	}
+}
+
+// allow counts a request against a fixed one-minute window. When the limit is
+// reached it returns how long to wait until the window resets.
+func (e *Emulator) allow() (time.Duration, bool) {
+	if e.cfg.RateLimit <= 0 {
+		return 0, true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	if now.Sub(e.windowStart) >= time.Minute {
+		e.windowStart = now
+		e.windowCount = 0
+	}
+	if e.windowCount >= e.cfg.RateLimit {
+		return e.windowStart.Add(time.Minute).Sub(now), false
+	}
+	e.windowCount++
+	return 0, true
+}
+
+func (e *Emulator) simulateLatency() {
+	if e.cfg.MaxLatency <= 0 {
+		return
+	}
+	latency := e.cfg.MinLatency
+	if spread := e.cfg.MaxLatency - e.cfg.MinLatency; spread > 0 {
+		latency += time.Duration(rand.Int63n(int64(spread)))
+	}
+	e.sleep(latency)
+}
+
+func retryAfterSeconds(d time.Duration) string {
+	seconds := int((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
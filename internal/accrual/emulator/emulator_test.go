@@ -0,0 +1,148 @@
+package emulator
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/accrual"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestEmulator(cfg Config, rules []*Rule) (*Emulator, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	e := New(cfg, rules)
+	e.now = clock.Now
+	e.sleep = func(time.Duration) {}
+	return e, clock
+}
+
+func TestCalculate(t *testing.T) {
+	rules := []*Rule{
+		{Match: "Bork", Reward: money.FromInt(10), RewardType: RewardTypePercent},
+		{Match: "LG", Reward: money.FromInt(50), RewardType: RewardTypePoints},
+	}
+
+	tests := []struct {
+		name    string
+		goods   []*Good
+		accrual money.Amount
+		ok      bool
+	}{
+		{
+			name:    "percent",
+			goods:   []*Good{{Description: "Чайник Bork", Price: money.FromInt(7000)}},
+			accrual: money.FromInt(700),
+			ok:      true,
+		},
+		{
+			name:    "percent rounds to hundredths",
+			goods:   []*Good{{Description: "bork kettle", Price: money.MustParse("0.15")}},
+			accrual: money.MustParse("0.02"),
+			ok:      true,
+		},
+		{
+			name: "points and percent",
+			goods: []*Good{
+				{Description: "Стиральная машинка LG", Price: money.FromInt(30000)},
+				{Description: "Чайник Bork", Price: money.FromInt(7000)},
+				{Description: "Unknown", Price: money.FromInt(100)},
+			},
+			accrual: money.FromInt(750),
+			ok:      true,
+		},
+		{
+			name:  "no match",
+			goods: []*Good{{Description: "Unknown", Price: money.FromInt(100)}},
+			ok:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accrual, ok := calculate(rules, tt.goods)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.accrual, accrual)
+		})
+	}
+}
+
+func TestEmulator_StatusProgression(t *testing.T) {
+	rules := []*Rule{{Match: "Bork", Reward: money.FromInt(10), RewardType: RewardTypePercent}}
+	e, clock := newTestEmulator(Config{StatusStep: time.Second}, rules)
+	require.NoError(t, e.RegisterOrder("12345678903", []*Good{{Description: "Bork", Price: money.FromInt(1000)}}))
+	require.NoError(t, e.RegisterOrder("79927398713", []*Good{{Description: "Unknown", Price: money.FromInt(1000)}}))
+	assert.ErrorIs(t, e.RegisterOrder("12345678903", nil), ErrOrderAlreadyRegistered)
+
+	assert.Equal(t, accrual.StatusRegistered, e.GetOrder("12345678903").Status)
+	clock.Advance(time.Second)
+	assert.Equal(t, accrual.StatusProcessing, e.GetOrder("12345678903").Status)
+	clock.Advance(time.Second)
+	assert.Equal(t, &accrual.Order{Order: "12345678903", Status: accrual.StatusProcessed, Accrual: money.FromInt(100)}, e.GetOrder("12345678903"))
+	assert.Equal(t, accrual.StatusInvalid, e.GetOrder("79927398713").Status)
+
+	assert.Nil(t, e.GetOrder("4561261212345467"))
+}
+
+func TestEmulator_AutoRegister(t *testing.T) {
+	e, clock := newTestEmulator(Config{AutoRegister: true, DefaultAccrual: money.FromInt(100)}, nil)
+	clock.Advance(time.Second)
+	assert.Equal(t, &accrual.Order{Order: "12345678903", Status: accrual.StatusProcessed, Accrual: money.FromInt(100)}, e.GetOrder("12345678903"))
+	assert.Equal(t, accrual.StatusInvalid, e.GetOrder("12345678900").Status)
+}
+
+func TestEmulator_Handler(t *testing.T) {
+	e, clock := newTestEmulator(Config{RateLimit: 2}, nil)
+	srv := httptest.NewServer(e.Handler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/goods", "application/json", strings.NewReader(`{"match": "Bork", "reward": 10, "reward_type": "%"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL+"/api/orders", "application/json", strings.NewReader(`{"order": "12345678903", "goods": [{"description": "Чайник Bork", "price": 7000}]}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	client := accrual.NewClient(srv.URL)
+	order, err := client.GetOrder(context.Background(), 12345678903)
+	require.NoError(t, err)
+	assert.Equal(t, &accrual.Order{Order: "12345678903", Status: accrual.StatusProcessed, Accrual: money.FromInt(700)}, order)
+
+	_, err = client.GetOrder(context.Background(), 79927398713)
+	assert.ErrorIs(t, err, accrual.ErrOrderNotRegistered)
+
+	_, err = client.GetOrder(context.Background(), 12345678903)
+	var retryAfter *accrual.RetryAfterError
+	require.True(t, errors.As(err, &retryAfter))
+	assert.Equal(t, time.Minute, retryAfter.Delay)
+
+	clock.Advance(time.Minute)
+	_, err = client.GetOrder(context.Background(), 12345678903)
+	assert.NoError(t, err)
+}
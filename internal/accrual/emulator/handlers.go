@@ -0,0 +1,98 @@
+package emulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+type RegisterOrderRequest struct {
+	Order string  `json:"order"`
+	Goods []*Good `json:"goods"`
+}
+
+func (e *Emulator) Handler() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/api/orders/{number}", e.getOrder)
+	r.Post("/api/orders", e.registerOrder)
+	r.Post("/api/goods", e.addRule)
+	return r
+}
+
+func (e *Emulator) getOrder(w http.ResponseWriter, r *http.Request) {
+	if retryAfter, ok := e.allow(); !ok {
+		w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+		http.Error(w, fmt.Sprintf("No more than %d requests per minute allowed", e.cfg.RateLimit), http.StatusTooManyRequests)
+		return
+	}
+	e.simulateLatency()
+
+	number := chi.URLParam(r, "number")
+	if _, err := strconv.Atoi(number); err != nil {
+		http.Error(w, "Invalid order number", http.StatusBadRequest)
+		return
+	}
+	order := e.GetOrder(number)
+	if order == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(order); err != nil {
+		logger.Log.Error("Error encoding response", zap.Error(err))
+	}
+}
+
+func (e *Emulator) registerOrder(w http.ResponseWriter, r *http.Request) {
+	var req RegisterOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, err := strconv.Atoi(req.Order); err != nil {
+		http.Error(w, "Invalid order number", http.StatusBadRequest)
+		return
+	}
+
+	err := e.RegisterOrder(req.Order, req.Goods)
+	if err != nil {
+		if errors.Is(err, ErrOrderAlreadyRegistered) {
+			http.Error(w, "Order already registered", http.StatusConflict)
+			return
+		}
+		logger.Log.Error("Error registering order", zap.Error(err))
+		http.Error(w, "Error registering order", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (e *Emulator) addRule(w http.ResponseWriter, r *http.Request) {
+	var rule Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := rule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := e.AddRule(&rule)
+	if err != nil {
+		if errors.Is(err, ErrRuleAlreadyExists) {
+			http.Error(w, "Rule already exists", http.StatusConflict)
+			return
+		}
+		logger.Log.Error("Error adding rule", zap.Error(err))
+		http.Error(w, "Error adding rule", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,91 @@
+package emulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"os"
+	"strings"
+)
+
+const (
+	RewardTypePercent = "%"
+	RewardTypePoints  = "pt"
+)
+
+var ErrInvalidRule = errors.New("invalid reward rule")
+
+// Rule rewards goods whose description contains Match, either with a percent
+// of the price or with a fixed number of points.
+type Rule struct {
+	Match      string       `json:"match"`
+	Reward     money.Amount `json:"reward"`
+	RewardType string       `json:"reward_type"`
+}
+
+func (r *Rule) Validate() error {
+	if r.Match == "" {
+		return fmt.Errorf("%w: empty match", ErrInvalidRule)
+	}
+	if r.Reward < 0 {
+		return fmt.Errorf("%w: negative reward", ErrInvalidRule)
+	}
+	if r.RewardType != RewardTypePercent && r.RewardType != RewardTypePoints {
+		return fmt.Errorf("%w: unknown reward type %q", ErrInvalidRule, r.RewardType)
+	}
+	return nil
+}
+
+func (r *Rule) matches(description string) bool {
+	return strings.Contains(strings.ToLower(description), strings.ToLower(r.Match))
+}
+
+func (r *Rule) reward(price money.Amount) money.Amount {
+	if r.RewardType == RewardTypePoints {
+		return r.Reward
+	}
+	// Both values are in hundredths, round the product half away from zero.
+	product := int64(price) * int64(r.Reward)
+	if product >= 0 {
+		return money.Amount((product + 5000) / 10000)
+	}
+	return money.Amount((product - 5000) / 10000)
+}
+
+type Good struct {
+	Description string       `json:"description"`
+	Price       money.Amount `json:"price"`
+}
+
+// calculate sums the rewards of all goods using the first matching rule for
+// each of them. ok is false when no good matched any rule.
+func calculate(rules []*Rule, goods []*Good) (accrual money.Amount, ok bool) {
+	for _, good := range goods {
+		for _, rule := range rules {
+			if rule.matches(good.Description) {
+				accrual += rule.reward(good.Price)
+				ok = true
+				break
+			}
+		}
+	}
+	return accrual, ok
+}
+
+func LoadRules(path string) ([]*Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	var rules []*Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,72 @@
+package accrual
+
+import (
+	"context"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"strconv"
+	"sync"
+)
+
+// Response is a scripted answer of the Fake client: either an order state or
+// an error such as ErrOrderNotRegistered or &RetryAfterError{}.
+type Response struct {
+	Status  string
+	Accrual money.Amount
+	Err     error
+}
+
+// Fake is a deterministic in-process AccrualClient. Each call for an order
+// returns the next scripted response; the last one is repeated once the
+// script is exhausted. Orders without a script are not registered.
+type Fake struct {
+	mu      sync.Mutex
+	scripts map[int][]Response
+	calls   map[int]int
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		scripts: make(map[int][]Response),
+		calls:   make(map[int]int),
+	}
+}
+
+func (f *Fake) Script(number int, responses ...Response) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scripts[number] = responses
+	return f
+}
+
+func (f *Fake) Calls(number int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[number]
+}
+
+func (f *Fake) GetOrder(ctx context.Context, number int) (*Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	call := f.calls[number]
+	f.calls[number]++
+
+	script := f.scripts[number]
+	if len(script) == 0 {
+		return nil, ErrOrderNotRegistered
+	}
+	if call >= len(script) {
+		call = len(script) - 1
+	}
+	resp := script[call]
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	return &Order{
+		Order:   strconv.Itoa(number),
+		Status:  resp.Status,
+		Accrual: resp.Accrual,
+	}, nil
+}
@@ -0,0 +1,35 @@
+package accrual
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"testing"
+)
+
+func TestFake_GetOrder(t *testing.T) {
+	ctx := context.Background()
+	f := NewFake().Script(12345678903,
+		Response{Err: ErrUnavailable},
+		Response{Status: StatusProcessing},
+		Response{Status: StatusProcessed, Accrual: money.FromInt(500)},
+	)
+
+	_, err := f.GetOrder(ctx, 12345678903)
+	assert.ErrorIs(t, err, ErrUnavailable)
+
+	order, err := f.GetOrder(ctx, 12345678903)
+	require.NoError(t, err)
+	assert.Equal(t, StatusProcessing, order.Status)
+
+	for i := 0; i < 2; i++ {
+		order, err = f.GetOrder(ctx, 12345678903)
+		require.NoError(t, err)
+		assert.Equal(t, &Order{Order: "12345678903", Status: StatusProcessed, Accrual: money.FromInt(500)}, order)
+	}
+	assert.Equal(t, 4, f.Calls(12345678903))
+
+	_, err = f.GetOrder(ctx, 79927398713)
+	assert.ErrorIs(t, err, ErrOrderNotRegistered)
+}
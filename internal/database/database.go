@@ -4,41 +4,42 @@ import (
 	"context"
 	"fmt"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vindosVP/loyalty-system/internal/tracing"
 	"github.com/vindosVP/loyalty-system/pkg/logger"
 )
 
+// New connects to the database and applies pending migrations.
 func New(ctx context.Context, dbURI string) (*pgxpool.Pool, error) {
-	logger.Log.Info("Connecting to database")
-	pool, err := pgxpool.New(ctx, dbURI)
+	pool, err := Connect(ctx, dbURI)
 	if err != nil {
-		return nil, fmt.Errorf("pgxpool.New: %w", err)
+		return nil, fmt.Errorf("Connect: %w", err)
 	}
-	logger.Log.Info("Connected successfully")
-	logger.Log.Info("Creating tables")
-	err = createTables(ctx, pool)
+	m, err := NewMigrator(pool)
 	if err != nil {
-		return nil, fmt.Errorf("createTables: %w", err)
+		pool.Close()
+		return nil, fmt.Errorf("NewMigrator: %w", err)
 	}
-	logger.Log.Info("Tables created successfully")
+	logger.Log.Info("Applying migrations")
+	err = m.Up(ctx)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("m.Up: %w", err)
+	}
+	logger.Log.Info("Migrations applied successfully")
 	return pool, nil
 }
 
-func createTables(ctx context.Context, pool *pgxpool.Pool) error {
-	query := `CREATE TABLE IF NOT EXISTS users (
-    			  id SERIAL NOT NULL PRIMARY KEY, 
-    			  login TEXT NOT NULL, 
-    			  encryptedPassword TEXT NOT NULL
-              );
-              CREATE TABLE IF NOT EXISTS orders (
-                  id BIGINT NOT NULL PRIMARY KEY, 
-                  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE, 
-                  status TEXT NOT NULL, 
-                  sum FLOAT NOT NULL, 
-                  uploaded_at TIMESTAMP NOT NULL
-    		  );`
-	_, err := pool.Exec(ctx, query)
+func Connect(ctx context.Context, dbURI string) (*pgxpool.Pool, error) {
+	logger.Log.Info("Connecting to database")
+	cfg, err := pgxpool.ParseConfig(dbURI)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("pgxpool.ParseConfig: %w", err)
 	}
-	return nil
+	cfg.ConnConfig.Tracer = tracing.QueryTracer{}
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("pgxpool.NewWithConfig: %w", err)
+	}
+	logger.Log.Info("Connected successfully")
+	return pool, nil
 }
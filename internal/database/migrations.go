@@ -0,0 +1,258 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"go.uber.org/zap"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationsLockKey is the pg_advisory_lock key that keeps replicas starting
+// at the same time from applying migrations concurrently.
+const migrationsLockKey int64 = 7_514_020_311
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+var (
+	ErrNoMigrationsApplied = errors.New("no migrations applied")
+	ErrInvalidMigration    = errors.New("invalid migration")
+)
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration *Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []*Migration
+}
+
+func NewMigrator(pool *pgxpool.Pool) (*Migrator, error) {
+	sub, err := fs.Sub(migrationsFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("fs.Sub: %w", err)
+	}
+	migrations, err := loadMigrations(sub)
+	if err != nil {
+		return nil, fmt.Errorf("loadMigrations: %w", err)
+	}
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+func loadMigrations(fsys fs.FS) ([]*Migration, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("fs.ReadDir: %w", err)
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, f := range files {
+		match := migrationFileRe.FindStringSubmatch(f.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%w: unexpected file %s", ErrInvalidMigration, f.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("strconv.ParseInt: %w", err)
+		}
+		data, err := fs.ReadFile(fsys, path.Clean(f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("fs.ReadFile: %w", err)
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("%w: version %d has names %s and %s", ErrInvalidMigration, version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("%w: version %d must have both up and down files", ErrInvalidMigration, m.Version)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies all pending migrations, each in its own transaction.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("appliedVersions: %w", err)
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			logger.Log.Info("Applying migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, migration.Up); err != nil {
+					return fmt.Errorf("tx.Exec: %w", err)
+				}
+				query := "insert into schema_migrations (version, name, applied_at) values ($1, $2, $3)"
+				if _, err := tx.Exec(ctx, query, migration.Version, migration.Name, time.Now()); err != nil {
+					return fmt.Errorf("tx.Exec: %w", err)
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("appliedVersions: %w", err)
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			logger.Log.Info("Rolling back migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, migration.Down); err != nil {
+					return fmt.Errorf("tx.Exec: %w", err)
+				}
+				if _, err := tx.Exec(ctx, "delete from schema_migrations where version = $1", migration.Version); err != nil {
+					return fmt.Errorf("tx.Exec: %w", err)
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			return nil
+		}
+		return ErrNoMigrationsApplied
+	})
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	var statuses []*MigrationStatus
+	err := m.withConn(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("appliedVersions: %w", err)
+		}
+		statuses = make([]*MigrationStatus, len(m.migrations))
+		for i, migration := range m.migrations {
+			appliedAt, ok := applied[migration.Version]
+			statuses[i] = &MigrationStatus{Migration: migration, Applied: ok, AppliedAt: appliedAt}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// Pending returns the versions of the migrations that aren't applied.
+func (m *Migrator) Pending(ctx context.Context) ([]int64, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("m.Status: %w", err)
+	}
+	return pendingVersions(statuses), nil
+}
+
+func pendingVersions(statuses []*MigrationStatus) []int64 {
+	var pending []int64
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, status.Migration.Version)
+		}
+	}
+	return pending
+}
+
+func (m *Migrator) withConn(ctx context.Context, f func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("m.pool.Acquire: %w", err)
+	}
+	defer conn.Release()
+	query := `create table if not exists schema_migrations (
+                  version BIGINT NOT NULL PRIMARY KEY,
+                  name TEXT NOT NULL,
+                  applied_at TIMESTAMP NOT NULL
+              )`
+	if _, err := conn.Exec(ctx, query); err != nil {
+		return fmt.Errorf("conn.Exec: %w", err)
+	}
+	return f(conn)
+}
+
+// withLock holds a session-level advisory lock on a dedicated connection while
+// f runs, so only one instance migrates the schema at a time.
+func (m *Migrator) withLock(ctx context.Context, f func(conn *pgxpool.Conn) error) error {
+	return m.withConn(ctx, func(conn *pgxpool.Conn) error {
+		if _, err := conn.Exec(ctx, "select pg_advisory_lock($1)", migrationsLockKey); err != nil {
+			return fmt.Errorf("pg_advisory_lock: %w", err)
+		}
+		defer func() {
+			if _, err := conn.Exec(context.Background(), "select pg_advisory_unlock($1)", migrationsLockKey); err != nil {
+				logger.Log.Error("Failed to release migrations lock", zap.Error(err))
+			}
+		}()
+		return f(conn)
+	})
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, "select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("conn.Query: %w", err)
+	}
+	defer rows.Close()
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
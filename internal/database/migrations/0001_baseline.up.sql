@@ -0,0 +1,69 @@
+-- Baseline schema. Statements are idempotent so databases created before
+-- migrations were introduced are adopted as is.
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL NOT NULL PRIMARY KEY,
+    login TEXT NOT NULL,
+    encryptedPassword TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id BIGINT NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    sum NUMERIC(20,2) NOT NULL,
+    uploaded_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id SERIAL NOT NULL PRIMARY KEY,
+    code TEXT NOT NULL UNIQUE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    order_id BIGINT NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (kind, order_id)
+);
+
+CREATE TABLE IF NOT EXISTS ledger_postings (
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES ledger_entries(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES ledger_accounts(id) ON DELETE CASCADE,
+    amount NUMERIC(20,2) NOT NULL
+);
+
+ALTER TABLE orders ALTER COLUMN sum TYPE NUMERIC(20,2);
+ALTER TABLE ledger_postings ALTER COLUMN amount TYPE NUMERIC(20,2);
+
+INSERT INTO ledger_accounts (code) VALUES ('system:accruals'), ('system:withdrawals')
+ON CONFLICT (code) DO NOTHING;
+
+-- Balances used to be kept in the orders table: accrued sums of processed
+-- orders and withdrawals stored as orders with a negative sum.
+INSERT INTO ledger_accounts (code, user_id)
+SELECT DISTINCT 'user:' || user_id, user_id FROM orders WHERE sum < 0 OR (sum > 0 AND status = 'PROCESSED')
+ON CONFLICT (code) DO NOTHING;
+
+WITH entries AS (
+    INSERT INTO ledger_entries (kind, order_id, user_id, created_at)
+    SELECT CASE WHEN sum < 0 THEN 'WITHDRAWAL' ELSE 'ACCRUAL' END, id, user_id, uploaded_at
+    FROM orders WHERE sum < 0 OR (sum > 0 AND status = 'PROCESSED')
+    ON CONFLICT (kind, order_id) DO NOTHING
+    RETURNING id, kind, order_id, user_id
+)
+INSERT INTO ledger_postings (entry_id, account_id, amount)
+SELECT e.id, a.id, o.sum FROM entries e
+JOIN orders o ON o.id = e.order_id
+JOIN ledger_accounts a ON a.code = 'user:' || e.user_id
+UNION ALL
+SELECT e.id, a.id, -o.sum FROM entries e
+JOIN orders o ON o.id = e.order_id
+JOIN ledger_accounts a ON a.code = CASE WHEN e.kind = 'WITHDRAWAL' THEN 'system:withdrawals' ELSE 'system:accruals' END;
+
+-- The legacy withdrawal rows are kept, so nothing is lost, but marked so the
+-- order queries leave them out.
+UPDATE orders SET status = 'WITHDRAWAL' WHERE sum < 0;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS ledger_postings_account_id_idx;
+DROP INDEX IF EXISTS ledger_postings_entry_id_idx;
+DROP INDEX IF EXISTS ledger_entries_user_id_idx;
+DROP INDEX IF EXISTS orders_status_idx;
+DROP INDEX IF EXISTS orders_user_id_idx;
+DROP INDEX IF EXISTS users_login_key;
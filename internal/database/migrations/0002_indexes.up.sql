@@ -0,0 +1,6 @@
+CREATE UNIQUE INDEX IF NOT EXISTS users_login_key ON users (login);
+CREATE INDEX IF NOT EXISTS orders_user_id_idx ON orders (user_id);
+CREATE INDEX IF NOT EXISTS orders_status_idx ON orders (status);
+CREATE INDEX IF NOT EXISTS ledger_entries_user_id_idx ON ledger_entries (user_id);
+CREATE INDEX IF NOT EXISTS ledger_postings_entry_id_idx ON ledger_postings (entry_id);
+CREATE INDEX IF NOT EXISTS ledger_postings_account_id_idx ON ledger_postings (account_id);
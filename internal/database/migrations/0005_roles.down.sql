@@ -0,0 +1,6 @@
+DELETE FROM ledger_entries WHERE order_id IS NULL;
+ALTER TABLE ledger_entries DROP COLUMN IF EXISTS created_by;
+ALTER TABLE ledger_entries DROP COLUMN IF EXISTS reason;
+ALTER TABLE ledger_entries ALTER COLUMN order_id SET NOT NULL;
+
+ALTER TABLE users DROP COLUMN IF EXISTS roles;
@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS roles TEXT[] NOT NULL DEFAULT '{customer}';
+
+-- Manual adjustments are not tied to an order. NULLs never conflict, so the
+-- (kind, order_id) constraint keeps deduplicating order entries only.
+ALTER TABLE ledger_entries ALTER COLUMN order_id DROP NOT NULL;
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS reason TEXT;
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS created_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+INSERT INTO ledger_accounts (code) VALUES ('system:adjustments')
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,36 @@
+-- Audit events outlive the users and orders they refer to, so there are no
+-- foreign keys. before and after are JSON rather than JSONB to keep the text
+-- the hash was computed over.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    occurred_at TIMESTAMP NOT NULL,
+    actor_id INTEGER,
+    action TEXT NOT NULL,
+    entity_type TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    user_id INTEGER,
+    order_id BIGINT,
+    before JSON,
+    after JSON,
+    request_id TEXT,
+    ip TEXT,
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_user_id_idx ON audit_events (user_id, id);
+CREATE INDEX IF NOT EXISTS audit_events_order_id_idx ON audit_events (order_id, id);
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_no_update ON audit_events;
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
+CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
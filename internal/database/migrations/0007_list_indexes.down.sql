@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS ledger_entries_user_kind_created_at_idx;
+DROP INDEX IF EXISTS orders_user_id_uploaded_at_idx;
@@ -0,0 +1,3 @@
+-- Keyset pagination of a user's orders and withdrawals.
+CREATE INDEX IF NOT EXISTS orders_user_id_uploaded_at_idx ON orders (user_id, uploaded_at, id);
+CREATE INDEX IF NOT EXISTS ledger_entries_user_kind_created_at_idx ON ledger_entries (user_id, kind, created_at, order_id);
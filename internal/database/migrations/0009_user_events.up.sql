@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS user_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    data JSON NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS user_events_user_id_idx ON user_events (user_id, id);
+CREATE INDEX IF NOT EXISTS user_events_subject_idx ON user_events (user_id, subject, id);
+CREATE INDEX IF NOT EXISTS user_events_created_at_idx ON user_events (created_at);
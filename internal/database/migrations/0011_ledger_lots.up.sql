@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS ledger_lots (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES ledger_entries(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount NUMERIC(20,2) NOT NULL,
+    remaining NUMERIC(20,2) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS ledger_lots_user_id_idx ON ledger_lots (user_id, id) WHERE remaining > 0;
+CREATE INDEX IF NOT EXISTS ledger_lots_expires_at_idx ON ledger_lots (expires_at) WHERE remaining > 0;
+
+INSERT INTO ledger_accounts (code) VALUES ('system:expirations')
+ON CONFLICT (code) DO NOTHING;
+
+-- Every credit so far becomes a lot. What users have spent is taken from
+-- their oldest lots, so the lots of a user add up to their balance. The lots
+-- get no expiry date here: the service starts the clock for accruals once it
+-- runs with an expiry policy, so nothing expires retroactively.
+WITH credits AS (
+    SELECT e.id AS entry_id, e.user_id, e.created_at, p.amount,
+           sum(p.amount) OVER (PARTITION BY e.user_id ORDER BY e.id) AS running
+    FROM ledger_entries e
+    JOIN ledger_postings p ON p.entry_id = e.id
+    JOIN ledger_accounts a ON a.id = p.account_id AND a.user_id = e.user_id
+    WHERE p.amount > 0
+), spent AS (
+    SELECT a.user_id, -sum(p.amount) AS amount
+    FROM ledger_postings p
+    JOIN ledger_accounts a ON a.id = p.account_id
+    WHERE a.user_id IS NOT NULL AND p.amount < 0
+    GROUP BY a.user_id
+)
+INSERT INTO ledger_lots (entry_id, user_id, amount, remaining, created_at)
+SELECT c.entry_id, c.user_id, c.amount,
+       greatest(0, least(c.amount, c.running - coalesce(s.amount, 0))),
+       c.created_at
+FROM credits c
+LEFT JOIN spent s ON s.user_id = c.user_id
+ORDER BY c.entry_id;
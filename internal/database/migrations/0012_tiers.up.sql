@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS tier_rules (
+    tier TEXT NOT NULL PRIMARY KEY,
+    min_accrued NUMERIC(20,2) NOT NULL,
+    multiplier NUMERIC(20,2) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+INSERT INTO tier_rules (tier, min_accrued, multiplier, updated_at) VALUES
+    ('BRONZE', 0, 1, now()),
+    ('SILVER', 1000, 1.25, now()),
+    ('GOLD', 5000, 1.5, now())
+ON CONFLICT (tier) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS tier_changes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tier TEXT NOT NULL,
+    accrued NUMERIC(20,2) NOT NULL,
+    changed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS tier_changes_user_id_idx ON tier_changes (user_id, id);
+
+-- What an accrual was worth before the tier multiplier, which is what counts
+-- towards the tiers. Accruals posted before tiers were multiplied by nothing.
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS accrued NUMERIC(20,2);
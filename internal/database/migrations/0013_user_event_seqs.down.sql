@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_event_seqs;
+DROP INDEX IF EXISTS user_events_seq_idx;
+ALTER TABLE user_events DROP COLUMN IF EXISTS seq;
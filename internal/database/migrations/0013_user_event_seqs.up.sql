@@ -0,0 +1,18 @@
+-- Events are numbered per user under a lock on the user's counter, so they
+-- commit in the order of their numbers and a client resuming after the last
+-- event it saw can't miss one that committed later with a lower number.
+-- Existing events keep their ids as numbers and counters start past every id
+-- handed out so far, so Last-Event-IDs already sent stay valid.
+ALTER TABLE user_events ADD COLUMN IF NOT EXISTS seq BIGINT;
+UPDATE user_events SET seq = id WHERE seq IS NULL;
+ALTER TABLE user_events ALTER COLUMN seq SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS user_events_seq_idx ON user_events (user_id, seq);
+
+CREATE TABLE IF NOT EXISTS user_event_seqs (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    seq BIGINT NOT NULL
+);
+
+INSERT INTO user_event_seqs (user_id, seq)
+SELECT id, (SELECT last_value FROM user_events_id_seq) FROM users
+ON CONFLICT (user_id) DO NOTHING;
@@ -0,0 +1,4 @@
+-- A key stays in progress only until locked_until, so a retry can take it
+-- over when the request that reserved it died before finishing or releasing
+-- it. Keys reserved before this have no lease and can be taken over at once.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
@@ -0,0 +1,160 @@
+-- SQLite schema, equivalent to PostgreSQL migrations 0001 to 0010. Amounts are
+-- integer hundredths and timestamps integer microseconds since the Unix epoch,
+-- so sums are exact and comparisons numeric. AUTOINCREMENT keeps ids of
+-- deleted rows from being reused, like PostgreSQL sequences do.
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    login TEXT NOT NULL UNIQUE,
+    encrypted_password TEXT NOT NULL,
+    roles TEXT NOT NULL DEFAULT '["customer"]'
+);
+
+CREATE TABLE orders (
+    id INTEGER NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    sum INTEGER NOT NULL,
+    uploaded_at INTEGER NOT NULL,
+    locked_by TEXT,
+    locked_until INTEGER
+);
+
+CREATE INDEX orders_status_idx ON orders (status);
+CREATE INDEX orders_user_id_uploaded_at_idx ON orders (user_id, uploaded_at, id);
+CREATE INDEX orders_unprocessed_idx ON orders (uploaded_at) WHERE status IN ('NEW', 'PROCESSING');
+
+CREATE TABLE ledger_accounts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL UNIQUE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO ledger_accounts (code) VALUES ('system:accruals'), ('system:withdrawals'), ('system:adjustments');
+
+-- NULLs never conflict, so adjustments, which have no order, aren't
+-- deduplicated by the (kind, order_id) constraint.
+CREATE TABLE ledger_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    order_id INTEGER,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at INTEGER NOT NULL,
+    reason TEXT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE (kind, order_id)
+);
+
+CREATE INDEX ledger_entries_user_kind_created_at_idx ON ledger_entries (user_id, kind, created_at, order_id);
+
+CREATE TABLE ledger_postings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entry_id INTEGER NOT NULL REFERENCES ledger_entries(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES ledger_accounts(id) ON DELETE CASCADE,
+    amount INTEGER NOT NULL
+);
+
+CREATE INDEX ledger_postings_entry_id_idx ON ledger_postings (entry_id);
+CREATE INDEX ledger_postings_account_id_idx ON ledger_postings (account_id);
+
+CREATE TABLE sessions (
+    id TEXT NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refresh_token_hash TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL,
+    revoked_at INTEGER
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- Audit events outlive the users and orders they refer to, so there are no
+-- foreign keys. before and after keep the JSON text the hash was computed over.
+CREATE TABLE audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    occurred_at INTEGER NOT NULL,
+    actor_id INTEGER,
+    action TEXT NOT NULL,
+    entity_type TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    user_id INTEGER,
+    order_id INTEGER,
+    before TEXT,
+    after TEXT,
+    request_id TEXT,
+    ip TEXT,
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX audit_events_user_id_idx ON audit_events (user_id, id);
+CREATE INDEX audit_events_order_id_idx ON audit_events (order_id, id);
+
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TABLE idempotency_keys (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    status_code INTEGER,
+    headers TEXT,
+    body BLOB,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL,
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+CREATE TABLE user_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    data TEXT NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX user_events_user_id_idx ON user_events (user_id, id);
+CREATE INDEX user_events_subject_idx ON user_events (user_id, subject, id);
+CREATE INDEX user_events_created_at_idx ON user_events (created_at);
+
+-- events is a JSON array of event types.
+CREATE TABLE webhook_endpoints (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX webhook_endpoints_user_id_idx ON webhook_endpoints (user_id);
+
+CREATE TABLE webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    endpoint_id INTEGER NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    state TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at INTEGER NOT NULL,
+    last_status INTEGER,
+    last_error TEXT NOT NULL DEFAULT '',
+    locked_by TEXT,
+    locked_until INTEGER,
+    created_at INTEGER NOT NULL,
+    delivered_at INTEGER
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE state = 'pending';
+CREATE INDEX webhook_deliveries_endpoint_idx ON webhook_deliveries (endpoint_id, state, id);
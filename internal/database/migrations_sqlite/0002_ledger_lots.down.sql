@@ -0,0 +1,2 @@
+-- Expiry entries stay in the ledger, they are part of the users' balances.
+DROP TABLE IF EXISTS ledger_lots;
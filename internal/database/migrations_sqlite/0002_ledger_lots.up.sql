@@ -0,0 +1,39 @@
+CREATE TABLE ledger_lots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entry_id INTEGER NOT NULL REFERENCES ledger_entries(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount INTEGER NOT NULL,
+    remaining INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER
+);
+
+CREATE INDEX ledger_lots_user_id_idx ON ledger_lots (user_id, id) WHERE remaining > 0;
+CREATE INDEX ledger_lots_expires_at_idx ON ledger_lots (expires_at) WHERE remaining > 0;
+
+INSERT INTO ledger_accounts (code) VALUES ('system:expirations')
+ON CONFLICT (code) DO NOTHING;
+
+-- Every credit so far becomes a lot without an expiry date, see the
+-- PostgreSQL migration.
+WITH credits AS (
+    SELECT e.id AS entry_id, e.user_id, e.created_at, p.amount,
+           sum(p.amount) OVER (PARTITION BY e.user_id ORDER BY e.id) AS running
+    FROM ledger_entries e
+    JOIN ledger_postings p ON p.entry_id = e.id
+    JOIN ledger_accounts a ON a.id = p.account_id AND a.user_id = e.user_id
+    WHERE p.amount > 0
+), spent AS (
+    SELECT a.user_id, -sum(p.amount) AS amount
+    FROM ledger_postings p
+    JOIN ledger_accounts a ON a.id = p.account_id
+    WHERE a.user_id IS NOT NULL AND p.amount < 0
+    GROUP BY a.user_id
+)
+INSERT INTO ledger_lots (entry_id, user_id, amount, remaining, created_at)
+SELECT c.entry_id, c.user_id, c.amount,
+       max(0, min(c.amount, c.running - coalesce(s.amount, 0))),
+       c.created_at
+FROM credits c
+LEFT JOIN spent s ON s.user_id = c.user_id
+ORDER BY c.entry_id;
@@ -0,0 +1,3 @@
+ALTER TABLE ledger_entries DROP COLUMN accrued;
+DROP TABLE IF EXISTS tier_changes;
+DROP TABLE IF EXISTS tier_rules;
@@ -0,0 +1,26 @@
+CREATE TABLE tier_rules (
+    tier TEXT NOT NULL PRIMARY KEY,
+    min_accrued INTEGER NOT NULL,
+    multiplier INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+-- Amounts and multipliers are in hundredths, times in unix microseconds.
+INSERT INTO tier_rules (tier, min_accrued, multiplier, updated_at) VALUES
+    ('BRONZE', 0, 100, unixepoch() * 1000000),
+    ('SILVER', 100000, 125, unixepoch() * 1000000),
+    ('GOLD', 500000, 150, unixepoch() * 1000000);
+
+CREATE TABLE tier_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tier TEXT NOT NULL,
+    accrued INTEGER NOT NULL,
+    changed_at INTEGER NOT NULL
+);
+
+CREATE INDEX tier_changes_user_id_idx ON tier_changes (user_id, id);
+
+-- What an accrual was worth before the tier multiplier, see the PostgreSQL
+-- migration.
+ALTER TABLE ledger_entries ADD COLUMN accrued INTEGER;
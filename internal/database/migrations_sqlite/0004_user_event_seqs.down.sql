@@ -0,0 +1,3 @@
+DROP TABLE user_event_seqs;
+DROP INDEX user_events_seq_idx;
+ALTER TABLE user_events DROP COLUMN seq;
@@ -0,0 +1,13 @@
+-- Events are numbered per user, as in PostgreSQL. Existing events keep their
+-- ids as numbers and counters start past every id handed out so far.
+ALTER TABLE user_events ADD COLUMN seq INTEGER NOT NULL DEFAULT 0;
+UPDATE user_events SET seq = id;
+CREATE UNIQUE INDEX user_events_seq_idx ON user_events (user_id, seq);
+
+CREATE TABLE user_event_seqs (
+    user_id INTEGER NOT NULL PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    seq INTEGER NOT NULL
+);
+
+INSERT INTO user_event_seqs (user_id, seq)
+SELECT id, coalesce((SELECT seq FROM sqlite_sequence WHERE name = 'user_events'), 0) FROM users;
@@ -0,0 +1,2 @@
+-- In-progress keys are leased until locked_until, as in PostgreSQL.
+ALTER TABLE idempotency_keys ADD COLUMN locked_until INTEGER;
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	type want struct {
+		versions []int64
+		err      error
+	}
+
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want want
+	}{
+		{
+			name: "sorted by version",
+			fsys: fstest.MapFS{
+				"0010_second.up.sql":   {Data: []byte("up")},
+				"0010_second.down.sql": {Data: []byte("down")},
+				"0002_first.up.sql":    {Data: []byte("up")},
+				"0002_first.down.sql":  {Data: []byte("down")},
+			},
+			want: want{versions: []int64{2, 10}},
+		},
+		{
+			name: "missing down",
+			fsys: fstest.MapFS{
+				"0001_first.up.sql": {Data: []byte("up")},
+			},
+			want: want{err: ErrInvalidMigration},
+		},
+		{
+			name: "duplicate version",
+			fsys: fstest.MapFS{
+				"0001_first.up.sql":   {Data: []byte("up")},
+				"0001_first.down.sql": {Data: []byte("down")},
+				"0001_other.up.sql":   {Data: []byte("up")},
+				"0001_other.down.sql": {Data: []byte("down")},
+			},
+			want: want{err: ErrInvalidMigration},
+		},
+		{
+			name: "unexpected file",
+			fsys: fstest.MapFS{
+				"README.md": {Data: []byte("readme")},
+			},
+			want: want{err: ErrInvalidMigration},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.fsys)
+			if tt.want.err != nil {
+				assert.ErrorIs(t, err, tt.want.err)
+				return
+			}
+			require.NoError(t, err)
+			versions := make([]int64, len(migrations))
+			for i, m := range migrations {
+				versions[i] = m.Version
+			}
+			assert.Equal(t, tt.want.versions, versions)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	sub, err := fs.Sub(migrationsFS, "migrations")
+	require.NoError(t, err)
+	migrations, err := loadMigrations(sub)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	assert.EqualValues(t, 1, migrations[0].Version)
+	assert.Equal(t, "baseline", migrations[0].Name)
+}
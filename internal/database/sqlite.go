@@ -0,0 +1,224 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"go.uber.org/zap"
+	"io/fs"
+	"net/url"
+	"strings"
+	"time"
+
+	// Registers the "sqlite" database/sql driver.
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteScheme starts a DATABASE_URI that selects SQLite. The rest of the URI
+// is the path of the database file, e.g. sqlite:///var/lib/gophermart.db.
+const SQLiteScheme = "sqlite://"
+
+// sqliteBusyTimeout is how long a statement waits for another connection's
+// write lock before failing with SQLITE_BUSY.
+const sqliteBusyTimeout = 10 * time.Second
+
+//go:embed migrations_sqlite/*.sql
+var sqliteMigrationsFS embed.FS
+
+func IsSQLite(dbURI string) bool {
+	return strings.HasPrefix(dbURI, SQLiteScheme)
+}
+
+// NewSQLite opens the SQLite database and applies pending migrations.
+func NewSQLite(ctx context.Context, dbURI string) (*sql.DB, error) {
+	db, err := ConnectSQLite(ctx, dbURI)
+	if err != nil {
+		return nil, fmt.Errorf("ConnectSQLite: %w", err)
+	}
+	m, err := NewSQLiteMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("NewSQLiteMigrator: %w", err)
+	}
+	logger.Log.Info("Applying migrations")
+	if err = m.Up(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("m.Up: %w", err)
+	}
+	logger.Log.Info("Migrations applied successfully")
+	return db, nil
+}
+
+// ConnectSQLite opens the database file in WAL mode, so readers don't block
+// the writer. Transactions begin with BEGIN IMMEDIATE and take the write lock
+// up front: a transaction that reads a balance and then writes a withdrawal
+// can't interleave with another one, and waits for the lock instead of
+// failing when it tries to upgrade a read lock.
+func ConnectSQLite(ctx context.Context, dbURI string) (*sql.DB, error) {
+	logger.Log.Info("Opening database")
+	path := strings.TrimPrefix(dbURI, SQLiteScheme)
+	if path == "" {
+		return nil, fmt.Errorf("%s: missing database path", dbURI)
+	}
+	params := url.Values{}
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Add("_pragma", "foreign_keys(ON)")
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", sqliteBusyTimeout.Milliseconds()))
+	params.Set("_txlock", "immediate")
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open: %w", err)
+	}
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("db.PingContext: %w", err)
+	}
+	return db, nil
+}
+
+// SQLiteMigrator applies the SQLite migration set. Each migration runs in an
+// immediate transaction that first checks whether it's applied, so processes
+// starting at the same time don't apply it twice.
+type SQLiteMigrator struct {
+	db         *sql.DB
+	migrations []*Migration
+}
+
+func NewSQLiteMigrator(db *sql.DB) (*SQLiteMigrator, error) {
+	sub, err := fs.Sub(sqliteMigrationsFS, "migrations_sqlite")
+	if err != nil {
+		return nil, fmt.Errorf("fs.Sub: %w", err)
+	}
+	migrations, err := loadMigrations(sub)
+	if err != nil {
+		return nil, fmt.Errorf("loadMigrations: %w", err)
+	}
+	return &SQLiteMigrator{db: db, migrations: migrations}, nil
+}
+
+// Up applies all pending migrations, each in its own transaction.
+func (m *SQLiteMigrator) Up(ctx context.Context) error {
+	if err := m.createTable(ctx); err != nil {
+		return fmt.Errorf("m.createTable: %w", err)
+	}
+	for _, migration := range m.migrations {
+		err := m.inTx(ctx, func(tx *sql.Tx) error {
+			var applied bool
+			row := tx.QueryRowContext(ctx, "select exists(select 1 from schema_migrations where version = ?)", migration.Version)
+			if err := row.Scan(&applied); err != nil {
+				return fmt.Errorf("row.Scan: %w", err)
+			}
+			if applied {
+				return nil
+			}
+			logger.Log.Info("Applying migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+			if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+				return fmt.Errorf("tx.ExecContext: %w", err)
+			}
+			query := "insert into schema_migrations (version, name, applied_at) values (?, ?, ?)"
+			if _, err := tx.ExecContext(ctx, query, migration.Version, migration.Name, time.Now().UnixMicro()); err != nil {
+				return fmt.Errorf("tx.ExecContext: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+	return nil
+}
+
+// Down rolls back the most recently applied migration.
+func (m *SQLiteMigrator) Down(ctx context.Context) error {
+	if err := m.createTable(ctx); err != nil {
+		return fmt.Errorf("m.createTable: %w", err)
+	}
+	return m.inTx(ctx, func(tx *sql.Tx) error {
+		var version int64
+		err := tx.QueryRowContext(ctx, "select coalesce(max(version), 0) from schema_migrations").Scan(&version)
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		for _, migration := range m.migrations {
+			if migration.Version != version {
+				continue
+			}
+			logger.Log.Info("Rolling back migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+			if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+				return fmt.Errorf("migration %d_%s: tx.ExecContext: %w", migration.Version, migration.Name, err)
+			}
+			if _, err := tx.ExecContext(ctx, "delete from schema_migrations where version = ?", version); err != nil {
+				return fmt.Errorf("tx.ExecContext: %w", err)
+			}
+			return nil
+		}
+		return ErrNoMigrationsApplied
+	})
+}
+
+func (m *SQLiteMigrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	if err := m.createTable(ctx); err != nil {
+		return nil, fmt.Errorf("m.createTable: %w", err)
+	}
+	rows, err := m.db.QueryContext(ctx, "select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("m.db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version, appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		applied[version] = time.UnixMicro(appliedAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	statuses := make([]*MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses[i] = &MigrationStatus{Migration: migration, Applied: ok, AppliedAt: appliedAt}
+	}
+	return statuses, nil
+}
+
+// Pending returns the versions of the migrations that aren't applied.
+func (m *SQLiteMigrator) Pending(ctx context.Context) ([]int64, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("m.Status: %w", err)
+	}
+	return pendingVersions(statuses), nil
+}
+
+func (m *SQLiteMigrator) createTable(ctx context.Context) error {
+	query := `create table if not exists schema_migrations (
+                  version INTEGER NOT NULL PRIMARY KEY,
+                  name TEXT NOT NULL,
+                  applied_at INTEGER NOT NULL
+              )`
+	if _, err := m.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("m.db.ExecContext: %w", err)
+	}
+	return nil
+}
+
+func (m *SQLiteMigrator) inTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("m.db.BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+	if err = f(tx); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteMigrator(t *testing.T) {
+	ctx := context.Background()
+	db, err := ConnectSQLite(ctx, SQLiteScheme+filepath.Join(t.TempDir(), "gophermart.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	m, err := NewSQLiteMigrator(db)
+	require.NoError(t, err)
+
+	pending, err := m.Pending(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, len(m.migrations))
+
+	require.NoError(t, m.Up(ctx))
+	require.NoError(t, m.Up(ctx), "applying twice is a no-op")
+	pending, err = m.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	var journalMode string
+	require.NoError(t, db.QueryRowContext(ctx, "pragma journal_mode").Scan(&journalMode))
+	assert.Equal(t, "wal", journalMode)
+
+	for range m.migrations {
+		require.NoError(t, m.Down(ctx))
+	}
+	assert.ErrorIs(t, m.Down(ctx), ErrNoMigrationsApplied)
+	var tables int
+	require.NoError(t, db.QueryRowContext(ctx, "select count(*) from sqlite_master where type = 'table' and name = 'users'").Scan(&tables))
+	assert.Zero(t, tables)
+}
+
+func TestConnectSQLite_MissingPath(t *testing.T) {
+	_, err := ConnectSQLite(context.Background(), SQLiteScheme)
+	assert.Error(t, err)
+}
@@ -0,0 +1,157 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	// subscriptionBuffer is how many events a slow subscriber may fall behind
+	// before it's dropped. It can resume from its last event id.
+	subscriptionBuffer = 64
+	replayBatchSize    = 500
+	listenRetryDelay   = time.Second
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Store
+type Store interface {
+	Append(ctx context.Context, event *models.UserEvent) (bool, error)
+	ListAfter(ctx context.Context, userID int, afterID int64, limit int) ([]*models.UserEvent, error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+	Listen(ctx context.Context, f func(event *models.UserEvent)) error
+}
+
+// Bus delivers user events published on any instance to the subscribers on
+// this one. Events go through the store, which persists them for replay and
+// broadcasts them to every instance, this one included.
+type Bus struct {
+	store  Store
+	mu     sync.Mutex
+	subs   map[int]map[chan *models.UserEvent]struct{}
+	closed bool
+}
+
+func NewBus(store Store) *Bus {
+	return &Bus{store: store, subs: make(map[int]map[chan *models.UserEvent]struct{})}
+}
+
+func (b *Bus) Publish(ctx context.Context, event *models.UserEvent) error {
+	if _, err := b.store.Append(ctx, event); err != nil {
+		return fmt.Errorf("b.store.Append: %w", err)
+	}
+	return nil
+}
+
+// Subscribe returns the user's live events and a func to unsubscribe. The
+// channel is closed if the subscriber falls behind, if delivery between
+// instances was interrupted or once the bus stops.
+func (b *Bus) Subscribe(userID int) (<-chan *models.UserEvent, func()) {
+	ch := make(chan *models.UserEvent, subscriptionBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[chan *models.UserEvent]struct{})
+	}
+	b.subs[userID][ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(userID, ch)
+	}
+}
+
+// Replay returns the user's stored events with ids above afterID.
+func (b *Bus) Replay(ctx context.Context, userID int, afterID int64) ([]*models.UserEvent, error) {
+	var events []*models.UserEvent
+	for {
+		batch, err := b.store.ListAfter(ctx, userID, afterID, replayBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("b.store.ListAfter: %w", err)
+		}
+		events = append(events, batch...)
+		if len(batch) < replayBatchSize {
+			return events, nil
+		}
+		afterID = batch[len(batch)-1].ID
+	}
+}
+
+func (b *Bus) Prune(ctx context.Context, before time.Time) (int64, error) {
+	deleted, err := b.store.DeleteBefore(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("b.store.DeleteBefore: %w", err)
+	}
+	return deleted, nil
+}
+
+// Run receives events from the store until ctx is cancelled and then closes
+// every subscription. If listening fails, subscribers may have missed events,
+// so they are dropped to make them resume.
+func (b *Bus) Run(ctx context.Context) {
+	defer b.close()
+	for {
+		err := b.store.Listen(ctx, b.deliver)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Log.Error("Listening for events failed", zap.Error(err))
+		b.dropAll()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+func (b *Bus) deliver(event *models.UserEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[event.UserID] {
+		select {
+		case ch <- event:
+		default:
+			logger.Log.Warn("Dropping slow event subscriber", zap.Int("userID", event.UserID))
+			b.remove(event.UserID, ch)
+		}
+	}
+}
+
+func (b *Bus) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for userID, subs := range b.subs {
+		for ch := range subs {
+			b.remove(userID, ch)
+		}
+	}
+}
+
+func (b *Bus) close() {
+	b.dropAll()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+}
+
+// remove must be called with b.mu held.
+func (b *Bus) remove(userID int, ch chan *models.UserEvent) {
+	subs := b.subs[userID]
+	if _, ok := subs[ch]; !ok {
+		return
+	}
+	delete(subs, ch)
+	close(ch)
+	if len(subs) == 0 {
+		delete(b.subs, userID)
+	}
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/events/mocks"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"testing"
+	"time"
+)
+
+func TestBus_Run(t *testing.T) {
+	store := mocks.NewStore(t)
+	bus := NewBus(store)
+	own, unsubscribe := bus.Subscribe(1)
+	defer unsubscribe()
+	other, unsubscribeOther := bus.Subscribe(2)
+	defer unsubscribeOther()
+
+	delivered := make(chan struct{})
+	store.On("Listen", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		f := args.Get(1).(func(*models.UserEvent))
+		f(&models.UserEvent{ID: 1, UserID: 1, Type: models.UserEventOrder})
+		close(delivered)
+		<-args.Get(0).(context.Context).Done()
+	}).Return(context.Canceled)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		bus.Run(ctx)
+		close(stopped)
+	}()
+	<-delivered
+
+	event := <-own
+	assert.EqualValues(t, 1, event.ID)
+	assert.Empty(t, other)
+
+	cancel()
+	<-stopped
+	_, ok := <-own
+	assert.False(t, ok, "subscriptions must be closed once the bus stops")
+	late, _ := bus.Subscribe(1)
+	_, ok = <-late
+	assert.False(t, ok)
+}
+
+func TestBus_DropsSlowSubscriber(t *testing.T) {
+	bus := NewBus(mocks.NewStore(t))
+	ch, unsubscribe := bus.Subscribe(1)
+	for i := 0; i <= subscriptionBuffer; i++ {
+		bus.deliver(&models.UserEvent{ID: int64(i + 1), UserID: 1})
+	}
+	received := 0
+	for range ch {
+		received++
+	}
+	assert.Equal(t, subscriptionBuffer, received)
+	unsubscribe()
+}
+
+func TestBus_DropsSubscribersWhenListenFails(t *testing.T) {
+	store := mocks.NewStore(t)
+	bus := NewBus(store)
+	ch, _ := bus.Subscribe(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store.On("Listen", mock.Anything, mock.Anything).Return(errors.New("connection lost")).Once()
+	store.On("Listen", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}).Return(context.Canceled).Maybe()
+	go bus.Run(ctx)
+
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("subscription wasn't dropped")
+	}
+}
+
+func TestBus_Replay(t *testing.T) {
+	store := mocks.NewStore(t)
+	bus := NewBus(store)
+
+	first := make([]*models.UserEvent, replayBatchSize)
+	for i := range first {
+		first[i] = &models.UserEvent{ID: int64(i + 11), UserID: 1}
+	}
+	last := []*models.UserEvent{{ID: int64(replayBatchSize + 11), UserID: 1}}
+	store.On("ListAfter", mock.Anything, 1, int64(10), replayBatchSize).Return(first, nil)
+	store.On("ListAfter", mock.Anything, 1, int64(replayBatchSize+10), replayBatchSize).Return(last, nil)
+
+	events, err := bus.Replay(context.Background(), 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, events, replayBatchSize+1)
+	assert.EqualValues(t, replayBatchSize+11, events[len(events)-1].ID)
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultUserSearchLimit = 50
+	maxUserSearchLimit     = 100
+	defaultAuditLimit      = 100
+	maxAuditLimit          = 1000
+)
+
+type AdminUserResponse struct {
+	ID    int      `json:"id"`
+	Login string   `json:"login"`
+	Roles []string `json:"roles"`
+}
+
+type AdjustmentRequest struct {
+	Amount money.Amount `json:"amount"`
+	Reason string       `json:"reason"`
+}
+
+type AdjustmentResponse struct {
+	UserID  int          `json:"user_id"`
+	Amount  money.Amount `json:"amount"`
+	Reason  string       `json:"reason"`
+	Balance money.Amount `json:"balance"`
+}
+
+// SearchUsers finds users by a login substring: GET /api/admin/users?login=&limit=
+func SearchUsers(s AdminStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultUserSearchLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 || parsed > maxUserSearchLimit {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = parsed
+		}
+
+		users, err := s.SearchUsers(r.Context(), r.URL.Query().Get("login"), limit)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error searching users", zap.Error(err))
+			http.Error(w, "Error searching users", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]*AdminUserResponse, len(users))
+		for i, u := range users {
+			resp[i] = &AdminUserResponse{ID: u.ID, Login: u.Login, Roles: u.Roles}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func GetUserOrders(s AdminStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := requireUser(w, r, s)
+		if !ok {
+			return
+		}
+		orders, err := s.GetUsersOrders(r.Context(), userID)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error getting users orders", zap.Error(err))
+			http.Error(w, "Error getting users orders", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, newOrdersListResponse(orders))
+	}
+}
+
+func GetUserWithdrawals(s AdminStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := requireUser(w, r, s)
+		if !ok {
+			return
+		}
+		withdrawals, err := s.GetUsersWithdrawals(r.Context(), userID)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error getting user withdrawals", zap.Error(err))
+			http.Error(w, "Error getting user withdrawals", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, newWithdrawalResponse(withdrawals))
+	}
+}
+
+// RequeueOrder resets a stuck order so that the processor polls the accrual
+// system for it again.
+func RequeueOrder(s AdminStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderID, err := strconv.Atoi(chi.URLParam(r, "number"))
+		if err != nil {
+			http.Error(w, "Invalid order number", http.StatusBadRequest)
+			return
+		}
+
+		order, err := s.RequeueOrder(r.Context(), orderID)
+		if err != nil {
+			if errors.Is(err, storage.ErrOrderNotFound) {
+				http.Error(w, "Order not found", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, storage.ErrOrderAlreadyProcessed) {
+				http.Error(w, "Order is already processed", http.StatusConflict)
+				return
+			}
+			logger.FromContext(r.Context()).Error("Error requeueing order", zap.Error(err))
+			http.Error(w, "Error requeueing order", http.StatusInternalServerError)
+			return
+		}
+		logger.FromContext(r.Context()).Info("Order requeued", zap.Int("orderId", orderID), zap.Int("by", principalID(r)))
+		writeJSON(w, http.StatusOK, newOrderResponse(order))
+	}
+}
+
+// CreateAdjustment credits or debits a user's balance. The reason is required
+// and is kept with the ledger entry.
+func CreateAdjustment(s AdminStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			logger.FromContext(r.Context()).Error("Principal is missing")
+			http.Error(w, "Principal is missing", http.StatusInternalServerError)
+			return
+		}
+		userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid user id", http.StatusBadRequest)
+			return
+		}
+
+		req := &AdjustmentRequest{}
+		err = json.NewDecoder(r.Body).Decode(req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		adjustment := &models.Adjustment{
+			UserID:    userID,
+			Amount:    req.Amount,
+			Reason:    req.Reason,
+			CreatedBy: principal.ID,
+		}
+		if err = adjustment.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		balance, err := s.AdjustBalance(r.Context(), adjustment)
+		if err != nil {
+			if errors.Is(err, storage.ErrUserNotFound) {
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, storage.ErrInsufficientFunds) {
+				http.Error(w, "Not enough balance", http.StatusConflict)
+				return
+			}
+			logger.FromContext(r.Context()).Error("Error adjusting balance", zap.Error(err))
+			http.Error(w, "Error adjusting balance", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, &AdjustmentResponse{
+			UserID:  userID,
+			Amount:  adjustment.Amount,
+			Reason:  adjustment.Reason,
+			Balance: balance,
+		})
+	}
+}
+
+// QueryAuditEvents lists audit events newest first:
+// GET /api/admin/audit?user_id=&order_id=&action=&before_id=&limit=
+func QueryAuditEvents(s AdminStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		filter := models.AuditFilter{Action: q.Get("action"), Limit: defaultAuditLimit}
+		var err error
+		if v := q.Get("user_id"); v != "" {
+			if filter.UserID, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "Invalid user id", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("order_id"); v != "" {
+			if filter.OrderID, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "Invalid order id", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("before_id"); v != "" {
+			if filter.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil {
+				http.Error(w, "Invalid before id", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("limit"); v != "" {
+			filter.Limit, err = strconv.Atoi(v)
+			if err != nil || filter.Limit <= 0 || filter.Limit > maxAuditLimit {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		events, err := s.QueryAuditEvents(r.Context(), filter)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error querying audit events", zap.Error(err))
+			http.Error(w, "Error querying audit events", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, events)
+	}
+}
+
+// VerifyAuditLog recomputes the audit log hash chain.
+func VerifyAuditLog(s AdminStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, err := s.VerifyAuditLog(r.Context())
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error verifying audit log", zap.Error(err))
+			http.Error(w, "Error verifying audit log", http.StatusInternalServerError)
+			return
+		}
+		if !res.Valid {
+			logger.FromContext(r.Context()).Error("Audit log hash chain is broken", zap.Int64("brokenAt", res.BrokenAt))
+		}
+		writeJSON(w, http.StatusOK, res)
+	}
+}
+
+// requireUser reads the {id} URL parameter and checks that the user exists,
+// writing an error response if not.
+func requireUser(w http.ResponseWriter, r *http.Request, s AdminStorage) (int, bool) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return 0, false
+	}
+	_, err = s.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return 0, false
+		}
+		logger.FromContext(r.Context()).Error("Error getting user", zap.Error(err))
+		http.Error(w, "Error getting user", http.StatusInternalServerError)
+		return 0, false
+	}
+	return userID, true
+}
+
+func principalID(r *http.Request) int {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		return 0
+	}
+	return principal.ID
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Log.Error("Error marshaling response", zap.Error(err))
+		http.Error(w, "Error marshaling response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err = w.Write(data); err != nil {
+		logger.Log.Error("Error writing response", zap.Error(err))
+	}
+}
@@ -0,0 +1,452 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/handlers/mocks"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchUsers(t *testing.T) {
+	type searchUsersMock struct {
+		needed bool
+		limit  int
+		result []*models.User
+		err    error
+	}
+	type want struct {
+		statusCode int
+		result     []*AdminUserResponse
+	}
+
+	tests := []struct {
+		name            string
+		query           string
+		searchUsersMock searchUsersMock
+		want            want
+	}{
+		{
+			name:  "ok",
+			query: "?login=jo",
+			searchUsersMock: searchUsersMock{
+				needed: true,
+				limit:  defaultUserSearchLimit,
+				result: []*models.User{{ID: 1, Login: "john", EncryptedPwd: "secret", Roles: []string{models.RoleCustomer}}},
+			},
+			want: want{
+				statusCode: http.StatusOK,
+				result:     []*AdminUserResponse{{ID: 1, Login: "john", Roles: []string{models.RoleCustomer}}},
+			},
+		},
+		{
+			name:  "custom limit",
+			query: "?login=jo&limit=5",
+			searchUsersMock: searchUsersMock{
+				needed: true,
+				limit:  5,
+				result: []*models.User{},
+			},
+			want: want{
+				statusCode: http.StatusOK,
+				result:     []*AdminUserResponse{},
+			},
+		},
+		{
+			name:  "invalid limit",
+			query: "?limit=1000",
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:  "unexpected error",
+			query: "?login=jo",
+			searchUsersMock: searchUsersMock{
+				needed: true,
+				limit:  defaultUserSearchLimit,
+				err:    errors.New("unexpected error"),
+			},
+			want: want{
+				statusCode: http.StatusInternalServerError,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewAdminStorage(t)
+			if tt.searchUsersMock.needed {
+				s.On("SearchUsers", mock.Anything, "jo", tt.searchUsersMock.limit).Return(tt.searchUsersMock.result, tt.searchUsersMock.err)
+			}
+
+			r := chi.NewRouter()
+			r.Get("/api/admin/users", SearchUsers(s))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/users"+tt.query, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.want.statusCode, res.StatusCode)
+			if tt.want.statusCode == http.StatusOK {
+				var resp []*AdminUserResponse
+				require.NoError(t, json.NewDecoder(res.Body).Decode(&resp))
+				assert.Equal(t, tt.want.result, resp)
+			}
+		})
+	}
+}
+
+func TestGetUserOrders(t *testing.T) {
+	uploadedAt := time.Now()
+	type getUserByIDMock struct {
+		needed bool
+		err    error
+	}
+	type getUsersOrdersMock struct {
+		needed bool
+		result []*models.Order
+		err    error
+	}
+
+	tests := []struct {
+		name               string
+		userID             string
+		getUserByIDMock    getUserByIDMock
+		getUsersOrdersMock getUsersOrdersMock
+		wantStatusCode     int
+	}{
+		{
+			name:            "ok",
+			userID:          "1",
+			getUserByIDMock: getUserByIDMock{needed: true},
+			getUsersOrdersMock: getUsersOrdersMock{
+				needed: true,
+				result: []*models.Order{{ID: 7703824164, UserID: 1, Status: models.OrderStatusNew, UploadedAt: uploadedAt}},
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid user id",
+			userID:         "abc",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:            "user not found",
+			userID:          "1",
+			getUserByIDMock: getUserByIDMock{needed: true, err: storage.ErrUserNotFound},
+			wantStatusCode:  http.StatusNotFound,
+		},
+		{
+			name:               "orders unexpected error",
+			userID:             "1",
+			getUserByIDMock:    getUserByIDMock{needed: true},
+			getUsersOrdersMock: getUsersOrdersMock{needed: true, err: errors.New("unexpected error")},
+			wantStatusCode:     http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewAdminStorage(t)
+			if tt.getUserByIDMock.needed {
+				var user *models.User
+				if tt.getUserByIDMock.err == nil {
+					user = &models.User{ID: 1, Login: "john"}
+				}
+				s.On("GetUserByID", mock.Anything, 1).Return(user, tt.getUserByIDMock.err)
+			}
+			if tt.getUsersOrdersMock.needed {
+				s.On("GetUsersOrders", mock.Anything, 1).Return(tt.getUsersOrdersMock.result, tt.getUsersOrdersMock.err)
+			}
+
+			r := chi.NewRouter()
+			r.Get("/api/admin/users/{id}/orders", GetUserOrders(s))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/users/"+tt.userID+"/orders", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.wantStatusCode, res.StatusCode)
+		})
+	}
+}
+
+func TestRequeueOrder(t *testing.T) {
+	tests := []struct {
+		name           string
+		number         string
+		mockNeeded     bool
+		result         *models.Order
+		err            error
+		wantStatusCode int
+	}{
+		{
+			name:           "ok",
+			number:         "7703824164",
+			mockNeeded:     true,
+			result:         &models.Order{ID: 7703824164, UserID: 1, Status: models.OrderStatusNew, UploadedAt: time.Now()},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid number",
+			number:         "abc",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "not found",
+			number:         "7703824164",
+			mockNeeded:     true,
+			err:            storage.ErrOrderNotFound,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "already processed",
+			number:         "7703824164",
+			mockNeeded:     true,
+			err:            storage.ErrOrderAlreadyProcessed,
+			wantStatusCode: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewAdminStorage(t)
+			if tt.mockNeeded {
+				s.On("RequeueOrder", mock.Anything, 7703824164).Return(tt.result, tt.err)
+			}
+
+			r := chi.NewRouter()
+			r.Post("/api/admin/orders/{number}/requeue", RequeueOrder(s))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/orders/"+tt.number+"/requeue", nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: 2}))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.wantStatusCode, res.StatusCode)
+		})
+	}
+}
+
+func TestCreateAdjustment(t *testing.T) {
+	type adjustBalanceMock struct {
+		needed bool
+		result money.Amount
+		err    error
+	}
+	type want struct {
+		statusCode int
+		result     *AdjustmentResponse
+	}
+
+	tests := []struct {
+		name              string
+		userID            string
+		body              string
+		adjustBalanceMock adjustBalanceMock
+		want              want
+	}{
+		{
+			name:   "ok",
+			userID: "1",
+			body:   `{"amount": -20.5, "reason": "duplicate accrual"}`,
+			adjustBalanceMock: adjustBalanceMock{
+				needed: true,
+				result: money.MustParse("79.50"),
+			},
+			want: want{
+				statusCode: http.StatusCreated,
+				result: &AdjustmentResponse{
+					UserID:  1,
+					Amount:  money.MustParse("-20.50"),
+					Reason:  "duplicate accrual",
+					Balance: money.MustParse("79.50"),
+				},
+			},
+		},
+		{
+			name:   "missing reason",
+			userID: "1",
+			body:   `{"amount": 10}`,
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "zero amount",
+			userID: "1",
+			body:   `{"amount": 0, "reason": "goodwill"}`,
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "invalid body",
+			userID: "1",
+			body:   `{"amount":`,
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "invalid user id",
+			userID: "abc",
+			body:   `{"amount": 10, "reason": "goodwill"}`,
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "user not found",
+			userID: "1",
+			body:   `{"amount": 10, "reason": "goodwill"}`,
+			adjustBalanceMock: adjustBalanceMock{
+				needed: true,
+				err:    storage.ErrUserNotFound,
+			},
+			want: want{
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name:   "insufficient funds",
+			userID: "1",
+			body:   `{"amount": -1000, "reason": "chargeback"}`,
+			adjustBalanceMock: adjustBalanceMock{
+				needed: true,
+				err:    storage.ErrInsufficientFunds,
+			},
+			want: want{
+				statusCode: http.StatusConflict,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewAdminStorage(t)
+			if tt.adjustBalanceMock.needed {
+				s.On("AdjustBalance", mock.Anything, mock.MatchedBy(func(a *models.Adjustment) bool {
+					return a.UserID == 1 && a.CreatedBy == 2 && a.Reason != ""
+				})).Return(tt.adjustBalanceMock.result, tt.adjustBalanceMock.err)
+			}
+
+			r := chi.NewRouter()
+			r.Post("/api/admin/users/{id}/adjustments", CreateAdjustment(s))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/users/"+tt.userID+"/adjustments", strings.NewReader(tt.body))
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: 2, Roles: []string{models.RoleAdmin}}))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.want.statusCode, res.StatusCode)
+			if tt.want.result != nil {
+				resp := &AdjustmentResponse{}
+				require.NoError(t, json.NewDecoder(res.Body).Decode(resp))
+				assert.Equal(t, tt.want.result, resp)
+			}
+		})
+	}
+}
+
+func TestQueryAuditEvents(t *testing.T) {
+	type queryAuditEventsMock struct {
+		needed bool
+		filter models.AuditFilter
+		result []*models.AuditEvent
+		err    error
+	}
+
+	tests := []struct {
+		name                 string
+		query                string
+		queryAuditEventsMock queryAuditEventsMock
+		wantStatusCode       int
+	}{
+		{
+			name:  "by user",
+			query: "?user_id=1",
+			queryAuditEventsMock: queryAuditEventsMock{
+				needed: true,
+				filter: models.AuditFilter{UserID: 1, Limit: defaultAuditLimit},
+				result: []*models.AuditEvent{{ID: 2, Action: models.AuditActionOrderUploaded, UserID: 1, OrderID: 7703824164}},
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:  "by order with paging",
+			query: "?order_id=7703824164&action=order.status_changed&before_id=10&limit=5",
+			queryAuditEventsMock: queryAuditEventsMock{
+				needed: true,
+				filter: models.AuditFilter{OrderID: 7703824164, Action: models.AuditActionOrderStatusChanged, BeforeID: 10, Limit: 5},
+				result: []*models.AuditEvent{},
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid user id",
+			query:          "?user_id=abc",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "limit too large",
+			query:          "?limit=100000",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:  "unexpected error",
+			query: "",
+			queryAuditEventsMock: queryAuditEventsMock{
+				needed: true,
+				filter: models.AuditFilter{Limit: defaultAuditLimit},
+				err:    errors.New("unexpected error"),
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewAdminStorage(t)
+			if tt.queryAuditEventsMock.needed {
+				s.On("QueryAuditEvents", mock.Anything, tt.queryAuditEventsMock.filter).Return(tt.queryAuditEventsMock.result, tt.queryAuditEventsMock.err)
+			}
+
+			r := chi.NewRouter()
+			r.Get("/api/admin/audit", QueryAuditEvents(s))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/audit"+tt.query, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.wantStatusCode, res.StatusCode)
+			if tt.wantStatusCode == http.StatusOK {
+				var events []*models.AuditEvent
+				require.NoError(t, json.NewDecoder(res.Body).Decode(&events))
+				assert.Len(t, events, len(tt.queryAuditEventsMock.result))
+			}
+		})
+	}
+}
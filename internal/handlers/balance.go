@@ -6,67 +6,103 @@ import (
 	"errors"
 	"github.com/vindosVP/loyalty-system/internal/models"
 	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
 	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"github.com/vindosVP/loyalty-system/pkg/money"
 	"go.uber.org/zap"
 	"net/http"
 	"strconv"
 	"time"
 )
 
+// expiringSoonWindow is how far ahead the balance reports expiring points.
+const expiringSoonWindow = 30 * 24 * time.Hour
+
 type BalanceResponse struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current      money.Amount      `json:"current"`
+	Withdrawn    money.Amount      `json:"withdrawn"`
+	ExpiringSoon []*ExpiringPoints `json:"expiring_soon"`
+}
+
+type ExpiringPoints struct {
+	Sum       money.Amount `json:"sum"`
+	ExpiresAt string       `json:"expires_at"`
+}
+
+func newExpiringPoints(expirations []*models.Expiration) []*ExpiringPoints {
+	resp := make([]*ExpiringPoints, len(expirations))
+	for i, v := range expirations {
+		resp[i] = &ExpiringPoints{
+			Sum:       v.Amount,
+			ExpiresAt: v.ExpiresAt.Format(time.RFC3339),
+		}
+	}
+	return resp
 }
 
 type WithdrawRequest struct {
-	OrderID string  `json:"order"`
-	Sum     float64 `json:"sum"`
+	OrderID string       `json:"order"`
+	Sum     money.Amount `json:"sum"`
 }
 
 type WithdrawalOrder struct {
-	OrderID     string  `json:"order"`
-	Sum         float64 `json:"sum"`
-	ProcessedAt string  `json:"processed_at"`
+	OrderID     string       `json:"order"`
+	Sum         money.Amount `json:"sum"`
+	ProcessedAt string       `json:"processed_at"`
 }
 
 type WithdrawalResponse []*WithdrawalOrder
 
+func newWithdrawalResponse(withdrawals []*models.Withdrawal) WithdrawalResponse {
+	resp := make(WithdrawalResponse, len(withdrawals))
+	for i, v := range withdrawals {
+		resp[i] = &WithdrawalOrder{
+			OrderID:     strconv.Itoa(v.OrderID),
+			Sum:         v.Sum,
+			ProcessedAt: v.ProcessedAt.Format(time.RFC3339),
+		}
+	}
+	return resp
+}
+
 func GetUsersBalance(s Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		gotUserID := r.Header.Get("x-user-id")
-		if gotUserID == "" {
-			logger.Log.Error("User id is empty")
-			http.Error(w, "User id is empty", http.StatusInternalServerError)
-			return
-		}
-		userID, err := strconv.Atoi(gotUserID)
-		if err != nil {
-			logger.Log.Error("Error parsing user id", zap.Error(err))
-			http.Error(w, "Error parsing user id", http.StatusInternalServerError)
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			logger.FromContext(r.Context()).Error("Principal is missing")
+			http.Error(w, "Principal is missing", http.StatusInternalServerError)
 			return
 		}
 
-		currentBalance, err := s.GetUsersCurrentBalance(r.Context(), userID)
+		currentBalance, err := s.GetUsersCurrentBalance(r.Context(), principal.ID)
 		if err != nil {
-			logger.Log.Error("Error getting user balance", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error getting user balance", zap.Error(err))
 			http.Error(w, "Error getting user balance", http.StatusInternalServerError)
 			return
 		}
-		withdrawnBalance, err := s.GetUsersWithdrawnBalance(r.Context(), userID)
+		withdrawnBalance, err := s.GetUsersWithdrawnBalance(r.Context(), principal.ID)
 		if err != nil {
-			logger.Log.Error("Error getting user withdrawn balance", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error getting user withdrawn balance", zap.Error(err))
 			http.Error(w, "Error getting user withdrawn balance", http.StatusInternalServerError)
 			return
 		}
+		until := time.Now().Add(expiringSoonWindow)
+		expiringSoon, err := s.GetUsersExpirations(r.Context(), principal.ID, &until)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error getting user expiring points", zap.Error(err))
+			http.Error(w, "Error getting user expiring points", http.StatusInternalServerError)
+			return
+		}
 
 		resp := &BalanceResponse{
-			Current:   currentBalance,
-			Withdrawn: withdrawnBalance,
+			Current:      currentBalance,
+			Withdrawn:    withdrawnBalance,
+			ExpiringSoon: newExpiringPoints(expiringSoon),
 		}
 
 		data, err := json.Marshal(&resp)
 		if err != nil {
-			logger.Log.Error("Error marshaling response", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error marshaling response", zap.Error(err))
 			http.Error(w, "Error marshaling response", http.StatusInternalServerError)
 			return
 		}
@@ -74,7 +110,7 @@ func GetUsersBalance(s Storage) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 		_, err = w.Write(data)
 		if err != nil {
-			logger.Log.Error("Error writing response", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error writing response", zap.Error(err))
 			http.Error(w, "Error writing response", http.StatusInternalServerError)
 			return
 		}
@@ -84,23 +120,17 @@ func GetUsersBalance(s Storage) http.HandlerFunc {
 
 func WithdrawOrder(s Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		gotUserID := r.Header.Get("x-user-id")
-		if gotUserID == "" {
-			logger.Log.Error("User id is empty")
-			http.Error(w, "User id is empty", http.StatusInternalServerError)
-			return
-		}
-		userID, err := strconv.Atoi(gotUserID)
-		if err != nil {
-			logger.Log.Error("Error parsing user id", zap.Error(err))
-			http.Error(w, "Error parsing user id", http.StatusInternalServerError)
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			logger.FromContext(r.Context()).Error("Principal is missing")
+			http.Error(w, "Principal is missing", http.StatusInternalServerError)
 			return
 		}
 
 		var buf bytes.Buffer
-		_, err = buf.ReadFrom(r.Body)
+		_, err := buf.ReadFrom(r.Body)
 		if err != nil {
-			logger.Log.Error("Error reading body", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error reading body", zap.Error(err))
 			http.Error(w, "Error reading body", http.StatusInternalServerError)
 			return
 		}
@@ -108,7 +138,7 @@ func WithdrawOrder(s Storage) http.HandlerFunc {
 		req := &WithdrawRequest{}
 		err = json.Unmarshal(buf.Bytes(), &req)
 		if err != nil {
-			logger.Log.Error("Error unmarshalling body", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error unmarshalling body", zap.Error(err))
 			http.Error(w, "Error unmarshalling body", http.StatusInternalServerError)
 			return
 		}
@@ -118,40 +148,31 @@ func WithdrawOrder(s Storage) http.HandlerFunc {
 			return
 		}
 
-		currentBalance, err := s.GetUsersCurrentBalance(r.Context(), userID)
-		if err != nil {
-			logger.Log.Error("Error getting users current balance", zap.Error(err))
-			http.Error(w, "Error getting users current balance", http.StatusInternalServerError)
-			return
-		}
-
-		if currentBalance < req.Sum {
-			http.Error(w, "Not enough balance", http.StatusPaymentRequired)
-			return
-		}
-
 		orderID, err := strconv.Atoi(req.OrderID)
 		if err != nil {
-			logger.Log.Error("Error parsing order id", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error parsing order id", zap.Error(err))
 			http.Error(w, "Error parsing order id", http.StatusInternalServerError)
 			return
 		}
 
-		order := &models.Order{
-			ID:         orderID,
-			UserID:     userID,
-			Status:     models.OrderStatusProcessed,
-			Sum:        -req.Sum,
-			UploadedAt: time.Now(),
+		withdrawal := &models.Withdrawal{
+			OrderID:     orderID,
+			UserID:      principal.ID,
+			Sum:         req.Sum,
+			ProcessedAt: time.Now(),
 		}
-		err = order.Validate()
+		err = withdrawal.Validate()
 		if err != nil {
 			http.Error(w, "Invalid order id", http.StatusUnprocessableEntity)
 			return
 		}
 
-		_, err = s.CreateOrder(r.Context(), order)
+		_, err = s.CreateWithdrawal(r.Context(), withdrawal)
 		if err != nil {
+			if errors.Is(err, storage.ErrInsufficientFunds) {
+				http.Error(w, "Not enough balance", http.StatusPaymentRequired)
+				return
+			}
 			if errors.Is(err, storage.ErrOrderAlreadyExists) {
 				http.Error(w, "Order with this number already exists", http.StatusConflict)
 				return
@@ -160,8 +181,9 @@ func WithdrawOrder(s Storage) http.HandlerFunc {
 				http.Error(w, "Order was already created by other user", http.StatusConflict)
 				return
 			}
-			logger.Log.Error("Error creating order", zap.Error(err))
-			http.Error(w, "Error creating order", http.StatusInternalServerError)
+			logger.FromContext(r.Context()).Error("Error creating withdrawal", zap.Error(err))
+			http.Error(w, "Error creating withdrawal", http.StatusInternalServerError)
+			return
 		}
 		w.WriteHeader(http.StatusOK)
 	}
@@ -169,22 +191,32 @@ func WithdrawOrder(s Storage) http.HandlerFunc {
 
 func GetUsersWithdrawals(s Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		gotUserID := r.Header.Get("x-user-id")
-		if gotUserID == "" {
-			logger.Log.Error("User id is empty")
-			http.Error(w, "User id is empty", http.StatusInternalServerError)
-			return
-		}
-		userID, err := strconv.Atoi(gotUserID)
-		if err != nil {
-			logger.Log.Error("Error parsing user id", zap.Error(err))
-			http.Error(w, "Error parsing user id", http.StatusInternalServerError)
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			logger.FromContext(r.Context()).Error("Principal is missing")
+			http.Error(w, "Principal is missing", http.StatusInternalServerError)
 			return
 		}
 
-		withdrawals, err := s.GetUsersWithdrawals(r.Context(), userID)
+		// Without query parameters the whole list is returned, as before pagination.
+		var withdrawals []*models.Withdrawal
+		var err error
+		if r.URL.RawQuery == "" {
+			withdrawals, err = s.GetUsersWithdrawals(r.Context(), principal.ID)
+		} else {
+			var filter models.ListFilter
+			if filter, err = parseListFilter(r.URL.Query(), nil); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var page *models.WithdrawalsPage
+			if page, err = s.ListUsersWithdrawals(r.Context(), principal.ID, filter); err == nil {
+				withdrawals = page.Withdrawals
+				setNextPage(w, r, page.Next)
+			}
+		}
 		if err != nil {
-			logger.Log.Error("Error getting user withdrawals", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error getting user withdrawals", zap.Error(err))
 			http.Error(w, "Error getting user withdrawals", http.StatusInternalServerError)
 			return
 		}
@@ -194,25 +226,58 @@ func GetUsersWithdrawals(s Storage) http.HandlerFunc {
 			return
 		}
 
-		resp := make(WithdrawalResponse, len(withdrawals))
-		for i, v := range withdrawals {
-			resp[i] = &WithdrawalOrder{
-				OrderID:     strconv.Itoa(v.ID),
-				Sum:         v.Sum,
-				ProcessedAt: v.UploadedAt.Format(time.RFC3339),
-			}
+		resp := newWithdrawalResponse(withdrawals)
+		data, err := json.Marshal(&resp)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error marshaling response", zap.Error(err))
+			http.Error(w, "Error marshaling response", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, err = w.Write(data)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error writing response", zap.Error(err))
+			http.Error(w, "Error writing response", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// GetUsersExpirations lists all of the user's points that are going to expire,
+// soonest first.
+func GetUsersExpirations(s Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			logger.FromContext(r.Context()).Error("Principal is missing")
+			http.Error(w, "Principal is missing", http.StatusInternalServerError)
+			return
+		}
+
+		expirations, err := s.GetUsersExpirations(r.Context(), principal.ID, nil)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error getting user expirations", zap.Error(err))
+			http.Error(w, "Error getting user expirations", http.StatusInternalServerError)
+			return
+		}
+
+		if len(expirations) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
 		}
 
+		resp := newExpiringPoints(expirations)
 		data, err := json.Marshal(&resp)
 		if err != nil {
-			logger.Log.Error("Error marshaling response", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error marshaling response", zap.Error(err))
 			http.Error(w, "Error marshaling response", http.StatusInternalServerError)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
 		_, err = w.Write(data)
 		if err != nil {
-			logger.Log.Error("Error writing response", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error writing response", zap.Error(err))
 			http.Error(w, "Error writing response", http.StatusInternalServerError)
 			return
 		}
@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/vindosVP/loyalty-system/internal/handlers/mocks"
 	"github.com/vindosVP/loyalty-system/internal/models"
 	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/money"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -20,16 +23,21 @@ func TestGetUsersBalance(t *testing.T) {
 
 	type request struct {
 		method string
-		userID string
+		userID int
 	}
 	type getUsersCurrentBalanceMock struct {
 		needed bool
-		result float64
+		result money.Amount
 		err    error
 	}
 	type getUsersWithdrawnBalanceMock struct {
 		needed bool
-		result float64
+		result money.Amount
+		err    error
+	}
+	type getUsersExpirationsMock struct {
+		needed bool
+		result []*models.Expiration
 		err    error
 	}
 	type want struct {
@@ -37,42 +45,105 @@ func TestGetUsersBalance(t *testing.T) {
 		result     BalanceResponse
 	}
 
+	expiresAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
 	tests := []struct {
 		name                         string
 		request                      request
 		getUsersCurrentBalanceMock   getUsersCurrentBalanceMock
 		getUsersWithdrawnBalanceMock getUsersWithdrawnBalanceMock
+		getUsersExpirationsMock      getUsersExpirationsMock
 		want                         want
 	}{
 		{
 			name: "ok",
 			request: request{
 				method: http.MethodGet,
-				userID: "1",
+				userID: 1,
+			},
+			getUsersCurrentBalanceMock: getUsersCurrentBalanceMock{
+				needed: true,
+				result: money.FromInt(100),
+				err:    nil,
+			},
+			getUsersWithdrawnBalanceMock: getUsersWithdrawnBalanceMock{
+				needed: true,
+				result: money.FromInt(50),
+				err:    nil,
+			},
+			getUsersExpirationsMock: getUsersExpirationsMock{
+				needed: true,
+				result: []*models.Expiration{},
+				err:    nil,
+			},
+			want: want{
+				statusCode: http.StatusOK,
+				result: BalanceResponse{
+					Current:      money.FromInt(100),
+					Withdrawn:    money.FromInt(50),
+					ExpiringSoon: []*ExpiringPoints{},
+				},
+			},
+		},
+		{
+			name: "points expiring soon",
+			request: request{
+				method: http.MethodGet,
+				userID: 1,
 			},
 			getUsersCurrentBalanceMock: getUsersCurrentBalanceMock{
 				needed: true,
-				result: 100,
+				result: money.FromInt(100),
 				err:    nil,
 			},
 			getUsersWithdrawnBalanceMock: getUsersWithdrawnBalanceMock{
 				needed: true,
-				result: 50,
+				result: money.FromInt(50),
+				err:    nil,
+			},
+			getUsersExpirationsMock: getUsersExpirationsMock{
+				needed: true,
+				result: []*models.Expiration{{Amount: money.FromInt(30), ExpiresAt: expiresAt}},
 				err:    nil,
 			},
 			want: want{
 				statusCode: http.StatusOK,
 				result: BalanceResponse{
-					Current:   100,
-					Withdrawn: 50,
+					Current:      money.FromInt(100),
+					Withdrawn:    money.FromInt(50),
+					ExpiringSoon: []*ExpiringPoints{{Sum: money.FromInt(30), ExpiresAt: "2024-03-01T12:00:00Z"}},
 				},
 			},
 		},
+		{
+			name: "expirations error",
+			request: request{
+				method: http.MethodGet,
+				userID: 1,
+			},
+			getUsersCurrentBalanceMock: getUsersCurrentBalanceMock{
+				needed: true,
+				result: money.FromInt(100),
+				err:    nil,
+			},
+			getUsersWithdrawnBalanceMock: getUsersWithdrawnBalanceMock{
+				needed: true,
+				result: money.FromInt(50),
+				err:    nil,
+			},
+			getUsersExpirationsMock: getUsersExpirationsMock{
+				needed: true,
+				result: nil,
+				err:    errors.New("unexpected error"),
+			},
+			want: want{
+				statusCode: http.StatusInternalServerError,
+			},
+		},
 		{
 			name: "wrong method",
 			request: request{
 				method: http.MethodPost,
-				userID: "1",
+				userID: 1,
 			},
 			getUsersCurrentBalanceMock: getUsersCurrentBalanceMock{
 				needed: false,
@@ -96,7 +167,7 @@ func TestGetUsersBalance(t *testing.T) {
 			name: "zero balance",
 			request: request{
 				method: http.MethodGet,
-				userID: "1",
+				userID: 1,
 			},
 			getUsersCurrentBalanceMock: getUsersCurrentBalanceMock{
 				needed: true,
@@ -108,11 +179,17 @@ func TestGetUsersBalance(t *testing.T) {
 				result: 0,
 				err:    nil,
 			},
+			getUsersExpirationsMock: getUsersExpirationsMock{
+				needed: true,
+				result: []*models.Expiration{},
+				err:    nil,
+			},
 			want: want{
 				statusCode: http.StatusOK,
 				result: BalanceResponse{
-					Current:   0,
-					Withdrawn: 0,
+					Current:      0,
+					Withdrawn:    0,
+					ExpiringSoon: []*ExpiringPoints{},
 				},
 			},
 		},
@@ -127,12 +204,17 @@ func TestGetUsersBalance(t *testing.T) {
 			if tt.getUsersWithdrawnBalanceMock.needed {
 				s.On("GetUsersWithdrawnBalance", mock.Anything, mock.Anything).Return(tt.getUsersWithdrawnBalanceMock.result, tt.getUsersWithdrawnBalanceMock.err)
 			}
+			if tt.getUsersExpirationsMock.needed {
+				s.On("GetUsersExpirations", mock.Anything, tt.request.userID, mock.MatchedBy(func(until *time.Time) bool {
+					return until != nil && until.After(time.Now().Add(29*24*time.Hour))
+				})).Return(tt.getUsersExpirationsMock.result, tt.getUsersExpirationsMock.err)
+			}
 
 			r := chi.NewRouter()
 			r.Get("/api/user/balance", GetUsersBalance(s))
 
 			req := httptest.NewRequest(tt.request.method, uri, nil)
-			req.Header.Set("x-user-id", tt.request.userID)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: tt.request.userID}))
 			w := httptest.NewRecorder()
 			r.ServeHTTP(w, req)
 			res := w.Result()
@@ -154,17 +236,12 @@ func TestWithdrawOrder(t *testing.T) {
 
 	type request struct {
 		method string
-		userID string
+		userID int
 		body   string
 	}
-	type getUsersCurrentBalanceMock struct {
+	type createWithdrawalMock struct {
 		needed bool
-		result float64
-		err    error
-	}
-	type createOrderMock struct {
-		needed bool
-		result *models.Order
+		result *models.Withdrawal
 		err    error
 	}
 	type want struct {
@@ -172,32 +249,25 @@ func TestWithdrawOrder(t *testing.T) {
 	}
 
 	tests := []struct {
-		name                       string
-		request                    request
-		getUsersCurrentBalanceMock getUsersCurrentBalanceMock
-		createOrderMock            createOrderMock
-		want                       want
+		name                 string
+		request              request
+		createWithdrawalMock createWithdrawalMock
+		want                 want
 	}{
 		{
 			name: "ok",
 			request: request{
 				method: http.MethodPost,
-				userID: "1",
+				userID: 1,
 				body:   "{\"order\": \"8023459525\", \"sum\": 100}",
 			},
-			getUsersCurrentBalanceMock: getUsersCurrentBalanceMock{
-				needed: true,
-				result: 200,
-				err:    nil,
-			},
-			createOrderMock: createOrderMock{
+			createWithdrawalMock: createWithdrawalMock{
 				needed: true,
-				result: &models.Order{
-					ID:         1,
-					UserID:     8023459525,
-					Status:     models.OrderStatusProcessed,
-					Sum:        -100,
-					UploadedAt: time.Now(),
+				result: &models.Withdrawal{
+					OrderID:     8023459525,
+					UserID:      1,
+					Sum:         money.FromInt(100),
+					ProcessedAt: time.Now(),
 				},
 				err: nil,
 			},
@@ -209,15 +279,10 @@ func TestWithdrawOrder(t *testing.T) {
 			name: "wrong method",
 			request: request{
 				method: http.MethodGet,
-				userID: "1",
+				userID: 1,
 				body:   "{\"order\": \"8023459525\", \"sum\": 100}",
 			},
-			getUsersCurrentBalanceMock: getUsersCurrentBalanceMock{
-				needed: false,
-				result: 0,
-				err:    nil,
-			},
-			createOrderMock: createOrderMock{
+			createWithdrawalMock: createWithdrawalMock{
 				needed: false,
 				result: nil,
 				err:    nil,
@@ -230,15 +295,10 @@ func TestWithdrawOrder(t *testing.T) {
 			name: "wrong order number",
 			request: request{
 				method: http.MethodPost,
-				userID: "1",
+				userID: 1,
 				body:   "{\"order\": \"1111\", \"sum\": 100}",
 			},
-			getUsersCurrentBalanceMock: getUsersCurrentBalanceMock{
-				needed: true,
-				result: 200,
-				err:    nil,
-			},
-			createOrderMock: createOrderMock{
+			createWithdrawalMock: createWithdrawalMock{
 				needed: false,
 				result: nil,
 				err:    nil,
@@ -251,15 +311,10 @@ func TestWithdrawOrder(t *testing.T) {
 			name: "wrong sum",
 			request: request{
 				method: http.MethodPost,
-				userID: "1",
+				userID: 1,
 				body:   "{\"order\": \"8023459525\", \"sum\": -100}",
 			},
-			getUsersCurrentBalanceMock: getUsersCurrentBalanceMock{
-				needed: false,
-				result: 0,
-				err:    nil,
-			},
-			createOrderMock: createOrderMock{
+			createWithdrawalMock: createWithdrawalMock{
 				needed: false,
 				result: nil,
 				err:    nil,
@@ -272,18 +327,13 @@ func TestWithdrawOrder(t *testing.T) {
 			name: "not enough balance",
 			request: request{
 				method: http.MethodPost,
-				userID: "1",
+				userID: 1,
 				body:   "{\"order\": \"8023459525\", \"sum\": 100}",
 			},
-			getUsersCurrentBalanceMock: getUsersCurrentBalanceMock{
+			createWithdrawalMock: createWithdrawalMock{
 				needed: true,
-				result: 50,
-				err:    nil,
-			},
-			createOrderMock: createOrderMock{
-				needed: false,
 				result: nil,
-				err:    nil,
+				err:    storage.ErrInsufficientFunds,
 			},
 			want: want{
 				statusCode: http.StatusPaymentRequired,
@@ -293,15 +343,10 @@ func TestWithdrawOrder(t *testing.T) {
 			name: "order already exists",
 			request: request{
 				method: http.MethodPost,
-				userID: "1",
+				userID: 1,
 				body:   "{\"order\": \"8023459525\", \"sum\": 100}",
 			},
-			getUsersCurrentBalanceMock: getUsersCurrentBalanceMock{
-				needed: true,
-				result: 200,
-				err:    nil,
-			},
-			createOrderMock: createOrderMock{
+			createWithdrawalMock: createWithdrawalMock{
 				needed: true,
 				result: nil,
 				err:    storage.ErrOrderAlreadyExists,
@@ -314,15 +359,10 @@ func TestWithdrawOrder(t *testing.T) {
 			name: "order already created by other user",
 			request: request{
 				method: http.MethodPost,
-				userID: "1",
+				userID: 1,
 				body:   "{\"order\": \"8023459525\", \"sum\": 100}",
 			},
-			getUsersCurrentBalanceMock: getUsersCurrentBalanceMock{
-				needed: true,
-				result: 200,
-				err:    nil,
-			},
-			createOrderMock: createOrderMock{
+			createWithdrawalMock: createWithdrawalMock{
 				needed: true,
 				result: nil,
 				err:    storage.ErrOrderCreatedByOtherUser,
@@ -336,17 +376,14 @@ func TestWithdrawOrder(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := mocks.NewStorage(t)
-			if tt.getUsersCurrentBalanceMock.needed {
-				s.On("GetUsersCurrentBalance", mock.Anything, mock.Anything).Return(tt.getUsersCurrentBalanceMock.result, tt.getUsersCurrentBalanceMock.err)
-			}
-			if tt.createOrderMock.needed {
-				s.On("CreateOrder", mock.Anything, mock.Anything).Return(tt.createOrderMock.result, tt.createOrderMock.err)
+			if tt.createWithdrawalMock.needed {
+				s.On("CreateWithdrawal", mock.Anything, mock.Anything).Return(tt.createWithdrawalMock.result, tt.createWithdrawalMock.err)
 			}
 
 			r := chi.NewRouter()
 			r.Post(uri, WithdrawOrder(s))
 			req := httptest.NewRequest(tt.request.method, uri, strings.NewReader(tt.request.body))
-			req.Header.Set("x-user-id", tt.request.userID)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: tt.request.userID}))
 			w := httptest.NewRecorder()
 			r.ServeHTTP(w, req)
 			res := w.Result()
@@ -363,7 +400,7 @@ func TestGetUsersWithdrawals(t *testing.T) {
 
 	type request struct {
 		method string
-		userID string
+		userID int
 	}
 	type want struct {
 		statusCode int
@@ -371,7 +408,7 @@ func TestGetUsersWithdrawals(t *testing.T) {
 	}
 	type getUsersWithdrawalsMock struct {
 		needed bool
-		result []*models.Order
+		result []*models.Withdrawal
 		err    error
 	}
 
@@ -385,30 +422,27 @@ func TestGetUsersWithdrawals(t *testing.T) {
 			name: "ok",
 			request: request{
 				method: http.MethodGet,
-				userID: "1",
+				userID: 1,
 			},
 			getUsersWithdrawalsMock: getUsersWithdrawalsMock{
 				needed: true,
-				result: []*models.Order{
+				result: []*models.Withdrawal{
 					{
-						ID:         1,
-						UserID:     1,
-						Status:     models.OrderStatusProcessed,
-						Sum:        200,
-						UploadedAt: currentTime,
+						OrderID:     1,
+						UserID:      1,
+						Sum:         money.FromInt(200),
+						ProcessedAt: currentTime,
 					},
 					{
-						ID:         2,
-						UserID:     1,
-						Status:     models.OrderStatusProcessed,
-						Sum:        1,
-						UploadedAt: currentTime,
+						OrderID:     2,
+						UserID:      1,
+						Sum:         money.FromInt(1),
+						ProcessedAt: currentTime,
 					}, {
-						ID:         3,
-						UserID:     1,
-						Status:     models.OrderStatusProcessed,
-						Sum:        1000,
-						UploadedAt: currentTime,
+						OrderID:     3,
+						UserID:      1,
+						Sum:         money.FromInt(1000),
+						ProcessedAt: currentTime,
 					},
 				},
 				err: nil,
@@ -418,17 +452,17 @@ func TestGetUsersWithdrawals(t *testing.T) {
 				result: WithdrawalResponse{
 					&WithdrawalOrder{
 						OrderID:     "1",
-						Sum:         200,
+						Sum:         money.FromInt(200),
 						ProcessedAt: currentTime.Format(time.RFC3339),
 					},
 					&WithdrawalOrder{
 						OrderID:     "2",
-						Sum:         1,
+						Sum:         money.FromInt(1),
 						ProcessedAt: currentTime.Format(time.RFC3339),
 					},
 					&WithdrawalOrder{
 						OrderID:     "3",
-						Sum:         1000,
+						Sum:         money.FromInt(1000),
 						ProcessedAt: currentTime.Format(time.RFC3339),
 					},
 				},
@@ -438,11 +472,11 @@ func TestGetUsersWithdrawals(t *testing.T) {
 			name: "no withdrawals",
 			request: request{
 				method: http.MethodGet,
-				userID: "1",
+				userID: 1,
 			},
 			getUsersWithdrawalsMock: getUsersWithdrawalsMock{
 				needed: true,
-				result: make([]*models.Order, 0),
+				result: make([]*models.Withdrawal, 0),
 				err:    nil,
 			},
 			want: want{
@@ -454,7 +488,7 @@ func TestGetUsersWithdrawals(t *testing.T) {
 			name: "wrong method",
 			request: request{
 				method: http.MethodPost,
-				userID: "1",
+				userID: 1,
 			},
 			getUsersWithdrawalsMock: getUsersWithdrawalsMock{
 				needed: false,
@@ -478,7 +512,7 @@ func TestGetUsersWithdrawals(t *testing.T) {
 			r := chi.NewRouter()
 			r.Get(uri, GetUsersWithdrawals(s))
 			req := httptest.NewRequest(tt.request.method, uri, nil)
-			req.Header.Set("x-user-id", tt.request.userID)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: tt.request.userID}))
 			w := httptest.NewRecorder()
 			r.ServeHTTP(w, req)
 			res := w.Result()
@@ -494,3 +528,110 @@ func TestGetUsersWithdrawals(t *testing.T) {
 		})
 	}
 }
+
+func TestGetUsersExpirations(t *testing.T) {
+	uri := "/api/user/balance/expirations"
+	expiresAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	type request struct {
+		method string
+		userID int
+	}
+	type want struct {
+		statusCode int
+		result     []*ExpiringPoints
+	}
+	type getUsersExpirationsMock struct {
+		needed bool
+		result []*models.Expiration
+		err    error
+	}
+
+	tests := []struct {
+		name                    string
+		request                 request
+		getUsersExpirationsMock getUsersExpirationsMock
+		want                    want
+	}{
+		{
+			name: "ok",
+			request: request{
+				method: http.MethodGet,
+				userID: 1,
+			},
+			getUsersExpirationsMock: getUsersExpirationsMock{
+				needed: true,
+				result: []*models.Expiration{
+					{Amount: money.FromInt(30), ExpiresAt: expiresAt},
+					{Amount: money.FromInt(70), ExpiresAt: expiresAt.AddDate(0, 1, 0)},
+				},
+				err: nil,
+			},
+			want: want{
+				statusCode: http.StatusOK,
+				result: []*ExpiringPoints{
+					{Sum: money.FromInt(30), ExpiresAt: "2024-03-01T12:00:00Z"},
+					{Sum: money.FromInt(70), ExpiresAt: "2024-04-01T12:00:00Z"},
+				},
+			},
+		},
+		{
+			name: "nothing expires",
+			request: request{
+				method: http.MethodGet,
+				userID: 1,
+			},
+			getUsersExpirationsMock: getUsersExpirationsMock{
+				needed: true,
+				result: make([]*models.Expiration, 0),
+				err:    nil,
+			},
+			want: want{
+				statusCode: http.StatusNoContent,
+				result:     nil,
+			},
+		},
+		{
+			name: "storage error",
+			request: request{
+				method: http.MethodGet,
+				userID: 1,
+			},
+			getUsersExpirationsMock: getUsersExpirationsMock{
+				needed: true,
+				result: nil,
+				err:    errors.New("unexpected error"),
+			},
+			want: want{
+				statusCode: http.StatusInternalServerError,
+				result:     nil,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewStorage(t)
+			if tt.getUsersExpirationsMock.needed {
+				s.On("GetUsersExpirations", mock.Anything, tt.request.userID, (*time.Time)(nil)).Return(tt.getUsersExpirationsMock.result, tt.getUsersExpirationsMock.err)
+			}
+
+			r := chi.NewRouter()
+			r.Get(uri, GetUsersExpirations(s))
+			req := httptest.NewRequest(tt.request.method, uri, nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: tt.request.userID}))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.want.statusCode, res.StatusCode)
+			if tt.want.statusCode == http.StatusOK {
+				var response []*ExpiringPoints
+				err := json.NewDecoder(res.Body).Decode(&response)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want.result, response)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	LastEventIDHeader = "Last-Event-ID"
+	heartbeatInterval = 15 * time.Second
+)
+
+// OrderEvents streams the user's order and balance events as Server-Sent
+// Events. A client reconnecting with Last-Event-ID first gets the events it
+// missed. The stream ends when the subscription is dropped, and the client is
+// expected to reconnect.
+func OrderEvents(es EventSource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			logger.FromContext(r.Context()).Error("Principal is missing")
+			http.Error(w, "Principal is missing", http.StatusInternalServerError)
+			return
+		}
+
+		var lastID int64
+		resume := r.Header.Get(LastEventIDHeader) != ""
+		if resume {
+			var err error
+			lastID, err = strconv.ParseInt(r.Header.Get(LastEventIDHeader), 10, 64)
+			if err != nil || lastID < 0 {
+				http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+				return
+			}
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			logger.FromContext(r.Context()).Error("Streaming is not supported")
+			http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		// Subscribing before the replay makes sure nothing published in
+		// between is lost; events seen in both are skipped the second time.
+		// Event ids follow the user's commit order, so replaying everything
+		// after Last-Event-ID misses nothing that committed later.
+		live, unsubscribe := es.Subscribe(principal.ID)
+		defer unsubscribe()
+		var missed []*models.UserEvent
+		if resume {
+			var err error
+			missed, err = es.Replay(r.Context(), principal.ID, lastID)
+			if err != nil {
+				logger.FromContext(r.Context()).Error("Error replaying events", zap.Error(err))
+				http.Error(w, "Error replaying events", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		replayed := make(map[int64]struct{}, len(missed))
+		for _, event := range missed {
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			replayed[event.ID] = struct{}{}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+					return
+				}
+			case event, ok := <-live:
+				if !ok {
+					return
+				}
+				if _, ok := replayed[event.ID]; ok {
+					delete(replayed, event.ID)
+					continue
+				}
+				if err := writeEvent(w, event); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w io.Writer, event *models.UserEvent) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+	return err
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vindosVP/loyalty-system/internal/handlers/mocks"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOrderEvents(t *testing.T) {
+	uri := "/api/user/orders/events"
+	event := func(id int64, eventType string, data string) *models.UserEvent {
+		return &models.UserEvent{ID: id, UserID: 1, Type: eventType, Data: json.RawMessage(data)}
+	}
+
+	type replayMock struct {
+		needed  bool
+		afterID int64
+		result  []*models.UserEvent
+		err     error
+	}
+	type want struct {
+		code int
+		body string
+	}
+	tests := []struct {
+		name        string
+		lastEventID string
+		replayMock  replayMock
+		live        []*models.UserEvent
+		want        want
+	}{
+		{
+			name: "live events",
+			live: []*models.UserEvent{
+				event(5, models.UserEventOrder, `{"number":"12345678903","status":"PROCESSED","accrual":500,"uploaded_at":"2024-03-01T12:00:00Z"}`),
+				event(6, models.UserEventBalance, `{"current":500,"withdrawn":0}`),
+			},
+			want: want{
+				code: http.StatusOK,
+				body: "id: 5\nevent: order\ndata: {\"number\":\"12345678903\",\"status\":\"PROCESSED\",\"accrual\":500,\"uploaded_at\":\"2024-03-01T12:00:00Z\"}\n\n" +
+					"id: 6\nevent: balance\ndata: {\"current\":500,\"withdrawn\":0}\n\n",
+			},
+		},
+		{
+			name: "live events out of id order",
+			live: []*models.UserEvent{
+				event(6, models.UserEventBalance, `{"current":300,"withdrawn":0}`),
+				event(5, models.UserEventBalance, `{"current":200,"withdrawn":0}`),
+			},
+			want: want{
+				code: http.StatusOK,
+				body: "id: 6\nevent: balance\ndata: {\"current\":300,\"withdrawn\":0}\n\n" +
+					"id: 5\nevent: balance\ndata: {\"current\":200,\"withdrawn\":0}\n\n",
+			},
+		},
+		{
+			name:        "resume",
+			lastEventID: "3",
+			replayMock: replayMock{
+				needed:  true,
+				afterID: 3,
+				result: []*models.UserEvent{
+					event(4, models.UserEventBalance, `{"current":100,"withdrawn":0}`),
+					event(5, models.UserEventBalance, `{"current":200,"withdrawn":0}`),
+				},
+			},
+			live: []*models.UserEvent{
+				event(5, models.UserEventBalance, `{"current":200,"withdrawn":0}`),
+				event(6, models.UserEventBalance, `{"current":300,"withdrawn":0}`),
+			},
+			want: want{
+				code: http.StatusOK,
+				body: "id: 4\nevent: balance\ndata: {\"current\":100,\"withdrawn\":0}\n\n" +
+					"id: 5\nevent: balance\ndata: {\"current\":200,\"withdrawn\":0}\n\n" +
+					"id: 6\nevent: balance\ndata: {\"current\":300,\"withdrawn\":0}\n\n",
+			},
+		},
+		{
+			name:        "resume with a lower live event",
+			lastEventID: "3",
+			replayMock: replayMock{
+				needed:  true,
+				afterID: 3,
+				result: []*models.UserEvent{
+					event(4, models.UserEventBalance, `{"current":100,"withdrawn":0}`),
+					event(6, models.UserEventBalance, `{"current":300,"withdrawn":0}`),
+				},
+			},
+			live: []*models.UserEvent{
+				event(6, models.UserEventBalance, `{"current":300,"withdrawn":0}`),
+				event(5, models.UserEventBalance, `{"current":200,"withdrawn":0}`),
+			},
+			want: want{
+				code: http.StatusOK,
+				body: "id: 4\nevent: balance\ndata: {\"current\":100,\"withdrawn\":0}\n\n" +
+					"id: 6\nevent: balance\ndata: {\"current\":300,\"withdrawn\":0}\n\n" +
+					"id: 5\nevent: balance\ndata: {\"current\":200,\"withdrawn\":0}\n\n",
+			},
+		},
+		{
+			name:        "invalid last event id",
+			lastEventID: "abc",
+			want: want{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name:        "replay unexpected error",
+			lastEventID: "3",
+			replayMock: replayMock{
+				needed:  true,
+				afterID: 3,
+				err:     errors.New("unexpected error"),
+			},
+			want: want{
+				code: http.StatusInternalServerError,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := mocks.NewEventSource(t)
+			if tt.want.code != http.StatusBadRequest {
+				live := make(chan *models.UserEvent, len(tt.live))
+				for _, e := range tt.live {
+					live <- e
+				}
+				close(live)
+				es.On("Subscribe", 1).Return((<-chan *models.UserEvent)(live), func() {})
+			}
+			if tt.replayMock.needed {
+				es.On("Replay", mock.Anything, 1, tt.replayMock.afterID).Return(tt.replayMock.result, tt.replayMock.err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, uri, nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: 1}))
+			if tt.lastEventID != "" {
+				req.Header.Set(LastEventIDHeader, tt.lastEventID)
+			}
+			w := httptest.NewRecorder()
+			OrderEvents(es)(w, req)
+
+			assert.Equal(t, tt.want.code, w.Code)
+			if tt.want.code == http.StatusOK {
+				assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+				assert.Equal(t, tt.want.body, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// Liveness reports that the process is up. It doesn't check dependencies, so
+// a database outage doesn't get the process restarted.
+func Liveness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+	}
+}
+
+// Readiness reports 503 Service Unavailable with the per-component breakdown
+// if a critical component is down or the server is draining.
+func Readiness(c ReadinessChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Check(r.Context())
+		code := http.StatusOK
+		if !report.Ready() {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, code, report)
+	}
+}
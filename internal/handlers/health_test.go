@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/handlers/mocks"
+	"github.com/vindosVP/loyalty-system/internal/health"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name     string
+		report   *health.Report
+		wantCode int
+	}{
+		{
+			name: "ready",
+			report: &health.Report{Status: health.StatusReady, Components: map[string]*health.Component{
+				"database": {Status: health.StatusUp, Critical: true},
+			}},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "not ready",
+			report: &health.Report{Status: health.StatusNotReady, Components: map[string]*health.Component{
+				"database": {Status: health.StatusDown, Critical: true, Error: "connection refused"},
+			}},
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "draining",
+			report:   &health.Report{Status: health.StatusNotReady, Draining: true, Components: map[string]*health.Component{}},
+			wantCode: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mocks.NewReadinessChecker(t)
+			c.On("Check", mock.Anything).Return(tt.report)
+
+			w := httptest.NewRecorder()
+			Readiness(c)(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			var got health.Report
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+			assert.Equal(t, *tt.report, got)
+		})
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	w := httptest.NewRecorder()
+	Liveness()(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -2,16 +2,69 @@ package handlers
 
 import (
 	"context"
+	"github.com/vindosVP/loyalty-system/internal/health"
 	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"time"
 )
 
 //go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Storage
 type Storage interface {
 	CreateUser(ctx context.Context, user *models.User) (*models.User, error)
 	GetUserByLogin(ctx context.Context, login string) (*models.User, error)
+	GetUserByID(ctx context.Context, id int) (*models.User, error)
 	CreateOrder(ctx context.Context, order *models.Order) (*models.Order, error)
 	GetUsersOrders(ctx context.Context, userID int) ([]*models.Order, error)
-	GetUsersCurrentBalance(ctx context.Context, userID int) (float64, error)
-	GetUsersWithdrawnBalance(ctx context.Context, userID int) (float64, error)
-	GetUsersWithdrawals(ctx context.Context, userID int) ([]*models.Order, error)
+	GetUsersCurrentBalance(ctx context.Context, userID int) (money.Amount, error)
+	GetUsersWithdrawnBalance(ctx context.Context, userID int) (money.Amount, error)
+	GetUsersWithdrawals(ctx context.Context, userID int) ([]*models.Withdrawal, error)
+	GetUsersExpirations(ctx context.Context, userID int, until *time.Time) ([]*models.Expiration, error)
+	ListUsersOrders(ctx context.Context, userID int, filter models.ListFilter) (*models.OrdersPage, error)
+	ListUsersWithdrawals(ctx context.Context, userID int, filter models.ListFilter) (*models.WithdrawalsPage, error)
+	CreateWithdrawal(ctx context.Context, withdrawal *models.Withdrawal) (*models.Withdrawal, error)
+	CreateSession(ctx context.Context, session *models.Session) (*models.Session, error)
+	RotateSession(ctx context.Context, id string, oldHash string, newHash string, expiresAt time.Time) (*models.Session, error)
+	RevokeSession(ctx context.Context, id string) error
+	RevokeUserSessions(ctx context.Context, userID int) error
+	RecordAuditEvent(ctx context.Context, event *models.AuditEvent) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=AdminStorage
+type AdminStorage interface {
+	SearchUsers(ctx context.Context, login string, limit int) ([]*models.User, error)
+	GetUserByID(ctx context.Context, id int) (*models.User, error)
+	GetUsersOrders(ctx context.Context, userID int) ([]*models.Order, error)
+	GetUsersWithdrawals(ctx context.Context, userID int) ([]*models.Withdrawal, error)
+	RequeueOrder(ctx context.Context, id int) (*models.Order, error)
+	AdjustBalance(ctx context.Context, adjustment *models.Adjustment) (money.Amount, error)
+	QueryAuditEvents(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, error)
+	VerifyAuditLog(ctx context.Context) (*models.AuditVerification, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=EventSource
+type EventSource interface {
+	Subscribe(userID int) (<-chan *models.UserEvent, func())
+	Replay(ctx context.Context, userID int, afterID int64) ([]*models.UserEvent, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=WebhookStorage
+type WebhookStorage interface {
+	CreateWebhookEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) (*models.WebhookEndpoint, error)
+	ListWebhookEndpoints(ctx context.Context, userID int) ([]*models.WebhookEndpoint, error)
+	DeleteUsersWebhookEndpoint(ctx context.Context, userID int, id int) error
+	DeleteWebhookEndpoint(ctx context.Context, id int) error
+	ListWebhookDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error)
+	ReplayWebhookDeliveries(ctx context.Context, endpointID int, deliveryID int64) (int64, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=TierStorage
+type TierStorage interface {
+	GetUserTier(ctx context.Context, userID int) (*models.TierStatus, error)
+	ListTierRules(ctx context.Context) ([]*models.TierRule, error)
+	UpdateTierRule(ctx context.Context, rule *models.TierRule) (*models.TierRule, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=ReadinessChecker
+type ReadinessChecker interface {
+	Check(ctx context.Context) *health.Report
 }
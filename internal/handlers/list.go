@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	NextCursorHeader = "X-Next-Cursor"
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+var errStatusFilterNotSupported = errors.New("status filter is not supported")
+
+var orderStatuses = []string{
+	models.OrderStatusNew,
+	models.OrderStatusProcessing,
+	models.OrderStatusInvalid,
+	models.OrderStatusProcessed,
+}
+
+// parseListFilter reads limit, cursor, status, from, to, min_amount and
+// max_amount query parameters. Statuses may be repeated or comma-separated;
+// statuses restricts the accepted values, nil rejects the parameter.
+func parseListFilter(q url.Values, statuses []string) (models.ListFilter, error) {
+	filter := models.ListFilter{Limit: defaultListLimit}
+	var err error
+	if v := q.Get("limit"); v != "" {
+		filter.Limit, err = strconv.Atoi(v)
+		if err != nil || filter.Limit <= 0 || filter.Limit > maxListLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+	}
+	if v := q.Get("cursor"); v != "" {
+		if filter.After, err = models.ParseCursor(v); err != nil {
+			return filter, err
+		}
+	}
+	for _, v := range q["status"] {
+		for _, status := range strings.Split(v, ",") {
+			if statuses == nil {
+				return filter, errStatusFilterNotSupported
+			}
+			status = strings.ToUpper(strings.TrimSpace(status))
+			if !contains(statuses, status) {
+				return filter, fmt.Errorf("unknown status %q", status)
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+	if filter.From, err = parseTimeParam(q, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTimeParam(q, "to"); err != nil {
+		return filter, err
+	}
+	if filter.MinAmount, err = parseAmountParam(q, "min_amount"); err != nil {
+		return filter, err
+	}
+	if filter.MaxAmount, err = parseAmountParam(q, "max_amount"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func parseTimeParam(q url.Values, name string) (*time.Time, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return &t, nil
+}
+
+func parseAmountParam(q url.Values, name string) (*money.Amount, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	a, err := money.Parse(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a decimal amount", name)
+	}
+	return &a, nil
+}
+
+// setNextPage advertises the next page in the Link and X-Next-Cursor headers.
+// It must be called before the response body is written.
+func setNextPage(w http.ResponseWriter, r *http.Request, next *models.Cursor) {
+	if next == nil {
+		return
+	}
+	cursor := next.String()
+	q := r.URL.Query()
+	q.Set("cursor", cursor)
+	nextURL := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextURL.String()))
+	w.Header().Set(NextCursorHeader, cursor)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
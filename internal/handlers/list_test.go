@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseListFilter(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.FixedZone("", 3*60*60))
+	minAmount, maxAmount := money.MustParse("10.5"), money.FromInt(1000)
+	cursor := &models.Cursor{Time: from, ID: 42}
+
+	tests := []struct {
+		name     string
+		query    string
+		statuses []string
+		want     models.ListFilter
+		wantErr  bool
+	}{
+		{
+			name:     "defaults",
+			query:    "",
+			statuses: orderStatuses,
+			want:     models.ListFilter{Limit: defaultListLimit},
+		},
+		{
+			name:     "all parameters",
+			query:    "limit=20&cursor=" + cursor.String() + "&status=new,Processing&status=INVALID&from=2024-01-01T00:00:00Z&to=2024-02-01T00:00:00%2B03:00&min_amount=10.5&max_amount=1000",
+			statuses: orderStatuses,
+			want: models.ListFilter{
+				Limit:     20,
+				After:     cursor,
+				Statuses:  []string{models.OrderStatusNew, models.OrderStatusProcessing, models.OrderStatusInvalid},
+				From:      &from,
+				To:        &to,
+				MinAmount: &minAmount,
+				MaxAmount: &maxAmount,
+			},
+		},
+		{
+			name:     "limit too large",
+			query:    "limit=501",
+			statuses: orderStatuses,
+			wantErr:  true,
+		},
+		{
+			name:     "unknown status",
+			query:    "status=LOST",
+			statuses: orderStatuses,
+			wantErr:  true,
+		},
+		{
+			name:     "status not supported",
+			query:    "status=NEW",
+			statuses: nil,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid date",
+			query:    "from=yesterday",
+			statuses: orderStatuses,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid amount",
+			query:    "max_amount=lots",
+			statuses: orderStatuses,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+			got, err := parseListFilter(q, tt.statuses)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.Limit, got.Limit)
+			assert.Equal(t, tt.want.After, got.After)
+			assert.Equal(t, tt.want.Statuses, got.Statuses)
+			assert.Equal(t, tt.want.MinAmount, got.MinAmount)
+			assert.Equal(t, tt.want.MaxAmount, got.MaxAmount)
+			if tt.want.From != nil {
+				assert.True(t, tt.want.From.Equal(*got.From))
+				assert.True(t, tt.want.To.Equal(*got.To))
+			}
+		})
+	}
+}
@@ -4,10 +4,11 @@ package mocks
 
 import (
 	context "context"
+	time "time"
 
 	mock "github.com/stretchr/testify/mock"
-
 	models "github.com/vindosVP/loyalty-system/internal/models"
+	money "github.com/vindosVP/loyalty-system/pkg/money"
 )
 
 // Storage is an autogenerated mock type for the Storage type
@@ -41,6 +42,32 @@ func (_m *Storage) CreateOrder(ctx context.Context, order *models.Order) (*model
 	return r0, r1
 }
 
+// CreateSession provides a mock function with given fields: ctx, session
+func (_m *Storage) CreateSession(ctx context.Context, session *models.Session) (*models.Session, error) {
+	ret := _m.Called(ctx, session)
+
+	var r0 *models.Session
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Session) (*models.Session, error)); ok {
+		return rf(ctx, session)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Session) *models.Session); ok {
+		r0 = rf(ctx, session)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Session)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *models.Session) error); ok {
+		r1 = rf(ctx, session)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateUser provides a mock function with given fields: ctx, user
 func (_m *Storage) CreateUser(ctx context.Context, user *models.User) (*models.User, error) {
 	ret := _m.Called(ctx, user)
@@ -67,6 +94,58 @@ func (_m *Storage) CreateUser(ctx context.Context, user *models.User) (*models.U
 	return r0, r1
 }
 
+// CreateWithdrawal provides a mock function with given fields: ctx, withdrawal
+func (_m *Storage) CreateWithdrawal(ctx context.Context, withdrawal *models.Withdrawal) (*models.Withdrawal, error) {
+	ret := _m.Called(ctx, withdrawal)
+
+	var r0 *models.Withdrawal
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Withdrawal) (*models.Withdrawal, error)); ok {
+		return rf(ctx, withdrawal)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Withdrawal) *models.Withdrawal); ok {
+		r0 = rf(ctx, withdrawal)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Withdrawal)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *models.Withdrawal) error); ok {
+		r1 = rf(ctx, withdrawal)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserByID provides a mock function with given fields: ctx, id
+func (_m *Storage) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *models.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (*models.User, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) *models.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetUserByLogin provides a mock function with given fields: ctx, login
 func (_m *Storage) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
 	ret := _m.Called(ctx, login)
@@ -94,18 +173,18 @@ func (_m *Storage) GetUserByLogin(ctx context.Context, login string) (*models.Us
 }
 
 // GetUsersCurrentBalance provides a mock function with given fields: ctx, userID
-func (_m *Storage) GetUsersCurrentBalance(ctx context.Context, userID int) (float64, error) {
+func (_m *Storage) GetUsersCurrentBalance(ctx context.Context, userID int) (money.Amount, error) {
 	ret := _m.Called(ctx, userID)
 
-	var r0 float64
+	var r0 money.Amount
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, int) (float64, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, int) (money.Amount, error)); ok {
 		return rf(ctx, userID)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, int) float64); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, int) money.Amount); ok {
 		r0 = rf(ctx, userID)
 	} else {
-		r0 = ret.Get(0).(float64)
+		r0 = ret.Get(0).(money.Amount)
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
@@ -117,6 +196,32 @@ func (_m *Storage) GetUsersCurrentBalance(ctx context.Context, userID int) (floa
 	return r0, r1
 }
 
+// GetUsersExpirations provides a mock function with given fields: ctx, userID, until
+func (_m *Storage) GetUsersExpirations(ctx context.Context, userID int, until *time.Time) ([]*models.Expiration, error) {
+	ret := _m.Called(ctx, userID, until)
+
+	var r0 []*models.Expiration
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, *time.Time) ([]*models.Expiration, error)); ok {
+		return rf(ctx, userID, until)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, *time.Time) []*models.Expiration); ok {
+		r0 = rf(ctx, userID, until)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Expiration)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, *time.Time) error); ok {
+		r1 = rf(ctx, userID, until)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetUsersOrders provides a mock function with given fields: ctx, userID
 func (_m *Storage) GetUsersOrders(ctx context.Context, userID int) ([]*models.Order, error) {
 	ret := _m.Called(ctx, userID)
@@ -144,19 +249,19 @@ func (_m *Storage) GetUsersOrders(ctx context.Context, userID int) ([]*models.Or
 }
 
 // GetUsersWithdrawals provides a mock function with given fields: ctx, userID
-func (_m *Storage) GetUsersWithdrawals(ctx context.Context, userID int) ([]*models.Order, error) {
+func (_m *Storage) GetUsersWithdrawals(ctx context.Context, userID int) ([]*models.Withdrawal, error) {
 	ret := _m.Called(ctx, userID)
 
-	var r0 []*models.Order
+	var r0 []*models.Withdrawal
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, int) ([]*models.Order, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]*models.Withdrawal, error)); ok {
 		return rf(ctx, userID)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, int) []*models.Order); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, int) []*models.Withdrawal); ok {
 		r0 = rf(ctx, userID)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]*models.Order)
+			r0 = ret.Get(0).([]*models.Withdrawal)
 		}
 	}
 
@@ -170,18 +275,18 @@ func (_m *Storage) GetUsersWithdrawals(ctx context.Context, userID int) ([]*mode
 }
 
 // GetUsersWithdrawnBalance provides a mock function with given fields: ctx, userID
-func (_m *Storage) GetUsersWithdrawnBalance(ctx context.Context, userID int) (float64, error) {
+func (_m *Storage) GetUsersWithdrawnBalance(ctx context.Context, userID int) (money.Amount, error) {
 	ret := _m.Called(ctx, userID)
 
-	var r0 float64
+	var r0 money.Amount
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, int) (float64, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, int) (money.Amount, error)); ok {
 		return rf(ctx, userID)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, int) float64); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, int) money.Amount); ok {
 		r0 = rf(ctx, userID)
 	} else {
-		r0 = ret.Get(0).(float64)
+		r0 = ret.Get(0).(money.Amount)
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
@@ -193,6 +298,126 @@ func (_m *Storage) GetUsersWithdrawnBalance(ctx context.Context, userID int) (fl
 	return r0, r1
 }
 
+// ListUsersOrders provides a mock function with given fields: ctx, userID, filter
+func (_m *Storage) ListUsersOrders(ctx context.Context, userID int, filter models.ListFilter) (*models.OrdersPage, error) {
+	ret := _m.Called(ctx, userID, filter)
+
+	var r0 *models.OrdersPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, models.ListFilter) (*models.OrdersPage, error)); ok {
+		return rf(ctx, userID, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, models.ListFilter) *models.OrdersPage); ok {
+		r0 = rf(ctx, userID, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.OrdersPage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, models.ListFilter) error); ok {
+		r1 = rf(ctx, userID, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListUsersWithdrawals provides a mock function with given fields: ctx, userID, filter
+func (_m *Storage) ListUsersWithdrawals(ctx context.Context, userID int, filter models.ListFilter) (*models.WithdrawalsPage, error) {
+	ret := _m.Called(ctx, userID, filter)
+
+	var r0 *models.WithdrawalsPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, models.ListFilter) (*models.WithdrawalsPage, error)); ok {
+		return rf(ctx, userID, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, models.ListFilter) *models.WithdrawalsPage); ok {
+		r0 = rf(ctx, userID, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.WithdrawalsPage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, models.ListFilter) error); ok {
+		r1 = rf(ctx, userID, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RecordAuditEvent provides a mock function with given fields: ctx, event
+func (_m *Storage) RecordAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	ret := _m.Called(ctx, event)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.AuditEvent) error); ok {
+		r0 = rf(ctx, event)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeSession provides a mock function with given fields: ctx, id
+func (_m *Storage) RevokeSession(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeUserSessions provides a mock function with given fields: ctx, userID
+func (_m *Storage) RevokeUserSessions(ctx context.Context, userID int) error {
+	ret := _m.Called(ctx, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RotateSession provides a mock function with given fields: ctx, id, oldHash, newHash, expiresAt
+func (_m *Storage) RotateSession(ctx context.Context, id string, oldHash string, newHash string, expiresAt time.Time) (*models.Session, error) {
+	ret := _m.Called(ctx, id, oldHash, newHash, expiresAt)
+
+	var r0 *models.Session
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, time.Time) (*models.Session, error)); ok {
+		return rf(ctx, id, oldHash, newHash, expiresAt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, time.Time) *models.Session); ok {
+		r0 = rf(ctx, id, oldHash, newHash, expiresAt)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Session)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, time.Time) error); ok {
+		r1 = rf(ctx, id, oldHash, newHash, expiresAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type mockConstructorTestingTNewStorage interface {
 	mock.TestingT
 	Cleanup(func())
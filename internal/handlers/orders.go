@@ -6,7 +6,9 @@ import (
 	"errors"
 	"github.com/vindosVP/loyalty-system/internal/models"
 	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
 	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"github.com/vindosVP/loyalty-system/pkg/money"
 	"go.uber.org/zap"
 	"net/http"
 	"strconv"
@@ -14,22 +16,41 @@ import (
 )
 
 type OrderResponse struct {
-	Number     string  `json:"number"`
-	Status     string  `json:"status"`
-	Accrual    float64 `json:"accrual,omitempty"`
-	Withdrawal float64 `json:"withdrawal,omitempty"`
-	UploadedAt string  `json:"uploaded_at"`
+	Number     string       `json:"number"`
+	Status     string       `json:"status"`
+	Accrual    money.Amount `json:"accrual,omitempty"`
+	UploadedAt string       `json:"uploaded_at"`
 }
 
 type OrdersListResponse []*OrderResponse
 
+func newOrderResponse(order *models.Order) *OrderResponse {
+	resp := &OrderResponse{
+		Number:     strconv.Itoa(order.ID),
+		Status:     order.Status,
+		UploadedAt: order.UploadedAt.Format(time.RFC3339),
+	}
+	if order.Sum > 0 {
+		resp.Accrual = order.Sum
+	}
+	return resp
+}
+
+func newOrdersListResponse(orders []*models.Order) OrdersListResponse {
+	resp := make(OrdersListResponse, len(orders))
+	for i, order := range orders {
+		resp[i] = newOrderResponse(order)
+	}
+	return resp
+}
+
 func CreateOrder(s Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		var buf bytes.Buffer
 		_, err := buf.ReadFrom(r.Body)
 		if err != nil {
-			logger.Log.Error("Error reading body", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error reading body", zap.Error(err))
 			http.Error(w, "Error reading body", http.StatusInternalServerError)
 			return
 		}
@@ -45,22 +66,16 @@ func CreateOrder(s Storage) http.HandlerFunc {
 			return
 		}
 
-		gotUserID := r.Header.Get("x-user-id")
-		if gotUserID == "" {
-			logger.Log.Error("User id is empty")
-			http.Error(w, "User id is empty", http.StatusInternalServerError)
-			return
-		}
-		userID, err := strconv.Atoi(gotUserID)
-		if err != nil {
-			logger.Log.Error("Error parsing user id", zap.Error(err))
-			http.Error(w, "Error parsing user id", http.StatusInternalServerError)
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			logger.FromContext(r.Context()).Error("Principal is missing")
+			http.Error(w, "Principal is missing", http.StatusInternalServerError)
 			return
 		}
 
 		order := &models.Order{
 			ID:         orderID,
-			UserID:     userID,
+			UserID:     principal.ID,
 			Status:     models.OrderStatusNew,
 			Sum:        0,
 			UploadedAt: time.Now(),
@@ -82,7 +97,7 @@ func CreateOrder(s Storage) http.HandlerFunc {
 				http.Error(w, "Order was already created by other user", http.StatusConflict)
 				return
 			}
-			logger.Log.Error("Error creating order", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error creating order", zap.Error(err))
 			http.Error(w, "Error creating order", http.StatusInternalServerError)
 		}
 
@@ -93,22 +108,32 @@ func CreateOrder(s Storage) http.HandlerFunc {
 func GetOrderList(s Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		gotUserID := r.Header.Get("x-user-id")
-		if gotUserID == "" {
-			logger.Log.Error("User id is empty")
-			http.Error(w, "User id is empty", http.StatusInternalServerError)
-			return
-		}
-		userID, err := strconv.Atoi(gotUserID)
-		if err != nil {
-			logger.Log.Error("Error parsing user id", zap.Error(err))
-			http.Error(w, "Error parsing user id", http.StatusInternalServerError)
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			logger.FromContext(r.Context()).Error("Principal is missing")
+			http.Error(w, "Principal is missing", http.StatusInternalServerError)
 			return
 		}
 
-		usersOrders, err := s.GetUsersOrders(r.Context(), userID)
+		// Without query parameters the whole list is returned, as before pagination.
+		var usersOrders []*models.Order
+		var err error
+		if r.URL.RawQuery == "" {
+			usersOrders, err = s.GetUsersOrders(r.Context(), principal.ID)
+		} else {
+			var filter models.ListFilter
+			if filter, err = parseListFilter(r.URL.Query(), orderStatuses); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var page *models.OrdersPage
+			if page, err = s.ListUsersOrders(r.Context(), principal.ID, filter); err == nil {
+				usersOrders = page.Orders
+				setNextPage(w, r, page.Next)
+			}
+		}
 		if err != nil {
-			logger.Log.Error("Error getting users orders", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error getting users orders", zap.Error(err))
 			http.Error(w, "Error getting users orders", http.StatusInternalServerError)
 			return
 		}
@@ -118,31 +143,17 @@ func GetOrderList(s Storage) http.HandlerFunc {
 			return
 		}
 
-		resp := make([]*OrderResponse, len(usersOrders))
-		for i, order := range usersOrders {
-			resp[i] = &OrderResponse{
-				Number:     strconv.Itoa(order.ID),
-				Status:     order.Status,
-				UploadedAt: order.UploadedAt.Format(time.RFC3339),
-			}
-			if order.Sum > 0 {
-				resp[i].Accrual = order.Sum
-			}
-			if order.Sum < 0 {
-				resp[i].Withdrawal = -order.Sum
-			}
-		}
-
+		resp := newOrdersListResponse(usersOrders)
 		data, err := json.Marshal(&resp)
 		if err != nil {
-			logger.Log.Error("Error marshaling orders", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error marshaling orders", zap.Error(err))
 			http.Error(w, "Error marshaling orders", http.StatusInternalServerError)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
 		_, err = w.Write(data)
 		if err != nil {
-			logger.Log.Error("Error writing orders", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error writing orders", zap.Error(err))
 			http.Error(w, "Error writing orders", http.StatusInternalServerError)
 			return
 		}
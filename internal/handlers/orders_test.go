@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/vindosVP/loyalty-system/internal/handlers/mocks"
 	"github.com/vindosVP/loyalty-system/internal/models"
 	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/money"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -21,7 +25,7 @@ func TestCreateOrder(t *testing.T) {
 	type request struct {
 		method string
 		body   string
-		userID string
+		userID int
 	}
 	type want struct {
 		statusCode int
@@ -54,7 +58,7 @@ func TestCreateOrder(t *testing.T) {
 			request: request{
 				method: http.MethodPost,
 				body:   "7703824164",
-				userID: "1",
+				userID: 1,
 			},
 			want: want{
 				statusCode: http.StatusAccepted,
@@ -70,7 +74,7 @@ func TestCreateOrder(t *testing.T) {
 			request: request{
 				method: http.MethodGet,
 				body:   "7703824164",
-				userID: "1",
+				userID: 1,
 			},
 			want: want{
 				statusCode: http.StatusMethodNotAllowed,
@@ -86,7 +90,7 @@ func TestCreateOrder(t *testing.T) {
 			request: request{
 				method: http.MethodPost,
 				body:   "1111",
-				userID: "1",
+				userID: 1,
 			},
 			want: want{
 				statusCode: http.StatusUnprocessableEntity,
@@ -102,7 +106,7 @@ func TestCreateOrder(t *testing.T) {
 			request: request{
 				method: http.MethodPost,
 				body:   "",
-				userID: "1",
+				userID: 1,
 			},
 			want: want{
 				statusCode: http.StatusBadRequest,
@@ -118,7 +122,7 @@ func TestCreateOrder(t *testing.T) {
 			request: request{
 				method: http.MethodPost,
 				body:   "7703824164",
-				userID: "1",
+				userID: 1,
 			},
 			want: want{
 				statusCode: http.StatusOK,
@@ -134,7 +138,7 @@ func TestCreateOrder(t *testing.T) {
 			request: request{
 				method: http.MethodPost,
 				body:   "7703824164",
-				userID: "1",
+				userID: 1,
 			},
 			want: want{
 				statusCode: http.StatusConflict,
@@ -153,7 +157,7 @@ func TestCreateOrder(t *testing.T) {
 			r.Post(uri, CreateOrder(s))
 
 			req := httptest.NewRequest(tt.request.method, uri, strings.NewReader(tt.request.body))
-			req.Header.Set("x-user-id", tt.request.userID)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: tt.request.userID}))
 			w := httptest.NewRecorder()
 			r.ServeHTTP(w, req)
 			res := w.Result()
@@ -171,7 +175,7 @@ func TestGetOrderList(t *testing.T) {
 
 	type request struct {
 		method string
-		userID string
+		userID int
 	}
 	type getUserOrdersMock struct {
 		needed bool
@@ -220,7 +224,7 @@ func TestGetOrderList(t *testing.T) {
 			},
 			request: request{
 				method: http.MethodGet,
-				userID: "1",
+				userID: 1,
 			},
 			want: want{
 				statusCode: http.StatusOK,
@@ -253,7 +257,7 @@ func TestGetOrderList(t *testing.T) {
 			},
 			request: request{
 				method: http.MethodPost,
-				userID: "1",
+				userID: 1,
 			},
 			want: want{
 				statusCode: http.StatusMethodNotAllowed,
@@ -269,7 +273,7 @@ func TestGetOrderList(t *testing.T) {
 			},
 			request: request{
 				method: http.MethodGet,
-				userID: "1",
+				userID: 1,
 			},
 			want: want{
 				statusCode: http.StatusNoContent,
@@ -289,7 +293,7 @@ func TestGetOrderList(t *testing.T) {
 			r.Get(uri, GetOrderList(s))
 
 			req := httptest.NewRequest(tt.request.method, uri, nil)
-			req.Header.Set("x-user-id", tt.request.userID)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: tt.request.userID}))
 			w := httptest.NewRecorder()
 			r.ServeHTTP(w, req)
 			res := w.Result()
@@ -305,3 +309,123 @@ func TestGetOrderList(t *testing.T) {
 		})
 	}
 }
+
+func TestGetOrderList_Paged(t *testing.T) {
+	uri := "/api/user/orders"
+	uploadedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	next := &models.Cursor{Time: uploadedAt, ID: 9278923470}
+	minAmount := money.FromInt(100)
+
+	type listUsersOrdersMock struct {
+		needed bool
+		filter models.ListFilter
+		result *models.OrdersPage
+		err    error
+	}
+	type want struct {
+		statusCode int
+		link       string
+		orders     int
+	}
+
+	tests := []struct {
+		name                string
+		query               string
+		listUsersOrdersMock listUsersOrdersMock
+		want                want
+	}{
+		{
+			name:  "first page",
+			query: "?limit=1&status=processed&min_amount=100",
+			listUsersOrdersMock: listUsersOrdersMock{
+				needed: true,
+				filter: models.ListFilter{Limit: 1, Statuses: []string{models.OrderStatusProcessed}, MinAmount: &minAmount},
+				result: &models.OrdersPage{
+					Orders: []*models.Order{{ID: 9278923470, UserID: 1, Status: models.OrderStatusProcessed, Sum: 500, UploadedAt: uploadedAt}},
+					Next:   next,
+				},
+			},
+			want: want{
+				statusCode: http.StatusOK,
+				link:       "</api/user/orders?cursor=" + next.String() + "&limit=1&min_amount=100&status=processed>; rel=\"next\"",
+				orders:     1,
+			},
+		},
+		{
+			name:  "last page",
+			query: "?cursor=" + next.String(),
+			listUsersOrdersMock: listUsersOrdersMock{
+				needed: true,
+				filter: models.ListFilter{Limit: defaultListLimit, After: next},
+				result: &models.OrdersPage{
+					Orders: []*models.Order{{ID: 12345678903, UserID: 1, Status: models.OrderStatusNew, UploadedAt: uploadedAt}},
+				},
+			},
+			want: want{
+				statusCode: http.StatusOK,
+				orders:     1,
+			},
+		},
+		{
+			name:  "empty page",
+			query: "?status=NEW",
+			listUsersOrdersMock: listUsersOrdersMock{
+				needed: true,
+				filter: models.ListFilter{Limit: defaultListLimit, Statuses: []string{models.OrderStatusNew}},
+				result: &models.OrdersPage{Orders: []*models.Order{}},
+			},
+			want: want{
+				statusCode: http.StatusNoContent,
+			},
+		},
+		{
+			name:  "invalid cursor",
+			query: "?cursor=garbage",
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:  "unexpected error",
+			query: "?limit=10",
+			listUsersOrdersMock: listUsersOrdersMock{
+				needed: true,
+				filter: models.ListFilter{Limit: 10},
+				err:    errors.New("unexpected error"),
+			},
+			want: want{
+				statusCode: http.StatusInternalServerError,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewStorage(t)
+			if tt.listUsersOrdersMock.needed {
+				s.On("ListUsersOrders", mock.Anything, 1, tt.listUsersOrdersMock.filter).Return(tt.listUsersOrdersMock.result, tt.listUsersOrdersMock.err)
+			}
+
+			r := chi.NewRouter()
+			r.Get(uri, GetOrderList(s))
+
+			req := httptest.NewRequest(http.MethodGet, uri+tt.query, nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: 1}))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.want.statusCode, res.StatusCode)
+			assert.Equal(t, tt.want.link, res.Header.Get("Link"))
+			if tt.want.link != "" {
+				assert.Equal(t, next.String(), res.Header.Get(NextCursorHeader))
+			}
+			if tt.want.statusCode == http.StatusOK {
+				var ordersListResponse OrdersListResponse
+				require.NoError(t, json.NewDecoder(res.Body).Decode(&ordersListResponse))
+				assert.Len(t, ordersListResponse, tt.want.orders)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"github.com/vindosVP/loyalty-system/pkg/tokens"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+const RefreshTokenHeader = "X-Refresh-Token"
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// issueTokens starts a new session for the user and writes an access token
+// bound to it to the Authorization header and its refresh token to X-Refresh-Token.
+func issueTokens(ctx context.Context, s Storage, cfg tokens.Config, user *models.User, w http.ResponseWriter) error {
+	sessionID, err := tokens.NewSessionID()
+	if err != nil {
+		return fmt.Errorf("tokens.NewSessionID: %w", err)
+	}
+	refreshToken, err := tokens.NewRefreshToken(sessionID)
+	if err != nil {
+		return fmt.Errorf("tokens.NewRefreshToken: %w", err)
+	}
+	now := time.Now()
+	session, err := s.CreateSession(ctx, &models.Session{
+		ID:               sessionID,
+		UserID:           user.ID,
+		RefreshTokenHash: refreshToken.Hash(),
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(cfg.RefreshTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("s.CreateSession: %w", err)
+	}
+	return writeTokens(w, cfg, user, session, refreshToken)
+}
+
+func writeTokens(w http.ResponseWriter, cfg tokens.Config, user *models.User, session *models.Session, refreshToken *tokens.RefreshToken) error {
+	accessToken, err := tokens.CreateJWT(
+		tokens.SessionClaims(user.ID, user.Login, session.ID, time.Now().Add(cfg.AccessTTL).Unix(), user.Roles...),
+		cfg.Secret,
+	)
+	if err != nil {
+		return fmt.Errorf("tokens.CreateJWT: %w", err)
+	}
+	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	w.Header().Set(RefreshTokenHeader, refreshToken.String())
+	return nil
+}
+
+func RefreshToken(s Storage, cfg tokens.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		req := &RefreshRequest{}
+		err := json.NewDecoder(r.Body).Decode(req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		oldToken, err := tokens.ParseRefreshToken(req.RefreshToken)
+		if err != nil {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		newToken, err := tokens.NewRefreshToken(oldToken.SessionID)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error creating refresh token", zap.Error(err))
+			http.Error(w, "Error creating refresh token", http.StatusInternalServerError)
+			return
+		}
+
+		session, err := s.RotateSession(r.Context(), oldToken.SessionID, oldToken.Hash(), newToken.Hash(), time.Now().Add(cfg.RefreshTTL))
+		if err != nil {
+			if errors.Is(err, storage.ErrSessionNotFound) {
+				http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+				return
+			}
+			logger.FromContext(r.Context()).Error("Error rotating session", zap.Error(err))
+			http.Error(w, "Error rotating session", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := s.GetUserByID(r.Context(), session.UserID)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error getting user", zap.Error(err))
+			http.Error(w, "Error getting user", http.StatusInternalServerError)
+			return
+		}
+
+		err = writeTokens(w, cfg, user, session, newToken)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error creating token", zap.Error(err))
+			http.Error(w, "Error creating token", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func Logout(s Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			logger.FromContext(r.Context()).Error("Principal is missing")
+			http.Error(w, "Principal is missing", http.StatusInternalServerError)
+			return
+		}
+
+		err := s.RevokeSession(r.Context(), principal.SessionID)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error revoking session", zap.Error(err))
+			http.Error(w, "Error revoking session", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func LogoutAll(s Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			logger.FromContext(r.Context()).Error("Principal is missing")
+			http.Error(w, "Principal is missing", http.StatusInternalServerError)
+			return
+		}
+
+		err := s.RevokeUserSessions(r.Context(), principal.ID)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error revoking sessions", zap.Error(err))
+			http.Error(w, "Error revoking sessions", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
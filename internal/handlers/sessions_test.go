@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/handlers/mocks"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/tokens"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRefreshToken(t *testing.T) {
+	tokenCfg := tokens.Config{Secret: "superSecret", AccessTTL: time.Minute, RefreshTTL: time.Hour}
+	uri := "/api/user/token/refresh"
+	oldToken := &tokens.RefreshToken{SessionID: "someSession", Secret: "someSecret"}
+
+	type rotateSessionMock struct {
+		needed bool
+		result *models.Session
+		err    error
+	}
+	type getUserByIDMock struct {
+		needed bool
+		result *models.User
+		err    error
+	}
+	type want struct {
+		code     int
+		checkJWT bool
+	}
+
+	tests := []struct {
+		name              string
+		body              string
+		rotateSessionMock rotateSessionMock
+		getUserByIDMock   getUserByIDMock
+		want              want
+	}{
+		{
+			name: "ok",
+			body: `{"refresh_token": "` + oldToken.String() + `"}`,
+			rotateSessionMock: rotateSessionMock{
+				needed: true,
+				result: &models.Session{ID: "someSession", UserID: 1},
+				err:    nil,
+			},
+			getUserByIDMock: getUserByIDMock{
+				needed: true,
+				result: &models.User{ID: 1, Login: "someLogin"},
+				err:    nil,
+			},
+			want: want{code: http.StatusOK, checkJWT: true},
+		},
+		{
+			name: "already used token",
+			body: `{"refresh_token": "` + oldToken.String() + `"}`,
+			rotateSessionMock: rotateSessionMock{
+				needed: true,
+				result: nil,
+				err:    storage.ErrSessionNotFound,
+			},
+			want: want{code: http.StatusUnauthorized},
+		},
+		{
+			name: "malformed token",
+			body: `{"refresh_token": "someToken"}`,
+			want: want{code: http.StatusUnauthorized},
+		},
+		{
+			name: "invalid body",
+			body: `refresh`,
+			want: want{code: http.StatusBadRequest},
+		},
+		{
+			name: "unexpected error",
+			body: `{"refresh_token": "` + oldToken.String() + `"}`,
+			rotateSessionMock: rotateSessionMock{
+				needed: true,
+				result: nil,
+				err:    errors.New("unexpected error"),
+			},
+			want: want{code: http.StatusInternalServerError},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewStorage(t)
+			if tt.rotateSessionMock.needed {
+				s.On("RotateSession", mock.Anything, "someSession", oldToken.Hash(), mock.Anything, mock.Anything).Return(tt.rotateSessionMock.result, tt.rotateSessionMock.err)
+			}
+			if tt.getUserByIDMock.needed {
+				s.On("GetUserByID", mock.Anything, 1).Return(tt.getUserByIDMock.result, tt.getUserByIDMock.err)
+			}
+
+			r := chi.NewRouter()
+			r.Post(uri, RefreshToken(s, tokenCfg))
+			req := httptest.NewRequest(http.MethodPost, uri, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.want.code, res.StatusCode)
+			if tt.want.checkJWT {
+				token := strings.TrimPrefix(res.Header.Get("Authorization"), "Bearer ")
+				claims, err := tokens.ParseClaims(token, tokenCfg.Secret)
+				require.NoError(t, err)
+				assert.Equal(t, "someSession", claims.SessionID)
+
+				newToken, err := tokens.ParseRefreshToken(res.Header.Get(RefreshTokenHeader))
+				require.NoError(t, err)
+				assert.Equal(t, "someSession", newToken.SessionID)
+				assert.NotEqual(t, oldToken.Secret, newToken.Secret)
+			}
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   func(s Storage) http.HandlerFunc
+		mockSetup func(s *mocks.Storage)
+		code      int
+	}{
+		{
+			name:    "logout",
+			handler: Logout,
+			mockSetup: func(s *mocks.Storage) {
+				s.On("RevokeSession", mock.Anything, "someSession").Return(nil)
+			},
+			code: http.StatusNoContent,
+		},
+		{
+			name:    "logout unexpected error",
+			handler: Logout,
+			mockSetup: func(s *mocks.Storage) {
+				s.On("RevokeSession", mock.Anything, "someSession").Return(errors.New("unexpected error"))
+			},
+			code: http.StatusInternalServerError,
+		},
+		{
+			name:    "logout all",
+			handler: LogoutAll,
+			mockSetup: func(s *mocks.Storage) {
+				s.On("RevokeUserSessions", mock.Anything, 1).Return(nil)
+			},
+			code: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewStorage(t)
+			tt.mockSetup(s)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/user/logout", nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: 1, SessionID: "someSession"}))
+			w := httptest.NewRecorder()
+			tt.handler(s).ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.code, res.StatusCode)
+		})
+	}
+}
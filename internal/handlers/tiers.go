@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type TierResponse struct {
+	Tier       string              `json:"tier"`
+	Multiplier money.Amount        `json:"multiplier"`
+	Accrued    money.Amount        `json:"accrued"`
+	Next       *TierProgress       `json:"next,omitempty"`
+	History    []*TierHistoryEntry `json:"history"`
+}
+
+// TierProgress is how far the user is from the next tier.
+type TierProgress struct {
+	Tier       string       `json:"tier"`
+	MinAccrued money.Amount `json:"min_accrued"`
+	Remaining  money.Amount `json:"remaining"`
+}
+
+type TierHistoryEntry struct {
+	Tier      string       `json:"tier"`
+	Accrued   money.Amount `json:"accrued"`
+	ChangedAt string       `json:"changed_at"`
+}
+
+func newTierResponse(status *models.TierStatus) *TierResponse {
+	resp := &TierResponse{
+		Tier:       status.Current.Tier,
+		Multiplier: status.Current.Multiplier,
+		Accrued:    status.Accrued,
+		History:    make([]*TierHistoryEntry, len(status.History)),
+	}
+	if status.Next != nil {
+		resp.Next = &TierProgress{
+			Tier:       status.Next.Tier,
+			MinAccrued: status.Next.MinAccrued,
+			Remaining:  status.Remaining(),
+		}
+	}
+	for i, v := range status.History {
+		resp.History[i] = &TierHistoryEntry{
+			Tier:      v.Tier,
+			Accrued:   v.Accrued,
+			ChangedAt: v.ChangedAt.Format(time.RFC3339),
+		}
+	}
+	return resp
+}
+
+type TierRuleRequest struct {
+	MinAccrued money.Amount `json:"min_accrued"`
+	Multiplier money.Amount `json:"multiplier"`
+}
+
+// GetUserTier returns the user's tier, their progress to the next one and
+// their tier history, newest first.
+func GetUserTier(s TierStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			logger.FromContext(r.Context()).Error("Principal is missing")
+			http.Error(w, "Principal is missing", http.StatusInternalServerError)
+			return
+		}
+
+		status, err := s.GetUserTier(r.Context(), principal.ID)
+		if err == nil && status.Current == nil {
+			err = errors.New("no tier rules")
+		}
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error getting user tier", zap.Error(err))
+			http.Error(w, "Error getting user tier", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, newTierResponse(status))
+	}
+}
+
+// GetTierRules lists the tier rules from the lowest tier to the highest.
+func GetTierRules(s TierStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules, err := s.ListTierRules(r.Context())
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error listing tier rules", zap.Error(err))
+			http.Error(w, "Error listing tier rules", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, rules)
+	}
+}
+
+// UpdateTierRule changes the threshold and multiplier of a tier:
+// PUT /api/admin/tiers/{tier}
+func UpdateTierRule(s TierStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &TierRuleRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		rule := &models.TierRule{
+			Tier:       strings.ToUpper(chi.URLParam(r, "tier")),
+			MinAccrued: req.MinAccrued,
+			Multiplier: req.Multiplier,
+		}
+		if err := rule.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		updated, err := s.UpdateTierRule(r.Context(), rule)
+		if err != nil {
+			if errors.Is(err, storage.ErrTierNotFound) {
+				http.Error(w, "Tier not found", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, models.ErrTierRulesOutOfOrder) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			logger.FromContext(r.Context()).Error("Error updating tier rule", zap.Error(err))
+			http.Error(w, "Error updating tier rule", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
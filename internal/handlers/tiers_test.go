@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/handlers/mocks"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testTierRules() []*models.TierRule {
+	return []*models.TierRule{
+		{Tier: models.TierBronze, MinAccrued: 0, Multiplier: money.FromInt(1)},
+		{Tier: models.TierSilver, MinAccrued: money.FromInt(1000), Multiplier: money.MustParse("1.25")},
+		{Tier: models.TierGold, MinAccrued: money.FromInt(5000), Multiplier: money.MustParse("1.5")},
+	}
+}
+
+func TestGetUserTier(t *testing.T) {
+	uri := "/api/user/tier"
+	changedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	rules := testTierRules()
+
+	tests := []struct {
+		name     string
+		status   *models.TierStatus
+		err      error
+		wantCode int
+		want     *TierResponse
+	}{
+		{
+			name:     "ok",
+			status:   models.NewTierStatus(rules, money.FromInt(1500), []*models.TierChange{{Tier: models.TierSilver, Accrued: money.FromInt(1200), ChangedAt: changedAt}}),
+			wantCode: http.StatusOK,
+			want: &TierResponse{
+				Tier:       models.TierSilver,
+				Multiplier: money.MustParse("1.25"),
+				Accrued:    money.FromInt(1500),
+				Next:       &TierProgress{Tier: models.TierGold, MinAccrued: money.FromInt(5000), Remaining: money.FromInt(3500)},
+				History:    []*TierHistoryEntry{{Tier: models.TierSilver, Accrued: money.FromInt(1200), ChangedAt: "2024-03-01T12:00:00Z"}},
+			},
+		},
+		{
+			name:     "top tier",
+			status:   models.NewTierStatus(rules, money.FromInt(6000), []*models.TierChange{{Tier: models.TierGold, Accrued: money.FromInt(6000), ChangedAt: changedAt}}),
+			wantCode: http.StatusOK,
+			want: &TierResponse{
+				Tier:       models.TierGold,
+				Multiplier: money.MustParse("1.5"),
+				Accrued:    money.FromInt(6000),
+				History:    []*TierHistoryEntry{{Tier: models.TierGold, Accrued: money.FromInt(6000), ChangedAt: "2024-03-01T12:00:00Z"}},
+			},
+		},
+		{
+			name:     "no history",
+			status:   models.NewTierStatus(rules, 0, []*models.TierChange{}),
+			wantCode: http.StatusOK,
+			want: &TierResponse{
+				Tier:       models.TierBronze,
+				Multiplier: money.FromInt(1),
+				Next:       &TierProgress{Tier: models.TierSilver, MinAccrued: money.FromInt(1000), Remaining: money.FromInt(1000)},
+				History:    []*TierHistoryEntry{},
+			},
+		},
+		{
+			name:     "no tier rules",
+			status:   models.NewTierStatus(nil, 0, nil),
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name:     "unexpected error",
+			err:      errors.New("unexpected error"),
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewTierStorage(t)
+			s.On("GetUserTier", mock.Anything, 1).Return(tt.status, tt.err)
+
+			req := httptest.NewRequest(http.MethodGet, uri, nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: 1}))
+			w := httptest.NewRecorder()
+			GetUserTier(s)(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.want != nil {
+				resp := &TierResponse{}
+				require.NoError(t, json.NewDecoder(w.Body).Decode(resp))
+				assert.Equal(t, tt.want, resp)
+			}
+		})
+	}
+}
+
+func TestUpdateTierRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		tier     string
+		body     string
+		err      error
+		wantCode int
+	}{
+		{name: "ok", tier: "gold", body: `{"min_accrued": 4000, "multiplier": 1.75}`, wantCode: http.StatusOK},
+		{name: "not found", tier: "platinum", body: `{"min_accrued": 9000, "multiplier": 2}`, err: storage.ErrTierNotFound, wantCode: http.StatusNotFound},
+		{name: "out of order", tier: "gold", body: `{"min_accrued": 500, "multiplier": 1.75}`, err: models.ErrTierRulesOutOfOrder, wantCode: http.StatusConflict},
+		{name: "zero multiplier", tier: "gold", body: `{"min_accrued": 4000, "multiplier": 0}`, wantCode: http.StatusBadRequest},
+		{name: "negative threshold", tier: "gold", body: `{"min_accrued": -1, "multiplier": 1}`, wantCode: http.StatusBadRequest},
+		{name: "invalid body", tier: "gold", body: `{"min_accrued":`, wantCode: http.StatusBadRequest},
+		{name: "unexpected error", tier: "gold", body: `{"min_accrued": 4000, "multiplier": 1.75}`, err: errors.New("unexpected error"), wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewTierStorage(t)
+			if tt.wantCode != http.StatusBadRequest {
+				s.On("UpdateTierRule", mock.Anything, mock.MatchedBy(func(rule *models.TierRule) bool {
+					return rule.Tier == strings.ToUpper(tt.tier)
+				})).Return(func(_ context.Context, rule *models.TierRule) *models.TierRule {
+					return rule
+				}, tt.err)
+			}
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("tier", tt.tier)
+			req := httptest.NewRequest(http.MethodPut, "/api/admin/tiers/"+tt.tier, strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+			UpdateTierRule(s)(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusOK {
+				rule := &models.TierRule{}
+				require.NoError(t, json.NewDecoder(w.Body).Decode(rule))
+				assert.Equal(t, models.TierGold, rule.Tier)
+				assert.Equal(t, money.MustParse("1.75"), rule.Multiplier)
+			}
+		})
+	}
+}
@@ -2,26 +2,26 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/vindosVP/loyalty-system/internal/models"
 	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/internal/tracing"
 	"github.com/vindosVP/loyalty-system/pkg/logger"
 	"github.com/vindosVP/loyalty-system/pkg/passwords"
 	"github.com/vindosVP/loyalty-system/pkg/tokens"
 	"go.uber.org/zap"
 	"net/http"
-	"time"
 )
 
-func Login(s Storage, jwtSecret string) http.HandlerFunc {
+func Login(s Storage, cfg tokens.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		var buf bytes.Buffer
 		_, err := buf.ReadFrom(r.Body)
 		if err != nil {
-			logger.Log.Error("Error reading body", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error reading body", zap.Error(err))
 			http.Error(w, "Error reading body", http.StatusInternalServerError)
 			return
 		}
@@ -29,7 +29,7 @@ func Login(s Storage, jwtSecret string) http.HandlerFunc {
 		user := &models.User{}
 		err = json.Unmarshal(buf.Bytes(), &user)
 		if err != nil {
-			logger.Log.Error("Error unmarshalling body", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error unmarshalling body", zap.Error(err))
 			http.Error(w, "Error unmarshalling body", http.StatusInternalServerError)
 			return
 		}
@@ -42,40 +42,54 @@ func Login(s Storage, jwtSecret string) http.HandlerFunc {
 		gotUser, err := s.GetUserByLogin(r.Context(), user.Login)
 		if err != nil {
 			if errors.Is(err, storage.ErrUserNotFound) {
+				recordLoginFailure(r.Context(), s, user.Login, 0)
 				http.Error(w, "Invalid login or password", http.StatusUnauthorized)
 				return
 			}
-			logger.Log.Error("Error getting user", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error getting user", zap.Error(err))
 			http.Error(w, "Error getting user", http.StatusInternalServerError)
 			return
 		}
-		if !passwords.Compare(user.Pwd, gotUser.EncryptedPwd) {
+		_, span := tracing.Tracer.Start(r.Context(), "passwords.Compare")
+		matches := passwords.Compare(user.Pwd, gotUser.EncryptedPwd)
+		span.End()
+		if !matches {
+			recordLoginFailure(r.Context(), s, user.Login, gotUser.ID)
 			http.Error(w, "Invalid login or password", http.StatusUnauthorized)
 			return
 		}
 
-		token, err := tokens.CreateJWT(
-			tokens.JWTClaims(gotUser.ID, gotUser.Login, time.Now().Add(time.Hour*72).Unix()),
-			jwtSecret,
-		)
+		err = issueTokens(r.Context(), s, cfg, gotUser, w)
 		if err != nil {
-			logger.Log.Error("Error creating token", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error creating token", zap.Error(err))
 			http.Error(w, "Error creating token", http.StatusInternalServerError)
 			return
 		}
-
-		w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", token))
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
-func Register(s Storage, jwtSecret string) http.HandlerFunc {
+// recordLoginFailure audits a rejected login. It doesn't fail the request,
+// the caller has been rejected anyway.
+func recordLoginFailure(ctx context.Context, s Storage, login string, userID int) {
+	event, err := models.NewAuditEvent(models.AuditActionLoginFailed, models.AuditEntityUser, login).
+		WithChange(nil, map[string]interface{}{"login": login})
+	if err == nil {
+		event.UserID = userID
+		err = s.RecordAuditEvent(ctx, event)
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("Error recording failed login", zap.Error(err))
+	}
+}
+
+func Register(s Storage, cfg tokens.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		var buf bytes.Buffer
 		_, err := buf.ReadFrom(r.Body)
 		if err != nil {
-			logger.Log.Error("Error reading body", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error reading body", zap.Error(err))
 			http.Error(w, "Error reading body", http.StatusInternalServerError)
 			return
 		}
@@ -83,7 +97,7 @@ func Register(s Storage, jwtSecret string) http.HandlerFunc {
 		user := &models.User{}
 		err = json.Unmarshal(buf.Bytes(), &user)
 		if err != nil {
-			logger.Log.Error("Error unmarshalling body", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error unmarshalling body", zap.Error(err))
 			http.Error(w, "Error unmarshalling body", http.StatusInternalServerError)
 			return
 		}
@@ -95,7 +109,7 @@ func Register(s Storage, jwtSecret string) http.HandlerFunc {
 
 		foundUser, err := s.GetUserByLogin(r.Context(), user.Login)
 		if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
-			logger.Log.Error("Error getting user", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error getting user", zap.Error(err))
 			http.Error(w, "Error getting user", http.StatusInternalServerError)
 			return
 		}
@@ -104,9 +118,11 @@ func Register(s Storage, jwtSecret string) http.HandlerFunc {
 			return
 		}
 
+		_, span := tracing.Tracer.Start(r.Context(), "passwords.Encrypt")
 		encPwd, err := passwords.Encrypt(user.Pwd)
+		span.End()
 		if err != nil {
-			logger.Log.Error("Error encrypting password", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error encrypting password", zap.Error(err))
 			http.Error(w, "Error encrypting password", http.StatusInternalServerError)
 			return
 		}
@@ -119,33 +135,29 @@ func Register(s Storage, jwtSecret string) http.HandlerFunc {
 				http.Error(w, "User already exists", http.StatusConflict)
 				return
 			}
-			logger.Log.Error("Error creating user", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error creating user", zap.Error(err))
 			http.Error(w, "Error creating user", http.StatusInternalServerError)
 			return
 		}
 
-		token, err := tokens.CreateJWT(
-			tokens.JWTClaims(createdUser.ID, createdUser.Login, time.Now().Add(time.Hour*72).Unix()),
-			jwtSecret,
-		)
+		err = issueTokens(r.Context(), s, cfg, createdUser, w)
 		if err != nil {
-			logger.Log.Error("Error creating token", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error creating token", zap.Error(err))
 			http.Error(w, "Error creating token", http.StatusInternalServerError)
 			return
 		}
 
 		data, err := json.Marshal(createdUser)
 		if err != nil {
-			logger.Log.Error("Error marshalling user", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error marshalling user", zap.Error(err))
 			http.Error(w, "Error marshalling user", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", token))
 		_, err = w.Write(data)
 		if err != nil {
-			logger.Log.Error("Error writing response", zap.Error(err))
+			logger.FromContext(r.Context()).Error("Error writing response", zap.Error(err))
 			http.Error(w, "Error writing response", http.StatusInternalServerError)
 			return
 		}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -12,12 +14,15 @@ import (
 	"github.com/vindosVP/loyalty-system/pkg/tokens"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestRegister(t *testing.T) {
 	jwtSecret := "superSecret"
+	tokenCfg := tokens.Config{Secret: jwtSecret, AccessTTL: time.Minute, RefreshTTL: time.Hour}
 	uri := "/api/user/register"
 
 	type request struct {
@@ -179,8 +184,14 @@ func TestRegister(t *testing.T) {
 				s.On("CreateUser", mock.Anything, mock.Anything).Return(tt.createUserMock.result, tt.createUserMock.err)
 			}
 
+			if tt.want.checkJWT {
+				s.On("CreateSession", mock.Anything, mock.Anything).Return(func(ctx context.Context, session *models.Session) *models.Session {
+					return session
+				}, nil)
+			}
+
 			r := chi.NewRouter()
-			r.Post(uri, Register(s, jwtSecret))
+			r.Post(uri, Register(s, tokenCfg))
 
 			req := httptest.NewRequest(tt.request.method, uri, strings.NewReader(tt.request.body))
 			w := httptest.NewRecorder()
@@ -197,13 +208,14 @@ func TestRegister(t *testing.T) {
 				require.Len(t, splitToken, 2)
 				token := splitToken[1]
 
-				valid, err := tokens.IsAuthorized(token, jwtSecret)
+				claims, err := tokens.ParseClaims(token, jwtSecret)
 				require.NoError(t, err)
-				assert.True(t, valid)
+				assert.Equal(t, tt.want.userID, strconv.Itoa(claims.ID))
 
-				id, err := tokens.ExtractID(token, jwtSecret)
+				refreshToken, err := tokens.ParseRefreshToken(res.Header.Get(RefreshTokenHeader))
 				require.NoError(t, err)
-				assert.Equal(t, tt.want.userID, id)
+				assert.NotEmpty(t, claims.SessionID)
+				assert.Equal(t, claims.SessionID, refreshToken.SessionID)
 			}
 		})
 	}
@@ -211,6 +223,7 @@ func TestRegister(t *testing.T) {
 
 func TestLogin(t *testing.T) {
 	jwtSecret := "superSecret"
+	tokenCfg := tokens.Config{Secret: jwtSecret, AccessTTL: time.Minute, RefreshTTL: time.Hour}
 	uri := "/api/user/login"
 	encryptedSomePassword, _ := passwords.Encrypt("somePassword")
 
@@ -228,12 +241,17 @@ func TestLogin(t *testing.T) {
 		result *models.User
 		err    error
 	}
+	type recordAuditEventMock struct {
+		needed bool
+		err    error
+	}
 
 	tests := []struct {
-		name               string
-		getUserByLoginMock getUserByLoginMock
-		request            request
-		want               want
+		name                 string
+		getUserByLoginMock   getUserByLoginMock
+		recordAuditEventMock recordAuditEventMock
+		request              request
+		want                 want
 	}{
 		{
 			name: "ok",
@@ -314,6 +332,10 @@ func TestLogin(t *testing.T) {
 				result: nil,
 				err:    storage.ErrUserNotFound,
 			},
+			recordAuditEventMock: recordAuditEventMock{
+				needed: true,
+				err:    nil,
+			},
 			request: request{
 				method: http.MethodPost,
 				body:   "{\"login\": \"someLogin\",\"password\": \"password\"}",
@@ -335,6 +357,35 @@ func TestLogin(t *testing.T) {
 				},
 				err: nil,
 			},
+			recordAuditEventMock: recordAuditEventMock{
+				needed: true,
+				err:    nil,
+			},
+			request: request{
+				method: http.MethodPost,
+				body:   "{\"login\": \"someLogin\",\"password\": \"somePassword\"}",
+			},
+			want: want{
+				code:     http.StatusUnauthorized,
+				checkJWT: false,
+				userID:   "",
+			},
+		},
+		{
+			name: "wrong password audit error",
+			getUserByLoginMock: getUserByLoginMock{
+				needed: true,
+				result: &models.User{
+					ID:           1,
+					Login:        "someLogin",
+					EncryptedPwd: "someWrongPassword",
+				},
+				err: nil,
+			},
+			recordAuditEventMock: recordAuditEventMock{
+				needed: true,
+				err:    errors.New("unexpected error"),
+			},
 			request: request{
 				method: http.MethodPost,
 				body:   "{\"login\": \"someLogin\",\"password\": \"somePassword\"}",
@@ -353,9 +404,20 @@ func TestLogin(t *testing.T) {
 			if tt.getUserByLoginMock.needed {
 				s.On("GetUserByLogin", mock.Anything, mock.Anything).Return(tt.getUserByLoginMock.result, tt.getUserByLoginMock.err)
 			}
+			if tt.recordAuditEventMock.needed {
+				s.On("RecordAuditEvent", mock.Anything, mock.MatchedBy(func(event *models.AuditEvent) bool {
+					return event.Action == models.AuditActionLoginFailed && event.EntityID == "someLogin"
+				})).Return(tt.recordAuditEventMock.err)
+			}
+
+			if tt.want.checkJWT {
+				s.On("CreateSession", mock.Anything, mock.Anything).Return(func(ctx context.Context, session *models.Session) *models.Session {
+					return session
+				}, nil)
+			}
 
 			r := chi.NewRouter()
-			r.Post(uri, Login(s, jwtSecret))
+			r.Post(uri, Login(s, tokenCfg))
 			req := httptest.NewRequest(tt.request.method, uri, strings.NewReader(tt.request.body))
 			w := httptest.NewRecorder()
 			r.ServeHTTP(w, req)
@@ -371,13 +433,14 @@ func TestLogin(t *testing.T) {
 				require.Len(t, splitToken, 2)
 				token := splitToken[1]
 
-				valid, err := tokens.IsAuthorized(token, jwtSecret)
+				claims, err := tokens.ParseClaims(token, jwtSecret)
 				require.NoError(t, err)
-				assert.True(t, valid)
+				assert.Equal(t, tt.want.userID, strconv.Itoa(claims.ID))
 
-				id, err := tokens.ExtractID(token, jwtSecret)
+				refreshToken, err := tokens.ParseRefreshToken(res.Header.Get(RefreshTokenHeader))
 				require.NoError(t, err)
-				assert.Equal(t, tt.want.userID, id)
+				assert.NotEmpty(t, claims.SessionID)
+				assert.Equal(t, claims.SessionID, refreshToken.SessionID)
 			}
 		})
 	}
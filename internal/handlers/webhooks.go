@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultDeliveriesLimit = 100
+	maxDeliveriesLimit     = 1000
+)
+
+type WebhookRequest struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description"`
+}
+
+type ReplayResponse struct {
+	Replayed int64 `json:"replayed"`
+}
+
+// CreateWebhook registers an endpoint for the user's own events. The secret
+// for verifying signatures is only returned here.
+func CreateWebhook(s WebhookStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			logger.FromContext(r.Context()).Error("Principal is missing")
+			http.Error(w, "Principal is missing", http.StatusInternalServerError)
+			return
+		}
+		createWebhook(w, r, s, &principal.ID)
+	}
+}
+
+// CreatePartnerWebhook registers an endpoint that gets every user's events.
+func CreatePartnerWebhook(s WebhookStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		createWebhook(w, r, s, nil)
+	}
+}
+
+func createWebhook(w http.ResponseWriter, r *http.Request, s WebhookStorage, userID *int) {
+	req := &WebhookRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	endpoint := &models.WebhookEndpoint{
+		UserID:      userID,
+		URL:         req.URL,
+		Events:      req.Events,
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+	}
+	if err := endpoint.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	secret, err := models.NewWebhookSecret()
+	if err != nil {
+		logger.FromContext(r.Context()).Error("Error generating webhook secret", zap.Error(err))
+		http.Error(w, "Error generating webhook secret", http.StatusInternalServerError)
+		return
+	}
+	endpoint.Secret = secret
+
+	created, err := s.CreateWebhookEndpoint(r.Context(), endpoint)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("Error creating webhook", zap.Error(err))
+		http.Error(w, "Error creating webhook", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func GetWebhooks(s WebhookStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			logger.FromContext(r.Context()).Error("Principal is missing")
+			http.Error(w, "Principal is missing", http.StatusInternalServerError)
+			return
+		}
+		getWebhooks(w, r, s, principal.ID)
+	}
+}
+
+func GetPartnerWebhooks(s WebhookStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		getWebhooks(w, r, s, 0)
+	}
+}
+
+func getWebhooks(w http.ResponseWriter, r *http.Request, s WebhookStorage, userID int) {
+	endpoints, err := s.ListWebhookEndpoints(r.Context(), userID)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("Error getting webhooks", zap.Error(err))
+		http.Error(w, "Error getting webhooks", http.StatusInternalServerError)
+		return
+	}
+	if len(endpoints) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	for _, e := range endpoints {
+		e.Secret = ""
+	}
+	writeJSON(w, http.StatusOK, endpoints)
+}
+
+// DeleteWebhook deletes one of the user's endpoints along with its deliveries.
+func DeleteWebhook(s WebhookStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			logger.FromContext(r.Context()).Error("Principal is missing")
+			http.Error(w, "Principal is missing", http.StatusInternalServerError)
+			return
+		}
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid webhook id", http.StatusBadRequest)
+			return
+		}
+		writeDeleteResult(w, s.DeleteUsersWebhookEndpoint(r.Context(), principal.ID, id))
+	}
+}
+
+// AdminDeleteWebhook deletes any endpoint, a partner's or a user's.
+func AdminDeleteWebhook(s WebhookStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid webhook id", http.StatusBadRequest)
+			return
+		}
+		writeDeleteResult(w, s.DeleteWebhookEndpoint(r.Context(), id))
+	}
+}
+
+func writeDeleteResult(w http.ResponseWriter, err error) {
+	if err != nil {
+		if errors.Is(err, storage.ErrWebhookEndpointNotFound) {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		logger.Log.Error("Error deleting webhook", zap.Error(err))
+		http.Error(w, "Error deleting webhook", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries lists deliveries newest first:
+// GET /api/admin/webhooks/deliveries?endpoint_id=&state=&before_id=&limit=
+func GetWebhookDeliveries(s WebhookStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		filter := models.WebhookDeliveryFilter{State: q.Get("state"), Limit: defaultDeliveriesLimit}
+		var err error
+		if filter.State != "" && !contains(models.DeliveryStates, filter.State) {
+			http.Error(w, "Invalid state", http.StatusBadRequest)
+			return
+		}
+		if v := q.Get("endpoint_id"); v != "" {
+			if filter.EndpointID, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "Invalid endpoint id", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("before_id"); v != "" {
+			if filter.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil {
+				http.Error(w, "Invalid before id", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("limit"); v != "" {
+			filter.Limit, err = strconv.Atoi(v)
+			if err != nil || filter.Limit <= 0 || filter.Limit > maxDeliveriesLimit {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		deliveries, err := s.ListWebhookDeliveries(r.Context(), filter)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error getting webhook deliveries", zap.Error(err))
+			http.Error(w, "Error getting webhook deliveries", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, deliveries)
+	}
+}
+
+// ReplayWebhookDelivery resends a dead-lettered delivery.
+func ReplayWebhookDelivery(s WebhookStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid delivery id", http.StatusBadRequest)
+			return
+		}
+		replayed, err := s.ReplayWebhookDeliveries(r.Context(), 0, id)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error replaying webhook delivery", zap.Error(err))
+			http.Error(w, "Error replaying webhook delivery", http.StatusInternalServerError)
+			return
+		}
+		if replayed == 0 {
+			http.Error(w, "No dead-lettered delivery with this id", http.StatusNotFound)
+			return
+		}
+		logger.FromContext(r.Context()).Info("Webhook delivery replayed", zap.Int64("delivery", id), zap.Int("by", principalID(r)))
+		writeJSON(w, http.StatusOK, &ReplayResponse{Replayed: replayed})
+	}
+}
+
+// ReplayWebhookEndpoint resends every dead-lettered delivery of an endpoint,
+// e.g. after the partner fixed an outage.
+func ReplayWebhookEndpoint(s WebhookStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid webhook id", http.StatusBadRequest)
+			return
+		}
+		replayed, err := s.ReplayWebhookDeliveries(r.Context(), id, 0)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Error replaying webhook deliveries", zap.Error(err))
+			http.Error(w, "Error replaying webhook deliveries", http.StatusInternalServerError)
+			return
+		}
+		logger.FromContext(r.Context()).Info("Webhook deliveries replayed", zap.Int("endpoint", id), zap.Int64("replayed", replayed), zap.Int("by", principalID(r)))
+		writeJSON(w, http.StatusOK, &ReplayResponse{Replayed: replayed})
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/handlers/mocks"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	uri := "/api/user/webhooks"
+
+	type createMock struct {
+		needed bool
+		err    error
+	}
+	tests := []struct {
+		name       string
+		body       string
+		createMock createMock
+		wantCode   int
+	}{
+		{
+			name:       "ok",
+			body:       `{"url": "https://shop.example.com/hooks", "events": ["order.status_changed"]}`,
+			createMock: createMock{needed: true},
+			wantCode:   http.StatusCreated,
+		},
+		{
+			name:     "invalid url",
+			body:     `{"url": "shop.example.com", "events": ["order.status_changed"]}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "unknown event",
+			body:     `{"url": "https://shop.example.com/hooks", "events": ["order.deleted"]}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid body",
+			body:     `{"url":`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:       "unexpected error",
+			body:       `{"url": "https://shop.example.com/hooks", "events": ["balance.withdrawn"]}`,
+			createMock: createMock{needed: true, err: errors.New("unexpected error")},
+			wantCode:   http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewWebhookStorage(t)
+			if tt.createMock.needed {
+				s.On("CreateWebhookEndpoint", mock.Anything, mock.MatchedBy(func(e *models.WebhookEndpoint) bool {
+					return e.UserID != nil && *e.UserID == 1 && strings.HasPrefix(e.Secret, "whsec_")
+				})).Return(func(_ context.Context, e *models.WebhookEndpoint) *models.WebhookEndpoint {
+					created := *e
+					created.ID = 10
+					return &created
+				}, tt.createMock.err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, uri, strings.NewReader(tt.body))
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: 1}))
+			w := httptest.NewRecorder()
+			CreateWebhook(s)(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusCreated {
+				var resp models.WebhookEndpoint
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, 10, resp.ID)
+				assert.NotEmpty(t, resp.Secret)
+			}
+		})
+	}
+}
+
+func TestGetWebhooks_HidesSecrets(t *testing.T) {
+	s := mocks.NewWebhookStorage(t)
+	s.On("ListWebhookEndpoints", mock.Anything, 0).Return([]*models.WebhookEndpoint{
+		{ID: 1, URL: "https://partner.example.com/hooks", Secret: "whsec_secret", Events: []string{models.WebhookEventOrderStatusChanged}},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	GetPartnerWebhooks(s)(w, httptest.NewRequest(http.MethodGet, "/api/admin/webhooks", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "whsec_secret")
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		err      error
+		wantCode int
+	}{
+		{name: "ok", id: "10", wantCode: http.StatusNoContent},
+		{name: "not found", id: "10", err: storage.ErrWebhookEndpointNotFound, wantCode: http.StatusNotFound},
+		{name: "unexpected error", id: "10", err: errors.New("unexpected error"), wantCode: http.StatusInternalServerError},
+		{name: "invalid id", id: "abc", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewWebhookStorage(t)
+			if tt.wantCode != http.StatusBadRequest {
+				s.On("DeleteUsersWebhookEndpoint", mock.Anything, 1, 10).Return(tt.err)
+			}
+			r := chi.NewRouter()
+			r.Delete("/api/user/webhooks/{id}", DeleteWebhook(s))
+			req := httptest.NewRequest(http.MethodDelete, "/api/user/webhooks/"+tt.id, nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: 1}))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
+
+func TestReplayWebhookDelivery(t *testing.T) {
+	tests := []struct {
+		name     string
+		replayed int64
+		err      error
+		wantCode int
+	}{
+		{name: "ok", replayed: 1, wantCode: http.StatusOK},
+		{name: "not dead-lettered", replayed: 0, wantCode: http.StatusNotFound},
+		{name: "unexpected error", err: errors.New("unexpected error"), wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewWebhookStorage(t)
+			s.On("ReplayWebhookDeliveries", mock.Anything, 0, int64(7)).Return(tt.replayed, tt.err)
+			r := chi.NewRouter()
+			r.Post("/api/admin/webhooks/deliveries/{id}/replay", ReplayWebhookDelivery(s))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/webhooks/deliveries/7/replay", nil))
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
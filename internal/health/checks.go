@@ -0,0 +1,52 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrMigrationsPending = errors.New("migrations pending")
+	ErrStale             = errors.New("no successful tick")
+)
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping checks a dependency that can be pinged, like the pool or the accrual system.
+func Ping(p Pinger) CheckFunc {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		return nil, p.Ping(ctx)
+	}
+}
+
+// Migrations fails while migrations are pending. pending returns the
+// versions that aren't applied.
+func Migrations(pending func(ctx context.Context) ([]int64, error)) CheckFunc {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		versions, err := pending(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) > 0 {
+			return map[string]interface{}{"pending": versions}, fmt.Errorf("%w: %d", ErrMigrationsPending, len(versions))
+		}
+		return nil, nil
+	}
+}
+
+// Tick fails if last returns a time more than maxAge ago, e.g. because the
+// goroutine ticking has died or is stuck.
+func Tick(last func() time.Time, maxAge time.Duration) CheckFunc {
+	return func(context.Context) (map[string]interface{}, error) {
+		t := last()
+		details := map[string]interface{}{"lastTick": t}
+		if age := time.Since(t); age > maxAge {
+			return details, fmt.Errorf("%w for %s", ErrStale, age.Truncate(time.Second))
+		}
+		return details, nil
+	}
+}
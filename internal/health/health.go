@@ -0,0 +1,105 @@
+// Package health reports whether the service can take traffic. Every
+// registered component is checked concurrently on each readiness probe.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+)
+
+// CheckFunc checks a component. The details are reported whether the check
+// fails or not.
+type CheckFunc func(ctx context.Context) (map[string]interface{}, error)
+
+type Component struct {
+	Status   string                 `json:"status"`
+	Critical bool                   `json:"critical"`
+	Error    string                 `json:"error,omitempty"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+}
+
+type Report struct {
+	Status     string                `json:"status"`
+	Draining   bool                  `json:"draining"`
+	Components map[string]*Component `json:"components"`
+}
+
+func (r *Report) Ready() bool {
+	return r.Status == StatusReady
+}
+
+type check struct {
+	name     string
+	critical bool
+	f        CheckFunc
+}
+
+type Checker struct {
+	timeout  time.Duration
+	checks   []check
+	draining atomic.Bool
+}
+
+// New returns a Checker that gives each check up to timeout.
+func New(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Register adds a component. A failing critical component makes the service
+// not ready, others are only reported.
+func (c *Checker) Register(name string, critical bool, f CheckFunc) {
+	c.checks = append(c.checks, check{name: name, critical: critical, f: f})
+}
+
+// Drain makes the service not ready for the rest of its life.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+func (c *Checker) Check(ctx context.Context) *Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	report := &Report{
+		Status:     StatusReady,
+		Draining:   c.draining.Load(),
+		Components: make(map[string]*Component, len(c.checks)),
+	}
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, chk := range c.checks {
+		wg.Add(1)
+		go func(chk check) {
+			defer wg.Done()
+			details, err := chk.f(ctx)
+			component := &Component{Status: StatusUp, Critical: chk.critical, Details: details}
+			if err != nil {
+				component.Status, component.Error = StatusDown, err.Error()
+			}
+			mu.Lock()
+			report.Components[chk.name] = component
+			mu.Unlock()
+		}(chk)
+	}
+	wg.Wait()
+
+	if report.Draining {
+		report.Status = StatusNotReady
+	}
+	for _, component := range report.Components {
+		if component.Critical && component.Status == StatusDown {
+			report.Status = StatusNotReady
+		}
+	}
+	return report
+}
@@ -0,0 +1,77 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestChecker_Check(t *testing.T) {
+	up := func(context.Context) (map[string]interface{}, error) { return nil, nil }
+	down := func(context.Context) (map[string]interface{}, error) { return nil, errors.New("connection refused") }
+
+	tests := []struct {
+		name       string
+		critical   CheckFunc
+		optional   CheckFunc
+		drain      bool
+		wantStatus string
+	}{
+		{name: "all up", critical: up, optional: up, wantStatus: StatusReady},
+		{name: "optional down", critical: up, optional: down, wantStatus: StatusReady},
+		{name: "critical down", critical: down, optional: up, wantStatus: StatusNotReady},
+		{name: "draining", critical: up, optional: up, drain: true, wantStatus: StatusNotReady},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(time.Second)
+			c.Register("database", true, tt.critical)
+			c.Register("accrual", false, tt.optional)
+			if tt.drain {
+				c.Drain()
+			}
+
+			report := c.Check(context.Background())
+			assert.Equal(t, tt.wantStatus, report.Status)
+			assert.Equal(t, tt.drain, report.Draining)
+			require.Len(t, report.Components, 2)
+			assert.True(t, report.Components["database"].Critical)
+			assert.False(t, report.Components["accrual"].Critical)
+		})
+	}
+}
+
+func TestChecker_Check_Timeout(t *testing.T) {
+	c := New(10 * time.Millisecond)
+	c.Register("database", true, func(ctx context.Context) (map[string]interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	report := c.Check(context.Background())
+	assert.Equal(t, StatusNotReady, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Components["database"].Error)
+}
+
+func TestMigrations(t *testing.T) {
+	details, err := Migrations(func(context.Context) ([]int64, error) { return []int64{10, 11}, nil })(context.Background())
+	assert.ErrorIs(t, err, ErrMigrationsPending)
+	assert.Equal(t, []int64{10, 11}, details["pending"])
+
+	_, err = Migrations(func(context.Context) ([]int64, error) { return nil, nil })(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestTick(t *testing.T) {
+	_, err := Tick(func() time.Time { return time.Now().Add(-time.Second) }, time.Minute)(context.Background())
+	assert.NoError(t, err)
+
+	_, err = Tick(func() time.Time { return time.Now().Add(-time.Hour) }, time.Minute)(context.Background())
+	assert.ErrorIs(t, err, ErrStale)
+
+	_, err = Tick(func() time.Time { return time.Time{} }, time.Minute)(context.Background())
+	assert.ErrorIs(t, err, ErrStale)
+}
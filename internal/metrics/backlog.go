@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+const backlogTimeout = 5 * time.Second
+
+// BacklogCounter counts orders by status.
+type BacklogCounter interface {
+	CountOrdersByStatus(ctx context.Context, statuses ...string) (map[string]int64, error)
+}
+
+// BacklogCollector exports the number of orders waiting for the accrual
+// system, counted on every scrape.
+type BacklogCollector struct {
+	counter  BacklogCounter
+	statuses []string
+	desc     *prometheus.Desc
+}
+
+func NewBacklogCollector(counter BacklogCounter, statuses ...string) *BacklogCollector {
+	return &BacklogCollector{
+		counter:  counter,
+		statuses: statuses,
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "processor", "backlog_orders"),
+			"Orders waiting for the accrual system by status.", []string{"status"}, nil),
+	}
+}
+
+func (c *BacklogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect skips the metric if the orders couldn't be counted, so a database
+// outage shows up as missing data rather than an empty backlog.
+func (c *BacklogCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), backlogTimeout)
+	defer cancel()
+	counts, err := c.counter.CountOrdersByStatus(ctx, c.statuses...)
+	if err != nil {
+		logger.Log.Error("Failed to count order backlog", zap.Error(err))
+		return
+	}
+	for _, status := range c.statuses {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(counts[status]), status)
+	}
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+type backlogCounterFunc func(ctx context.Context, statuses ...string) (map[string]int64, error)
+
+func (f backlogCounterFunc) CountOrdersByStatus(ctx context.Context, statuses ...string) (map[string]int64, error) {
+	return f(ctx, statuses...)
+}
+
+func TestBacklogCollector(t *testing.T) {
+	c := NewBacklogCollector(backlogCounterFunc(func(_ context.Context, statuses ...string) (map[string]int64, error) {
+		assert.Equal(t, []string{"NEW", "PROCESSING"}, statuses)
+		return map[string]int64{"NEW": 3}, nil
+	}), "NEW", "PROCESSING")
+
+	want := `
+# HELP gophermart_processor_backlog_orders Orders waiting for the accrual system by status.
+# TYPE gophermart_processor_backlog_orders gauge
+gophermart_processor_backlog_orders{status="NEW"} 3
+gophermart_processor_backlog_orders{status="PROCESSING"} 0
+`
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(want)))
+}
+
+func TestBacklogCollector_Error(t *testing.T) {
+	c := NewBacklogCollector(backlogCounterFunc(func(context.Context, ...string) (map[string]int64, error) {
+		return nil, errors.New("unexpected error")
+	}), "NEW")
+	assert.Equal(t, 0, testutil.CollectAndCount(c))
+}
@@ -1,21 +1,45 @@
 package middleware
 
 import (
+	"context"
 	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
 	"github.com/vindosVP/loyalty-system/pkg/tokens"
+	"go.uber.org/zap"
 	"net/http"
 )
 
+// identityHeaders used to carry the caller's identity before it moved to the
+// request context. Clients must not be able to inject them.
+var identityHeaders = []string{"x-user-id", "x-user-login", "x-user-roles", "x-session-id"}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Sessions
+type Sessions interface {
+	IsSessionActive(ctx context.Context, id string) (bool, error)
+}
+
 type Authenticator struct {
-	secret string
+	secret   string
+	sessions Sessions
 }
 
-func NewAuthenticator(secret string) *Authenticator {
-	return &Authenticator{secret: secret}
+func NewAuthenticator(secret string, sessions Sessions) *Authenticator {
+	return &Authenticator{secret: secret, sessions: sessions}
 }
 
-func (a *Authenticator) WithAuth(next http.Handler) http.Handler {
+// StripIdentityHeaders removes identity headers sent by the client so that
+// neither handlers nor upstream proxies can be fooled by them.
+func StripIdentityHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, h := range identityHeaders {
+			r.Header.Del(h)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Authenticator) WithAuth(next http.Handler) http.Handler {
+	return StripIdentityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		token, err := auth.ParseBearerToken(r)
 		if err != nil {
@@ -23,19 +47,34 @@ func (a *Authenticator) WithAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		authorized, err := tokens.IsAuthorized(token, a.secret)
-		if err != nil || !authorized {
+		claims, err := tokens.ParseClaims(token, a.secret)
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
+		if claims.SessionID == "" {
+			http.Error(w, "token is not bound to a session", http.StatusUnauthorized)
+			return
+		}
 
-		userID, err := tokens.ExtractID(token, a.secret)
+		active, err := a.sessions.IsSessionActive(r.Context(), claims.SessionID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			logger.FromContext(r.Context()).Error("Error checking session", zap.Error(err))
+			http.Error(w, "Error checking session", http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			http.Error(w, "Session is revoked", http.StatusUnauthorized)
 			return
 		}
 
-		r.Header.Set("x-user-id", userID)
-		next.ServeHTTP(w, r)
-	})
+		principal := &auth.Principal{
+			ID:        claims.ID,
+			Login:     claims.Login,
+			Roles:     claims.Roles,
+			SessionID: claims.SessionID,
+			Scopes:    claims.Scopes,
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}))
 }
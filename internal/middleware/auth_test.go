@@ -2,20 +2,28 @@ package middleware
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/middleware/mocks"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
 	"github.com/vindosVP/loyalty-system/pkg/tokens"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 )
 
 func TestAuthenticator_WithAuth(t *testing.T) {
 	type AuthTestResponse struct {
-		UserID string
+		UserID        string
+		Login         string
+		SessionID     string
+		SpoofedUserID string
 	}
 	JWTSecret := "superSecret"
 	uri := "/testAuth"
@@ -24,9 +32,15 @@ func TestAuthenticator_WithAuth(t *testing.T) {
 		ID    int
 		Login string
 	}
-	type auth struct {
+	type authorization struct {
 		addHeader bool
 		schema    string
+		noSession bool
+	}
+	type isSessionActiveMock struct {
+		needed bool
+		result bool
+		err    error
 	}
 	type want struct {
 		code   int
@@ -34,17 +48,23 @@ func TestAuthenticator_WithAuth(t *testing.T) {
 	}
 
 	tests := []struct {
-		name string
-		auth auth
-		user user
-		want want
+		name                string
+		auth                authorization
+		isSessionActiveMock isSessionActiveMock
+		user                user
+		want                want
 	}{
 		{
 			name: "ok",
-			auth: auth{
+			auth: authorization{
 				addHeader: true,
 				schema:    "Bearer",
 			},
+			isSessionActiveMock: isSessionActiveMock{
+				needed: true,
+				result: true,
+				err:    nil,
+			},
 			user: user{
 				ID:    1,
 				Login: "someLogin",
@@ -52,13 +72,15 @@ func TestAuthenticator_WithAuth(t *testing.T) {
 			want: want{
 				code: http.StatusOK,
 				result: AuthTestResponse{
-					UserID: "1",
+					UserID:    "1",
+					Login:     "someLogin",
+					SessionID: "someSession",
 				},
 			},
 		},
 		{
 			name: "no auth header",
-			auth: auth{
+			auth: authorization{
 				addHeader: false,
 				schema:    "Bearer",
 			},
@@ -75,7 +97,7 @@ func TestAuthenticator_WithAuth(t *testing.T) {
 		},
 		{
 			name: "wrong schema",
-			auth: auth{
+			auth: authorization{
 				addHeader: false,
 				schema:    "Basic",
 			},
@@ -90,6 +112,59 @@ func TestAuthenticator_WithAuth(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "revoked session",
+			auth: authorization{
+				addHeader: true,
+				schema:    "Bearer",
+			},
+			isSessionActiveMock: isSessionActiveMock{
+				needed: true,
+				result: false,
+				err:    nil,
+			},
+			user: user{
+				ID:    1,
+				Login: "someLogin",
+			},
+			want: want{
+				code: http.StatusUnauthorized,
+			},
+		},
+		{
+			name: "session check unexpected error",
+			auth: authorization{
+				addHeader: true,
+				schema:    "Bearer",
+			},
+			isSessionActiveMock: isSessionActiveMock{
+				needed: true,
+				result: false,
+				err:    errors.New("unexpected error"),
+			},
+			user: user{
+				ID:    1,
+				Login: "someLogin",
+			},
+			want: want{
+				code: http.StatusInternalServerError,
+			},
+		},
+		{
+			name: "token without session",
+			auth: authorization{
+				addHeader: true,
+				schema:    "Bearer",
+				noSession: true,
+			},
+			user: user{
+				ID:    1,
+				Login: "someLogin",
+			},
+			want: want{
+				code: http.StatusUnauthorized,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -97,24 +172,38 @@ func TestAuthenticator_WithAuth(t *testing.T) {
 
 			handler := func() http.HandlerFunc {
 				return func(w http.ResponseWriter, r *http.Request) {
-					userID := r.Header.Get("x-user-id")
-					resp := AuthTestResponse{UserID: userID}
+					principal, ok := auth.PrincipalFromContext(r.Context())
+					require.True(t, ok)
+					resp := AuthTestResponse{
+						UserID:        strconv.Itoa(principal.ID),
+						Login:         principal.Login,
+						SessionID:     principal.SessionID,
+						SpoofedUserID: r.Header.Get("x-user-id"),
+					}
 					data, _ := json.Marshal(&resp)
 					_, _ = w.Write(data)
 					w.WriteHeader(http.StatusOK)
 				}
 			}
 
-			a := NewAuthenticator(JWTSecret)
+			sessions := mocks.NewSessions(t)
+			if tt.isSessionActiveMock.needed {
+				sessions.On("IsSessionActive", mock.Anything, "someSession").Return(tt.isSessionActiveMock.result, tt.isSessionActiveMock.err)
+			}
+			a := NewAuthenticator(JWTSecret, sessions)
 
 			r := chi.NewRouter()
 			r.Use(a.WithAuth)
 			r.Get(uri, handler())
 
 			req := httptest.NewRequest("GET", uri, nil)
+			req.Header.Set("x-user-id", "42")
 			if tt.auth.addHeader {
-				token, err := tokens.CreateJWT(
-					tokens.JWTClaims(tt.user.ID, tt.user.Login, time.Now().Add(time.Hour*72).Unix()), JWTSecret)
+				claims := tokens.SessionClaims(tt.user.ID, tt.user.Login, "someSession", time.Now().Add(time.Hour*72).Unix())
+				if tt.auth.noSession {
+					claims = tokens.JWTClaims(tt.user.ID, tt.user.Login, time.Now().Add(time.Hour*72).Unix())
+				}
+				token, err := tokens.CreateJWT(claims, JWTSecret)
 				require.NoError(t, err)
 				req.Header.Set("Authorization", fmt.Sprintf("%s %s", tt.auth.schema, token))
 			}
@@ -133,3 +222,23 @@ func TestAuthenticator_WithAuth(t *testing.T) {
 		})
 	}
 }
+
+func TestStripIdentityHeaders(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(StripIdentityHeaders)
+	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
+		for _, h := range identityHeaders {
+			assert.Empty(t, r.Header.Get(h), h)
+		}
+		assert.Equal(t, "value", r.Header.Get("x-other"))
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("x-user-id", "1")
+	req.Header.Set("X-Session-Id", "someSession")
+	req.Header.Set("x-other", "value")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotentRequestBytes = 1 << 20
+)
+
+// unstoredHeaders are set by the compression middleware around this one for
+// the compressed body, while the stored body is the uncompressed one.
+var unstoredHeaders = []string{"Content-Encoding", "Content-Length", "Vary"}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=IdempotencyStore
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error
+	ReleaseIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error
+}
+
+// Idempotency makes requests carrying an Idempotency-Key header safe to retry.
+// The first response for a key is stored for ttl and replayed for every retry
+// with the same body; reusing the key for a different request is rejected.
+// Responses with a 5xx status aren't stored, so the request can be retried.
+// A request in progress holds its key for lease: the handler is cancelled when
+// the lease runs out, and a retry after that takes the key over, so a key isn't
+// stuck if the process dies mid-request.
+// Keys are scoped to the user, so it must be used after WithAuth.
+func Idempotency(store IdempotencyStore, ttl time.Duration, lease time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			value := r.Header.Get(IdempotencyKeyHeader)
+			if value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if err := models.ValidateIdempotencyKey(value); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			principal, ok := auth.PrincipalFromContext(r.Context())
+			if !ok {
+				logger.FromContext(r.Context()).Error("Principal is missing")
+				http.Error(w, "Principal is missing", http.StatusInternalServerError)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentRequestBytes+1))
+			if err != nil {
+				logger.FromContext(r.Context()).Error("Error reading body", zap.Error(err))
+				http.Error(w, "Error reading body", http.StatusInternalServerError)
+				return
+			}
+			if len(body) > maxIdempotentRequestBytes {
+				http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			now := time.Now()
+			key := &models.IdempotencyKey{
+				UserID:      principal.ID,
+				Key:         value,
+				Fingerprint: models.RequestFingerprint(r.Method, r.URL.Path, body),
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(ttl),
+				LockedUntil: now.Add(lease),
+			}
+			stored, reserved, err := store.ReserveIdempotencyKey(r.Context(), key)
+			if err != nil {
+				logger.FromContext(r.Context()).Error("Error reserving idempotency key", zap.Error(err))
+				http.Error(w, "Error reserving idempotency key", http.StatusInternalServerError)
+				return
+			}
+			if !reserved {
+				replay(w, key, stored)
+				return
+			}
+
+			// Completing or releasing the key is a no-op once a retry has taken
+			// it over, which the stored reservation time tells.
+			key.CreatedAt = stored.CreatedAt
+			handlerCtx, cancel := context.WithTimeout(r.Context(), lease)
+			defer cancel()
+			// The outcome is saved even if the client has gone away, since
+			// that's exactly when it's going to retry.
+			ctx := context.WithoutCancel(r.Context())
+			rec := &responseRecorder{ResponseWriter: w}
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				if err := store.ReleaseIdempotencyKey(ctx, key); err != nil {
+					logger.FromContext(r.Context()).Error("Error releasing idempotency key", zap.Error(err))
+				}
+			}()
+			next.ServeHTTP(rec, r.WithContext(handlerCtx))
+
+			if rec.status() >= http.StatusInternalServerError {
+				return
+			}
+			key.StatusCode = rec.status()
+			key.Header = rec.Header().Clone()
+			for _, h := range unstoredHeaders {
+				key.Header.Del(h)
+			}
+			key.Body = rec.body.Bytes()
+			if err := store.CompleteIdempotencyKey(ctx, key); err != nil {
+				logger.FromContext(r.Context()).Error("Error saving idempotent response", zap.Error(err))
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, key *models.IdempotencyKey, stored *models.IdempotencyKey) {
+	if stored.Fingerprint != key.Fingerprint {
+		http.Error(w, "Idempotency-Key was used for a different request", http.StatusUnprocessableEntity)
+		return
+	}
+	if !stored.Completed() {
+		http.Error(w, "Request with this Idempotency-Key is in progress", http.StatusConflict)
+		return
+	}
+	for name, values := range stored.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, strconv.FormatBool(true))
+	w.WriteHeader(stored.StatusCode)
+	if _, err := w.Write(stored.Body); err != nil {
+		logger.Log.Error("Error writing response", zap.Error(err))
+	}
+}
+
+// responseRecorder passes the response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	if rr.code != 0 {
+		return
+	}
+	rr.code = code
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.code == 0 {
+		rr.WriteHeader(http.StatusOK)
+	}
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
+
+func (rr *responseRecorder) status() int {
+	if rr.code == 0 {
+		return http.StatusOK
+	}
+	return rr.code
+}
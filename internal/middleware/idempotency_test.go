@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vindosVP/loyalty-system/internal/middleware/mocks"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotency(t *testing.T) {
+	uri := "/api/user/orders"
+	body := "12345678903"
+	fingerprint := models.RequestFingerprint(http.MethodPost, uri, []byte(body))
+	reservedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	type reserveMock struct {
+		needed   bool
+		stored   *models.IdempotencyKey
+		reserved bool
+		err      error
+	}
+	type want struct {
+		code          int
+		body          string
+		replayed      bool
+		handlerCalled bool
+		completed     bool
+		released      bool
+	}
+	tests := []struct {
+		name        string
+		key         string
+		handlerCode int
+		reserveMock reserveMock
+		want        want
+	}{
+		{
+			name:        "no key",
+			key:         "",
+			handlerCode: http.StatusAccepted,
+			want: want{
+				code:          http.StatusAccepted,
+				body:          "handled",
+				handlerCalled: true,
+			},
+		},
+		{
+			name: "key too long",
+			key:  strings.Repeat("k", 256),
+			want: want{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name:        "first request",
+			key:         "someKey",
+			handlerCode: http.StatusAccepted,
+			reserveMock: reserveMock{
+				needed:   true,
+				stored:   &models.IdempotencyKey{Fingerprint: fingerprint, CreatedAt: reservedAt},
+				reserved: true,
+			},
+			want: want{
+				code:          http.StatusAccepted,
+				body:          "handled",
+				handlerCalled: true,
+				completed:     true,
+			},
+		},
+		{
+			name:        "first request failed",
+			key:         "someKey",
+			handlerCode: http.StatusInternalServerError,
+			reserveMock: reserveMock{
+				needed:   true,
+				stored:   &models.IdempotencyKey{Fingerprint: fingerprint, CreatedAt: reservedAt},
+				reserved: true,
+			},
+			want: want{
+				code:          http.StatusInternalServerError,
+				body:          "handled",
+				handlerCalled: true,
+				released:      true,
+			},
+		},
+		{
+			name: "retry",
+			key:  "someKey",
+			reserveMock: reserveMock{
+				needed: true,
+				stored: &models.IdempotencyKey{
+					Fingerprint: fingerprint,
+					StatusCode:  http.StatusPaymentRequired,
+					Header:      http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+					Body:        []byte("Not enough balance\n"),
+				},
+			},
+			want: want{
+				code:     http.StatusPaymentRequired,
+				body:     "Not enough balance\n",
+				replayed: true,
+			},
+		},
+		{
+			name: "key reused for other request",
+			key:  "someKey",
+			reserveMock: reserveMock{
+				needed: true,
+				stored: &models.IdempotencyKey{
+					Fingerprint: "otherFingerprint",
+					StatusCode:  http.StatusAccepted,
+				},
+			},
+			want: want{
+				code: http.StatusUnprocessableEntity,
+			},
+		},
+		{
+			name: "first request in progress",
+			key:  "someKey",
+			reserveMock: reserveMock{
+				needed: true,
+				stored: &models.IdempotencyKey{Fingerprint: fingerprint},
+			},
+			want: want{
+				code: http.StatusConflict,
+			},
+		},
+		{
+			name: "reserve unexpected error",
+			key:  "someKey",
+			reserveMock: reserveMock{
+				needed: true,
+				err:    errors.New("unexpected error"),
+			},
+			want: want{
+				code: http.StatusInternalServerError,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := mocks.NewIdempotencyStore(t)
+			if tt.reserveMock.needed {
+				store.On("ReserveIdempotencyKey", mock.Anything, mock.MatchedBy(func(k *models.IdempotencyKey) bool {
+					return k.UserID == 1 && k.Key == tt.key && k.Fingerprint == fingerprint && k.ExpiresAt.Sub(k.CreatedAt) == time.Hour &&
+						k.LockedUntil.Sub(k.CreatedAt) == time.Minute
+				})).Return(tt.reserveMock.stored, tt.reserveMock.reserved, tt.reserveMock.err)
+			}
+			if tt.want.completed {
+				store.On("CompleteIdempotencyKey", mock.Anything, mock.MatchedBy(func(k *models.IdempotencyKey) bool {
+					return k.StatusCode == tt.handlerCode && string(k.Body) == "handled" && k.Header.Get("Content-Type") == "text/plain" &&
+						k.CreatedAt.Equal(reservedAt)
+				})).Return(nil)
+			}
+			if tt.want.released {
+				store.On("ReleaseIdempotencyKey", mock.Anything, mock.MatchedBy(func(k *models.IdempotencyKey) bool {
+					return k.UserID == 1 && k.Key == tt.key && k.CreatedAt.Equal(reservedAt)
+				})).Return(nil)
+			}
+
+			handlerCalled := false
+			r := chi.NewRouter()
+			r.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{ID: 1})))
+				})
+			})
+			r.Use(Idempotency(store, time.Hour, time.Minute))
+			r.Post(uri, func(w http.ResponseWriter, r *http.Request) {
+				handlerCalled = true
+				_, leased := r.Context().Deadline()
+				assert.Equal(t, tt.key != "", leased, "the handler runs under the key's lease")
+				got, _ := io.ReadAll(r.Body)
+				assert.Equal(t, body, string(got))
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(tt.handlerCode)
+				_, _ = w.Write([]byte("handled"))
+			})
+
+			req := httptest.NewRequest(http.MethodPost, uri, strings.NewReader(body))
+			if tt.key != "" {
+				req.Header.Set(IdempotencyKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want.code, w.Code)
+			assert.Equal(t, tt.want.handlerCalled, handlerCalled)
+			if tt.want.body != "" {
+				assert.Equal(t, tt.want.body, w.Body.String())
+			}
+			if tt.want.replayed {
+				assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+			} else {
+				assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+			}
+		})
+	}
+}
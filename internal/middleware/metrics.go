@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"github.com/go-chi/chi/v5"
+	chim "github.com/go-chi/chi/v5/middleware"
+	"github.com/vindosVP/loyalty-system/internal/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute labels requests that didn't match any route, so that
+// scanning for random paths doesn't create a series per path.
+const unmatchedRoute = "unmatched"
+
+// Metrics counts requests and observes their latency per chi route pattern.
+// The pattern is only known once the router has matched the request, so it's
+// read after next returns.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chim.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		metrics.HTTPRequests.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
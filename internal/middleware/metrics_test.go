@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/vindosVP/loyalty-system/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Metrics)
+	r.Route("/api/user", func(r chi.Router) {
+		r.Get("/orders/{number}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+	})
+	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {})
+
+	orders := metrics.HTTPRequests.WithLabelValues("/api/user/orders/{number}", http.MethodGet, "404")
+	ping := metrics.HTTPRequests.WithLabelValues("/ping", http.MethodGet, "200")
+	unmatched := metrics.HTTPRequests.WithLabelValues(unmatchedRoute, http.MethodGet, "404")
+	before := []float64{testutil.ToFloat64(orders), testutil.ToFloat64(ping), testutil.ToFloat64(unmatched)}
+
+	for _, path := range []string{"/api/user/orders/1", "/api/user/orders/2", "/ping", "/wp-admin"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, before[0]+2, testutil.ToFloat64(orders))
+	assert.Equal(t, before[1]+1, testutil.ToFloat64(ping))
+	assert.Equal(t, before[2]+1, testutil.ToFloat64(unmatched))
+}
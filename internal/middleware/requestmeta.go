@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	chim "github.com/go-chi/chi/v5/middleware"
+	"github.com/vindosVP/loyalty-system/pkg/requestmeta"
+	"net"
+	"net/http"
+)
+
+// RequestMeta records the request id and the client IP in the request context
+// for the audit log. It must be used after chi's RequestID middleware.
+func RequestMeta(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		meta := &requestmeta.Meta{
+			RequestID: chim.GetReqID(r.Context()),
+			IP:        ip,
+		}
+		next.ServeHTTP(w, r.WithContext(requestmeta.WithMeta(r.Context(), meta)))
+	})
+}
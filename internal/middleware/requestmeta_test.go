@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"github.com/go-chi/chi/v5"
+	chim "github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/pkg/requestmeta"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestMeta(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(chim.RequestID, RequestMeta)
+	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
+		meta, ok := requestmeta.FromContext(r.Context())
+		require.True(t, ok)
+		assert.Equal(t, "someRequest", meta.RequestID)
+		assert.Equal(t, "192.0.2.1", meta.IP)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set(chim.RequestIDHeader, "someRequest")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"net/http"
+)
+
+// RequireRole lets the request through if the authenticated principal has any
+// of the given roles. It must be used after WithAuth.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.PrincipalFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			for _, role := range roles {
+				if principal.HasRole(role) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		})
+	}
+}
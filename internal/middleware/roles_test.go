@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireRole(t *testing.T) {
+	uri := "/admin"
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		wantCode  int
+	}{
+		{
+			name:      "has role",
+			principal: &auth.Principal{ID: 1, Roles: []string{"customer", "support"}},
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "has other allowed role",
+			principal: &auth.Principal{ID: 1, Roles: []string{"admin"}},
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "missing role",
+			principal: &auth.Principal{ID: 1, Roles: []string{"customer"}},
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name:      "no roles",
+			principal: &auth.Principal{ID: 1},
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name:      "no principal",
+			principal: nil,
+			wantCode:  http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.Use(RequireRole("support", "admin"))
+			r.Get(uri, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, uri, nil)
+			if tt.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), tt.principal))
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"errors"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"strings"
+)
+
+var (
+	ErrEmptyAdjustmentReason = errors.New("adjustment reason is required")
+	ErrZeroAdjustment        = errors.New("adjustment amount must not be zero")
+)
+
+// Adjustment is a manual balance correction made by staff. A positive amount
+// credits the user, a negative one debits them.
+type Adjustment struct {
+	UserID    int          `json:"user_id"`
+	Amount    money.Amount `json:"amount"`
+	Reason    string       `json:"reason"`
+	CreatedBy int          `json:"created_by"`
+}
+
+func (a *Adjustment) Validate() error {
+	if strings.TrimSpace(a.Reason) == "" {
+		return ErrEmptyAdjustmentReason
+	}
+	if a.Amount == 0 {
+		return ErrZeroAdjustment
+	}
+	return nil
+}
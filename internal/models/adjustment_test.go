@@ -0,0 +1,41 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"testing"
+)
+
+func TestAdjustment_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		adjustment *Adjustment
+		wantErr    error
+	}{
+		{
+			name:       "valid credit",
+			adjustment: &Adjustment{UserID: 1, Amount: money.FromInt(10), Reason: "goodwill", CreatedBy: 2},
+			wantErr:    nil,
+		},
+		{
+			name:       "valid debit",
+			adjustment: &Adjustment{UserID: 1, Amount: money.MustParse("-0.50"), Reason: "duplicate accrual", CreatedBy: 2},
+			wantErr:    nil,
+		},
+		{
+			name:       "blank reason",
+			adjustment: &Adjustment{UserID: 1, Amount: money.FromInt(10), Reason: "  ", CreatedBy: 2},
+			wantErr:    ErrEmptyAdjustmentReason,
+		},
+		{
+			name:       "zero amount",
+			adjustment: &Adjustment{UserID: 1, Amount: 0, Reason: "goodwill", CreatedBy: 2},
+			wantErr:    ErrZeroAdjustment,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.adjustment.Validate(), tt.wantErr)
+		})
+	}
+}
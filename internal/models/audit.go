@@ -0,0 +1,153 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	AuditActionUserRegistered     = "user.registered"
+	AuditActionRoleGranted        = "user.role_granted"
+	AuditActionLoginFailed        = "user.login_failed"
+	AuditActionSessionCreated     = "session.created"
+	AuditActionSessionRevoked     = "session.revoked"
+	AuditActionSessionsRevoked    = "session.revoked_all"
+	AuditActionOrderUploaded      = "order.uploaded"
+	AuditActionOrderStatusChanged = "order.status_changed"
+	AuditActionOrderRequeued      = "order.requeued"
+	AuditActionAccrualPosted      = "balance.accrued"
+	AuditActionWithdrawalCreated  = "balance.withdrawn"
+	AuditActionBalanceAdjusted    = "balance.adjusted"
+	AuditActionPointsExpired      = "balance.expired"
+	AuditActionWebhookCreated     = "webhook.created"
+	AuditActionWebhookDeleted     = "webhook.deleted"
+	AuditActionTierChanged        = "user.tier_changed"
+	AuditActionTierRuleUpdated    = "tier.rule_updated"
+)
+
+const (
+	AuditEntityUser    = "user"
+	AuditEntitySession = "session"
+	AuditEntityOrder   = "order"
+	AuditEntityEntry   = "ledger_entry"
+	AuditEntityWebhook = "webhook_endpoint"
+	AuditEntityTier    = "tier_rule"
+)
+
+// AuditEvent is a row of the append-only audit log. The events of each user
+// form a hash chain, and events without a user form one more: each Hash covers
+// the event's fields and the Hash of the previous event in its chain, so
+// editing or removing a row breaks every hash after it in that chain.
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ActorID    int             `json:"actor_id,omitempty"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	UserID     int             `json:"user_id,omitempty"`
+	OrderID    int             `json:"order_id,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+type AuditFilter struct {
+	UserID   int
+	OrderID  int
+	Action   string
+	BeforeID int64
+	Limit    int
+}
+
+type AuditVerification struct {
+	Valid    bool  `json:"valid"`
+	Checked  int   `json:"checked"`
+	BrokenAt int64 `json:"broken_at,omitempty"`
+}
+
+// NewAuditEvent returns an event that occurred now. Timestamps are kept in UTC
+// with microsecond precision, which is what PostgreSQL stores, so the hash
+// can be recomputed from a stored row.
+func NewAuditEvent(action string, entityType string, entityID string) *AuditEvent {
+	return &AuditEvent{
+		OccurredAt: time.Now().UTC().Truncate(time.Microsecond),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}
+}
+
+// WithChange records the entity's state before and after the change. Either
+// may be nil.
+func (e *AuditEvent) WithChange(before interface{}, after interface{}) (*AuditEvent, error) {
+	var err error
+	if before != nil {
+		if e.Before, err = json.Marshal(before); err != nil {
+			return nil, fmt.Errorf("json.Marshal: %w", err)
+		}
+	}
+	if after != nil {
+		if e.After, err = json.Marshal(after); err != nil {
+			return nil, fmt.Errorf("json.Marshal: %w", err)
+		}
+	}
+	return e, nil
+}
+
+// ComputeHash returns the hash of the event chained to prevHash.
+func (e *AuditEvent) ComputeHash(prevHash string) string {
+	data, _ := json.Marshal(struct {
+		PrevHash   string          `json:"prev_hash"`
+		OccurredAt string          `json:"occurred_at"`
+		ActorID    int             `json:"actor_id"`
+		Action     string          `json:"action"`
+		EntityType string          `json:"entity_type"`
+		EntityID   string          `json:"entity_id"`
+		UserID     int             `json:"user_id"`
+		OrderID    int             `json:"order_id"`
+		Before     json.RawMessage `json:"before"`
+		After      json.RawMessage `json:"after"`
+		RequestID  string          `json:"request_id"`
+		IP         string          `json:"ip"`
+	}{
+		PrevHash:   prevHash,
+		OccurredAt: e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		ActorID:    e.ActorID,
+		Action:     e.Action,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+		UserID:     e.UserID,
+		OrderID:    e.OrderID,
+		Before:     nullIfEmpty(e.Before),
+		After:      nullIfEmpty(e.After),
+		RequestID:  e.RequestID,
+		IP:         e.IP,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Chain links the event to the previous one and seals it.
+func (e *AuditEvent) Chain(prevHash string) {
+	e.PrevHash = prevHash
+	e.Hash = e.ComputeHash(prevHash)
+}
+
+// Verify reports whether the event is intact and follows prevHash.
+func (e *AuditEvent) Verify(prevHash string) bool {
+	return e.PrevHash == prevHash && e.Hash == e.ComputeHash(prevHash)
+}
+
+func nullIfEmpty(m json.RawMessage) json.RawMessage {
+	if len(m) == 0 {
+		return json.RawMessage("null")
+	}
+	return m
+}
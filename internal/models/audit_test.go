@@ -0,0 +1,34 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAuditEvent_Chain(t *testing.T) {
+	first, err := NewAuditEvent(AuditActionOrderUploaded, AuditEntityOrder, "7703824164").
+		WithChange(nil, map[string]interface{}{"status": OrderStatusNew})
+	require.NoError(t, err)
+	first.ActorID, first.UserID, first.OrderID = 1, 1, 7703824164
+	first.Chain("")
+
+	second, err := NewAuditEvent(AuditActionOrderStatusChanged, AuditEntityOrder, "7703824164").
+		WithChange(map[string]interface{}{"status": OrderStatusNew}, map[string]interface{}{"status": OrderStatusProcessed, "sum": 500})
+	require.NoError(t, err)
+	second.UserID, second.OrderID = 1, 7703824164
+	second.Chain(first.Hash)
+
+	assert.Len(t, first.Hash, 64)
+	assert.True(t, first.Verify(""))
+	assert.True(t, second.Verify(first.Hash))
+	assert.False(t, second.Verify(""), "chained to the wrong predecessor")
+
+	tampered := *second
+	tampered.After = []byte(`{"status":"PROCESSED","sum":5000}`)
+	assert.False(t, tampered.Verify(first.Hash))
+
+	tampered = *first
+	tampered.ActorID = 2
+	assert.False(t, tampered.Verify(""))
+}
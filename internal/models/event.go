@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"strconv"
+	"time"
+)
+
+const (
+	UserEventOrder   = "order"
+	UserEventBalance = "balance"
+)
+
+// UserEvent is a change pushed to the user's event stream. ID numbers the
+// user's events in the order they're committed, so it's where a client
+// resumes from. Subject names what changed, so a repeated event carrying the
+// same state can be told apart from a new one.
+type UserEvent struct {
+	ID        int64           `json:"id"`
+	UserID    int             `json:"user_id"`
+	Type      string          `json:"type"`
+	Subject   string          `json:"subject"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type OrderEventData struct {
+	Number     string       `json:"number"`
+	Status     string       `json:"status"`
+	Accrual    money.Amount `json:"accrual,omitempty"`
+	UploadedAt string       `json:"uploaded_at"`
+}
+
+type BalanceEventData struct {
+	Current   money.Amount `json:"current"`
+	Withdrawn money.Amount `json:"withdrawn"`
+}
+
+func NewOrderEvent(order *Order) (*UserEvent, error) {
+	data := &OrderEventData{
+		Number:     strconv.Itoa(order.ID),
+		Status:     order.Status,
+		UploadedAt: order.UploadedAt.Format(time.RFC3339),
+	}
+	if order.Sum > 0 {
+		data.Accrual = order.Sum
+	}
+	return newUserEvent(order.UserID, UserEventOrder, fmt.Sprintf("order:%d", order.ID), data)
+}
+
+func NewBalanceEvent(userID int, current money.Amount, withdrawn money.Amount) (*UserEvent, error) {
+	data := &BalanceEventData{Current: current, Withdrawn: withdrawn}
+	return newUserEvent(userID, UserEventBalance, UserEventBalance, data)
+}
+
+func newUserEvent(userID int, eventType string, subject string, data interface{}) (*UserEvent, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+	return &UserEvent{
+		UserID:    userID,
+		Type:      eventType,
+		Subject:   subject,
+		Data:      raw,
+		CreatedAt: time.Now(),
+	}, nil
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"testing"
+	"time"
+)
+
+func TestNewOrderEvent(t *testing.T) {
+	uploadedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	event, err := NewOrderEvent(&Order{ID: 12345678903, UserID: 1, Status: OrderStatusProcessed, Sum: money.MustParse("729.98"), UploadedAt: uploadedAt})
+	require.NoError(t, err)
+	assert.Equal(t, 1, event.UserID)
+	assert.Equal(t, UserEventOrder, event.Type)
+	assert.Equal(t, "order:12345678903", event.Subject)
+	assert.JSONEq(t, `{"number":"12345678903","status":"PROCESSED","accrual":729.98,"uploaded_at":"2024-03-01T12:00:00Z"}`, string(event.Data))
+
+	event, err = NewOrderEvent(&Order{ID: 12345678903, UserID: 1, Status: OrderStatusNew, UploadedAt: uploadedAt})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"number":"12345678903","status":"NEW","uploaded_at":"2024-03-01T12:00:00Z"}`, string(event.Data))
+}
+
+func TestNewBalanceEvent(t *testing.T) {
+	event, err := NewBalanceEvent(1, money.MustParse("500.5"), money.FromInt(42))
+	require.NoError(t, err)
+	assert.Equal(t, UserEventBalance, event.Type)
+	assert.Equal(t, UserEventBalance, event.Subject)
+	assert.JSONEq(t, `{"current":500.5,"withdrawn":42}`, string(event.Data))
+}
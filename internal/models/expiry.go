@@ -0,0 +1,41 @@
+package models
+
+import (
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"time"
+)
+
+// ExpiryPolicy tells when accrued points expire. Under the zero policy they
+// never do.
+type ExpiryPolicy struct {
+	Months int
+}
+
+// ExpiresAt returns the expiry date of points accrued at accruedAt, or nil if
+// they don't expire.
+func (p ExpiryPolicy) ExpiresAt(accruedAt time.Time) *time.Time {
+	if p.Months <= 0 {
+		return nil
+	}
+	t := accruedAt.AddDate(0, p.Months, 0)
+	return &t
+}
+
+// Lot is the part of a user's balance credited by one entry. Debits consume
+// the oldest lots first, and whatever remains of a lot at its expiry date is
+// expired. Lots without an expiry date never expire.
+type Lot struct {
+	ID        int64        `json:"id"`
+	EntryID   int64        `json:"entry_id"`
+	UserID    int          `json:"user_id"`
+	Amount    money.Amount `json:"amount"`
+	Remaining money.Amount `json:"remaining"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt *time.Time   `json:"expires_at,omitempty"`
+}
+
+// Expiration is the amount of a user's points that expire at the same time.
+type Expiration struct {
+	Amount    money.Amount `json:"amount"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
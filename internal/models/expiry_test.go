@@ -0,0 +1,35 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestExpiryPolicy_ExpiresAt(t *testing.T) {
+	accruedAt := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2025, 1, 31, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		policy ExpiryPolicy
+		want   *time.Time
+	}{
+		{
+			name:   "twelve months",
+			policy: ExpiryPolicy{Months: 12},
+			want:   &expiresAt,
+		},
+		{
+			name:   "never",
+			policy: ExpiryPolicy{},
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.policy.ExpiresAt(accruedAt))
+		})
+	}
+}
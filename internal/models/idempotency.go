@@ -0,0 +1,59 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const maxIdempotencyKeyLength = 255
+
+var (
+	ErrEmptyIdempotencyKey   = errors.New("idempotency key is empty")
+	ErrIdempotencyKeyTooLong = errors.New("idempotency key is too long")
+)
+
+// IdempotencyKey is a client supplied key together with the request it was
+// first used for and the response that request got. StatusCode is zero while
+// the first request is still being handled. If it's still zero at
+// LockedUntil, the first request is presumed dead and a retry may take the key
+// over.
+type IdempotencyKey struct {
+	UserID      int
+	Key         string
+	Fingerprint string
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	LockedUntil time.Time
+}
+
+func ValidateIdempotencyKey(key string) error {
+	if key == "" {
+		return ErrEmptyIdempotencyKey
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return ErrIdempotencyKeyTooLong
+	}
+	return nil
+}
+
+// RequestFingerprint identifies a request by its method, path and body, so a
+// key reused for a different request can be told apart from a retry.
+func RequestFingerprint(method string, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestValidateIdempotencyKey(t *testing.T) {
+	assert.NoError(t, ValidateIdempotencyKey("5d1c0b0e-8f5c-4f4c-9d43-1b5c2f0d7a11"))
+	assert.ErrorIs(t, ValidateIdempotencyKey(""), ErrEmptyIdempotencyKey)
+	assert.ErrorIs(t, ValidateIdempotencyKey(strings.Repeat("a", 256)), ErrIdempotencyKeyTooLong)
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	fp := RequestFingerprint("POST", "/api/user/orders", []byte("12345678903"))
+	assert.Len(t, fp, 64)
+	assert.Equal(t, fp, RequestFingerprint("POST", "/api/user/orders", []byte("12345678903")))
+	assert.NotEqual(t, fp, RequestFingerprint("POST", "/api/user/orders", []byte("79927398713")))
+	assert.NotEqual(t, fp, RequestFingerprint("POST", "/api/user/balance/withdraw", []byte("12345678903")))
+	assert.NotEqual(t, RequestFingerprint("POST", "/a", []byte("b")), RequestFingerprint("POST", "/ab", nil))
+}
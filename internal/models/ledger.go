@@ -0,0 +1,128 @@
+package models
+
+import (
+	"fmt"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"time"
+)
+
+const (
+	EntryKindAccrual    = "ACCRUAL"
+	EntryKindWithdrawal = "WITHDRAWAL"
+	EntryKindAdjustment = "ADJUSTMENT"
+	EntryKindExpiry     = "EXPIRY"
+)
+
+const (
+	AccountSystemAccruals    = "system:accruals"
+	AccountSystemWithdrawals = "system:withdrawals"
+	AccountSystemAdjustments = "system:adjustments"
+	AccountSystemExpirations = "system:expirations"
+)
+
+// Account is a ledger account. Postings with a positive amount debit the
+// account, postings with a negative amount credit it.
+type Account struct {
+	ID     int    `json:"id"`
+	Code   string `json:"code"`
+	UserID *int   `json:"user_id,omitempty"`
+}
+
+// Entry is a journal entry. The amounts of its postings always sum to zero.
+// Adjustments and expiries aren't tied to an order and have a zero OrderID.
+// Points credited to the user by an entry with ExpiresAt expire at that time.
+// Accrued is what an accrual was worth before the tier multiplier.
+type Entry struct {
+	ID        int64        `json:"id"`
+	Kind      string       `json:"kind"`
+	OrderID   int          `json:"order_id,omitempty"`
+	UserID    int          `json:"user_id"`
+	Reason    string       `json:"reason,omitempty"`
+	CreatedBy int          `json:"created_by,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt *time.Time   `json:"expires_at,omitempty"`
+	Accrued   money.Amount `json:"accrued,omitempty"`
+	Postings  []*Posting   `json:"postings"`
+}
+
+type Posting struct {
+	ID          int64        `json:"id"`
+	EntryID     int64        `json:"entry_id"`
+	AccountID   int          `json:"account_id"`
+	AccountCode string       `json:"account_code"`
+	Amount      money.Amount `json:"amount"`
+}
+
+type AccountBalance struct {
+	Account *Account     `json:"account"`
+	Balance money.Amount `json:"balance"`
+}
+
+// NewAccrualEntry moves points issued by the loyalty program to the user.
+func NewAccrualEntry(userID int, orderID int, sum money.Amount) *Entry {
+	return &Entry{
+		Kind:      EntryKindAccrual,
+		OrderID:   orderID,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+		Accrued:   sum,
+		Postings: []*Posting{
+			{AccountCode: UserAccountCode(userID), Amount: sum},
+			{AccountCode: AccountSystemAccruals, Amount: -sum},
+		},
+	}
+}
+
+// NewWithdrawalEntry moves points spent by the user out of their account.
+func NewWithdrawalEntry(userID int, orderID int, sum money.Amount) *Entry {
+	return &Entry{
+		Kind:      EntryKindWithdrawal,
+		OrderID:   orderID,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+		Postings: []*Posting{
+			{AccountCode: UserAccountCode(userID), Amount: -sum},
+			{AccountCode: AccountSystemWithdrawals, Amount: sum},
+		},
+	}
+}
+
+// NewAdjustmentEntry corrects the user's balance by amount on behalf of staff.
+func NewAdjustmentEntry(adj *Adjustment) *Entry {
+	return &Entry{
+		Kind:      EntryKindAdjustment,
+		UserID:    adj.UserID,
+		Reason:    adj.Reason,
+		CreatedBy: adj.CreatedBy,
+		CreatedAt: time.Now(),
+		Postings: []*Posting{
+			{AccountCode: UserAccountCode(adj.UserID), Amount: adj.Amount},
+			{AccountCode: AccountSystemAdjustments, Amount: -adj.Amount},
+		},
+	}
+}
+
+// NewExpiryEntry takes the user's expired points out of their account.
+func NewExpiryEntry(userID int, sum money.Amount) *Entry {
+	return &Entry{
+		Kind:      EntryKindExpiry,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+		Postings: []*Posting{
+			{AccountCode: UserAccountCode(userID), Amount: -sum},
+			{AccountCode: AccountSystemExpirations, Amount: sum},
+		},
+	}
+}
+
+func (e *Entry) Balanced() bool {
+	var total money.Amount
+	for _, p := range e.Postings {
+		total += p.Amount
+	}
+	return total == 0
+}
+
+func UserAccountCode(userID int) string {
+	return fmt.Sprintf("user:%d", userID)
+}
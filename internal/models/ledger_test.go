@@ -0,0 +1,82 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"testing"
+)
+
+func TestEntry_Balanced(t *testing.T) {
+	type args struct {
+		entry *Entry
+	}
+	type want struct {
+		balanced bool
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			name: "accrual",
+			args: args{
+				entry: NewAccrualEntry(1, 7324401889, money.MustParse("729.98")),
+			},
+			want: want{
+				balanced: true,
+			},
+		},
+		{
+			name: "withdrawal",
+			args: args{
+				entry: NewWithdrawalEntry(1, 7324401889, money.FromInt(100)),
+			},
+			want: want{
+				balanced: true,
+			},
+		},
+		{
+			name: "adjustment",
+			args: args{
+				entry: NewAdjustmentEntry(&Adjustment{UserID: 1, Amount: money.FromInt(-20), Reason: "duplicate accrual", CreatedBy: 2}),
+			},
+			want: want{
+				balanced: true,
+			},
+		},
+		{
+			name: "expiry",
+			args: args{
+				entry: NewExpiryEntry(1, money.MustParse("12.5")),
+			},
+			want: want{
+				balanced: true,
+			},
+		},
+		{
+			name: "unbalanced",
+			args: args{
+				entry: &Entry{
+					Kind:    EntryKindAccrual,
+					OrderID: 7324401889,
+					UserID:  1,
+					Postings: []*Posting{
+						{AccountCode: UserAccountCode(1), Amount: money.FromInt(100)},
+						{AccountCode: AccountSystemAccruals, Amount: money.FromInt(-50)},
+					},
+				},
+			},
+			want: want{
+				balanced: false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want.balanced, tt.args.entry.Balanced())
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at the last item of a page. Lists are ordered by time and
+// then by id, so the next page starts strictly after (Time, ID).
+type Cursor struct {
+	Time time.Time
+	ID   int
+}
+
+// String encodes the cursor as an opaque URL-safe token.
+func (c *Cursor) String() string {
+	raw := fmt.Sprintf("%d.%d", c.Time.UnixMicro(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	micros, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	t, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &Cursor{Time: time.UnixMicro(t).UTC()}
+	if c.ID, err = strconv.Atoi(id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// ListFilter narrows down and pages a user's orders or withdrawals. Nil and
+// empty fields don't filter. From is inclusive, To is exclusive; the amount
+// range applies to the accrual of orders and the sum of withdrawals.
+type ListFilter struct {
+	Statuses  []string
+	From      *time.Time
+	To        *time.Time
+	MinAmount *money.Amount
+	MaxAmount *money.Amount
+	After     *Cursor
+	Limit     int
+}
+
+type OrdersPage struct {
+	Orders []*Order
+	Next   *Cursor
+}
+
+type WithdrawalsPage struct {
+	Withdrawals []*Withdrawal
+	Next        *Cursor
+}
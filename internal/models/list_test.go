@@ -0,0 +1,20 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	c := &Cursor{Time: time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC), ID: 7703824164}
+	parsed, err := ParseCursor(c.String())
+	require.NoError(t, err)
+	assert.Equal(t, c, parsed)
+
+	for _, s := range []string{"", "not base64!", "MTIz", "YWJjLjE"} {
+		_, err := ParseCursor(s)
+		assert.ErrorIs(t, err, ErrInvalidCursor, s)
+	}
+}
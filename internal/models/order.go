@@ -2,6 +2,7 @@ package models
 
 import (
 	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/vindosVP/loyalty-system/pkg/money"
 	"strconv"
 	"time"
 )
@@ -14,11 +15,17 @@ const (
 )
 
 type Order struct {
-	ID         int       `json:"id"`
-	UserID     int       `json:"user_id"`
-	Status     string    `json:"status"`
-	Sum        float64   `json:"sum"`
-	UploadedAt time.Time `json:"uploaded_at"`
+	ID         int          `json:"id"`
+	UserID     int          `json:"user_id"`
+	Status     string       `json:"status"`
+	Sum        money.Amount `json:"sum"`
+	UploadedAt time.Time    `json:"uploaded_at"`
+}
+
+// Final reports whether the accrual system has settled the order, after which
+// its status and sum don't change.
+func (o *Order) Final() bool {
+	return o.Status == OrderStatusProcessed || o.Status == OrderStatusInvalid
 }
 
 func (o *Order) Validate() error {
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+type Session struct {
+	ID               string
+	UserID           int
+	RefreshTokenHash string
+	CreatedAt        time.Time
+	ExpiresAt        time.Time
+	RevokedAt        *time.Time
+}
+
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"errors"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"time"
+)
+
+const (
+	TierBronze = "BRONZE"
+	TierSilver = "SILVER"
+	TierGold   = "GOLD"
+)
+
+// TierWindowMonths is how far back the points accrued for processed orders
+// count towards a tier.
+const TierWindowMonths = 12
+
+var (
+	ErrNegativeTierThreshold = errors.New("tier threshold must not be negative")
+	ErrInvalidTierMultiplier = errors.New("tier multiplier must be positive")
+	ErrTierRulesOutOfOrder   = errors.New("tier thresholds must start at 0 and increase from bronze to gold")
+)
+
+// Tiers are the tiers from lowest to highest.
+var Tiers = []string{TierBronze, TierSilver, TierGold}
+
+// TierRule is what it takes to reach a tier and the multiplier applied to the
+// accruals of its members.
+type TierRule struct {
+	Tier       string       `json:"tier"`
+	MinAccrued money.Amount `json:"min_accrued"`
+	Multiplier money.Amount `json:"multiplier"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+func (r *TierRule) Validate() error {
+	if r.MinAccrued < 0 {
+		return ErrNegativeTierThreshold
+	}
+	if r.Multiplier <= 0 {
+		return ErrInvalidTierMultiplier
+	}
+	return nil
+}
+
+// Apply returns what a member of the tier is credited for an accrual.
+func (r *TierRule) Apply(accrual money.Amount) (money.Amount, error) {
+	return accrual.Mul(r.Multiplier)
+}
+
+// ValidateTierRules checks that rules, sorted by threshold, are the tiers in
+// order and that everyone reaches the lowest one.
+func ValidateTierRules(rules []*TierRule) error {
+	for i, rule := range rules {
+		if i >= len(Tiers) || rule.Tier != Tiers[i] {
+			return ErrTierRulesOutOfOrder
+		}
+		if (i == 0 && rule.MinAccrued != 0) || (i > 0 && rule.MinAccrued <= rules[i-1].MinAccrued) {
+			return ErrTierRulesOutOfOrder
+		}
+	}
+	return nil
+}
+
+// TierFor returns the highest of rules, sorted by threshold, that accrued
+// reaches, or nil if it reaches none.
+func TierFor(rules []*TierRule, accrued money.Amount) *TierRule {
+	var tier *TierRule
+	for _, rule := range rules {
+		if accrued < rule.MinAccrued {
+			break
+		}
+		tier = rule
+	}
+	return tier
+}
+
+// TierWindowStart returns when the points that count towards a tier at now
+// start.
+func TierWindowStart(now time.Time) time.Time {
+	return now.AddDate(0, -TierWindowMonths, 0)
+}
+
+// TierChange is an entry in a user's tier history. Accrued is what the user
+// accrued for processed orders in the window when the tier changed.
+type TierChange struct {
+	ID        int64        `json:"-"`
+	UserID    int          `json:"-"`
+	Tier      string       `json:"tier"`
+	Accrued   money.Amount `json:"accrued"`
+	ChangedAt time.Time    `json:"changed_at"`
+}
+
+func NewTierChange(userID int, tier string, accrued money.Amount) *TierChange {
+	return &TierChange{
+		UserID:    userID,
+		Tier:      tier,
+		Accrued:   accrued,
+		ChangedAt: time.Now(),
+	}
+}
+
+// TierStatus is a user's tier, what they accrued in the window and their tier
+// history, newest first. Next is nil at the top tier.
+type TierStatus struct {
+	Current *TierRule
+	Next    *TierRule
+	Accrued money.Amount
+	History []*TierChange
+}
+
+// NewTierStatus finds the current tier, the one accrued reaches or else the
+// lowest, among rules sorted by threshold.
+func NewTierStatus(rules []*TierRule, accrued money.Amount, history []*TierChange) *TierStatus {
+	status := &TierStatus{Accrued: accrued, History: history}
+	if len(rules) == 0 {
+		return status
+	}
+	current := 0
+	for i, rule := range rules {
+		if accrued >= rule.MinAccrued {
+			current = i
+		}
+	}
+	status.Current = rules[current]
+	if current+1 < len(rules) {
+		status.Next = rules[current+1]
+	}
+	return status
+}
+
+// Remaining returns how much more has to be accrued in the window to reach
+// the next tier, zero at the top tier or once it's reached.
+func (s *TierStatus) Remaining() money.Amount {
+	if s.Next == nil || s.Accrued >= s.Next.MinAccrued {
+		return 0
+	}
+	return s.Next.MinAccrued - s.Accrued
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"testing"
+)
+
+func testTierRules() []*TierRule {
+	return []*TierRule{
+		{Tier: TierBronze, MinAccrued: 0, Multiplier: money.FromInt(1)},
+		{Tier: TierSilver, MinAccrued: money.FromInt(1000), Multiplier: money.MustParse("1.25")},
+		{Tier: TierGold, MinAccrued: money.FromInt(5000), Multiplier: money.MustParse("1.5")},
+	}
+}
+
+func TestTierFor(t *testing.T) {
+	rules := testTierRules()
+
+	tests := []struct {
+		name    string
+		accrued money.Amount
+		want    string
+	}{
+		{name: "nothing accrued", accrued: 0, want: TierBronze},
+		{name: "just below silver", accrued: money.MustParse("999.99"), want: TierBronze},
+		{name: "silver threshold", accrued: money.FromInt(1000), want: TierSilver},
+		{name: "above gold", accrued: money.FromInt(7000), want: TierGold},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, TierFor(rules, tt.accrued).Tier)
+		})
+	}
+
+	assert.Nil(t, TierFor(nil, money.FromInt(100)))
+}
+
+func TestValidateTierRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(rules []*TierRule) []*TierRule
+		want   error
+	}{
+		{
+			name:   "valid",
+			modify: func(rules []*TierRule) []*TierRule { return rules },
+		},
+		{
+			name: "bronze above zero",
+			modify: func(rules []*TierRule) []*TierRule {
+				rules[0].MinAccrued = money.FromInt(1)
+				return rules
+			},
+			want: ErrTierRulesOutOfOrder,
+		},
+		{
+			name: "equal thresholds",
+			modify: func(rules []*TierRule) []*TierRule {
+				rules[2].MinAccrued = rules[1].MinAccrued
+				return rules
+			},
+			want: ErrTierRulesOutOfOrder,
+		},
+		{
+			name: "tiers swapped",
+			modify: func(rules []*TierRule) []*TierRule {
+				rules[1], rules[2] = rules[2], rules[1]
+				return rules
+			},
+			want: ErrTierRulesOutOfOrder,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, ValidateTierRules(tt.modify(testTierRules())), tt.want)
+		})
+	}
+}
+
+func TestNewTierStatus(t *testing.T) {
+	rules := testTierRules()
+
+	tests := []struct {
+		name          string
+		accrued       money.Amount
+		history       []*TierChange
+		wantCurrent   string
+		wantNext      string
+		wantRemaining money.Amount
+	}{
+		{
+			name:          "no history",
+			accrued:       money.FromInt(200),
+			wantCurrent:   TierBronze,
+			wantNext:      TierSilver,
+			wantRemaining: money.FromInt(800),
+		},
+		{
+			name:          "latest change",
+			accrued:       money.FromInt(1500),
+			history:       []*TierChange{NewTierChange(1, TierSilver, money.FromInt(1200)), NewTierChange(1, TierBronze, 0)},
+			wantCurrent:   TierSilver,
+			wantNext:      TierGold,
+			wantRemaining: money.FromInt(3500),
+		},
+		{
+			name:        "next tier reached before re-evaluation",
+			accrued:     money.FromInt(6000),
+			history:     []*TierChange{NewTierChange(1, TierSilver, money.FromInt(1200))},
+			wantCurrent: TierGold,
+		},
+		{
+			name:          "points left the window",
+			accrued:       money.FromInt(200),
+			history:       []*TierChange{NewTierChange(1, TierSilver, money.FromInt(1200))},
+			wantCurrent:   TierBronze,
+			wantNext:      TierSilver,
+			wantRemaining: money.FromInt(800),
+		},
+		{
+			name:          "top tier",
+			accrued:       money.FromInt(6000),
+			history:       []*TierChange{NewTierChange(1, TierGold, money.FromInt(6000))},
+			wantCurrent:   TierGold,
+			wantRemaining: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := NewTierStatus(rules, tt.accrued, tt.history)
+			assert.Equal(t, tt.wantCurrent, status.Current.Tier)
+			if tt.wantNext == "" {
+				assert.Nil(t, status.Next)
+			} else {
+				assert.Equal(t, tt.wantNext, status.Next.Tier)
+			}
+			assert.Equal(t, tt.wantRemaining, status.Remaining())
+		})
+	}
+}
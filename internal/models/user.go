@@ -2,14 +2,32 @@ package models
 
 import "github.com/go-playground/validator/v10"
 
+const (
+	RoleCustomer = "customer"
+	RoleSupport  = "support"
+	RoleAdmin    = "admin"
+)
+
+var Roles = []string{RoleCustomer, RoleSupport, RoleAdmin}
+
 type User struct {
-	ID           int    `json:"id"`
-	Login        string `json:"login" validate:"required"`
-	Pwd          string `json:"password,omitempty" validate:"required"`
-	EncryptedPwd string `json:"-"`
+	ID           int      `json:"id"`
+	Login        string   `json:"login" validate:"required"`
+	Pwd          string   `json:"password,omitempty" validate:"required"`
+	EncryptedPwd string   `json:"-"`
+	Roles        []string `json:"-"`
 }
 
 func (u *User) Validate() error {
 	validate := validator.New()
 	return validate.Struct(u)
 }
+
+func ValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
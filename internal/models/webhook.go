@@ -0,0 +1,183 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"github.com/vindosVP/loyalty-system/pkg/netguard"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	WebhookEventOrderStatusChanged = "order.status_changed"
+	WebhookEventBalanceWithdrawn   = "balance.withdrawn"
+)
+
+var WebhookEvents = []string{WebhookEventOrderStatusChanged, WebhookEventBalanceWithdrawn}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+var DeliveryStates = []string{DeliveryPending, DeliveryDelivered, DeliveryDead}
+
+var (
+	ErrInvalidWebhookURL   = errors.New("webhook url must be an absolute http or https url")
+	ErrPrivateWebhookURL   = errors.New("webhook url must point to a public host")
+	ErrNoWebhookEvents     = errors.New("webhook must subscribe to at least one event")
+	ErrUnknownWebhookEvent = errors.New("unknown webhook event")
+)
+
+// WebhookEndpoint receives the events it subscribed to. An endpoint with a
+// UserID gets that user's events only; one without is a partner endpoint and
+// gets everyone's. Secret signs the deliveries.
+type WebhookEndpoint struct {
+	ID          int       `json:"id"`
+	UserID      *int      `json:"user_id,omitempty"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (e *WebhookEndpoint) Validate() error {
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+	// Names are checked again once resolved, when the delivery is sent.
+	if !netguard.IsPublicHost(u.Hostname()) {
+		return ErrPrivateWebhookURL
+	}
+	if len(e.Events) == 0 {
+		return ErrNoWebhookEvents
+	}
+	for _, event := range e.Events {
+		if !ValidWebhookEvent(event) {
+			return fmt.Errorf("%w: %s", ErrUnknownWebhookEvent, event)
+		}
+	}
+	return nil
+}
+
+func ValidWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEvent is the body of a delivery. Its ID is shared by the deliveries
+// to all endpoints, so receivers can drop duplicates.
+type WebhookEvent struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	UserID    int             `json:"user_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type OrderWebhookData struct {
+	Number         string       `json:"number"`
+	Status         string       `json:"status"`
+	PreviousStatus string       `json:"previous_status"`
+	Accrual        money.Amount `json:"accrual,omitempty"`
+}
+
+type WithdrawalWebhookData struct {
+	Order       string       `json:"order"`
+	Sum         money.Amount `json:"sum"`
+	ProcessedAt string       `json:"processed_at"`
+}
+
+func NewOrderWebhookEvent(order *Order, previousStatus string) (*WebhookEvent, error) {
+	data := &OrderWebhookData{
+		Number:         strconv.Itoa(order.ID),
+		Status:         order.Status,
+		PreviousStatus: previousStatus,
+	}
+	if order.Sum > 0 {
+		data.Accrual = order.Sum
+	}
+	return newWebhookEvent(WebhookEventOrderStatusChanged, order.UserID, data)
+}
+
+func NewWithdrawalWebhookEvent(withdrawal *Withdrawal) (*WebhookEvent, error) {
+	data := &WithdrawalWebhookData{
+		Order:       strconv.Itoa(withdrawal.OrderID),
+		Sum:         withdrawal.Sum,
+		ProcessedAt: withdrawal.ProcessedAt.Format(time.RFC3339),
+	}
+	return newWebhookEvent(WebhookEventBalanceWithdrawn, withdrawal.UserID, data)
+}
+
+func newWebhookEvent(eventType string, userID int, data interface{}) (*WebhookEvent, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, fmt.Errorf("randomHex: %w", err)
+	}
+	return &WebhookEvent{
+		ID:        id,
+		Type:      eventType,
+		UserID:    userID,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Data:      raw,
+	}, nil
+}
+
+// WebhookDelivery is one event queued for one endpoint. URL and Secret are
+// filled in when the delivery is claimed for sending.
+type WebhookDelivery struct {
+	ID            int64           `json:"id"`
+	EndpointID    int             `json:"endpoint_id"`
+	EventID       string          `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	State         string          `json:"state"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastStatus    int             `json:"last_status,omitempty"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+	URL           string          `json:"-"`
+	Secret        string          `json:"-"`
+}
+
+// WebhookDeliveryFilter narrows the delivery log; zero fields don't filter.
+type WebhookDeliveryFilter struct {
+	EndpointID int
+	State      string
+	BeforeID   int64
+	Limit      int
+}
+
+func NewWebhookSecret() (string, error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", fmt.Errorf("randomHex: %w", err)
+	}
+	return "whsec_" + secret, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("rand.Read: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
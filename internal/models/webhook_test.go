@@ -0,0 +1,94 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookEndpoint_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint *WebhookEndpoint
+		wantErr  error
+	}{
+		{
+			name:     "valid",
+			endpoint: &WebhookEndpoint{URL: "https://shop.example.com/hooks", Events: []string{WebhookEventOrderStatusChanged}},
+		},
+		{
+			name:     "relative url",
+			endpoint: &WebhookEndpoint{URL: "/hooks", Events: []string{WebhookEventOrderStatusChanged}},
+			wantErr:  ErrInvalidWebhookURL,
+		},
+		{
+			name:     "unsupported scheme",
+			endpoint: &WebhookEndpoint{URL: "ftp://shop.example.com/hooks", Events: []string{WebhookEventOrderStatusChanged}},
+			wantErr:  ErrInvalidWebhookURL,
+		},
+		{
+			name:     "loopback url",
+			endpoint: &WebhookEndpoint{URL: "http://127.0.0.1:8080/hooks", Events: []string{WebhookEventOrderStatusChanged}},
+			wantErr:  ErrPrivateWebhookURL,
+		},
+		{
+			name:     "localhost url",
+			endpoint: &WebhookEndpoint{URL: "http://localhost/hooks", Events: []string{WebhookEventOrderStatusChanged}},
+			wantErr:  ErrPrivateWebhookURL,
+		},
+		{
+			name:     "link-local url",
+			endpoint: &WebhookEndpoint{URL: "http://169.254.169.254/latest/meta-data", Events: []string{WebhookEventOrderStatusChanged}},
+			wantErr:  ErrPrivateWebhookURL,
+		},
+		{
+			name:     "private url",
+			endpoint: &WebhookEndpoint{URL: "https://[fd00::1]/hooks", Events: []string{WebhookEventOrderStatusChanged}},
+			wantErr:  ErrPrivateWebhookURL,
+		},
+		{
+			name:     "no events",
+			endpoint: &WebhookEndpoint{URL: "https://shop.example.com/hooks"},
+			wantErr:  ErrNoWebhookEvents,
+		},
+		{
+			name:     "unknown event",
+			endpoint: &WebhookEndpoint{URL: "https://shop.example.com/hooks", Events: []string{"order.deleted"}},
+			wantErr:  ErrUnknownWebhookEvent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.endpoint.Validate()
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestNewOrderWebhookEvent(t *testing.T) {
+	event, err := NewOrderWebhookEvent(&Order{ID: 12345678903, UserID: 1, Status: OrderStatusProcessed, Sum: money.FromInt(500)}, OrderStatusProcessing)
+	require.NoError(t, err)
+	assert.Len(t, event.ID, 32)
+	assert.Equal(t, WebhookEventOrderStatusChanged, event.Type)
+	assert.Equal(t, 1, event.UserID)
+	assert.JSONEq(t, `{"number":"12345678903","status":"PROCESSED","previous_status":"PROCESSING","accrual":500}`, string(event.Data))
+
+	other, err := NewWithdrawalWebhookEvent(&Withdrawal{OrderID: 2377225624, UserID: 1, Sum: money.FromInt(100), ProcessedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	assert.NotEqual(t, event.ID, other.ID)
+	assert.JSONEq(t, `{"order":"2377225624","sum":100,"processed_at":"2024-03-01T12:00:00Z"}`, string(other.Data))
+}
+
+func TestNewWebhookSecret(t *testing.T) {
+	secret, err := NewWebhookSecret()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, "whsec_"))
+	assert.Len(t, secret, len("whsec_")+64)
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"strconv"
+	"time"
+)
+
+type Withdrawal struct {
+	OrderID     int          `json:"order_id"`
+	UserID      int          `json:"user_id"`
+	Sum         money.Amount `json:"sum"`
+	ProcessedAt time.Time    `json:"processed_at"`
+}
+
+func (w *Withdrawal) Validate() error {
+	return goluhn.Validate(strconv.Itoa(w.OrderID))
+}
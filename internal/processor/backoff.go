@@ -0,0 +1,81 @@
+package processor
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	backoffBase = 500 * time.Millisecond
+	backoffMax  = 30 * time.Second
+)
+
+// gate pauses all workers at once, while the accrual system asks us to slow
+// down with 429 Too Many Requests or backing off while it's failing.
+type gate struct {
+	mu       sync.Mutex
+	until    time.Time
+	failures int
+}
+
+func (g *gate) pause(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if until := time.Now().Add(d); until.After(g.until) {
+		g.until = until
+	}
+}
+
+// fail pauses the gate after the accrual system failed, for a backoff that
+// grows with every failure in a row, whichever worker saw it. Failures seen
+// while the gate is already paused were requests sent before it closed, so
+// they don't make the backoff grow. It returns how long the gate stays paused.
+func (g *gate) fail(backoff func(attempt int) time.Duration) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	if g.until.After(now) {
+		return g.until.Sub(now)
+	}
+	d := backoff(g.failures)
+	g.failures++
+	g.until = now.Add(d)
+	return d
+}
+
+// succeed resets the backoff once the accrual system answers again.
+func (g *gate) succeed() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures = 0
+}
+
+func (g *gate) remaining() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return time.Until(g.until)
+}
+
+// wait blocks until the gate is open. It returns false if done is closed first.
+func (g *gate) wait(done <-chan struct{}) bool {
+	for {
+		d := g.remaining()
+		if d <= 0 {
+			return true
+		}
+		if !sleep(d, done) {
+			return false
+		}
+	}
+}
+
+func sleep(d time.Duration, done <-chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,41 @@
+package processor
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestGate(t *testing.T) {
+	g := gate{}
+	assert.True(t, g.wait(nil))
+
+	g.pause(50 * time.Millisecond)
+	g.pause(time.Millisecond)
+	start := time.Now()
+	assert.True(t, g.wait(nil))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	done := make(chan struct{})
+	close(done)
+	g.pause(time.Hour)
+	assert.False(t, g.wait(done))
+}
+
+func TestGate_fail(t *testing.T) {
+	g := gate{}
+	var attempts []int
+	backoff := func(attempt int) time.Duration {
+		attempts = append(attempts, attempt)
+		return 10 * time.Millisecond
+	}
+
+	assert.Equal(t, 10*time.Millisecond, g.fail(backoff))
+	assert.LessOrEqual(t, g.fail(backoff), 10*time.Millisecond, "failures while paused don't extend the pause")
+	assert.True(t, g.wait(nil))
+	g.fail(backoff)
+	assert.True(t, g.wait(nil))
+	g.succeed()
+	g.fail(backoff)
+	assert.Equal(t, []int{0, 1, 0}, attempts)
+}
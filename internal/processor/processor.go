@@ -4,127 +4,272 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/go-resty/resty/v2"
+	"github.com/vindosVP/loyalty-system/internal/accrual"
+	"github.com/vindosVP/loyalty-system/internal/metrics"
 	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/internal/tracing"
 	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"github.com/vindosVP/loyalty-system/pkg/worker"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
-	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var ErrTooManyRequests = errors.New("too many requests")
+const maxAttempts = 5
 
+var ErrStopped = errors.New("processor stopped")
+
+type Outcome int
+
+const (
+	OutcomeUpdated Outcome = iota
+	OutcomeNotRegistered
+	OutcomeRateLimited
+	OutcomeUnavailable
+	OutcomeSuperseded
+	OutcomeFailed
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeUpdated:
+		return "updated"
+	case OutcomeNotRegistered:
+		return "not registered"
+	case OutcomeRateLimited:
+		return "rate limited"
+	case OutcomeUnavailable:
+		return "unavailable"
+	case OutcomeSuperseded:
+		return "superseded"
+	default:
+		return "failed"
+	}
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Storage
 type Storage interface {
-	GetUnprocessedOrders(ctx context.Context) ([]int, error)
-	UpdateOrder(ctx context.Context, id int, status string, sum float64) (*models.Order, error)
-	UpdateOrderStatus(ctx context.Context, id int, status string) (*models.Order, error)
+	ClaimUnprocessedOrders(ctx context.Context, owner string, limit int, lease time.Duration) ([]int, error)
+	UpdateOrder(ctx context.Context, id int, owner string, status string, sum money.Amount) (*models.Order, error)
+	UpdateOrderStatus(ctx context.Context, id int, owner string, status string) (*models.Order, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Publisher
+type Publisher interface {
+	Publish(ctx context.Context, event *models.UserEvent) error
+}
+
+type AccrualClient interface {
+	GetOrder(ctx context.Context, number int) (*accrual.Order, error)
 }
 
 type Processor struct {
 	RequestInterval time.Duration
-	ServerAddress   string
+	BatchSize       int
+	LeaseTimeout    time.Duration
+	WorkerID        string
 	Done            <-chan struct{}
 	Storage         Storage
-	Client          *resty.Client
+	Client          AccrualClient
+	Events          Publisher
+	gate            gate
+	backoff         func(attempt int) time.Duration
+	lastTick        atomic.Int64
 }
 
 type job struct {
-	id            int
-	serverAddress string
-	client        *resty.Client
-	order         int
-	storage       Storage
-}
-
-type result struct {
 	id    int
 	order int
-	err   error
 }
 
-type accrualResponse struct {
-	Order   string  `json:"order"`
-	Status  string  `json:"status"`
-	Accrual float64 `json:"accrual,omitempty"`
+type result struct {
+	id       int
+	order    int
+	outcome  Outcome
+	attempts int
+	err      error
 }
 
-func New(RequestInterval time.Duration, ServerAddress string, Storage Storage) *Processor {
+func New(RequestInterval time.Duration, ServerAddress string, BatchSize int, LeaseTimeout time.Duration, Storage Storage) *Processor {
 	return &Processor{
 		RequestInterval: RequestInterval,
-		ServerAddress:   ServerAddress,
+		BatchSize:       BatchSize,
+		LeaseTimeout:    LeaseTimeout,
+		WorkerID:        worker.NewID(),
+		backoff:         worker.Backoff{Base: backoffBase, Max: backoffMax}.Delay,
 		Storage:         Storage,
-		Client:          resty.New(),
+		Client:          accrual.NewClient(ServerAddress),
 	}
 }
 
+// Run polls the accrual system until Done is closed. Closing Done cancels
+// in-flight requests; Run returns once every worker has exited. Interrupted
+// orders keep their lease and are picked up again after it expires.
 func (p *Processor) Run() {
+	p.lastTick.Store(time.Now().UnixNano())
 	tick := time.NewTicker(p.RequestInterval * time.Second)
 	defer tick.Stop()
 
-	for {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
 		select {
 		case <-p.Done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
 			return
 		case <-tick.C:
-			p.requestAccruals()
+			p.requestAccruals(ctx)
 		}
 	}
 }
 
-func (p *Processor) requestAccruals() {
-	ctx := context.Background()
-	orders, err := p.Storage.GetUnprocessedOrders(ctx)
+func (p *Processor) requestAccruals(ctx context.Context) {
+	orders, err := p.Storage.ClaimUnprocessedOrders(ctx, p.WorkerID, p.BatchSize, p.LeaseTimeout)
 	if err != nil {
-		logger.Log.Error("Failed to get unprocessed orders", zap.Error(err))
+		logger.Log.Error("Failed to claim unprocessed orders", zap.Error(err))
 		return
 	}
+	metrics.OrdersClaimed.Observe(float64(len(orders)))
 	jobs := p.generateJobs(orders)
 	results := make(chan result)
-	go listenResults(results)
-	p.startWorkers(jobs, results, 10)
+	listened := make(chan struct{})
+	go func() {
+		listenResults(results)
+		close(listened)
+	}()
+	p.startWorkers(ctx, jobs, results, 10)
+	<-listened
+	p.lastTick.Store(time.Now().UnixNano())
+}
+
+// LastTick returns when the last poll finished, or when Run started if none
+// has yet. A poll that failed to claim orders doesn't count.
+func (p *Processor) LastTick() time.Time {
+	nanos := p.lastTick.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
 }
 
 func listenResults(results <-chan result) {
 	for res := range results {
-		if res.err != nil {
-			logger.Log.Error("worker failed", zap.Error(res.err), zap.Int("id", res.id))
-		} else {
-			logger.Log.Info("worker finished", zap.Int("id", res.id))
+		fields := []zap.Field{
+			zap.Int("id", res.id),
+			zap.Int("orderId", res.order),
+			zap.Stringer("outcome", res.outcome),
+			zap.Int("attempts", res.attempts),
+		}
+		switch res.outcome {
+		case OutcomeUpdated:
+			logger.Log.Info("worker finished", fields...)
+		case OutcomeNotRegistered, OutcomeSuperseded:
+			logger.Log.Warn("worker finished", append(fields, zap.Error(res.err))...)
+		default:
+			logger.Log.Error("worker failed", append(fields, zap.Error(res.err))...)
 		}
 	}
 }
 
-func (p *Processor) startWorkers(jobs <-chan job, results chan<- result, workers int) {
+func (p *Processor) startWorkers(ctx context.Context, jobs <-chan job, results chan<- result, workers int) {
 	wg := sync.WaitGroup{}
 	logger.Log.Info(fmt.Sprintf("Starting %d workers", workers))
+	metrics.Workers.Set(float64(workers))
+	defer metrics.Workers.Set(0)
 	for i := 1; i <= workers; i++ {
 		wg.Add(1)
-		go worker(jobs, results, &wg)
+		go p.worker(ctx, jobs, results, &wg)
 	}
 	wg.Wait()
 	close(results)
 }
 
-func worker(jobs <-chan job, results chan<- result, wg *sync.WaitGroup) {
+func (p *Processor) worker(ctx context.Context, jobs <-chan job, results chan<- result, wg *sync.WaitGroup) {
 	for j := range jobs {
-		err := processOrder(j.client, j.serverAddress, j.order, j.storage)
-		results <- result{j.id, j.order, err}
+		metrics.WorkersBusy.Inc()
+		res := p.process(ctx, j)
+		metrics.WorkersBusy.Dec()
+		results <- res
 	}
 	wg.Done()
 }
 
+// process polls the accrual system for one order. A 429 pauses every worker
+// for the Retry-After period; 5xx and network errors pause every worker with
+// an exponential backoff shared between them. Both count towards maxAttempts.
+func (p *Processor) process(ctx context.Context, j job) (res result) {
+	ctx, span := tracing.Tracer.Start(ctx, "Processor.process", trace.WithAttributes(attribute.Int("order.id", j.order)))
+	defer func() {
+		span.SetAttributes(attribute.Stringer("outcome", res.outcome), attribute.Int("attempts", res.attempts))
+		tracing.RecordError(span, res.err)
+		span.End()
+	}()
+	res = result{id: j.id, order: j.order}
+	for res.attempts < maxAttempts {
+		if !p.gate.wait(ctx.Done()) {
+			res.outcome, res.err = OutcomeFailed, ErrStopped
+			return res
+		}
+		res.attempts++
+		res.err = p.processOrder(ctx, j.order)
+		res.outcome = outcomeOf(res.err)
+		if res.outcome != OutcomeUnavailable {
+			p.gate.succeed()
+		}
+
+		var retryAfter *accrual.RetryAfterError
+		switch {
+		case errors.As(res.err, &retryAfter):
+			logger.FromContext(ctx).Warn("Accrual system rate limit reached, pausing workers", zap.Duration("retryAfter", retryAfter.Delay))
+			p.gate.pause(retryAfter.Delay)
+		case res.outcome == OutcomeUnavailable:
+			d := p.gate.fail(p.backoff)
+			logger.FromContext(ctx).Warn("Accrual system unavailable, pausing workers", zap.Duration("backoff", d), zap.Error(res.err))
+		default:
+			return res
+		}
+	}
+	return res
+}
+
+func outcomeOf(err error) Outcome {
+	switch {
+	case err == nil:
+		return OutcomeUpdated
+	case errors.Is(err, accrual.ErrOrderNotRegistered):
+		return OutcomeNotRegistered
+	case errors.Is(err, accrual.ErrTooManyRequests):
+		return OutcomeRateLimited
+	case errors.Is(err, accrual.ErrUnavailable):
+		return OutcomeUnavailable
+	case errors.Is(err, storage.ErrOrderLeaseLost), errors.Is(err, storage.ErrOrderFinal):
+		// Another worker claimed the order after our lease ran out.
+		return OutcomeSuperseded
+	default:
+		return OutcomeFailed
+	}
+}
+
 func (p *Processor) generateJobs(orders []int) chan job {
 	jobs := make(chan job)
 	go func() {
 		id := 0
 		for _, order := range orders {
 			jobs <- job{
-				id:            id,
-				serverAddress: p.ServerAddress,
-				client:        p.Client,
-				order:         order,
-				storage:       p.Storage,
+				id:    id,
+				order: order,
 			}
 			id++
 		}
@@ -133,29 +278,44 @@ func (p *Processor) generateJobs(orders []int) chan job {
 	return jobs
 }
 
-func processOrder(client *resty.Client, serverAddress string, order int, storage Storage) error {
-	var response accrualResponse
-	url := fmt.Sprintf("%s/api/orders/%s", serverAddress, strconv.Itoa(order))
-	resp, err := client.R().SetResult(&response).Get(url)
+func (p *Processor) processOrder(ctx context.Context, order int) error {
+	response, err := p.Client.GetOrder(ctx, order)
+	if ctx.Err() != nil {
+		return ErrStopped
+	}
 	if err != nil {
-		logger.Log.Error("Failed to get accruals by order", zap.Int("orderId", order), zap.Error(err))
+		return fmt.Errorf("p.Client.GetOrder: %w", err)
 	}
-	if resp.StatusCode() != 200 {
-		if resp.StatusCode() == 429 {
-			logger.Log.Error("Failed to get accruals by order", zap.Int("orderId", order), zap.Error(ErrTooManyRequests))
-		}
-		logger.Log.Error("Failed to get accruals by order", zap.Int("orderId", order), zap.Int("statusCode", resp.StatusCode()))
-		return err
+
+	var updated *models.Order
+	switch response.Status {
+	case accrual.StatusProcessed:
+		updated, err = p.Storage.UpdateOrder(ctx, order, p.WorkerID, models.OrderStatusProcessed, response.Accrual)
+	case accrual.StatusInvalid:
+		updated, err = p.Storage.UpdateOrderStatus(ctx, order, p.WorkerID, models.OrderStatusInvalid)
+	case accrual.StatusRegistered, accrual.StatusProcessing:
+		updated, err = p.Storage.UpdateOrderStatus(ctx, order, p.WorkerID, models.OrderStatusProcessing)
+	default:
+		return fmt.Errorf("unknown accrual status %q", response.Status)
 	}
-	id, _ := strconv.Atoi(response.Order)
-	if response.Status == models.OrderStatusProcessed {
-		_, err = storage.UpdateOrder(context.Background(), id, response.Status, response.Accrual)
-	} else {
-		_, err = storage.UpdateOrderStatus(context.Background(), id, response.Status)
+	if err != nil {
+		return fmt.Errorf("p.Storage.UpdateOrder: %w", err)
 	}
+	p.publish(ctx, updated)
+	return nil
+}
 
+// publish notifies the order's owner of its status. An unchanged status is
+// published on every poll and dropped by the bus.
+func (p *Processor) publish(ctx context.Context, order *models.Order) {
+	if p.Events == nil || order == nil {
+		return
+	}
+	event, err := models.NewOrderEvent(order)
+	if err == nil {
+		err = p.Events.Publish(ctx, event)
+	}
 	if err != nil {
-		return fmt.Errorf("storage.UpdateOrder: %w", err)
+		logger.FromContext(ctx).Error("Failed to publish order event", zap.Int("orderId", order.ID), zap.Error(err))
 	}
-	return nil
 }
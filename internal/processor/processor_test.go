@@ -0,0 +1,295 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/accrual"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/processor/mocks"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"testing"
+	"time"
+)
+
+const testOrder = 12345678903
+
+func newTestProcessor(storage Storage, client AccrualClient) *Processor {
+	return &Processor{
+		RequestInterval: 1,
+		BatchSize:       10,
+		LeaseTimeout:    time.Minute,
+		WorkerID:        "test",
+		Storage:         storage,
+		Client:          client,
+		backoff: func(attempt int) time.Duration {
+			return time.Millisecond
+		},
+	}
+}
+
+func TestProcessor_process(t *testing.T) {
+	unexpectedError := errors.New("unexpected error")
+
+	type storageUpdateOrderMock struct {
+		needed bool
+		sum    money.Amount
+		err    error
+	}
+	type storageUpdateOrderStatusMock struct {
+		needed bool
+		status string
+		err    error
+	}
+	type want struct {
+		outcome  Outcome
+		attempts int
+		err      error
+	}
+
+	tests := []struct {
+		name                         string
+		responses                    []accrual.Response
+		storageUpdateOrderMock       storageUpdateOrderMock
+		storageUpdateOrderStatusMock storageUpdateOrderStatusMock
+		want                         want
+	}{
+		{
+			name:      "processed",
+			responses: []accrual.Response{{Status: accrual.StatusProcessed, Accrual: money.MustParse("729.98")}},
+			storageUpdateOrderMock: storageUpdateOrderMock{
+				needed: true,
+				sum:    money.MustParse("729.98"),
+			},
+			want: want{outcome: OutcomeUpdated, attempts: 1},
+		},
+		{
+			name:      "registered is stored as processing",
+			responses: []accrual.Response{{Status: accrual.StatusRegistered}},
+			storageUpdateOrderStatusMock: storageUpdateOrderStatusMock{
+				needed: true,
+				status: models.OrderStatusProcessing,
+			},
+			want: want{outcome: OutcomeUpdated, attempts: 1},
+		},
+		{
+			name:      "processing",
+			responses: []accrual.Response{{Status: accrual.StatusProcessing}},
+			storageUpdateOrderStatusMock: storageUpdateOrderStatusMock{
+				needed: true,
+				status: models.OrderStatusProcessing,
+			},
+			want: want{outcome: OutcomeUpdated, attempts: 1},
+		},
+		{
+			name:      "invalid",
+			responses: []accrual.Response{{Status: accrual.StatusInvalid}},
+			storageUpdateOrderStatusMock: storageUpdateOrderStatusMock{
+				needed: true,
+				status: models.OrderStatusInvalid,
+			},
+			want: want{outcome: OutcomeUpdated, attempts: 1},
+		},
+		{
+			name:      "unknown status",
+			responses: []accrual.Response{{Status: "LOST"}},
+			want:      want{outcome: OutcomeFailed, attempts: 1},
+		},
+		{
+			name: "unknown order",
+			want: want{outcome: OutcomeNotRegistered, attempts: 1, err: accrual.ErrOrderNotRegistered},
+		},
+		{
+			name: "retries unavailable accrual system",
+			responses: []accrual.Response{
+				{Err: accrual.ErrUnavailable},
+				{Err: accrual.ErrUnavailable},
+				{Status: accrual.StatusProcessed, Accrual: money.FromInt(500)},
+			},
+			storageUpdateOrderMock: storageUpdateOrderMock{
+				needed: true,
+				sum:    money.FromInt(500),
+			},
+			want: want{outcome: OutcomeUpdated, attempts: 3},
+		},
+		{
+			name:      "gives up after max attempts",
+			responses: []accrual.Response{{Err: accrual.ErrUnavailable}},
+			want:      want{outcome: OutcomeUnavailable, attempts: maxAttempts, err: accrual.ErrUnavailable},
+		},
+		{
+			name: "waits out rate limit",
+			responses: []accrual.Response{
+				{Err: &accrual.RetryAfterError{Delay: 50 * time.Millisecond}},
+				{Status: accrual.StatusProcessing},
+			},
+			storageUpdateOrderStatusMock: storageUpdateOrderStatusMock{
+				needed: true,
+				status: models.OrderStatusProcessing,
+			},
+			want: want{outcome: OutcomeUpdated, attempts: 2},
+		},
+		{
+			name:      "lease lost",
+			responses: []accrual.Response{{Status: accrual.StatusInvalid}},
+			storageUpdateOrderStatusMock: storageUpdateOrderStatusMock{
+				needed: true,
+				status: models.OrderStatusInvalid,
+				err:    storage.ErrOrderLeaseLost,
+			},
+			want: want{outcome: OutcomeSuperseded, attempts: 1, err: storage.ErrOrderLeaseLost},
+		},
+		{
+			name:      "storage unexpected error",
+			responses: []accrual.Response{{Status: accrual.StatusProcessing}},
+			storageUpdateOrderStatusMock: storageUpdateOrderStatusMock{
+				needed: true,
+				status: models.OrderStatusProcessing,
+				err:    unexpectedError,
+			},
+			want: want{outcome: OutcomeFailed, attempts: 1, err: unexpectedError},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := mocks.NewStorage(t)
+			client := accrual.NewFake().Script(testOrder, tt.responses...)
+			p := newTestProcessor(storage, client)
+			if tt.storageUpdateOrderMock.needed {
+				storage.On("UpdateOrder", mock.Anything, testOrder, "test", models.OrderStatusProcessed, tt.storageUpdateOrderMock.sum).Return(&models.Order{}, tt.storageUpdateOrderMock.err)
+			}
+			if tt.storageUpdateOrderStatusMock.needed {
+				storage.On("UpdateOrderStatus", mock.Anything, testOrder, "test", tt.storageUpdateOrderStatusMock.status).Return(&models.Order{}, tt.storageUpdateOrderStatusMock.err)
+			}
+
+			res := p.process(context.Background(), job{id: 1, order: testOrder})
+			assert.Equal(t, tt.want.outcome, res.outcome)
+			assert.Equal(t, tt.want.attempts, res.attempts)
+			assert.Equal(t, tt.want.attempts, client.Calls(testOrder))
+			if tt.want.err != nil {
+				assert.ErrorIs(t, res.err, tt.want.err)
+			}
+			if tt.want.outcome == OutcomeUpdated {
+				assert.NoError(t, res.err)
+			}
+		})
+	}
+}
+
+func TestProcessor_process_RateLimitPausesWorkers(t *testing.T) {
+	client := accrual.NewFake().Script(testOrder, accrual.Response{Err: &accrual.RetryAfterError{Delay: time.Second}})
+	p := newTestProcessor(mocks.NewStorage(t), client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	res := p.process(ctx, job{id: 1, order: testOrder})
+	require.ErrorIs(t, res.err, ErrStopped)
+	assert.Equal(t, 2, client.Calls(testOrder))
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	assert.Greater(t, p.gate.remaining(), time.Duration(0))
+}
+
+func TestProcessor_process_UnavailablePausesWorkers(t *testing.T) {
+	const otherOrder = 79927398713
+	storage := mocks.NewStorage(t)
+	client := accrual.NewFake().
+		Script(testOrder, accrual.Response{Err: accrual.ErrUnavailable}, accrual.Response{Status: accrual.StatusProcessing}).
+		Script(otherOrder, accrual.Response{Status: accrual.StatusProcessing})
+	p := newTestProcessor(storage, client)
+	p.backoff = func(attempt int) time.Duration {
+		return 300 * time.Millisecond
+	}
+	storage.On("UpdateOrderStatus", mock.Anything, testOrder, "test", models.OrderStatusProcessing).Return(&models.Order{}, nil)
+	storage.On("UpdateOrderStatus", mock.Anything, otherOrder, "test", models.OrderStatusProcessing).Return(&models.Order{}, nil)
+
+	failing := make(chan result, 1)
+	go func() {
+		failing <- p.process(context.Background(), job{id: 1, order: testOrder})
+	}()
+	require.Eventually(t, func() bool {
+		return p.gate.remaining() > 0
+	}, time.Second, time.Millisecond)
+
+	other := make(chan result, 1)
+	go func() {
+		other <- p.process(context.Background(), job{id: 2, order: otherOrder})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, client.Calls(otherOrder), "other workers must wait while the accrual system is failing")
+
+	res := <-other
+	assert.Equal(t, OutcomeUpdated, res.outcome)
+	assert.Equal(t, 1, client.Calls(otherOrder))
+	res = <-failing
+	assert.Equal(t, OutcomeUpdated, res.outcome)
+	assert.Equal(t, 2, res.attempts)
+}
+
+func TestProcessor_requestAccruals(t *testing.T) {
+	storage := mocks.NewStorage(t)
+	client := accrual.NewFake().
+		Script(12345678903, accrual.Response{Status: accrual.StatusProcessed, Accrual: money.FromInt(100)}).
+		Script(79927398713, accrual.Response{Status: accrual.StatusInvalid}).
+		Script(4561261212345467, accrual.Response{Status: accrual.StatusRegistered})
+	p := newTestProcessor(storage, client)
+
+	storage.On("ClaimUnprocessedOrders", mock.Anything, "test", 10, time.Minute).Return([]int{12345678903, 79927398713, 4561261212345467, 49927398716}, nil)
+	storage.On("UpdateOrder", mock.Anything, 12345678903, "test", models.OrderStatusProcessed, money.FromInt(100)).Return(&models.Order{}, nil)
+	storage.On("UpdateOrderStatus", mock.Anything, 79927398713, "test", models.OrderStatusInvalid).Return(&models.Order{}, nil)
+	storage.On("UpdateOrderStatus", mock.Anything, 4561261212345467, "test", models.OrderStatusProcessing).Return(&models.Order{}, nil)
+
+	assert.True(t, p.LastTick().IsZero())
+	p.requestAccruals(context.Background())
+	assert.Equal(t, 1, client.Calls(49927398716))
+	assert.WithinDuration(t, time.Now(), p.LastTick(), time.Second)
+}
+
+func TestProcessor_requestAccruals_ClaimFailureIsNotATick(t *testing.T) {
+	storage := mocks.NewStorage(t)
+	p := newTestProcessor(storage, accrual.NewFake())
+	storage.On("ClaimUnprocessedOrders", mock.Anything, "test", 10, time.Minute).Return(nil, errors.New("unexpected error"))
+
+	p.requestAccruals(context.Background())
+	assert.True(t, p.LastTick().IsZero())
+}
+
+func TestProcessor_Run_StopsOnDone(t *testing.T) {
+	storage := mocks.NewStorage(t)
+	p := newTestProcessor(storage, accrual.NewFake())
+	done := make(chan struct{})
+	p.Done = done
+	storage.On("ClaimUnprocessedOrders", mock.Anything, "test", 10, time.Minute).Return([]int{}, nil).Maybe()
+
+	stopped := make(chan struct{})
+	go func() {
+		p.Run()
+		close(stopped)
+	}()
+	close(done)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("processor did not stop")
+	}
+}
+
+func TestProcessor_processOrder_PublishesEvent(t *testing.T) {
+	storage := mocks.NewStorage(t)
+	events := mocks.NewPublisher(t)
+	client := accrual.NewFake().Script(testOrder, accrual.Response{Status: accrual.StatusProcessed, Accrual: money.FromInt(100)})
+	p := newTestProcessor(storage, client)
+	p.Events = events
+
+	order := &models.Order{ID: testOrder, UserID: 1, Status: models.OrderStatusProcessed, Sum: money.FromInt(100), UploadedAt: time.Now()}
+	storage.On("UpdateOrder", mock.Anything, testOrder, "test", models.OrderStatusProcessed, money.FromInt(100)).Return(order, nil)
+	events.On("Publish", mock.Anything, mock.MatchedBy(func(e *models.UserEvent) bool {
+		return e.UserID == 1 && e.Type == models.UserEventOrder && e.Subject == "order:12345678903"
+	})).Return(errors.New("unexpected error"))
+
+	assert.NoError(t, p.processOrder(context.Background(), testOrder), "a failure to publish must not fail the order")
+}
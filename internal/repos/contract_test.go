@@ -0,0 +1,13 @@
+package repos
+
+import (
+	"github.com/vindosVP/loyalty-system/internal/storage/storagetest"
+	"testing"
+)
+
+func TestContract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) *storagetest.Backend {
+		pool := testPool(t)
+		return &storagetest.Backend{Users: NewUserRepo(pool), Orders: NewOrdersRepo(pool), Ledger: NewLedgerRepo(pool), Tiers: NewTierRepo(pool), Idempotency: NewIdempotencyRepo(pool), Tx: NewTx(pool)}
+	})
+}
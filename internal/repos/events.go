@@ -0,0 +1,159 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+// eventsChannel is the NOTIFY channel every instance listens on.
+const eventsChannel = "user_events"
+
+// eventNotification is the NOTIFY payload. It only identifies the event, as
+// payloads are limited to 8000 bytes; listeners read the event itself back.
+// ID is the event's number in the user's stream.
+type eventNotification struct {
+	UserID int   `json:"user_id"`
+	ID     int64 `json:"id"`
+}
+
+type EventRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewEventRepo(pool *pgxpool.Pool) *EventRepo {
+	return &EventRepo{pool: pool}
+}
+
+const eventColumns = "seq, user_id, type, subject, data, created_at"
+
+// Append stores the event and notifies every listening instance on commit.
+// It returns false without storing anything if the latest event on the same
+// subject carries the same data, as happens when an order is polled again
+// without a status change.
+//
+// The event is numbered from the user's counter, whose row stays locked until
+// commit, so the user's events commit in the order of their numbers.
+func (er *EventRepo) Append(ctx context.Context, event *models.UserEvent) (bool, error) {
+	appended := false
+	err := pgx.BeginFunc(ctx, er.pool, func(tx pgx.Tx) error {
+		query := `insert into user_event_seqs (user_id, seq) values ($1, 1)
+                  on conflict (user_id) do update set seq = user_event_seqs.seq + 1
+                  returning seq`
+		var seq int64
+		if err := tx.QueryRow(ctx, query, event.UserID).Scan(&seq); err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		query = `insert into user_events (user_id, seq, type, subject, data, created_at)
+                 select $1, $2, $3, $4, $5::json, $6
+                 where coalesce((select data::text from user_events
+                                 where user_id = $1 and subject = $4
+                                 order by seq desc limit 1), '') <> $5::text
+                 returning seq`
+		err := tx.QueryRow(ctx, query, event.UserID, seq, event.Type, event.Subject, string(event.Data), event.CreatedAt).Scan(&event.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		payload, err := json.Marshal(eventNotification{UserID: event.UserID, ID: event.ID})
+		if err != nil {
+			return fmt.Errorf("json.Marshal: %w", err)
+		}
+		if _, err := tx.Exec(ctx, "select pg_notify($1, $2)", eventsChannel, string(payload)); err != nil {
+			return fmt.Errorf("tx.Exec: %w", err)
+		}
+		appended = true
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("pgx.BeginFunc: %w", err)
+	}
+	return appended, nil
+}
+
+// ListAfter returns up to limit of the user's events numbered above afterID,
+// oldest first.
+func (er *EventRepo) ListAfter(ctx context.Context, userID int, afterID int64, limit int) ([]*models.UserEvent, error) {
+	query := "select " + eventColumns + " from user_events where user_id = $1 and seq > $2 order by seq limit $3"
+	rows, err := er.pool.Query(ctx, query, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("er.pool.Query: %w", err)
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.UserEvent, error) {
+		return scanEvent(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows: %w", err)
+	}
+	return events, nil
+}
+
+func scanEvent(row pgx.Row) (*models.UserEvent, error) {
+	e := &models.UserEvent{}
+	var data string
+	if err := row.Scan(&e.ID, &e.UserID, &e.Type, &e.Subject, &data, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	e.Data = json.RawMessage(data)
+	return e, nil
+}
+
+func (er *EventRepo) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := er.pool.Exec(ctx, "delete from user_events where created_at < $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("er.pool.Exec: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Listen calls f for every event appended by any instance until ctx is
+// cancelled or the connection fails. It holds a pool connection meanwhile.
+func (er *EventRepo) Listen(ctx context.Context, f func(event *models.UserEvent)) error {
+	conn, err := er.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("er.pool.Acquire: %w", err)
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, "listen "+eventsChannel); err != nil {
+		return fmt.Errorf("conn.Exec: %w", err)
+	}
+	defer func() {
+		if conn.Conn().IsClosed() {
+			return
+		}
+		if _, err := conn.Exec(context.Background(), "unlisten "+eventsChannel); err != nil {
+			logger.Log.Warn("Failed to stop listening for events", zap.Error(err))
+		}
+	}()
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("conn.WaitForNotification: %w", err)
+		}
+		n := eventNotification{}
+		if err := json.Unmarshal([]byte(notification.Payload), &n); err != nil {
+			logger.Log.Error("Invalid event notification", zap.String("payload", notification.Payload), zap.Error(err))
+			continue
+		}
+		query := "select " + eventColumns + " from user_events where user_id = $1 and seq = $2"
+		event, err := scanEvent(conn.QueryRow(ctx, query, n.UserID, n.ID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Pruned before it could be read; subscribers can't get it either way.
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("scanEvent: %w", err)
+		}
+		f(event)
+	}
+}
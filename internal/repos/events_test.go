@@ -0,0 +1,138 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventRepo(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	ur := NewUserRepo(pool)
+	er := NewEventRepo(pool)
+
+	user, err := ur.Create(ctx, &models.User{
+		Login:        fmt.Sprintf("events-test-%d", time.Now().UnixNano()),
+		EncryptedPwd: "encryptedPwd",
+	})
+	require.NoError(t, err)
+
+	listenCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	received := make(chan *models.UserEvent, 10)
+	listening := make(chan error, 1)
+	go func() {
+		listening <- er.Listen(listenCtx, func(event *models.UserEvent) {
+			if event.UserID == user.ID {
+				received <- event
+			}
+		})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	first, err := models.NewBalanceEvent(user.ID, money.FromInt(100), 0)
+	require.NoError(t, err)
+	appended, err := er.Append(ctx, first)
+	require.NoError(t, err)
+	assert.True(t, appended)
+
+	same, err := models.NewBalanceEvent(user.ID, money.FromInt(100), 0)
+	require.NoError(t, err)
+	appended, err = er.Append(ctx, same)
+	require.NoError(t, err)
+	assert.False(t, appended)
+
+	second, err := models.NewBalanceEvent(user.ID, money.FromInt(50), money.FromInt(50))
+	require.NoError(t, err)
+	appended, err = er.Append(ctx, second)
+	require.NoError(t, err)
+	assert.True(t, appended)
+
+	// Notifications only carry the event's id, so events of any size go through.
+	large := &models.UserEvent{
+		UserID:    user.ID,
+		Type:      models.UserEventOrder,
+		Subject:   "large",
+		Data:      json.RawMessage(`"` + strings.Repeat("x", 10000) + `"`),
+		CreatedAt: time.Now(),
+	}
+	appended, err = er.Append(ctx, large)
+	require.NoError(t, err)
+	assert.True(t, appended)
+
+	for _, want := range []*models.UserEvent{first, second, large} {
+		select {
+		case got := <-received:
+			assert.Equal(t, want.ID, got.ID)
+			assert.JSONEq(t, string(want.Data), string(got.Data))
+		case <-time.After(5 * time.Second):
+			t.Fatal("notification wasn't received")
+		}
+	}
+
+	events, err := er.ListAfter(ctx, user.ID, first.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, second.ID, events[0].ID)
+
+	cancel()
+	<-listening
+}
+
+// An event numbered after another can't commit before it, so a client that
+// saw the later one hasn't skipped the earlier one.
+func TestEventRepo_NumbersInCommitOrder(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	er := NewEventRepo(pool)
+	user, err := NewUserRepo(pool).Create(ctx, &models.User{
+		Login:        fmt.Sprintf("events-order-test-%d", time.Now().UnixNano()),
+		EncryptedPwd: "encryptedPwd",
+	})
+	require.NoError(t, err)
+	first, err := models.NewBalanceEvent(user.ID, money.FromInt(100), 0)
+	require.NoError(t, err)
+	_, err = er.Append(ctx, first)
+	require.NoError(t, err)
+
+	// Hold the user's counter as a slow append would.
+	tx, err := pool.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx)
+	var held int64
+	err = tx.QueryRow(ctx, "update user_event_seqs set seq = seq + 1 where user_id = $1 returning seq", user.ID).Scan(&held)
+	require.NoError(t, err)
+
+	second, err := models.NewBalanceEvent(user.ID, money.FromInt(50), 0)
+	require.NoError(t, err)
+	appended := make(chan error, 1)
+	go func() {
+		_, err := er.Append(ctx, second)
+		appended <- err
+	}()
+	select {
+	case err := <-appended:
+		t.Fatalf("append didn't wait for the earlier number to commit: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	_, err = tx.Exec(ctx, `insert into user_events (user_id, seq, type, subject, data, created_at)
+                           values ($1, $2, 'balance', 'held', '{}', now())`, user.ID, held)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit(ctx))
+	require.NoError(t, <-appended)
+	assert.Greater(t, second.ID, held)
+
+	events, err := er.ListAfter(ctx, user.ID, first.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, held, events[0].ID)
+	assert.Equal(t, second.ID, events[1].ID)
+}
@@ -0,0 +1,35 @@
+package repos
+
+import (
+	"fmt"
+	"strings"
+)
+
+// whereClause collects conditions with ? placeholders and numbers them as
+// pgx positional parameters.
+type whereClause struct {
+	conds []string
+	args  []interface{}
+}
+
+func (w *whereClause) add(cond string, args ...interface{}) {
+	for _, arg := range args {
+		w.args = append(w.args, arg)
+		cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(w.args)), 1)
+	}
+	w.conds = append(w.conds, cond)
+}
+
+// arg adds a parameter that isn't part of a condition, like a limit, and
+// returns its placeholder.
+func (w *whereClause) arg(v interface{}) string {
+	w.args = append(w.args, v)
+	return fmt.Sprintf("$%d", len(w.args))
+}
+
+func (w *whereClause) String() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return " where " + strings.Join(w.conds, " and ")
+}
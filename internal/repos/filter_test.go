@@ -0,0 +1,20 @@
+package repos
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWhereClause(t *testing.T) {
+	w := &whereClause{}
+	assert.Equal(t, "", w.String())
+
+	w.add("user_id = ?", 1)
+	w.add("status = any(?)", []string{"NEW"})
+	w.add("(uploaded_at, id) > (?, ?)", "t", 2)
+	limit := w.arg(10)
+
+	assert.Equal(t, " where user_id = $1 and status = any($2) and (uploaded_at, id) > ($3, $4)", w.String())
+	assert.Equal(t, "$5", limit)
+	assert.Equal(t, []interface{}{1, []string{"NEW"}, "t", 2, 10}, w.args)
+}
@@ -0,0 +1,105 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"time"
+)
+
+type IdempotencyRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewIdempotencyRepo(pool *pgxpool.Pool) *IdempotencyRepo {
+	return &IdempotencyRepo{pool: pool}
+}
+
+const idempotencyKeyColumns = "user_id, key, fingerprint, coalesce(status_code, 0), headers, body, created_at, expires_at, coalesce(locked_until, created_at)"
+
+func scanIdempotencyKey(row pgx.Row) (*models.IdempotencyKey, error) {
+	k := &models.IdempotencyKey{}
+	var headers []byte
+	err := row.Scan(&k.UserID, &k.Key, &k.Fingerprint, &k.StatusCode, &headers, &k.Body, &k.CreatedAt, &k.ExpiresAt, &k.LockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+	if len(headers) > 0 {
+		if err := json.Unmarshal(headers, &k.Header); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+	}
+	return k, nil
+}
+
+// Reserve stores key as in progress unless the user already has a live key
+// with the same value. It returns the stored key and whether it was reserved
+// by this call. An expired key is taken over as if it didn't exist, and so is
+// a key for the same request left in progress past its lease.
+func (ir *IdempotencyRepo) Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	insertQuery := `insert into idempotency_keys (user_id, key, fingerprint, created_at, expires_at, locked_until) values ($1, $2, $3, $4, $5, $6)
+              on conflict (user_id, key) do update
+              set fingerprint = excluded.fingerprint, status_code = null, headers = null, body = null,
+                  created_at = excluded.created_at, expires_at = excluded.expires_at, locked_until = excluded.locked_until
+              where idempotency_keys.expires_at <= excluded.created_at
+                 or (idempotency_keys.status_code is null and idempotency_keys.fingerprint = excluded.fingerprint
+                     and coalesce(idempotency_keys.locked_until, idempotency_keys.created_at) <= excluded.created_at)
+              returning ` + idempotencyKeyColumns
+	selectQuery := "select " + idempotencyKeyColumns + " from idempotency_keys where user_id = $1 and key = $2"
+	for {
+		row := ir.pool.QueryRow(ctx, insertQuery, key.UserID, key.Key, key.Fingerprint, key.CreatedAt, key.ExpiresAt, key.LockedUntil)
+		reserved, err := scanIdempotencyKey(row)
+		if err == nil {
+			return reserved, true, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, fmt.Errorf("scanIdempotencyKey: %w", err)
+		}
+
+		existing, err := scanIdempotencyKey(ir.pool.QueryRow(ctx, selectQuery, key.UserID, key.Key))
+		if err == nil {
+			return existing, false, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, fmt.Errorf("scanIdempotencyKey: %w", err)
+		}
+		// The key was released or expired since the insert; try again.
+	}
+}
+
+// Complete saves the response for a key reserved by Reserve. Nothing is saved
+// if the key was taken over since, which CreatedAt of the reservation tells.
+func (ir *IdempotencyRepo) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+	headers, err := json.Marshal(key.Header)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	query := `update idempotency_keys set status_code = $3, headers = $4, body = $5
+              where user_id = $1 and key = $2 and created_at = $6 and status_code is null`
+	if _, err := ir.pool.Exec(ctx, query, key.UserID, key.Key, key.StatusCode, string(headers), key.Body, key.CreatedAt); err != nil {
+		return fmt.Errorf("ir.pool.Exec: %w", err)
+	}
+	return nil
+}
+
+// Release drops a key that is still in progress so the request can be retried,
+// unless it was taken over since.
+func (ir *IdempotencyRepo) Release(ctx context.Context, key *models.IdempotencyKey) error {
+	query := "delete from idempotency_keys where user_id = $1 and key = $2 and created_at = $3 and status_code is null"
+	if _, err := ir.pool.Exec(ctx, query, key.UserID, key.Key, key.CreatedAt); err != nil {
+		return fmt.Errorf("ir.pool.Exec: %w", err)
+	}
+	return nil
+}
+
+func (ir *IdempotencyRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	tag, err := ir.pool.Exec(ctx, "delete from idempotency_keys where expires_at <= $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("ir.pool.Exec: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
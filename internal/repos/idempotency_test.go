@@ -0,0 +1,129 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyRepo(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	ur := NewUserRepo(pool)
+	ir := NewIdempotencyRepo(pool)
+
+	user, err := ur.Create(ctx, &models.User{
+		Login:        fmt.Sprintf("idempotency-test-%d", time.Now().UnixNano()),
+		EncryptedPwd: "encryptedPwd",
+	})
+	require.NoError(t, err)
+
+	now := time.Now().Truncate(time.Microsecond)
+	key := &models.IdempotencyKey{
+		UserID:      user.ID,
+		Key:         "someKey",
+		Fingerprint: "first",
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Hour),
+	}
+	stored, reserved, err := ir.Reserve(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	assert.False(t, stored.Completed())
+
+	stored, reserved, err = ir.Reserve(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.False(t, stored.Completed())
+
+	key.StatusCode = http.StatusAccepted
+	key.Header = http.Header{"Content-Type": {"text/plain"}}
+	key.Body = []byte("accepted")
+	require.NoError(t, ir.Complete(ctx, key))
+
+	stored, reserved, err = ir.Reserve(ctx, &models.IdempotencyKey{
+		UserID:      user.ID,
+		Key:         "someKey",
+		Fingerprint: "second",
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, "first", stored.Fingerprint)
+	assert.Equal(t, http.StatusAccepted, stored.StatusCode)
+	assert.Equal(t, key.Header, stored.Header)
+	assert.Equal(t, key.Body, stored.Body)
+
+	later := now.Add(2 * time.Hour)
+	stored, reserved, err = ir.Reserve(ctx, &models.IdempotencyKey{
+		UserID:      user.ID,
+		Key:         "someKey",
+		Fingerprint: "second",
+		CreatedAt:   later,
+		ExpiresAt:   later.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	assert.Equal(t, "second", stored.Fingerprint)
+
+	require.NoError(t, ir.Release(ctx, stored))
+	_, reserved, err = ir.Reserve(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	deleted, err := ir.DeleteExpired(ctx, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, deleted, int64(1))
+}
+
+func TestIdempotencyRepo_ReserveWhileReleased(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	ur := NewUserRepo(pool)
+	ir := NewIdempotencyRepo(pool)
+
+	user, err := ur.Create(ctx, &models.User{
+		Login:        fmt.Sprintf("idempotency-race-%d", time.Now().UnixNano()),
+		EncryptedPwd: "encryptedPwd",
+	})
+	require.NoError(t, err)
+
+	// A retry racing the release of its key must either see the key or
+	// reserve it, never fail because the key vanished in between.
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				now := time.Now()
+				key := &models.IdempotencyKey{UserID: user.ID, Key: "raceKey", Fingerprint: "fp", CreatedAt: now, ExpiresAt: now.Add(time.Hour), LockedUntil: now.Add(time.Minute)}
+				stored, reserved, err := ir.Reserve(ctx, key)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !reserved {
+					continue
+				}
+				if err := ir.Release(ctx, stored); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,572 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnbalancedEntry = errors.New("entry postings do not sum to zero")
+
+type LedgerRepo struct {
+	db dbtx
+}
+
+func NewLedgerRepo(pool *pgxpool.Pool) *LedgerRepo {
+	return &LedgerRepo{db: pool}
+}
+
+// Post records the entry and its postings in one transaction. Posting an entry
+// for an order that already has an entry of the same kind is a no-op.
+func (lr *LedgerRepo) Post(ctx context.Context, entry *models.Entry) error {
+	tx, err := lr.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("lr.db.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = lockUser(ctx, tx, entry.UserID); err != nil {
+		return fmt.Errorf("lockUser: %w", err)
+	}
+	posted, err := insertEntry(ctx, tx, entry)
+	if err != nil {
+		return fmt.Errorf("insertEntry: %w", err)
+	}
+	if posted {
+		event, err := newEntryAuditEvent(ctx, models.AuditActionAccrualPosted, entry, nil,
+			map[string]interface{}{"kind": entry.Kind, "amount": entry.Postings[0].Amount})
+		if err != nil {
+			return fmt.Errorf("newEntryAuditEvent: %w", err)
+		}
+		if err = insertAuditEvent(ctx, tx, event); err != nil {
+			return fmt.Errorf("insertAuditEvent: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+	return nil
+}
+
+// Withdraw posts the withdrawal if the user's balance covers it, taking the
+// points from the oldest unexpired lots. The user's account row is locked
+// until the transaction ends, so concurrent withdrawals of the same user are
+// serialized and can't overdraw the account.
+func (lr *LedgerRepo) Withdraw(ctx context.Context, withdrawal *models.Withdrawal) error {
+	entry := models.NewWithdrawalEntry(withdrawal.UserID, withdrawal.OrderID, withdrawal.Sum)
+	entry.CreatedAt = withdrawal.ProcessedAt
+	tx, err := lr.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("lr.db.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	balance, err := lockBalance(ctx, tx, withdrawal.UserID, entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("lockBalance: %w", err)
+	}
+
+	query := "select user_id from ledger_entries where kind = $1 and order_id = $2"
+	row := tx.QueryRow(ctx, query, models.EntryKindWithdrawal, withdrawal.OrderID)
+	var ownerID int
+	err = row.Scan(&ownerID)
+	if err == nil {
+		if ownerID == withdrawal.UserID {
+			return storage.ErrOrderAlreadyExists
+		}
+		return storage.ErrOrderCreatedByOtherUser
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("row.Scan: %w", err)
+	}
+
+	if balance < withdrawal.Sum {
+		return storage.ErrInsufficientFunds
+	}
+
+	posted, err := insertEntry(ctx, tx, entry)
+	if err != nil {
+		return fmt.Errorf("insertEntry: %w", err)
+	}
+	if !posted {
+		return storage.ErrOrderAlreadyExists
+	}
+	webhook, err := models.NewWithdrawalWebhookEvent(withdrawal)
+	if err != nil {
+		return fmt.Errorf("models.NewWithdrawalWebhookEvent: %w", err)
+	}
+	if err = enqueueWebhook(ctx, tx, webhook); err != nil {
+		return fmt.Errorf("enqueueWebhook: %w", err)
+	}
+	event, err := newEntryAuditEvent(ctx, models.AuditActionWithdrawalCreated, entry,
+		map[string]interface{}{"balance": balance},
+		map[string]interface{}{"balance": balance - withdrawal.Sum, "sum": withdrawal.Sum})
+	if err != nil {
+		return fmt.Errorf("newEntryAuditEvent: %w", err)
+	}
+	if err = insertAuditEvent(ctx, tx, event); err != nil {
+		return fmt.Errorf("insertAuditEvent: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+	return nil
+}
+
+// Adjust posts a manual balance correction. Like Withdraw it locks the user's
+// account, and it refuses a debit that would make the balance negative.
+func (lr *LedgerRepo) Adjust(ctx context.Context, adjustment *models.Adjustment) (money.Amount, error) {
+	entry := models.NewAdjustmentEntry(adjustment)
+	tx, err := lr.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("lr.db.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	balance, err := lockBalance(ctx, tx, adjustment.UserID, entry.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("lockBalance: %w", err)
+	}
+	if balance+adjustment.Amount < 0 {
+		return 0, storage.ErrInsufficientFunds
+	}
+	if _, err = insertEntry(ctx, tx, entry); err != nil {
+		return 0, fmt.Errorf("insertEntry: %w", err)
+	}
+	event, err := newEntryAuditEvent(ctx, models.AuditActionBalanceAdjusted, entry,
+		map[string]interface{}{"balance": balance},
+		map[string]interface{}{"balance": balance + adjustment.Amount, "amount": adjustment.Amount, "reason": adjustment.Reason})
+	if err != nil {
+		return 0, fmt.Errorf("newEntryAuditEvent: %w", err)
+	}
+	if err = insertAuditEvent(ctx, tx, event); err != nil {
+		return 0, fmt.Errorf("insertAuditEvent: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("tx.Commit: %w", err)
+	}
+	return balance + adjustment.Amount, nil
+}
+
+// lockBalance takes the user's lock and then their account row until the
+// transaction ends and returns the balance that can be spent at the given
+// time: points past their expiry date don't count even if they haven't been
+// expired yet.
+func lockBalance(ctx context.Context, tx pgx.Tx, userID int, at time.Time) (money.Amount, error) {
+	if err := lockUser(ctx, tx, userID); err != nil {
+		return 0, fmt.Errorf("lockUser: %w", err)
+	}
+	accountID, err := ensureAccount(ctx, tx, models.UserAccountCode(userID), userID)
+	if err != nil {
+		return 0, fmt.Errorf("ensureAccount: %w", err)
+	}
+	_, err = tx.Exec(ctx, "select id from ledger_accounts where id = $1 for update", accountID)
+	if err != nil {
+		return 0, fmt.Errorf("tx.Exec: %w", err)
+	}
+	query := `select coalesce(sum(amount), 0) - (select coalesce(sum(remaining), 0) from ledger_lots
+              where user_id = $2 and remaining > 0 and expires_at <= $3)
+              from ledger_postings where account_id = $1`
+	row := tx.QueryRow(ctx, query, accountID, userID, at)
+	var balance money.Amount
+	if err = row.Scan(&balance); err != nil {
+		return 0, fmt.Errorf("row.Scan: %w", err)
+	}
+	return balance, nil
+}
+
+// ExpirePoints expires the points of up to limit users whose lots are past
+// their expiry date at now, each user in a transaction of its own, and returns
+// the expiry entries posted.
+func (lr *LedgerRepo) ExpirePoints(ctx context.Context, now time.Time, limit int) ([]*models.Entry, error) {
+	query := `select user_id from ledger_lots where remaining > 0 and expires_at <= $1
+              group by user_id order by user_id limit $2`
+	rows, err := lr.db.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("lr.db.Query: %w", err)
+	}
+	userIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows: %w", err)
+	}
+
+	entries := make([]*models.Entry, 0, len(userIDs))
+	for _, userID := range userIDs {
+		entry, err := lr.expireLots(ctx, userID, now)
+		if err != nil {
+			return entries, fmt.Errorf("lr.expireLots: %w", err)
+		}
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// ScheduleExpiry sets the expiry date of the accrual lots that have points left
+// but no expiry date and returns how many there were.
+func (lr *LedgerRepo) ScheduleExpiry(ctx context.Context, expiresAt time.Time) (int64, error) {
+	query := `update ledger_lots l set expires_at = $1 from ledger_entries e
+              where e.id = l.entry_id and e.kind = $2 and l.remaining > 0 and l.expires_at is null`
+	tag, err := lr.db.Exec(ctx, query, expiresAt, models.EntryKindAccrual)
+	if err != nil {
+		return 0, fmt.Errorf("lr.db.Exec: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// expireLots empties the user's lots that are past their expiry date at now
+// and posts an expiry entry for what they had left. It returns nil if there
+// was nothing to expire.
+func (lr *LedgerRepo) expireLots(ctx context.Context, userID int, now time.Time) (*models.Entry, error) {
+	tx, err := lr.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("lr.db.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the account keeps withdrawals from taking the lots meanwhile.
+	if _, err = lockBalance(ctx, tx, userID, now); err != nil {
+		return nil, fmt.Errorf("lockBalance: %w", err)
+	}
+	query := `with due as (
+                  select id, remaining from ledger_lots
+                  where user_id = $1 and remaining > 0 and expires_at <= $2 for update
+              ), emptied as (
+                  update ledger_lots l set remaining = 0 from due where l.id = due.id
+              )
+              select coalesce(sum(remaining), 0) from due`
+	var expired money.Amount
+	if err = tx.QueryRow(ctx, query, userID, now).Scan(&expired); err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+	if expired == 0 {
+		return nil, nil
+	}
+
+	entry := models.NewExpiryEntry(userID, expired)
+	entry.CreatedAt = now
+	if _, err = insertEntry(ctx, tx, entry); err != nil {
+		return nil, fmt.Errorf("insertEntry: %w", err)
+	}
+	event, err := newEntryAuditEvent(ctx, models.AuditActionPointsExpired, entry, nil,
+		map[string]interface{}{"amount": expired})
+	if err != nil {
+		return nil, fmt.Errorf("newEntryAuditEvent: %w", err)
+	}
+	if err = insertAuditEvent(ctx, tx, event); err != nil {
+		return nil, fmt.Errorf("insertAuditEvent: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("tx.Commit: %w", err)
+	}
+	return entry, nil
+}
+
+// ListExpirations returns the user's points that expire after now, and before
+// until if it's set, soonest first.
+func (lr *LedgerRepo) ListExpirations(ctx context.Context, userID int, now time.Time, until *time.Time) ([]*models.Expiration, error) {
+	w := &whereClause{}
+	w.add("user_id = ?", userID)
+	w.add("remaining > 0")
+	w.add("expires_at > ?", now.Local())
+	if until != nil {
+		w.add("expires_at <= ?", until.Local())
+	}
+	query := "select sum(remaining), expires_at from ledger_lots" + w.String() + " group by expires_at order by expires_at"
+	rows, err := lr.db.Query(ctx, query, w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("lr.db.Query: %w", err)
+	}
+	defer rows.Close()
+	expirations := make([]*models.Expiration, 0)
+	for rows.Next() {
+		e := &models.Expiration{}
+		if err := rows.Scan(&e.Amount, &e.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		expirations = append(expirations, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return expirations, nil
+}
+
+func newEntryAuditEvent(ctx context.Context, action string, entry *models.Entry, before interface{}, after interface{}) (*models.AuditEvent, error) {
+	event, err := newAuditEvent(ctx, action, models.AuditEntityEntry, strconv.FormatInt(entry.ID, 10)).WithChange(before, after)
+	if err != nil {
+		return nil, fmt.Errorf("WithChange: %w", err)
+	}
+	event.UserID, event.OrderID = entry.UserID, entry.OrderID
+	return event, nil
+}
+
+func insertEntry(ctx context.Context, tx pgx.Tx, entry *models.Entry) (bool, error) {
+	if !entry.Balanced() {
+		return false, ErrUnbalancedEntry
+	}
+	query := `insert into ledger_entries (kind, order_id, user_id, created_at, reason, created_by, accrued)
+              values ($1, nullif($2::bigint, 0), $3, $4, nullif($5::text, ''), nullif($6::integer, 0), nullif($7::numeric, 0))
+              on conflict (kind, order_id) do nothing returning id`
+	row := tx.QueryRow(ctx, query, entry.Kind, entry.OrderID, entry.UserID, entry.CreatedAt, entry.Reason, entry.CreatedBy, entry.Accrued)
+	err := row.Scan(&entry.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("row.Scan: %w", err)
+	}
+
+	for _, p := range entry.Postings {
+		p.EntryID = entry.ID
+		p.AccountID, err = ensureAccount(ctx, tx, p.AccountCode, entry.UserID)
+		if err != nil {
+			return false, fmt.Errorf("ensureAccount: %w", err)
+		}
+		query = "insert into ledger_postings (entry_id, account_id, amount) values ($1, $2, $3) returning id"
+		row = tx.QueryRow(ctx, query, p.EntryID, p.AccountID, p.Amount)
+		if err = row.Scan(&p.ID); err != nil {
+			return false, fmt.Errorf("row.Scan: %w", err)
+		}
+	}
+	if err = updateLots(ctx, tx, entry); err != nil {
+		return false, fmt.Errorf("updateLots: %w", err)
+	}
+	return true, nil
+}
+
+// updateLots opens a lot for points the entry credits to the user and takes
+// points it debits from the oldest lots unexpired at the entry's creation.
+// Expiry entries are posted for lots expireLots has already emptied.
+func updateLots(ctx context.Context, tx pgx.Tx, entry *models.Entry) error {
+	if entry.Kind == models.EntryKindExpiry {
+		return nil
+	}
+	for _, p := range entry.Postings {
+		if p.AccountCode != models.UserAccountCode(entry.UserID) {
+			continue
+		}
+		if p.Amount > 0 {
+			query := `insert into ledger_lots (entry_id, user_id, amount, remaining, created_at, expires_at)
+                      values ($1, $2, $3, $3, $4, $5)`
+			if _, err := tx.Exec(ctx, query, entry.ID, entry.UserID, p.Amount, entry.CreatedAt, entry.ExpiresAt); err != nil {
+				return fmt.Errorf("tx.Exec: %w", err)
+			}
+			continue
+		}
+		if err := consumeLots(ctx, tx, entry.UserID, -p.Amount, entry.CreatedAt); err != nil {
+			return fmt.Errorf("consumeLots: %w", err)
+		}
+	}
+	return nil
+}
+
+// consumeLots takes sum from the user's lots unexpired at the given time,
+// oldest first. The balance checks keep sum within what the lots hold.
+func consumeLots(ctx context.Context, tx pgx.Tx, userID int, sum money.Amount, at time.Time) error {
+	query := `select id, remaining from ledger_lots
+              where user_id = $1 and remaining > 0 and (expires_at is null or expires_at > $2)
+              order by id for update`
+	rows, err := tx.Query(ctx, query, userID, at)
+	if err != nil {
+		return fmt.Errorf("tx.Query: %w", err)
+	}
+	type lot struct {
+		id        int64
+		remaining money.Amount
+	}
+	lots, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (lot, error) {
+		var l lot
+		return l, row.Scan(&l.id, &l.remaining)
+	})
+	if err != nil {
+		return fmt.Errorf("pgx.CollectRows: %w", err)
+	}
+	for _, l := range lots {
+		if sum == 0 {
+			break
+		}
+		taken := l.remaining
+		if taken > sum {
+			taken = sum
+		}
+		if _, err = tx.Exec(ctx, "update ledger_lots set remaining = remaining - $1 where id = $2", taken, l.id); err != nil {
+			return fmt.Errorf("tx.Exec: %w", err)
+		}
+		sum -= taken
+	}
+	return nil
+}
+
+func ensureAccount(ctx context.Context, tx pgx.Tx, code string, userID int) (int, error) {
+	var owner *int
+	if !strings.HasPrefix(code, "system:") {
+		owner = &userID
+	}
+	query := "insert into ledger_accounts (code, user_id) values ($1, $2) on conflict (code) do update set code = excluded.code returning id"
+	row := tx.QueryRow(ctx, query, code, owner)
+	var id int
+	if err := row.Scan(&id); err != nil {
+		return 0, fmt.Errorf("row.Scan: %w", err)
+	}
+	return id, nil
+}
+
+// GetBalance returns the user's balance less the points past their expiry date
+// that haven't been expired yet.
+func (lr *LedgerRepo) GetBalance(ctx context.Context, userID int) (money.Amount, error) {
+	query := `select coalesce(sum(p.amount), 0) - (select coalesce(sum(remaining), 0) from ledger_lots
+              where user_id = $2 and remaining > 0 and expires_at <= $3)
+              from ledger_postings p join ledger_accounts a on a.id = p.account_id where a.code = $1`
+	row := lr.db.QueryRow(ctx, query, models.UserAccountCode(userID), userID, time.Now())
+	var balance money.Amount
+	err := row.Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("row.Scan: %w", err)
+	}
+	return balance, nil
+}
+
+func (lr *LedgerRepo) GetWithdrawnTotal(ctx context.Context, userID int) (money.Amount, error) {
+	query := `select coalesce(sum(-p.amount), 0) from ledger_postings p
+              join ledger_entries e on e.id = p.entry_id
+              join ledger_accounts a on a.id = p.account_id
+              where a.code = $1 and e.kind = $2`
+	row := lr.db.QueryRow(ctx, query, models.UserAccountCode(userID), models.EntryKindWithdrawal)
+	var withdrawn money.Amount
+	err := row.Scan(&withdrawn)
+	if err != nil {
+		return 0, fmt.Errorf("row.Scan: %w", err)
+	}
+	return withdrawn, nil
+}
+
+// GetAccruedTotal returns what the user accrued for processed orders since the
+// given time, before tier multipliers.
+func (lr *LedgerRepo) GetAccruedTotal(ctx context.Context, userID int, since time.Time) (money.Amount, error) {
+	query := `select coalesce(sum(coalesce(e.accrued, p.amount)), 0) from ledger_entries e
+              join ledger_postings p on p.entry_id = e.id
+              join ledger_accounts a on a.id = p.account_id and a.user_id = e.user_id
+              where e.user_id = $1 and e.kind = $2 and e.created_at >= $3`
+	row := lr.db.QueryRow(ctx, query, userID, models.EntryKindAccrual, since.Local())
+	var accrued money.Amount
+	if err := row.Scan(&accrued); err != nil {
+		return 0, fmt.Errorf("row.Scan: %w", err)
+	}
+	return accrued, nil
+}
+
+func (lr *LedgerRepo) GetWithdrawal(ctx context.Context, orderID int) (*models.Withdrawal, error) {
+	query := `select e.order_id, e.user_id, -p.amount, e.created_at from ledger_entries e
+              join ledger_postings p on p.entry_id = e.id
+              join ledger_accounts a on a.id = p.account_id and a.user_id = e.user_id
+              where e.kind = $1 and e.order_id = $2`
+	row := lr.db.QueryRow(ctx, query, models.EntryKindWithdrawal, orderID)
+	w := &models.Withdrawal{}
+	err := row.Scan(&w.OrderID, &w.UserID, &w.Sum, &w.ProcessedAt)
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+	return w, nil
+}
+
+func (lr *LedgerRepo) GetWithdrawals(ctx context.Context, userID int) ([]*models.Withdrawal, error) {
+	query := `select e.order_id, e.user_id, -p.amount, e.created_at from ledger_entries e
+              join ledger_postings p on p.entry_id = e.id
+              join ledger_accounts a on a.id = p.account_id and a.user_id = e.user_id
+              where e.kind = $1 and e.user_id = $2 order by e.created_at`
+	withdrawals := make([]*models.Withdrawal, 0)
+	rows, err := lr.db.Query(ctx, query, models.EntryKindWithdrawal, userID)
+	if err != nil {
+		return nil, fmt.Errorf("lr.db.Query: %w", err)
+	}
+	for rows.Next() {
+		w := &models.Withdrawal{}
+		err := rows.Scan(&w.OrderID, &w.UserID, &w.Sum, &w.ProcessedAt)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		withdrawals = append(withdrawals, w)
+	}
+	return withdrawals, nil
+}
+
+// ListWithdrawals returns up to filter.Limit of the user's withdrawals matching
+// the filter, oldest first, starting after filter.After. The cursor id is the
+// withdrawal's order number.
+func (lr *LedgerRepo) ListWithdrawals(ctx context.Context, userID int, filter models.ListFilter) ([]*models.Withdrawal, error) {
+	w := &whereClause{}
+	w.add("e.kind = ?", models.EntryKindWithdrawal)
+	w.add("e.user_id = ?", userID)
+	if filter.From != nil {
+		w.add("e.created_at >= ?", filter.From.Local())
+	}
+	if filter.To != nil {
+		w.add("e.created_at < ?", filter.To.Local())
+	}
+	if filter.MinAmount != nil {
+		w.add("-p.amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		w.add("-p.amount <= ?", *filter.MaxAmount)
+	}
+	if filter.After != nil {
+		w.add("(e.created_at, e.order_id) > (?, ?)", filter.After.Time, filter.After.ID)
+	}
+	query := `select e.order_id, e.user_id, -p.amount, e.created_at from ledger_entries e
+              join ledger_postings p on p.entry_id = e.id
+              join ledger_accounts a on a.id = p.account_id and a.user_id = e.user_id` +
+		w.String() + " order by e.created_at, e.order_id limit " + w.arg(filter.Limit)
+
+	rows, err := lr.db.Query(ctx, query, w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("lr.db.Query: %w", err)
+	}
+	defer rows.Close()
+	withdrawals := make([]*models.Withdrawal, 0)
+	for rows.Next() {
+		wd := &models.Withdrawal{}
+		err := rows.Scan(&wd.OrderID, &wd.UserID, &wd.Sum, &wd.ProcessedAt)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		withdrawals = append(withdrawals, wd)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return withdrawals, nil
+}
+
+func (lr *LedgerRepo) GetTrialBalance(ctx context.Context) ([]*models.AccountBalance, error) {
+	query := `select a.id, a.code, a.user_id, coalesce(sum(p.amount), 0) from ledger_accounts a
+              left join ledger_postings p on p.account_id = a.id
+              group by a.id order by a.id`
+	balances := make([]*models.AccountBalance, 0)
+	rows, err := lr.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("lr.db.Query: %w", err)
+	}
+	for rows.Next() {
+		b := &models.AccountBalance{Account: &models.Account{}}
+		err := rows.Scan(&b.Account.ID, &b.Account.Code, &b.Account.UserID, &b.Balance)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		balances = append(balances, b)
+	}
+	return balances, nil
+}
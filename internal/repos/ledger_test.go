@@ -0,0 +1,157 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/database"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testPool(t *testing.T) *pgxpool.Pool {
+	uri := os.Getenv("TEST_DATABASE_URI")
+	if uri == "" {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+	pool, err := database.New(context.Background(), uri)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestLedgerRepo_WithdrawConcurrently(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	ur := NewUserRepo(pool)
+	lr := NewLedgerRepo(pool)
+
+	user, err := ur.Create(ctx, &models.User{
+		Login:        fmt.Sprintf("ledger-test-%d", time.Now().UnixNano()),
+		EncryptedPwd: "encryptedPwd",
+	})
+	require.NoError(t, err)
+
+	baseOrderID := int(time.Now().UnixNano() / 1000)
+	err = lr.Post(ctx, models.NewAccrualEntry(user.ID, baseOrderID, money.FromInt(100)))
+	require.NoError(t, err)
+
+	const attempts = 300
+	var succeeded, rejected atomic.Int32
+	wg := sync.WaitGroup{}
+	for i := 1; i <= attempts; i++ {
+		wg.Add(1)
+		go func(orderID int) {
+			defer wg.Done()
+			err := lr.Withdraw(ctx, &models.Withdrawal{
+				OrderID:     orderID,
+				UserID:      user.ID,
+				Sum:         money.FromInt(1),
+				ProcessedAt: time.Now(),
+			})
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case errors.Is(err, storage.ErrInsufficientFunds):
+				rejected.Add(1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(baseOrderID + i)
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, 100, succeeded.Load())
+	assert.EqualValues(t, attempts-100, rejected.Load())
+
+	balance, err := lr.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(0), balance)
+
+	withdrawn, err := lr.GetWithdrawnTotal(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromInt(100), withdrawn)
+}
+
+// Crediting an order locks the order and then the user, withdrawing locks the
+// user and then their account; neither may deadlock the other.
+func TestLedgerRepo_WithdrawWhileCrediting(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	ur := NewUserRepo(pool)
+	or := NewOrdersRepo(pool)
+	lr := NewLedgerRepo(pool)
+	s := storage.New(ur, or, lr, NewSessionRepo(pool), NewAuditRepo(pool), NewIdempotencyRepo(pool), NewWebhookRepo(pool), NewTierRepo(pool), NewTx(pool), nil)
+
+	user, err := ur.Create(ctx, &models.User{
+		Login:        fmt.Sprintf("credit-test-%d", time.Now().UnixNano()),
+		EncryptedPwd: "encryptedPwd",
+	})
+	require.NoError(t, err)
+	baseOrderID := int(time.Now().UnixNano() / 1000)
+	require.NoError(t, lr.Post(ctx, models.NewAccrualEntry(user.ID, baseOrderID, money.FromInt(100))))
+
+	const orders = 50
+	for i := 1; i <= orders; i++ {
+		_, err = or.Create(ctx, &models.Order{ID: baseOrderID + i, UserID: user.ID, Status: models.OrderStatusNew, UploadedAt: time.Now()})
+		require.NoError(t, err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 1; i <= orders; i++ {
+		wg.Add(2)
+		go func(orderID int) {
+			defer wg.Done()
+			_, err := s.UpdateOrder(ctx, orderID, "", models.OrderStatusProcessed, money.FromInt(1))
+			assert.NoError(t, err)
+		}(baseOrderID + i)
+		go func(orderID int) {
+			defer wg.Done()
+			err := lr.Withdraw(ctx, &models.Withdrawal{OrderID: orderID, UserID: user.ID, Sum: money.FromInt(1), ProcessedAt: time.Now()})
+			assert.NoError(t, err)
+		}(baseOrderID + orders + i)
+	}
+	wg.Wait()
+
+	balance, err := lr.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromInt(100), balance)
+}
+
+func TestLedgerRepo_Adjust(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	ur := NewUserRepo(pool)
+	lr := NewLedgerRepo(pool)
+
+	user, err := ur.Create(ctx, &models.User{
+		Login:        fmt.Sprintf("adjust-test-%d", time.Now().UnixNano()),
+		EncryptedPwd: "encryptedPwd",
+	})
+	require.NoError(t, err)
+
+	balance, err := lr.Adjust(ctx, &models.Adjustment{UserID: user.ID, Amount: money.FromInt(50), Reason: "goodwill", CreatedBy: user.ID})
+	require.NoError(t, err)
+	assert.Equal(t, money.FromInt(50), balance)
+
+	// Adjustments have no order, so several of them don't conflict.
+	balance, err = lr.Adjust(ctx, &models.Adjustment{UserID: user.ID, Amount: money.FromInt(-20), Reason: "duplicate accrual", CreatedBy: user.ID})
+	require.NoError(t, err)
+	assert.Equal(t, money.FromInt(30), balance)
+
+	_, err = lr.Adjust(ctx, &models.Adjustment{UserID: user.ID, Amount: money.FromInt(-31), Reason: "chargeback", CreatedBy: user.ID})
+	assert.ErrorIs(t, err, storage.ErrInsufficientFunds)
+
+	balance, err = lr.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromInt(30), balance)
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/vindosVP/loyalty-system/internal/models"
+)
+
+type AuditRepo struct {
+	db *DB
+}
+
+func NewAuditRepo(db *DB) *AuditRepo {
+	return &AuditRepo{db: db}
+}
+
+func copyAuditEvent(e *models.AuditEvent) *models.AuditEvent {
+	c := *e
+	c.Before = append(json.RawMessage(nil), e.Before...)
+	c.After = append(json.RawMessage(nil), e.After...)
+	if len(e.Before) == 0 {
+		c.Before = nil
+	}
+	if len(e.After) == 0 {
+		c.After = nil
+	}
+	return &c
+}
+
+// Append records an event that doesn't accompany a change, such as a failed
+// login. Actor and request details missing from the event are taken from ctx.
+func (ar *AuditRepo) Append(ctx context.Context, event *models.AuditEvent) error {
+	fromCtx := newAuditEvent(ctx, event.Action, event.EntityType, event.EntityID)
+	if event.ActorID == 0 {
+		event.ActorID = fromCtx.ActorID
+	}
+	if event.RequestID == "" {
+		event.RequestID = fromCtx.RequestID
+	}
+	if event.IP == "" {
+		event.IP = fromCtx.IP
+	}
+	ar.db.mu.Lock()
+	defer ar.db.mu.Unlock()
+	ar.db.appendAudit(event)
+	return nil
+}
+
+// Query returns the newest events matching the filter. Zero fields don't
+// filter; BeforeID pages back through older events.
+func (ar *AuditRepo) Query(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, error) {
+	ar.db.mu.Lock()
+	defer ar.db.mu.Unlock()
+	events := make([]*models.AuditEvent, 0)
+	for i := len(ar.db.audit) - 1; i >= 0 && len(events) < filter.Limit; i-- {
+		e := ar.db.audit[i]
+		if filter.UserID != 0 && e.UserID != filter.UserID ||
+			filter.OrderID != 0 && e.OrderID != filter.OrderID ||
+			filter.Action != "" && e.Action != filter.Action ||
+			filter.BeforeID != 0 && e.ID >= filter.BeforeID {
+			continue
+		}
+		events = append(events, copyAuditEvent(e))
+	}
+	return events, nil
+}
+
+// Verify walks every user's chain and reports the first event whose hash
+// doesn't match its contents or its predecessor.
+func (ar *AuditRepo) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	ar.db.mu.Lock()
+	defer ar.db.mu.Unlock()
+	res := &models.AuditVerification{Valid: true}
+	prevHashes := make(map[int]string)
+	for _, e := range ar.db.audit {
+		res.Checked++
+		if !e.Verify(prevHashes[e.UserID]) {
+			res.Valid = false
+			res.BrokenAt = e.ID
+			return res, nil
+		}
+		prevHashes[e.UserID] = e.Hash
+	}
+	return res, nil
+}
@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"github.com/vindosVP/loyalty-system/internal/storage/storagetest"
+	"testing"
+)
+
+func TestContract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) *storagetest.Backend {
+		db := New()
+		return &storagetest.Backend{Users: NewUserRepo(db), Orders: NewOrdersRepo(db), Ledger: NewLedgerRepo(db), Tiers: NewTierRepo(db), Idempotency: NewIdempotencyRepo(db), Tx: NewTx(db)}
+	})
+}
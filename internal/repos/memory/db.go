@@ -0,0 +1,95 @@
+// Package memory implements the storage repos in process memory, for local
+// development and tests that don't need PostgreSQL. The repos share a DB
+// guarded by a single mutex, so a change and the audit event or webhook
+// deliveries it causes are applied atomically, like the PostgreSQL repos do
+// in one transaction. Nothing survives a restart.
+package memory
+
+import (
+	"context"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/requestmeta"
+	"sync"
+	"time"
+)
+
+// URI is the DATABASE_URI that selects the in-memory backend.
+const URI = "memory://"
+
+type DB struct {
+	mu sync.Mutex
+
+	users           []*models.User
+	orders          map[int]*order
+	accounts        []*models.Account
+	entries         []*models.Entry
+	postingSeq      int64
+	lots            []*models.Lot
+	sessions        map[string]*models.Session
+	audit           []*models.AuditEvent
+	idempotencyKeys map[idempotencyKeyID]*models.IdempotencyKey
+	endpoints       []*models.WebhookEndpoint
+	endpointSeq     int
+	deliveries      []*delivery
+	deliverySeq     int64
+	tierRules       []*models.TierRule
+	tierChanges     []*models.TierChange
+	events          []*models.UserEvent
+	eventSeqs       map[int]int64
+	listeners       map[int]func(event *models.UserEvent)
+	listenerSeq     int
+	notifyMu        sync.Mutex
+}
+
+func New() *DB {
+	return &DB{
+		orders:          make(map[int]*order),
+		sessions:        make(map[string]*models.Session),
+		idempotencyKeys: make(map[idempotencyKeyID]*models.IdempotencyKey),
+		eventSeqs:       make(map[int]int64),
+		listeners:       make(map[int]func(event *models.UserEvent)),
+		tierRules:       defaultTierRules(),
+	}
+}
+
+// Ping always succeeds, there's nothing to connect to.
+func (db *DB) Ping(context.Context) error {
+	return nil
+}
+
+// pgTime truncates t to microseconds, the precision PostgreSQL stores, so
+// both backends return the same timestamps.
+func pgTime(t time.Time) time.Time {
+	return t.Truncate(time.Microsecond)
+}
+
+// newAuditEvent starts an event attributed to the authenticated caller and
+// the HTTP request found in ctx, if any.
+func newAuditEvent(ctx context.Context, action string, entityType string, entityID string) *models.AuditEvent {
+	event := models.NewAuditEvent(action, entityType, entityID)
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		event.ActorID = principal.ID
+	}
+	if meta, ok := requestmeta.FromContext(ctx); ok {
+		event.RequestID = meta.RequestID
+		event.IP = meta.IP
+	}
+	return event
+}
+
+// appendAudit chains the event to its user's events in the log. The caller
+// must hold db.mu.
+func (db *DB) appendAudit(event *models.AuditEvent) {
+	prevHash := ""
+	for i := len(db.audit) - 1; i >= 0; i-- {
+		if db.audit[i].UserID == event.UserID {
+			prevHash = db.audit[i].Hash
+			break
+		}
+	}
+	event.Chain(prevHash)
+	event.ID = int64(len(db.audit) + 1)
+	stored := *event
+	db.audit = append(db.audit, &stored)
+}
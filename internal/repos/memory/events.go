@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"time"
+)
+
+// EventRepo keeps user events and hands appended ones to the listeners of
+// this process, the only instance there is.
+type EventRepo struct {
+	db *DB
+}
+
+func NewEventRepo(db *DB) *EventRepo {
+	return &EventRepo{db: db}
+}
+
+func copyUserEvent(e *models.UserEvent) *models.UserEvent {
+	c := *e
+	c.Data = append(json.RawMessage(nil), e.Data...)
+	return &c
+}
+
+// Append stores the event and passes it to every listener. It returns false
+// without storing anything if the latest event on the same subject carries
+// the same data, as happens when an order is polled again without a status
+// change.
+func (er *EventRepo) Append(ctx context.Context, event *models.UserEvent) (bool, error) {
+	// notifyMu keeps listeners seeing events in order without calling them
+	// under db.mu.
+	er.db.notifyMu.Lock()
+	defer er.db.notifyMu.Unlock()
+
+	er.db.mu.Lock()
+	for i := len(er.db.events) - 1; i >= 0; i-- {
+		latest := er.db.events[i]
+		if latest.UserID != event.UserID || latest.Subject != event.Subject {
+			continue
+		}
+		if bytes.Equal(latest.Data, event.Data) {
+			er.db.mu.Unlock()
+			return false, nil
+		}
+		break
+	}
+	er.db.eventSeqs[event.UserID]++
+	event.ID = er.db.eventSeqs[event.UserID]
+	event.CreatedAt = pgTime(event.CreatedAt)
+	er.db.events = append(er.db.events, copyUserEvent(event))
+	listeners := make([]func(event *models.UserEvent), 0, len(er.db.listeners))
+	for _, f := range er.db.listeners {
+		listeners = append(listeners, f)
+	}
+	er.db.mu.Unlock()
+
+	for _, f := range listeners {
+		f(copyUserEvent(event))
+	}
+	return true, nil
+}
+
+// ListAfter returns up to limit of the user's events numbered above afterID,
+// oldest first.
+func (er *EventRepo) ListAfter(ctx context.Context, userID int, afterID int64, limit int) ([]*models.UserEvent, error) {
+	er.db.mu.Lock()
+	defer er.db.mu.Unlock()
+	events := make([]*models.UserEvent, 0)
+	for _, e := range er.db.events {
+		if len(events) == limit {
+			break
+		}
+		if e.UserID == userID && e.ID > afterID {
+			events = append(events, copyUserEvent(e))
+		}
+	}
+	return events, nil
+}
+
+func (er *EventRepo) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	er.db.mu.Lock()
+	defer er.db.mu.Unlock()
+	kept := er.db.events[:0:0]
+	for _, e := range er.db.events {
+		if !e.CreatedAt.Before(before) {
+			kept = append(kept, e)
+		}
+	}
+	deleted := int64(len(er.db.events) - len(kept))
+	er.db.events = kept
+	return deleted, nil
+}
+
+// Listen calls f for every event appended until ctx is cancelled.
+func (er *EventRepo) Listen(ctx context.Context, f func(event *models.UserEvent)) error {
+	er.db.mu.Lock()
+	er.db.listenerSeq++
+	id := er.db.listenerSeq
+	er.db.listeners[id] = f
+	er.db.mu.Unlock()
+
+	<-ctx.Done()
+
+	er.db.mu.Lock()
+	delete(er.db.listeners, id)
+	er.db.mu.Unlock()
+	return ctx.Err()
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"time"
+)
+
+type idempotencyKeyID struct {
+	userID int
+	key    string
+}
+
+type IdempotencyRepo struct {
+	db *DB
+}
+
+func NewIdempotencyRepo(db *DB) *IdempotencyRepo {
+	return &IdempotencyRepo{db: db}
+}
+
+func copyIdempotencyKey(k *models.IdempotencyKey) *models.IdempotencyKey {
+	c := *k
+	c.Header = k.Header.Clone()
+	c.Body = append([]byte(nil), k.Body...)
+	return &c
+}
+
+// Reserve stores key as in progress unless the user already has a live key
+// with the same value. It returns the stored key and whether it was reserved
+// by this call. An expired key is taken over as if it didn't exist, and so is
+// a key for the same request left in progress past its lease.
+func (ir *IdempotencyRepo) Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	ir.db.mu.Lock()
+	defer ir.db.mu.Unlock()
+	id := idempotencyKeyID{userID: key.UserID, key: key.Key}
+	if existing, ok := ir.db.idempotencyKeys[id]; ok && existing.ExpiresAt.After(key.CreatedAt) {
+		stale := !existing.Completed() && existing.Fingerprint == key.Fingerprint && !existing.LockedUntil.After(key.CreatedAt)
+		if !stale {
+			return copyIdempotencyKey(existing), false, nil
+		}
+	}
+	reserved := &models.IdempotencyKey{
+		UserID:      key.UserID,
+		Key:         key.Key,
+		Fingerprint: key.Fingerprint,
+		CreatedAt:   pgTime(key.CreatedAt),
+		ExpiresAt:   pgTime(key.ExpiresAt),
+		LockedUntil: pgTime(key.LockedUntil),
+	}
+	ir.db.idempotencyKeys[id] = reserved
+	return copyIdempotencyKey(reserved), true, nil
+}
+
+// Complete saves the response for a key reserved by Reserve. Nothing is saved
+// if the key was taken over since, which CreatedAt of the reservation tells.
+func (ir *IdempotencyRepo) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+	ir.db.mu.Lock()
+	defer ir.db.mu.Unlock()
+	stored, ok := ir.db.idempotencyKeys[idempotencyKeyID{userID: key.UserID, key: key.Key}]
+	if !ok || stored.Completed() || !stored.CreatedAt.Equal(pgTime(key.CreatedAt)) {
+		return nil
+	}
+	stored.StatusCode = key.StatusCode
+	stored.Header = key.Header.Clone()
+	stored.Body = append([]byte(nil), key.Body...)
+	return nil
+}
+
+// Release drops a key that is still in progress so the request can be retried,
+// unless it was taken over since.
+func (ir *IdempotencyRepo) Release(ctx context.Context, key *models.IdempotencyKey) error {
+	ir.db.mu.Lock()
+	defer ir.db.mu.Unlock()
+	id := idempotencyKeyID{userID: key.UserID, key: key.Key}
+	if stored, ok := ir.db.idempotencyKeys[id]; ok && !stored.Completed() && stored.CreatedAt.Equal(pgTime(key.CreatedAt)) {
+		delete(ir.db.idempotencyKeys, id)
+	}
+	return nil
+}
+
+func (ir *IdempotencyRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	ir.db.mu.Lock()
+	defer ir.db.mu.Unlock()
+	var deleted int64
+	for id, k := range ir.db.idempotencyKeys {
+		if !k.ExpiresAt.After(now) {
+			delete(ir.db.idempotencyKeys, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,447 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnbalancedEntry = errors.New("entry postings do not sum to zero")
+
+type LedgerRepo struct {
+	db *DB
+	// mu is db.mu, or a no-op within a transaction, which holds db.mu.
+	mu sync.Locker
+}
+
+func NewLedgerRepo(db *DB) *LedgerRepo {
+	return &LedgerRepo{db: db, mu: &db.mu}
+}
+
+// Post records the entry. Posting an entry for an order that already has an
+// entry of the same kind is a no-op.
+func (lr *LedgerRepo) Post(ctx context.Context, entry *models.Entry) error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	if !entry.Balanced() {
+		return ErrUnbalancedEntry
+	}
+	if lr.db.findEntry(entry.Kind, entry.OrderID) != nil {
+		return nil
+	}
+	lr.db.insertEntry(entry)
+	event, err := newEntryAuditEvent(ctx, models.AuditActionAccrualPosted, entry, nil,
+		map[string]interface{}{"kind": entry.Kind, "amount": entry.Postings[0].Amount})
+	if err != nil {
+		return fmt.Errorf("newEntryAuditEvent: %w", err)
+	}
+	lr.db.appendAudit(event)
+	return nil
+}
+
+// Withdraw posts the withdrawal if the user's balance covers it, taking the
+// points from the oldest unexpired lots.
+func (lr *LedgerRepo) Withdraw(ctx context.Context, withdrawal *models.Withdrawal) error {
+	entry := models.NewWithdrawalEntry(withdrawal.UserID, withdrawal.OrderID, withdrawal.Sum)
+	entry.CreatedAt = withdrawal.ProcessedAt
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	if existing := lr.db.findEntry(models.EntryKindWithdrawal, withdrawal.OrderID); existing != nil {
+		if existing.UserID == withdrawal.UserID {
+			return storage.ErrOrderAlreadyExists
+		}
+		return storage.ErrOrderCreatedByOtherUser
+	}
+	balance := lr.db.spendable(withdrawal.UserID, entry.CreatedAt)
+	if balance < withdrawal.Sum {
+		return storage.ErrInsufficientFunds
+	}
+	webhook, err := models.NewWithdrawalWebhookEvent(withdrawal)
+	if err != nil {
+		return fmt.Errorf("models.NewWithdrawalWebhookEvent: %w", err)
+	}
+	if err = lr.db.enqueueWebhook(webhook); err != nil {
+		return fmt.Errorf("enqueueWebhook: %w", err)
+	}
+	lr.db.insertEntry(entry)
+	event, err := newEntryAuditEvent(ctx, models.AuditActionWithdrawalCreated, entry,
+		map[string]interface{}{"balance": balance},
+		map[string]interface{}{"balance": balance - withdrawal.Sum, "sum": withdrawal.Sum})
+	if err != nil {
+		return fmt.Errorf("newEntryAuditEvent: %w", err)
+	}
+	lr.db.appendAudit(event)
+	return nil
+}
+
+// Adjust posts a manual balance correction. It refuses a debit that would
+// make the balance negative.
+func (lr *LedgerRepo) Adjust(ctx context.Context, adjustment *models.Adjustment) (money.Amount, error) {
+	entry := models.NewAdjustmentEntry(adjustment)
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	balance := lr.db.spendable(adjustment.UserID, entry.CreatedAt)
+	if balance+adjustment.Amount < 0 {
+		return 0, storage.ErrInsufficientFunds
+	}
+	lr.db.insertEntry(entry)
+	event, err := newEntryAuditEvent(ctx, models.AuditActionBalanceAdjusted, entry,
+		map[string]interface{}{"balance": balance},
+		map[string]interface{}{"balance": balance + adjustment.Amount, "amount": adjustment.Amount, "reason": adjustment.Reason})
+	if err != nil {
+		return 0, fmt.Errorf("newEntryAuditEvent: %w", err)
+	}
+	lr.db.appendAudit(event)
+	return balance + adjustment.Amount, nil
+}
+
+func newEntryAuditEvent(ctx context.Context, action string, entry *models.Entry, before interface{}, after interface{}) (*models.AuditEvent, error) {
+	event, err := newAuditEvent(ctx, action, models.AuditEntityEntry, strconv.FormatInt(entry.ID, 10)).WithChange(before, after)
+	if err != nil {
+		return nil, fmt.Errorf("WithChange: %w", err)
+	}
+	event.UserID, event.OrderID = entry.UserID, entry.OrderID
+	return event, nil
+}
+
+// findEntry returns the entry of the kind for the order or nil. Entries
+// without an order are never found. The caller must hold db.mu.
+func (db *DB) findEntry(kind string, orderID int) *models.Entry {
+	if orderID == 0 {
+		return nil
+	}
+	for _, e := range db.entries {
+		if e.Kind == kind && e.OrderID == orderID {
+			return e
+		}
+	}
+	return nil
+}
+
+// insertEntry stores a copy of the balanced entry and fills in the ids. The
+// caller must hold db.mu.
+func (db *DB) insertEntry(entry *models.Entry) {
+	entry.ID = int64(len(db.entries) + 1)
+	entry.CreatedAt = pgTime(entry.CreatedAt)
+	stored := *entry
+	stored.Postings = make([]*models.Posting, len(entry.Postings))
+	for i, p := range entry.Postings {
+		db.postingSeq++
+		p.ID, p.EntryID = db.postingSeq, entry.ID
+		p.AccountID = db.ensureAccount(p.AccountCode, entry.UserID).ID
+		posting := *p
+		stored.Postings[i] = &posting
+	}
+	db.entries = append(db.entries, &stored)
+	db.updateLots(&stored)
+}
+
+// updateLots opens a lot for points the entry credits to the user and takes
+// points it debits from the oldest lots unexpired at the entry's creation.
+// Expiry entries are posted for lots expirePoints has already emptied. The
+// caller must hold db.mu.
+func (db *DB) updateLots(entry *models.Entry) {
+	if entry.Kind == models.EntryKindExpiry {
+		return
+	}
+	for _, p := range entry.Postings {
+		if p.AccountCode != models.UserAccountCode(entry.UserID) {
+			continue
+		}
+		if p.Amount > 0 {
+			lot := &models.Lot{
+				ID:        int64(len(db.lots) + 1),
+				EntryID:   entry.ID,
+				UserID:    entry.UserID,
+				Amount:    p.Amount,
+				Remaining: p.Amount,
+				CreatedAt: entry.CreatedAt,
+			}
+			if entry.ExpiresAt != nil {
+				expiresAt := pgTime(*entry.ExpiresAt)
+				lot.ExpiresAt = &expiresAt
+			}
+			db.lots = append(db.lots, lot)
+			continue
+		}
+		// The balance checks keep the debit within what the lots hold.
+		sum := -p.Amount
+		for _, lot := range db.lots {
+			if sum == 0 {
+				break
+			}
+			if lot.UserID != entry.UserID || lot.Remaining == 0 || expired(lot, entry.CreatedAt) {
+				continue
+			}
+			taken := lot.Remaining
+			if taken > sum {
+				taken = sum
+			}
+			lot.Remaining -= taken
+			sum -= taken
+		}
+	}
+}
+
+func expired(lot *models.Lot, at time.Time) bool {
+	return lot.ExpiresAt != nil && !lot.ExpiresAt.After(at)
+}
+
+// spendable returns the user's balance less the points past their expiry date
+// at the given time that haven't been expired yet. The caller must hold db.mu.
+func (db *DB) spendable(userID int, at time.Time) money.Amount {
+	balance := db.balance(models.UserAccountCode(userID))
+	for _, lot := range db.lots {
+		if lot.UserID == userID && expired(lot, at) {
+			balance -= lot.Remaining
+		}
+	}
+	return balance
+}
+
+// ExpirePoints expires the points of up to limit users whose lots are past
+// their expiry date at now and returns the expiry entries posted.
+func (lr *LedgerRepo) ExpirePoints(ctx context.Context, now time.Time, limit int) ([]*models.Entry, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	due := make(map[int]money.Amount)
+	for _, lot := range lr.db.lots {
+		if lot.Remaining > 0 && expired(lot, now) {
+			due[lot.UserID] += lot.Remaining
+		}
+	}
+	userIDs := make([]int, 0, len(due))
+	for userID := range due {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Ints(userIDs)
+	if len(userIDs) > limit {
+		userIDs = userIDs[:limit]
+	}
+
+	entries := make([]*models.Entry, 0, len(userIDs))
+	for _, userID := range userIDs {
+		entry := models.NewExpiryEntry(userID, due[userID])
+		entry.CreatedAt = now
+		for _, lot := range lr.db.lots {
+			if lot.UserID == userID && expired(lot, now) {
+				lot.Remaining = 0
+			}
+		}
+		lr.db.insertEntry(entry)
+		event, err := newEntryAuditEvent(ctx, models.AuditActionPointsExpired, entry, nil,
+			map[string]interface{}{"amount": due[userID]})
+		if err != nil {
+			return entries, fmt.Errorf("newEntryAuditEvent: %w", err)
+		}
+		lr.db.appendAudit(event)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ScheduleExpiry sets the expiry date of the accrual lots that have points left
+// but no expiry date and returns how many there were.
+func (lr *LedgerRepo) ScheduleExpiry(ctx context.Context, expiresAt time.Time) (int64, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	accruals := make(map[int64]bool)
+	for _, e := range lr.db.entries {
+		accruals[e.ID] = e.Kind == models.EntryKindAccrual
+	}
+	var n int64
+	for _, lot := range lr.db.lots {
+		if lot.Remaining == 0 || lot.ExpiresAt != nil || !accruals[lot.EntryID] {
+			continue
+		}
+		t := pgTime(expiresAt)
+		lot.ExpiresAt = &t
+		n++
+	}
+	return n, nil
+}
+
+// ListExpirations returns the user's points that expire after now, and before
+// until if it's set, soonest first.
+func (lr *LedgerRepo) ListExpirations(ctx context.Context, userID int, now time.Time, until *time.Time) ([]*models.Expiration, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	expirations := make([]*models.Expiration, 0)
+	byTime := make(map[int64]*models.Expiration)
+	for _, lot := range lr.db.lots {
+		if lot.UserID != userID || lot.Remaining == 0 || lot.ExpiresAt == nil || expired(lot, now) {
+			continue
+		}
+		if until != nil && lot.ExpiresAt.After(*until) {
+			continue
+		}
+		e, ok := byTime[lot.ExpiresAt.UnixMicro()]
+		if !ok {
+			e = &models.Expiration{ExpiresAt: *lot.ExpiresAt}
+			byTime[lot.ExpiresAt.UnixMicro()] = e
+			expirations = append(expirations, e)
+		}
+		e.Amount += lot.Remaining
+	}
+	sort.Slice(expirations, func(i, j int) bool { return expirations[i].ExpiresAt.Before(expirations[j].ExpiresAt) })
+	return expirations, nil
+}
+
+// ensureAccount returns the account with the code, opening it if needed.
+// The caller must hold db.mu.
+func (db *DB) ensureAccount(code string, userID int) *models.Account {
+	for _, a := range db.accounts {
+		if a.Code == code {
+			return a
+		}
+	}
+	account := &models.Account{ID: len(db.accounts) + 1, Code: code}
+	if !strings.HasPrefix(code, "system:") {
+		owner := userID
+		account.UserID = &owner
+	}
+	db.accounts = append(db.accounts, account)
+	return account
+}
+
+// balance sums the postings to the account. The caller must hold db.mu.
+func (db *DB) balance(code string) money.Amount {
+	var balance money.Amount
+	for _, e := range db.entries {
+		for _, p := range e.Postings {
+			if p.AccountCode == code {
+				balance += p.Amount
+			}
+		}
+	}
+	return balance
+}
+
+// GetBalance returns the user's balance less the points past their expiry date
+// that haven't been expired yet.
+func (lr *LedgerRepo) GetBalance(ctx context.Context, userID int) (money.Amount, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.db.spendable(userID, time.Now()), nil
+}
+
+func (lr *LedgerRepo) GetWithdrawnTotal(ctx context.Context, userID int) (money.Amount, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	var withdrawn money.Amount
+	for _, w := range lr.db.withdrawals(func(*models.Withdrawal) bool { return true }, userID) {
+		withdrawn += w.Sum
+	}
+	return withdrawn, nil
+}
+
+// GetAccruedTotal returns what the user accrued for processed orders since the
+// given time, before tier multipliers.
+func (lr *LedgerRepo) GetAccruedTotal(ctx context.Context, userID int, since time.Time) (money.Amount, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	var accrued money.Amount
+	for _, e := range lr.db.entries {
+		if e.Kind != models.EntryKindAccrual || e.UserID != userID || e.CreatedAt.Before(since) {
+			continue
+		}
+		if e.Accrued != 0 {
+			accrued += e.Accrued
+			continue
+		}
+		for _, p := range e.Postings {
+			if p.AccountCode == models.UserAccountCode(userID) {
+				accrued += p.Amount
+			}
+		}
+	}
+	return accrued, nil
+}
+
+// withdrawals returns the user's withdrawals matching f, oldest first. The
+// caller must hold db.mu.
+func (db *DB) withdrawals(f func(w *models.Withdrawal) bool, userID int) []*models.Withdrawal {
+	withdrawals := make([]*models.Withdrawal, 0)
+	for _, e := range db.entries {
+		if e.Kind != models.EntryKindWithdrawal || e.UserID != userID {
+			continue
+		}
+		w := withdrawalOf(e)
+		if f(w) {
+			withdrawals = append(withdrawals, w)
+		}
+	}
+	sort.SliceStable(withdrawals, func(i, j int) bool {
+		if !withdrawals[i].ProcessedAt.Equal(withdrawals[j].ProcessedAt) {
+			return withdrawals[i].ProcessedAt.Before(withdrawals[j].ProcessedAt)
+		}
+		return withdrawals[i].OrderID < withdrawals[j].OrderID
+	})
+	return withdrawals
+}
+
+func withdrawalOf(e *models.Entry) *models.Withdrawal {
+	w := &models.Withdrawal{OrderID: e.OrderID, UserID: e.UserID, ProcessedAt: e.CreatedAt}
+	for _, p := range e.Postings {
+		if p.AccountCode == models.UserAccountCode(e.UserID) {
+			w.Sum = -p.Amount
+		}
+	}
+	return w
+}
+
+func (lr *LedgerRepo) GetWithdrawal(ctx context.Context, orderID int) (*models.Withdrawal, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	e := lr.db.findEntry(models.EntryKindWithdrawal, orderID)
+	if e == nil {
+		return nil, fmt.Errorf("withdrawal for order %d not found", orderID)
+	}
+	return withdrawalOf(e), nil
+}
+
+func (lr *LedgerRepo) GetWithdrawals(ctx context.Context, userID int) ([]*models.Withdrawal, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.db.withdrawals(func(*models.Withdrawal) bool { return true }, userID), nil
+}
+
+// ListWithdrawals returns up to filter.Limit of the user's withdrawals matching
+// the filter, oldest first, starting after filter.After. The cursor id is the
+// withdrawal's order number.
+func (lr *LedgerRepo) ListWithdrawals(ctx context.Context, userID int, filter models.ListFilter) ([]*models.Withdrawal, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	withdrawals := lr.db.withdrawals(func(w *models.Withdrawal) bool {
+		return matchesFilter(filter, w.ProcessedAt, w.Sum, w.OrderID)
+	}, userID)
+	if len(withdrawals) > filter.Limit {
+		withdrawals = withdrawals[:filter.Limit]
+	}
+	return withdrawals, nil
+}
+
+func (lr *LedgerRepo) GetTrialBalance(ctx context.Context) ([]*models.AccountBalance, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	balances := make([]*models.AccountBalance, 0, len(lr.db.accounts))
+	for _, a := range lr.db.accounts {
+		account := *a
+		if a.UserID != nil {
+			owner := *a.UserID
+			account.UserID = &owner
+		}
+		balances = append(balances, &models.AccountBalance{Account: &account, Balance: lr.db.balance(a.Code)})
+	}
+	return balances, nil
+}
@@ -0,0 +1,295 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type order struct {
+	models.Order
+	lockedBy    string
+	lockedUntil time.Time
+}
+
+type OrdersRepo struct {
+	db *DB
+	// mu is db.mu, or a no-op within a transaction, which holds db.mu.
+	mu sync.Locker
+}
+
+func NewOrdersRepo(db *DB) *OrdersRepo {
+	return &OrdersRepo{db: db, mu: &db.mu}
+}
+
+func copyOrder(o *order) *models.Order {
+	c := o.Order
+	return &c
+}
+
+// sortedOrders returns the orders matching f by upload time. The caller must
+// hold db.mu.
+func (db *DB) sortedOrders(f func(o *order) bool) []*order {
+	var orders []*order
+	for _, o := range db.orders {
+		if f(o) {
+			orders = append(orders, o)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].UploadedAt.Equal(orders[j].UploadedAt) {
+			return orders[i].UploadedAt.Before(orders[j].UploadedAt)
+		}
+		return orders[i].ID < orders[j].ID
+	})
+	return orders
+}
+
+// Create returns storage.ErrOrderAlreadyExists if the number is taken.
+func (or *OrdersRepo) Create(ctx context.Context, o *models.Order) (*models.Order, error) {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+	if _, ok := or.db.orders[o.ID]; ok {
+		return nil, storage.ErrOrderAlreadyExists
+	}
+	created := &order{Order: *o}
+	created.UploadedAt = pgTime(o.UploadedAt)
+	event, err := newAuditEvent(ctx, models.AuditActionOrderUploaded, models.AuditEntityOrder, strconv.Itoa(created.ID)).
+		WithChange(nil, orderState(&created.Order))
+	if err != nil {
+		return nil, fmt.Errorf("WithChange: %w", err)
+	}
+	event.UserID, event.OrderID = created.UserID, created.ID
+	or.db.orders[created.ID] = created
+	or.db.appendAudit(event)
+	return copyOrder(created), nil
+}
+
+func (or *OrdersRepo) GetByID(ctx context.Context, id int) (*models.Order, error) {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+	o, ok := or.db.orders[id]
+	if !ok {
+		return nil, storage.ErrOrderNotFound
+	}
+	return copyOrder(o), nil
+}
+
+func (or *OrdersRepo) Exists(ctx context.Context, id int) (bool, error) {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+	_, ok := or.db.orders[id]
+	return ok, nil
+}
+
+func (or *OrdersRepo) NumberOwner(ctx context.Context, id int) (int, error) {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+	if o, ok := or.db.orders[id]; ok {
+		return o.UserID, nil
+	}
+	if e := or.db.findEntry(models.EntryKindWithdrawal, id); e != nil {
+		return e.UserID, nil
+	}
+	return 0, storage.ErrOrderNotFound
+}
+
+// CountByStatus counts orders in each of the statuses. Statuses without
+// orders are missing from the result.
+func (or *OrdersRepo) CountByStatus(ctx context.Context, statuses ...string) (map[string]int64, error) {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+	counts := make(map[string]int64, len(statuses))
+	for _, o := range or.db.orders {
+		if contains(statuses, o.Status) {
+			counts[o.Status]++
+		}
+	}
+	return counts, nil
+}
+
+func (or *OrdersRepo) GetUsersOrders(ctx context.Context, userID int) ([]*models.Order, error) {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+	orders := make([]*models.Order, 0)
+	for _, o := range or.db.sortedOrders(func(o *order) bool { return o.UserID == userID }) {
+		orders = append(orders, copyOrder(o))
+	}
+	return orders, nil
+}
+
+// List returns up to filter.Limit of the user's orders matching the filter,
+// oldest first, starting after filter.After.
+func (or *OrdersRepo) List(ctx context.Context, userID int, filter models.ListFilter) ([]*models.Order, error) {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+	matches := or.db.sortedOrders(func(o *order) bool {
+		if o.UserID != userID {
+			return false
+		}
+		if len(filter.Statuses) > 0 && !contains(filter.Statuses, o.Status) {
+			return false
+		}
+		return matchesFilter(filter, o.UploadedAt, o.Sum, o.ID)
+	})
+	orders := make([]*models.Order, 0)
+	for _, o := range matches {
+		if len(orders) == filter.Limit {
+			break
+		}
+		orders = append(orders, copyOrder(o))
+	}
+	return orders, nil
+}
+
+// matchesFilter applies the time, amount and cursor conditions of the filter.
+func matchesFilter(filter models.ListFilter, t time.Time, amount money.Amount, id int) bool {
+	if filter.From != nil && t.Before(*filter.From) {
+		return false
+	}
+	if filter.To != nil && !t.Before(*filter.To) {
+		return false
+	}
+	if filter.MinAmount != nil && amount < *filter.MinAmount {
+		return false
+	}
+	if filter.MaxAmount != nil && amount > *filter.MaxAmount {
+		return false
+	}
+	if filter.After != nil {
+		if t.Before(filter.After.Time) || t.Equal(filter.After.Time) && id <= filter.After.ID {
+			return false
+		}
+	}
+	return true
+}
+
+// ClaimUnprocessedOrders leases up to limit unprocessed orders to owner for the
+// given duration. Leased orders are skipped until the lease expires.
+func (or *OrdersRepo) ClaimUnprocessedOrders(ctx context.Context, owner string, limit int, lease time.Duration) ([]int, error) {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+	now := time.Now()
+	claimable := or.db.sortedOrders(func(o *order) bool {
+		return (o.Status == models.OrderStatusNew || o.Status == models.OrderStatusProcessing) && !o.lockedUntil.After(now)
+	})
+	var ids []int
+	for _, o := range claimable {
+		if len(ids) == limit {
+			break
+		}
+		o.lockedBy, o.lockedUntil = owner, now.Add(lease)
+		ids = append(ids, o.ID)
+	}
+	return ids, nil
+}
+
+func (or *OrdersRepo) UpdateOrder(ctx context.Context, id int, owner string, status string, sum money.Amount) (*models.Order, error) {
+	return or.change(ctx, id, models.AuditActionOrderStatusChanged, false, func(o *models.Order, lockedBy string) error {
+		if err := checkLease(o, lockedBy, owner); err != nil {
+			return err
+		}
+		o.Status, o.Sum = status, sum
+		return nil
+	})
+}
+
+func (or *OrdersRepo) UpdateOrderStatus(ctx context.Context, id int, owner string, status string) (*models.Order, error) {
+	return or.change(ctx, id, models.AuditActionOrderStatusChanged, false, func(o *models.Order, lockedBy string) error {
+		if err := checkLease(o, lockedBy, owner); err != nil {
+			return err
+		}
+		o.Status = status
+		return nil
+	})
+}
+
+// Requeue puts an order back to NEW and drops its lease so that the next poll
+// asks the accrual system about it again. Processed orders are left alone.
+func (or *OrdersRepo) Requeue(ctx context.Context, id int) (*models.Order, error) {
+	return or.change(ctx, id, models.AuditActionOrderRequeued, true, func(o *models.Order, _ string) error {
+		if o.Status == models.OrderStatusProcessed {
+			return storage.ErrOrderAlreadyProcessed
+		}
+		o.Status = models.OrderStatusNew
+		return nil
+	})
+}
+
+// change mirrors repos.OrdersRepo.change: it applies f to the order and its
+// lease owner, releases the lease,
+// queues webhooks on a status change and audits the transition.
+func (or *OrdersRepo) change(ctx context.Context, id int, action string, always bool, f func(o *models.Order, lockedBy string) error) (*models.Order, error) {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+	stored, ok := or.db.orders[id]
+	if !ok {
+		return nil, storage.ErrOrderNotFound
+	}
+	before := stored.Order
+	updated := stored.Order
+	if err := f(&updated, stored.lockedBy); err != nil {
+		return nil, err
+	}
+
+	var webhook *models.WebhookEvent
+	var err error
+	if before.Status != updated.Status {
+		if webhook, err = models.NewOrderWebhookEvent(&updated, before.Status); err != nil {
+			return nil, fmt.Errorf("models.NewOrderWebhookEvent: %w", err)
+		}
+	}
+	var event *models.AuditEvent
+	if always || before.Status != updated.Status || before.Sum != updated.Sum {
+		event, err = newAuditEvent(ctx, action, models.AuditEntityOrder, strconv.Itoa(updated.ID)).
+			WithChange(orderState(&before), orderState(&updated))
+		if err != nil {
+			return nil, fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID, event.OrderID = updated.UserID, updated.ID
+	}
+
+	if webhook != nil {
+		if err = or.db.enqueueWebhook(webhook); err != nil {
+			return nil, fmt.Errorf("enqueueWebhook: %w", err)
+		}
+	}
+	stored.Order = updated
+	stored.lockedBy, stored.lockedUntil = "", time.Time{}
+	if event != nil {
+		or.db.appendAudit(event)
+	}
+	return copyOrder(stored), nil
+}
+
+// checkLease refuses an update from the accrual system to an order it has
+// settled already or, unless owner is empty, that's leased to someone other
+// than owner, who polled it under a lease that has run out since.
+func checkLease(order *models.Order, lockedBy string, owner string) error {
+	if order.Final() {
+		return storage.ErrOrderFinal
+	}
+	if owner != "" && lockedBy != owner {
+		return storage.ErrOrderLeaseLost
+	}
+	return nil
+}
+
+func orderState(o *models.Order) map[string]interface{} {
+	return map[string]interface{}{"status": o.Status, "sum": o.Sum}
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
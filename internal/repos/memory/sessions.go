@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type SessionRepo struct {
+	db *DB
+}
+
+func NewSessionRepo(db *DB) *SessionRepo {
+	return &SessionRepo{db: db}
+}
+
+func copySession(s *models.Session) *models.Session {
+	c := *s
+	if s.RevokedAt != nil {
+		revokedAt := *s.RevokedAt
+		c.RevokedAt = &revokedAt
+	}
+	return &c
+}
+
+// Create starts a session, which is what a successful login or registration
+// amounts to, so it's audited as the user's own action.
+func (sr *SessionRepo) Create(ctx context.Context, session *models.Session) (*models.Session, error) {
+	created := copySession(session)
+	created.CreatedAt, created.ExpiresAt, created.RevokedAt = pgTime(created.CreatedAt), pgTime(created.ExpiresAt), nil
+	event, err := newAuditEvent(ctx, models.AuditActionSessionCreated, models.AuditEntitySession, created.ID).
+		WithChange(nil, map[string]interface{}{"expires_at": created.ExpiresAt})
+	if err != nil {
+		return nil, fmt.Errorf("WithChange: %w", err)
+	}
+	event.UserID = created.UserID
+	if event.ActorID == 0 {
+		event.ActorID = created.UserID
+	}
+	sr.db.mu.Lock()
+	defer sr.db.mu.Unlock()
+	sr.db.sessions[created.ID] = created
+	sr.db.appendAudit(event)
+	return copySession(created), nil
+}
+
+func (sr *SessionRepo) GetByID(ctx context.Context, id string) (*models.Session, error) {
+	sr.db.mu.Lock()
+	defer sr.db.mu.Unlock()
+	session, ok := sr.db.sessions[id]
+	if !ok {
+		return nil, storage.ErrSessionNotFound
+	}
+	return copySession(session), nil
+}
+
+// Rotate replaces the refresh token hash of an active session. It returns
+// storage.ErrSessionNotFound if the session is unknown, revoked, expired or
+// oldHash doesn't match, so a refresh token can only be used once.
+func (sr *SessionRepo) Rotate(ctx context.Context, id string, oldHash string, newHash string, expiresAt time.Time) (*models.Session, error) {
+	sr.db.mu.Lock()
+	defer sr.db.mu.Unlock()
+	session, ok := sr.db.sessions[id]
+	if !ok || session.RefreshTokenHash != oldHash || !session.Active(time.Now()) {
+		return nil, storage.ErrSessionNotFound
+	}
+	session.RefreshTokenHash, session.ExpiresAt = newHash, pgTime(expiresAt)
+	return copySession(session), nil
+}
+
+func (sr *SessionRepo) Revoke(ctx context.Context, id string) error {
+	sr.db.mu.Lock()
+	defer sr.db.mu.Unlock()
+	session, ok := sr.db.sessions[id]
+	if !ok || session.RevokedAt != nil {
+		return nil
+	}
+	now := pgTime(time.Now())
+	session.RevokedAt = &now
+	event := newAuditEvent(ctx, models.AuditActionSessionRevoked, models.AuditEntitySession, id)
+	event.UserID = session.UserID
+	sr.db.appendAudit(event)
+	return nil
+}
+
+func (sr *SessionRepo) RevokeAllForUser(ctx context.Context, userID int) ([]string, error) {
+	sr.db.mu.Lock()
+	defer sr.db.mu.Unlock()
+	var revoked []*models.Session
+	for _, session := range sr.db.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			revoked = append(revoked, session)
+		}
+	}
+	if len(revoked) == 0 {
+		return nil, nil
+	}
+	sort.Slice(revoked, func(i, j int) bool { return revoked[i].ID < revoked[j].ID })
+	ids := make([]string, 0, len(revoked))
+	for _, session := range revoked {
+		ids = append(ids, session.ID)
+	}
+	event, err := newAuditEvent(ctx, models.AuditActionSessionsRevoked, models.AuditEntityUser, strconv.Itoa(userID)).
+		WithChange(nil, map[string]interface{}{"sessions": ids})
+	if err != nil {
+		return nil, fmt.Errorf("WithChange: %w", err)
+	}
+	event.UserID = userID
+	now := pgTime(time.Now())
+	for _, session := range revoked {
+		revokedAt := now
+		session.RevokedAt = &revokedAt
+	}
+	sr.db.appendAudit(event)
+	return ids, nil
+}
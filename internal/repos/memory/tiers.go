@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type TierRepo struct {
+	db *DB
+	// mu is db.mu, or a no-op within a transaction, which holds db.mu.
+	mu sync.Locker
+}
+
+func NewTierRepo(db *DB) *TierRepo {
+	return &TierRepo{db: db, mu: &db.mu}
+}
+
+// defaultTierRules are the rules the migrations start the other backends with.
+func defaultTierRules() []*models.TierRule {
+	now := pgTime(time.Now())
+	return []*models.TierRule{
+		{Tier: models.TierBronze, MinAccrued: 0, Multiplier: money.FromInt(1), UpdatedAt: now},
+		{Tier: models.TierSilver, MinAccrued: money.FromInt(1000), Multiplier: money.MustParse("1.25"), UpdatedAt: now},
+		{Tier: models.TierGold, MinAccrued: money.FromInt(5000), Multiplier: money.MustParse("1.5"), UpdatedAt: now},
+	}
+}
+
+// ListRules returns the tier rules sorted by threshold.
+func (tr *TierRepo) ListRules(ctx context.Context) ([]*models.TierRule, error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	rules := make([]*models.TierRule, len(tr.db.tierRules))
+	for i, r := range tr.db.tierRules {
+		c := *r
+		rules[i] = &c
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].MinAccrued < rules[j].MinAccrued
+	})
+	return rules, nil
+}
+
+// UpdateRule changes the threshold and multiplier of the rule's tier.
+func (tr *TierRepo) UpdateRule(ctx context.Context, rule *models.TierRule) (*models.TierRule, error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	for _, r := range tr.db.tierRules {
+		if r.Tier != rule.Tier {
+			continue
+		}
+		updated := &models.TierRule{Tier: r.Tier, MinAccrued: rule.MinAccrued, Multiplier: rule.Multiplier, UpdatedAt: pgTime(time.Now())}
+		event, err := newAuditEvent(ctx, models.AuditActionTierRuleUpdated, models.AuditEntityTier, rule.Tier).
+			WithChange(tierRuleState(r), tierRuleState(updated))
+		if err != nil {
+			return nil, fmt.Errorf("WithChange: %w", err)
+		}
+		*r = *updated
+		tr.db.appendAudit(event)
+		return updated, nil
+	}
+	return nil, storage.ErrTierNotFound
+}
+
+// tierRuleState is what the audit log records of a tier rule.
+func tierRuleState(rule *models.TierRule) map[string]interface{} {
+	return map[string]interface{}{"min_accrued": rule.MinAccrued, "multiplier": rule.Multiplier}
+}
+
+// GetHistory returns the user's tier changes, newest first.
+func (tr *TierRepo) GetHistory(ctx context.Context, userID int) ([]*models.TierChange, error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	history := make([]*models.TierChange, 0)
+	for i := len(tr.db.tierChanges) - 1; i >= 0; i-- {
+		if c := tr.db.tierChanges[i]; c.UserID == userID {
+			copied := *c
+			history = append(history, &copied)
+		}
+	}
+	return history, nil
+}
+
+// RecordChange adds the change to the user's tier history unless they are in
+// its tier already, and reports whether it did.
+func (tr *TierRepo) RecordChange(ctx context.Context, change *models.TierChange) (bool, error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	current := ""
+	for i := len(tr.db.tierChanges) - 1; i >= 0; i-- {
+		if c := tr.db.tierChanges[i]; c.UserID == change.UserID {
+			current = c.Tier
+			break
+		}
+	}
+	if current == change.Tier {
+		return false, nil
+	}
+	event, err := newAuditEvent(ctx, models.AuditActionTierChanged, models.AuditEntityUser, strconv.Itoa(change.UserID)).
+		WithChange(map[string]interface{}{"tier": current}, map[string]interface{}{"tier": change.Tier, "accrued": change.Accrued})
+	if err != nil {
+		return false, fmt.Errorf("WithChange: %w", err)
+	}
+	event.UserID = change.UserID
+	change.ID = int64(len(tr.db.tierChanges) + 1)
+	change.ChangedAt = pgTime(change.ChangedAt)
+	stored := *change
+	tr.db.tierChanges = append(tr.db.tierChanges, &stored)
+	tr.db.appendAudit(event)
+	return true, nil
+}
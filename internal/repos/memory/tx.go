@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+)
+
+// Tx runs units of work over the user, order, ledger and tier repos. A unit of
+// work holds db.mu throughout, so it's isolated from every other change, and
+// a failed one is rolled back to a snapshot taken at its start. Taking the
+// snapshot copies every user and order, which is fine at the sizes this
+// backend is meant for.
+type Tx struct {
+	db *DB
+}
+
+func NewTx(db *DB) *Tx {
+	return &Tx{db: db}
+}
+
+// noLock is the sync.Locker of the repos within a transaction.
+type noLock struct{}
+
+func (noLock) Lock()   {}
+func (noLock) Unlock() {}
+
+func (t *Tx) WithinTx(ctx context.Context, f func(repos *storage.Repos) error) (err error) {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+
+	snapshot := t.db.snapshot()
+	committed := false
+	defer func() {
+		if !committed {
+			t.db.restore(snapshot)
+		}
+	}()
+	err = f(&storage.Repos{
+		Users:  &UserRepo{db: t.db, mu: noLock{}},
+		Orders: &OrdersRepo{db: t.db, mu: noLock{}},
+		Ledger: &LedgerRepo{db: t.db, mu: noLock{}},
+		Tiers:  &TierRepo{db: t.db, mu: noLock{}},
+	})
+	committed = err == nil
+	return err
+}
+
+// snapshot is the state the user, order, ledger and tier repos can change.
+// Users, orders, lots and tier rules are changed in place, so they are copied;
+// the rest is only ever appended to within a transaction.
+type snapshot struct {
+	users       []*models.User
+	orders      map[int]*order
+	lots        []*models.Lot
+	tierRules   []*models.TierRule
+	accounts    int
+	entries     int
+	audit       int
+	deliveries  int
+	tierChanges int
+}
+
+// snapshot captures the state. The caller must hold db.mu.
+func (db *DB) snapshot() *snapshot {
+	s := &snapshot{
+		users:       make([]*models.User, len(db.users)),
+		orders:      make(map[int]*order, len(db.orders)),
+		lots:        make([]*models.Lot, len(db.lots)),
+		tierRules:   make([]*models.TierRule, len(db.tierRules)),
+		accounts:    len(db.accounts),
+		entries:     len(db.entries),
+		audit:       len(db.audit),
+		deliveries:  len(db.deliveries),
+		tierChanges: len(db.tierChanges),
+	}
+	for i, u := range db.users {
+		s.users[i] = copyUser(u)
+	}
+	for id, o := range db.orders {
+		c := *o
+		s.orders[id] = &c
+	}
+	for i, l := range db.lots {
+		c := *l
+		s.lots[i] = &c
+	}
+	for i, r := range db.tierRules {
+		c := *r
+		s.tierRules[i] = &c
+	}
+	return s
+}
+
+// restore rolls the state back to the snapshot. Like PostgreSQL sequences,
+// the id sequences aren't rolled back. The caller must hold db.mu.
+func (db *DB) restore(s *snapshot) {
+	db.users = s.users
+	db.orders = s.orders
+	db.lots = s.lots
+	db.tierRules = s.tierRules
+	db.accounts = db.accounts[:s.accounts]
+	db.entries = db.entries[:s.entries]
+	db.audit = db.audit[:s.audit]
+	db.deliveries = db.deliveries[:s.deliveries]
+	db.tierChanges = db.tierChanges[:s.tierChanges]
+}
@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type UserRepo struct {
+	db *DB
+	// mu is db.mu, or a no-op within a transaction, which holds db.mu.
+	mu sync.Locker
+}
+
+func NewUserRepo(db *DB) *UserRepo {
+	return &UserRepo{db: db, mu: &db.mu}
+}
+
+func copyUser(u *models.User) *models.User {
+	c := *u
+	c.Roles = append([]string{}, u.Roles...)
+	return &c
+}
+
+// findUser returns the stored user or nil. The caller must hold db.mu.
+func (db *DB) findUser(login string) *models.User {
+	for _, u := range db.users {
+		if u.Login == login {
+			return u
+		}
+	}
+	return nil
+}
+
+// Create returns storage.ErrUserAlreadyExists if the login is taken.
+func (ur *UserRepo) Create(ctx context.Context, user *models.User) (*models.User, error) {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+	if ur.db.findUser(user.Login) != nil {
+		return nil, storage.ErrUserAlreadyExists
+	}
+	roles := user.Roles
+	if len(roles) == 0 {
+		roles = []string{models.RoleCustomer}
+	}
+	created := &models.User{
+		ID:           len(ur.db.users) + 1,
+		Login:        user.Login,
+		EncryptedPwd: user.EncryptedPwd,
+		Roles:        append([]string{}, roles...),
+	}
+	event, err := newAuditEvent(ctx, models.AuditActionUserRegistered, models.AuditEntityUser, strconv.Itoa(created.ID)).
+		WithChange(nil, map[string]interface{}{"login": created.Login, "roles": created.Roles})
+	if err != nil {
+		return nil, fmt.Errorf("WithChange: %w", err)
+	}
+	event.UserID = created.ID
+	if event.ActorID == 0 {
+		event.ActorID = created.ID
+	}
+	ur.db.users = append(ur.db.users, created)
+	ur.db.appendAudit(event)
+	return copyUser(created), nil
+}
+
+func (ur *UserRepo) GetByLogin(ctx context.Context, login string) (*models.User, error) {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+	user := ur.db.findUser(login)
+	if user == nil {
+		return nil, storage.ErrUserNotFound
+	}
+	return copyUser(user), nil
+}
+
+func (ur *UserRepo) GetByID(ctx context.Context, id int) (*models.User, error) {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+	if id < 1 || id > len(ur.db.users) {
+		return nil, storage.ErrUserNotFound
+	}
+	return copyUser(ur.db.users[id-1]), nil
+}
+
+func (ur *UserRepo) Exists(ctx context.Context, login string) (bool, error) {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+	return ur.db.findUser(login) != nil, nil
+}
+
+// Search returns up to limit users whose login contains the given substring,
+// case-insensitively.
+func (ur *UserRepo) Search(ctx context.Context, login string, limit int) ([]*models.User, error) {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+	users := make([]*models.User, 0)
+	needle := strings.ToLower(login)
+	for _, u := range ur.db.users {
+		if len(users) == limit {
+			break
+		}
+		if strings.Contains(strings.ToLower(u.Login), needle) {
+			users = append(users, copyUser(u))
+		}
+	}
+	return users, nil
+}
+
+// AddRole grants the role to the user. Granting a role the user already has
+// is a no-op. It reports whether the user exists.
+func (ur *UserRepo) AddRole(ctx context.Context, login string, role string) (bool, error) {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+	user := ur.db.findUser(login)
+	if user == nil {
+		return false, nil
+	}
+	for _, r := range user.Roles {
+		if r == role {
+			return true, nil
+		}
+	}
+	newRoles := append(append([]string{}, user.Roles...), role)
+	event, err := newAuditEvent(ctx, models.AuditActionRoleGranted, models.AuditEntityUser, strconv.Itoa(user.ID)).
+		WithChange(map[string]interface{}{"roles": user.Roles}, map[string]interface{}{"roles": newRoles})
+	if err != nil {
+		return false, fmt.Errorf("WithChange: %w", err)
+	}
+	event.UserID = user.ID
+	user.Roles = newRoles
+	ur.db.appendAudit(event)
+	return true, nil
+}
@@ -0,0 +1,250 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type delivery struct {
+	models.WebhookDelivery
+	lockedBy    string
+	lockedUntil time.Time
+}
+
+type WebhookRepo struct {
+	db *DB
+}
+
+func NewWebhookRepo(db *DB) *WebhookRepo {
+	return &WebhookRepo{db: db}
+}
+
+func copyEndpoint(e *models.WebhookEndpoint) *models.WebhookEndpoint {
+	c := *e
+	c.Events = append([]string(nil), e.Events...)
+	if e.UserID != nil {
+		owner := *e.UserID
+		c.UserID = &owner
+	}
+	return &c
+}
+
+func copyDelivery(d *delivery) *models.WebhookDelivery {
+	c := d.WebhookDelivery
+	c.Payload = append(json.RawMessage(nil), d.Payload...)
+	if d.DeliveredAt != nil {
+		deliveredAt := *d.DeliveredAt
+		c.DeliveredAt = &deliveredAt
+	}
+	return &c
+}
+
+// enqueueWebhook queues the event for every endpoint subscribed to it. The
+// caller must hold db.mu, so an event is queued if and only if the change that
+// caused it is applied.
+func (db *DB) enqueueWebhook(event *models.WebhookEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	now := pgTime(time.Now())
+	for _, e := range db.endpoints {
+		if e.UserID != nil && *e.UserID != event.UserID || !contains(e.Events, event.Type) {
+			continue
+		}
+		db.deliverySeq++
+		db.deliveries = append(db.deliveries, &delivery{WebhookDelivery: models.WebhookDelivery{
+			ID:            db.deliverySeq,
+			EndpointID:    e.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			State:         models.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}})
+	}
+	return nil
+}
+
+func (wr *WebhookRepo) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) (*models.WebhookEndpoint, error) {
+	wr.db.mu.Lock()
+	defer wr.db.mu.Unlock()
+	wr.db.endpointSeq++
+	created := copyEndpoint(endpoint)
+	created.ID = wr.db.endpointSeq
+	created.CreatedAt = pgTime(created.CreatedAt)
+	event, err := newAuditEvent(ctx, models.AuditActionWebhookCreated, models.AuditEntityWebhook, strconv.Itoa(created.ID)).
+		WithChange(nil, webhookEndpointState(created))
+	if err != nil {
+		return nil, fmt.Errorf("WithChange: %w", err)
+	}
+	if created.UserID != nil {
+		event.UserID = *created.UserID
+	}
+	wr.db.endpoints = append(wr.db.endpoints, created)
+	wr.db.appendAudit(event)
+	return copyEndpoint(created), nil
+}
+
+func (wr *WebhookRepo) GetEndpoint(ctx context.Context, id int) (*models.WebhookEndpoint, error) {
+	wr.db.mu.Lock()
+	defer wr.db.mu.Unlock()
+	for _, e := range wr.db.endpoints {
+		if e.ID == id {
+			return copyEndpoint(e), nil
+		}
+	}
+	return nil, storage.ErrWebhookEndpointNotFound
+}
+
+// ListEndpoints returns the user's endpoints, or the partner endpoints if
+// userID is zero.
+func (wr *WebhookRepo) ListEndpoints(ctx context.Context, userID int) ([]*models.WebhookEndpoint, error) {
+	wr.db.mu.Lock()
+	defer wr.db.mu.Unlock()
+	endpoints := make([]*models.WebhookEndpoint, 0)
+	for _, e := range wr.db.endpoints {
+		owner := 0
+		if e.UserID != nil {
+			owner = *e.UserID
+		}
+		if owner == userID {
+			endpoints = append(endpoints, copyEndpoint(e))
+		}
+	}
+	return endpoints, nil
+}
+
+// DeleteEndpoint removes the endpoint together with its deliveries.
+func (wr *WebhookRepo) DeleteEndpoint(ctx context.Context, id int) error {
+	wr.db.mu.Lock()
+	defer wr.db.mu.Unlock()
+	for i, e := range wr.db.endpoints {
+		if e.ID != id {
+			continue
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionWebhookDeleted, models.AuditEntityWebhook, strconv.Itoa(id)).
+			WithChange(webhookEndpointState(e), nil)
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		if e.UserID != nil {
+			event.UserID = *e.UserID
+		}
+		wr.db.endpoints = append(wr.db.endpoints[:i:i], wr.db.endpoints[i+1:]...)
+		deliveries := wr.db.deliveries[:0:0]
+		for _, d := range wr.db.deliveries {
+			if d.EndpointID != id {
+				deliveries = append(deliveries, d)
+			}
+		}
+		wr.db.deliveries = deliveries
+		wr.db.appendAudit(event)
+		return nil
+	}
+	return storage.ErrWebhookEndpointNotFound
+}
+
+func (db *DB) endpoint(id int) *models.WebhookEndpoint {
+	for _, e := range db.endpoints {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// ClaimDeliveries leases up to limit due deliveries to owner, like
+// OrdersRepo.ClaimUnprocessedOrders, and fills in their endpoint's URL and secret.
+func (wr *WebhookRepo) ClaimDeliveries(ctx context.Context, owner string, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	wr.db.mu.Lock()
+	defer wr.db.mu.Unlock()
+	now := time.Now()
+	due := make([]*delivery, 0)
+	for _, d := range wr.db.deliveries {
+		if d.State == models.DeliveryPending && !d.NextAttemptAt.After(now) && !d.lockedUntil.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	deliveries := make([]*models.WebhookDelivery, 0, len(due))
+	for _, d := range due {
+		d.lockedBy, d.lockedUntil = owner, now.Add(lease)
+		claimed := copyDelivery(d)
+		e := wr.db.endpoint(d.EndpointID)
+		claimed.URL, claimed.Secret = e.URL, e.Secret
+		deliveries = append(deliveries, claimed)
+	}
+	return deliveries, nil
+}
+
+// UpdateDelivery records the outcome of an attempt and releases the lease.
+func (wr *WebhookRepo) UpdateDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	wr.db.mu.Lock()
+	defer wr.db.mu.Unlock()
+	for _, stored := range wr.db.deliveries {
+		if stored.ID != d.ID {
+			continue
+		}
+		stored.State, stored.Attempts, stored.LastStatus, stored.LastError = d.State, d.Attempts, d.LastStatus, d.LastError
+		stored.NextAttemptAt = pgTime(d.NextAttemptAt)
+		stored.DeliveredAt = nil
+		if d.DeliveredAt != nil {
+			deliveredAt := pgTime(*d.DeliveredAt)
+			stored.DeliveredAt = &deliveredAt
+		}
+		stored.lockedBy, stored.lockedUntil = "", time.Time{}
+	}
+	return nil
+}
+
+// ListDeliveries returns deliveries newest first.
+func (wr *WebhookRepo) ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error) {
+	wr.db.mu.Lock()
+	defer wr.db.mu.Unlock()
+	deliveries := make([]*models.WebhookDelivery, 0)
+	for i := len(wr.db.deliveries) - 1; i >= 0 && len(deliveries) < filter.Limit; i-- {
+		d := wr.db.deliveries[i]
+		if filter.EndpointID != 0 && d.EndpointID != filter.EndpointID ||
+			filter.State != "" && d.State != filter.State ||
+			filter.BeforeID != 0 && d.ID >= filter.BeforeID {
+			continue
+		}
+		deliveries = append(deliveries, copyDelivery(d))
+	}
+	return deliveries, nil
+}
+
+// ReplayDeadDeliveries puts dead-lettered deliveries back in the queue with a
+// fresh attempt budget. Zero endpointID or deliveryID don't filter. It returns
+// the number of deliveries requeued.
+func (wr *WebhookRepo) ReplayDeadDeliveries(ctx context.Context, endpointID int, deliveryID int64) (int64, error) {
+	wr.db.mu.Lock()
+	defer wr.db.mu.Unlock()
+	now := pgTime(time.Now())
+	var replayed int64
+	for _, d := range wr.db.deliveries {
+		if d.State != models.DeliveryDead ||
+			endpointID != 0 && d.EndpointID != endpointID ||
+			deliveryID != 0 && d.ID != deliveryID {
+			continue
+		}
+		d.State, d.Attempts, d.NextAttemptAt = models.DeliveryPending, 0, now
+		replayed++
+	}
+	return replayed, nil
+}
+
+func webhookEndpointState(e *models.WebhookEndpoint) map[string]interface{} {
+	return map[string]interface{}{"url": e.URL, "events": e.Events}
+}
@@ -2,38 +2,65 @@ package repos
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"strconv"
+	"time"
 )
 
 type OrdersRepo struct {
-	pool *pgxpool.Pool
+	db dbtx
 }
 
 func NewOrdersRepo(pool *pgxpool.Pool) *OrdersRepo {
-	return &OrdersRepo{pool: pool}
+	return &OrdersRepo{db: pool}
 }
 
+// Create returns storage.ErrOrderAlreadyExists if the number is taken.
 func (or *OrdersRepo) Create(ctx context.Context, order *models.Order) (*models.Order, error) {
-	query := "insert into orders (id, user_id, status, sum, uploaded_at) values ($1, $2, $3, $4, $5)"
-	_, err := or.pool.Exec(ctx, query, order.ID, order.UserID, order.Status, order.Sum, order.UploadedAt)
-	if err != nil {
-		return nil, fmt.Errorf("error or.pool.Exec: %w", err)
-	}
-	resOrder, err := or.GetByID(ctx, order.ID)
+	resOrder := &models.Order{}
+	err := pgx.BeginFunc(ctx, or.db, func(tx pgx.Tx) error {
+		query := "insert into orders (id, user_id, status, sum, uploaded_at) values ($1, $2, $3, $4, $5) returning id, user_id, status, sum, uploaded_at"
+		row := tx.QueryRow(ctx, query, order.ID, order.UserID, order.Status, order.Sum, order.UploadedAt)
+		err := row.Scan(&resOrder.ID, &resOrder.UserID, &resOrder.Status, &resOrder.Sum, &resOrder.UploadedAt)
+		if isUniqueViolation(err) {
+			return storage.ErrOrderAlreadyExists
+		}
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionOrderUploaded, models.AuditEntityOrder, strconv.Itoa(resOrder.ID)).
+			WithChange(nil, orderState(resOrder))
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID, event.OrderID = resOrder.UserID, resOrder.ID
+		return insertAuditEvent(ctx, tx, event)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("or.GetByID: %w", err)
+		return nil, fmt.Errorf("pgx.BeginFunc: %w", err)
 	}
 	return resOrder, nil
 }
 
+// legacyWithdrawalStatus marks the withdrawals the baseline migration found
+// stored as orders. They are kept for the record but aren't orders, so only
+// Exists, which keeps their numbers taken, sees them.
+const legacyWithdrawalStatus = "WITHDRAWAL"
+
 func (or *OrdersRepo) GetByID(ctx context.Context, id int) (*models.Order, error) {
-	query := "select id, user_id, status, sum, uploaded_at from orders where id = $1"
-	row := or.pool.QueryRow(ctx, query, id)
+	query := "select id, user_id, status, sum, uploaded_at from orders where id = $1 and status <> '" + legacyWithdrawalStatus + "'"
+	row := or.db.QueryRow(ctx, query, id)
 	order := &models.Order{}
 	err := row.Scan(&order.ID, &order.UserID, &order.Status, &order.Sum, &order.UploadedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrOrderNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("row.Scan: %w", err)
 	}
@@ -42,7 +69,7 @@ func (or *OrdersRepo) GetByID(ctx context.Context, id int) (*models.Order, error
 
 func (or *OrdersRepo) Exists(ctx context.Context, id int) (bool, error) {
 	query := "select exists(select 1 from orders where id = $1)"
-	row := or.pool.QueryRow(ctx, query, id)
+	row := or.db.QueryRow(ctx, query, id)
 	var exists bool
 	err := row.Scan(&exists)
 	if err != nil {
@@ -51,12 +78,63 @@ func (or *OrdersRepo) Exists(ctx context.Context, id int) (bool, error) {
 	return exists, nil
 }
 
+// orderNumberLockClass is the first key of the transaction-level advisory
+// locks NumberOwner takes on order numbers, which orders and withdrawals
+// share. The second key is the number, truncated: numbers that collide only
+// wait for each other.
+const orderNumberLockClass int32 = 751_402_032
+
+// NumberOwner takes the lock on the caller's transaction rather than in a
+// savepoint of its own, since rolling a savepoint back would release it.
+func (or *OrdersRepo) NumberOwner(ctx context.Context, id int) (int, error) {
+	if _, err := or.db.Exec(ctx, "select pg_advisory_xact_lock($1, $2)", orderNumberLockClass, int32(id)); err != nil {
+		return 0, fmt.Errorf("pg_advisory_xact_lock: %w", err)
+	}
+	query := `select user_id from orders where id = $1
+              union all
+              select user_id from ledger_entries where kind = $2 and order_id = $1
+              limit 1`
+	var owner int
+	err := or.db.QueryRow(ctx, query, id, models.EntryKindWithdrawal).Scan(&owner)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, storage.ErrOrderNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("row.Scan: %w", err)
+	}
+	return owner, nil
+}
+
+// CountByStatus counts orders in each of the statuses. Statuses without
+// orders are missing from the result.
+func (or *OrdersRepo) CountByStatus(ctx context.Context, statuses ...string) (map[string]int64, error) {
+	query := "select status, count(*) from orders where status = any($1) group by status"
+	rows, err := or.db.Query(ctx, query, statuses)
+	if err != nil {
+		return nil, fmt.Errorf("or.db.Query: %w", err)
+	}
+	defer rows.Close()
+	counts := make(map[string]int64, len(statuses))
+	for rows.Next() {
+		var status string
+		var count int64
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		counts[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return counts, nil
+}
+
 func (or *OrdersRepo) GetUsersOrders(ctx context.Context, userID int) ([]*models.Order, error) {
-	query := "select id, user_id, status, sum, uploaded_at from orders where user_id = $1 order by uploaded_at"
+	query := "select id, user_id, status, sum, uploaded_at from orders where user_id = $1 and status <> '" + legacyWithdrawalStatus + "' order by uploaded_at"
 	orders := make([]*models.Order, 0)
-	rows, err := or.pool.Query(ctx, query, userID)
+	rows, err := or.db.Query(ctx, query, userID)
 	if err != nil {
-		return nil, fmt.Errorf("or.pool.Query: %w", err)
+		return nil, fmt.Errorf("or.db.Query: %w", err)
 	}
 	for rows.Next() {
 		order := &models.Order{}
@@ -69,41 +147,38 @@ func (or *OrdersRepo) GetUsersOrders(ctx context.Context, userID int) ([]*models
 	return orders, nil
 }
 
-func (or *OrdersRepo) GetUsersCurrentBalance(ctx context.Context, userID int) (float64, error) {
-	query := "select sum(sum) from orders where user_id = $1"
-	row := or.pool.QueryRow(ctx, query, userID)
-	var balance sql.NullFloat64
-	err := row.Scan(&balance)
-	if err != nil {
-		return 0, fmt.Errorf("row.Scan: %w", err)
+// List returns up to filter.Limit of the user's orders matching the filter,
+// oldest first, starting after filter.After.
+func (or *OrdersRepo) List(ctx context.Context, userID int, filter models.ListFilter) ([]*models.Order, error) {
+	w := &whereClause{}
+	w.add("user_id = ?", userID)
+	w.add("status <> ?", legacyWithdrawalStatus)
+	if len(filter.Statuses) > 0 {
+		w.add("status = any(?)", filter.Statuses)
 	}
-	if balance.Valid {
-		return balance.Float64, nil
+	if filter.From != nil {
+		w.add("uploaded_at >= ?", filter.From.Local())
 	}
-	return 0, nil
-}
-
-func (or *OrdersRepo) GetUsersWithdrawnBalance(ctx context.Context, userID int) (float64, error) {
-	query := "select -sum(sum) from orders where user_id = $1 and sum < 0"
-	row := or.pool.QueryRow(ctx, query, userID)
-	var balance sql.NullFloat64
-	err := row.Scan(&balance)
-	if err != nil {
-		return 0, fmt.Errorf("row.Scan: %w", err)
+	if filter.To != nil {
+		w.add("uploaded_at < ?", filter.To.Local())
 	}
-	if balance.Valid {
-		return balance.Float64, nil
+	if filter.MinAmount != nil {
+		w.add("sum >= ?", *filter.MinAmount)
 	}
-	return 0, nil
-}
+	if filter.MaxAmount != nil {
+		w.add("sum <= ?", *filter.MaxAmount)
+	}
+	if filter.After != nil {
+		w.add("(uploaded_at, id) > (?, ?)", filter.After.Time, filter.After.ID)
+	}
+	query := "select id, user_id, status, sum, uploaded_at from orders" + w.String() + " order by uploaded_at, id limit " + w.arg(filter.Limit)
 
-func (or *OrdersRepo) GetUsersWithdrawals(ctx context.Context, userID int) ([]*models.Order, error) {
-	query := "select id, user_id, status, -sum, uploaded_at from orders where user_id = $1 and sum < 0 order by uploaded_at"
-	orders := make([]*models.Order, 0)
-	rows, err := or.pool.Query(ctx, query, userID)
+	rows, err := or.db.Query(ctx, query, w.args...)
 	if err != nil {
-		return nil, fmt.Errorf("or.pool.Query: %w", err)
+		return nil, fmt.Errorf("or.db.Query: %w", err)
 	}
+	defer rows.Close()
+	orders := make([]*models.Order, 0)
 	for rows.Next() {
 		order := &models.Order{}
 		err := rows.Scan(&order.ID, &order.UserID, &order.Status, &order.Sum, &order.UploadedAt)
@@ -112,15 +187,31 @@ func (or *OrdersRepo) GetUsersWithdrawals(ctx context.Context, userID int) ([]*m
 		}
 		orders = append(orders, order)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
 	return orders, nil
 }
 
-func (or *OrdersRepo) GetUnprocessedOrders(ctx context.Context) ([]int, error) {
-	query := "select id from orders where status = $1 or status = $2"
-	rows, err := or.pool.Query(ctx, query, models.OrderStatusNew, models.OrderStatusProcessing)
+// ClaimUnprocessedOrders leases up to limit unprocessed orders to owner for the
+// given duration. Rows leased by other instances are skipped, so concurrent
+// callers get disjoint batches; an expired lease makes the order claimable again.
+func (or *OrdersRepo) ClaimUnprocessedOrders(ctx context.Context, owner string, limit int, lease time.Duration) ([]int, error) {
+	query := `with claimable as (
+                  select id from orders
+                  where status in ($1, $2) and (locked_until is null or locked_until < now())
+                  order by uploaded_at
+                  limit $3
+                  for update skip locked
+              )
+              update orders o set locked_by = $4, locked_until = now() + $5 * interval '1 millisecond'
+              from claimable where o.id = claimable.id
+              returning o.id`
+	rows, err := or.db.Query(ctx, query, models.OrderStatusNew, models.OrderStatusProcessing, limit, owner, lease.Milliseconds())
 	if err != nil {
-		return nil, fmt.Errorf("or.pool.Query: %w", err)
+		return nil, fmt.Errorf("or.db.Query: %w", err)
 	}
+	defer rows.Close()
 	var ids []int
 	for rows.Next() {
 		var id int
@@ -130,23 +221,107 @@ func (or *OrdersRepo) GetUnprocessedOrders(ctx context.Context) ([]int, error) {
 		}
 		ids = append(ids, id)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
 	return ids, nil
 }
 
-func (or *OrdersRepo) UpdateOrder(ctx context.Context, id int, status string, sum float64) (*models.Order, error) {
-	query := "update orders set status = $1, sum = $2 where id = $3"
-	_, err := or.pool.Exec(ctx, query, status, sum, id)
+func (or *OrdersRepo) UpdateOrder(ctx context.Context, id int, owner string, status string, sum money.Amount) (*models.Order, error) {
+	return or.change(ctx, id, models.AuditActionOrderStatusChanged, false, func(order *models.Order, lockedBy string) error {
+		if err := checkLease(order, lockedBy, owner); err != nil {
+			return err
+		}
+		order.Status, order.Sum = status, sum
+		return nil
+	})
+}
+
+func (or *OrdersRepo) UpdateOrderStatus(ctx context.Context, id int, owner string, status string) (*models.Order, error) {
+	return or.change(ctx, id, models.AuditActionOrderStatusChanged, false, func(order *models.Order, lockedBy string) error {
+		if err := checkLease(order, lockedBy, owner); err != nil {
+			return err
+		}
+		order.Status = status
+		return nil
+	})
+}
+
+// Requeue puts an order back to NEW and drops its lease so that the next poll
+// asks the accrual system about it again. Processed orders are left alone.
+func (or *OrdersRepo) Requeue(ctx context.Context, id int) (*models.Order, error) {
+	return or.change(ctx, id, models.AuditActionOrderRequeued, true, func(order *models.Order, _ string) error {
+		if order.Status == models.OrderStatusProcessed {
+			return storage.ErrOrderAlreadyProcessed
+		}
+		order.Status = models.OrderStatusNew
+		return nil
+	})
+}
+
+// change applies f to the locked order and its lease owner, releases its lease and records the
+// transition in the audit log. Unless always is set, updates that change
+// neither the status nor the sum, like repeated PROCESSING polls, aren't audited.
+func (or *OrdersRepo) change(ctx context.Context, id int, action string, always bool, f func(order *models.Order, lockedBy string) error) (*models.Order, error) {
+	order := &models.Order{}
+	err := pgx.BeginFunc(ctx, or.db, func(tx pgx.Tx) error {
+		query := "select id, user_id, status, sum, uploaded_at, coalesce(locked_by, '') from orders where id = $1 and status <> '" + legacyWithdrawalStatus + "' for update"
+		var lockedBy string
+		err := tx.QueryRow(ctx, query, id).Scan(&order.ID, &order.UserID, &order.Status, &order.Sum, &order.UploadedAt, &lockedBy)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrOrderNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		before := *order
+		if err = f(order, lockedBy); err != nil {
+			return err
+		}
+
+		query = "update orders set status = $1, sum = $2, locked_by = null, locked_until = null where id = $3"
+		if _, err = tx.Exec(ctx, query, order.Status, order.Sum, order.ID); err != nil {
+			return fmt.Errorf("tx.Exec: %w", err)
+		}
+		if before.Status != order.Status {
+			webhook, err := models.NewOrderWebhookEvent(order, before.Status)
+			if err != nil {
+				return fmt.Errorf("models.NewOrderWebhookEvent: %w", err)
+			}
+			if err = enqueueWebhook(ctx, tx, webhook); err != nil {
+				return fmt.Errorf("enqueueWebhook: %w", err)
+			}
+		}
+		if !always && before.Status == order.Status && before.Sum == order.Sum {
+			return nil
+		}
+		event, err := newAuditEvent(ctx, action, models.AuditEntityOrder, strconv.Itoa(order.ID)).
+			WithChange(orderState(&before), orderState(order))
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID, event.OrderID = order.UserID, order.ID
+		return insertAuditEvent(ctx, tx, event)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("or.pool.Exec: %w", err)
+		return nil, fmt.Errorf("pgx.BeginFunc: %w", err)
 	}
-	return or.GetByID(ctx, id)
+	return order, nil
 }
 
-func (or *OrdersRepo) UpdateOrderStatus(ctx context.Context, id int, status string) (*models.Order, error) {
-	query := "update orders set status = $1 where id = $3"
-	_, err := or.pool.Exec(ctx, query, status, id)
-	if err != nil {
-		return nil, fmt.Errorf("or.pool.Exec: %w", err)
+// checkLease refuses an update from the accrual system to an order it has
+// settled already or, unless owner is empty, that's leased to someone other
+// than owner, who polled it under a lease that has run out since.
+func checkLease(order *models.Order, lockedBy string, owner string) error {
+	if order.Final() {
+		return storage.ErrOrderFinal
+	}
+	if owner != "" && lockedBy != owner {
+		return storage.ErrOrderLeaseLost
 	}
-	return or.GetByID(ctx, id)
+	return nil
+}
+
+func orderState(order *models.Order) map[string]interface{} {
+	return map[string]interface{}{"status": order.Status, "sum": order.Sum}
 }
@@ -0,0 +1,228 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrdersRepo_ClaimUnprocessedOrders(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	ur := NewUserRepo(pool)
+	or := NewOrdersRepo(pool)
+
+	user, err := ur.Create(ctx, &models.User{
+		Login:        fmt.Sprintf("claim-test-%d", time.Now().UnixNano()),
+		EncryptedPwd: "encryptedPwd",
+	})
+	require.NoError(t, err)
+
+	// Leave no claimable orders from previous runs around.
+	_, err = pool.Exec(ctx, "update orders set locked_by = 'other', locked_until = now() + interval '1 hour'")
+	require.NoError(t, err)
+
+	baseOrderID := int(time.Now().UnixNano() / 1000)
+	created := make(map[int]bool)
+	for i := 0; i < 6; i++ {
+		order, err := or.Create(ctx, &models.Order{
+			ID:         baseOrderID + i,
+			UserID:     user.ID,
+			Status:     models.OrderStatusNew,
+			UploadedAt: time.Now(),
+		})
+		require.NoError(t, err)
+		created[order.ID] = true
+	}
+
+	first, err := or.ClaimUnprocessedOrders(ctx, "first", 4, time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, first, 4)
+	second, err := or.ClaimUnprocessedOrders(ctx, "second", 4, time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, second, 2)
+
+	claimed := make(map[int]bool)
+	for _, id := range append(first, second...) {
+		assert.False(t, claimed[id], "order %d claimed twice", id)
+		assert.True(t, created[id])
+		claimed[id] = true
+	}
+
+	_, err = or.UpdateOrderStatus(ctx, second[0], "second", models.OrderStatusProcessing)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "update orders set locked_until = now() - interval '1 second' where id = $1", first[0])
+	require.NoError(t, err)
+
+	third, err := or.ClaimUnprocessedOrders(ctx, "third", 4, time.Minute)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int{first[0], second[0]}, third)
+}
+
+func TestOrdersRepo_Requeue(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	ur := NewUserRepo(pool)
+	or := NewOrdersRepo(pool)
+
+	user, err := ur.Create(ctx, &models.User{
+		Login:        fmt.Sprintf("requeue-test-%d", time.Now().UnixNano()),
+		EncryptedPwd: "encryptedPwd",
+	})
+	require.NoError(t, err)
+
+	orderID := int(time.Now().UnixNano() / 1000)
+	_, err = or.Create(ctx, &models.Order{ID: orderID, UserID: user.ID, Status: models.OrderStatusInvalid, UploadedAt: time.Now()})
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "update orders set locked_by = 'other', locked_until = now() + interval '1 hour' where id = $1", orderID)
+	require.NoError(t, err)
+
+	order, err := or.Requeue(ctx, orderID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusNew, order.Status)
+	var lockedBy *string
+	require.NoError(t, pool.QueryRow(ctx, "select locked_by from orders where id = $1", orderID).Scan(&lockedBy))
+	assert.Nil(t, lockedBy)
+
+	_, err = or.UpdateOrderStatus(ctx, orderID, "", models.OrderStatusProcessed)
+	require.NoError(t, err)
+	_, err = or.Requeue(ctx, orderID)
+	assert.ErrorIs(t, err, storage.ErrOrderAlreadyProcessed)
+
+	_, err = or.Requeue(ctx, orderID+1)
+	assert.ErrorIs(t, err, storage.ErrOrderNotFound)
+}
+
+func TestOrdersRepo_List(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	ur := NewUserRepo(pool)
+	or := NewOrdersRepo(pool)
+
+	user, err := ur.Create(ctx, &models.User{
+		Login:        fmt.Sprintf("list-test-%d", time.Now().UnixNano()),
+		EncryptedPwd: "encryptedPwd",
+	})
+	require.NoError(t, err)
+
+	base := time.Now().Truncate(time.Second)
+	baseOrderID := int(time.Now().UnixNano() / 1000)
+	for i := 0; i < 5; i++ {
+		_, err := or.Create(ctx, &models.Order{
+			ID:         baseOrderID + i,
+			UserID:     user.ID,
+			Status:     models.OrderStatusNew,
+			UploadedAt: base.Add(time.Duration(i) * time.Minute),
+		})
+		require.NoError(t, err)
+	}
+	_, err = or.UpdateOrder(ctx, baseOrderID+3, "", models.OrderStatusProcessed, money.FromInt(300))
+	require.NoError(t, err)
+
+	var ids []int
+	filter := models.ListFilter{Limit: 2}
+	for {
+		orders, err := or.List(ctx, user.ID, filter)
+		require.NoError(t, err)
+		for _, o := range orders {
+			ids = append(ids, o.ID)
+		}
+		if len(orders) < filter.Limit {
+			break
+		}
+		last := orders[len(orders)-1]
+		filter.After = &models.Cursor{Time: last.UploadedAt, ID: last.ID}
+	}
+	assert.Equal(t, []int{baseOrderID, baseOrderID + 1, baseOrderID + 2, baseOrderID + 3, baseOrderID + 4}, ids)
+
+	from, to := base.Add(time.Minute), base.Add(4*time.Minute)
+	orders, err := or.List(ctx, user.ID, models.ListFilter{From: &from, To: &to, Statuses: []string{models.OrderStatusNew}, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, orders, 2)
+
+	minAmount := money.FromInt(100)
+	orders, err = or.List(ctx, user.ID, models.ListFilter{MinAmount: &minAmount, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, baseOrderID+3, orders[0].ID)
+}
+
+func TestOrdersRepo_HidesLegacyWithdrawals(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	ur := NewUserRepo(pool)
+	or := NewOrdersRepo(pool)
+
+	user, err := ur.Create(ctx, &models.User{
+		Login:        fmt.Sprintf("legacy-test-%d", time.Now().UnixNano()),
+		EncryptedPwd: "encryptedPwd",
+	})
+	require.NoError(t, err)
+	orderID := int(time.Now().UnixNano() / 1000)
+	_, err = pool.Exec(ctx, "insert into orders (id, user_id, status, sum, uploaded_at) values ($1, $2, $3, -100, now())",
+		orderID, user.ID, legacyWithdrawalStatus)
+	require.NoError(t, err)
+
+	_, err = or.GetByID(ctx, orderID)
+	assert.ErrorIs(t, err, storage.ErrOrderNotFound)
+	orders, err := or.GetUsersOrders(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+	orders, err = or.List(ctx, user.ID, models.ListFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+	_, err = or.UpdateOrder(ctx, orderID, "", models.OrderStatusProcessed, money.FromInt(100))
+	assert.ErrorIs(t, err, storage.ErrOrderNotFound)
+	exists, err := or.Exists(ctx, orderID)
+	require.NoError(t, err)
+	assert.True(t, exists, "the number stays taken")
+}
+
+func TestOrdersRepo_NumberSharedWithWithdrawals(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	ur := NewUserRepo(pool)
+	lr := NewLedgerRepo(pool)
+	s := storage.New(ur, NewOrdersRepo(pool), lr, NewSessionRepo(pool), NewAuditRepo(pool), NewIdempotencyRepo(pool), NewWebhookRepo(pool), NewTierRepo(pool), NewTx(pool), nil)
+
+	uploader, err := ur.Create(ctx, &models.User{Login: fmt.Sprintf("uploader-%d", time.Now().UnixNano()), EncryptedPwd: "encryptedPwd"})
+	require.NoError(t, err)
+	withdrawer, err := ur.Create(ctx, &models.User{Login: fmt.Sprintf("withdrawer-%d", time.Now().UnixNano()), EncryptedPwd: "encryptedPwd"})
+	require.NoError(t, err)
+	baseOrderID := int(time.Now().UnixNano() / 1000)
+	require.NoError(t, lr.Post(ctx, models.NewAccrualEntry(withdrawer.ID, baseOrderID, money.FromInt(100))))
+
+	// Uploading a number and withdrawing against it at once, exactly one of
+	// them gets it.
+	const numbers = 20
+	var uploadErrs, withdrawErrs [numbers]error
+	wg := sync.WaitGroup{}
+	for i := 0; i < numbers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_, uploadErrs[i] = s.CreateOrder(ctx, &models.Order{ID: baseOrderID + 1 + i, UserID: uploader.ID, Status: models.OrderStatusNew, UploadedAt: time.Now()})
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			_, withdrawErrs[i] = s.CreateWithdrawal(ctx, &models.Withdrawal{OrderID: baseOrderID + 1 + i, UserID: withdrawer.ID, Sum: money.FromInt(1), ProcessedAt: time.Now()})
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < numbers; i++ {
+		if uploadErrs[i] == nil {
+			assert.ErrorIs(t, withdrawErrs[i], storage.ErrOrderCreatedByOtherUser)
+		} else {
+			assert.ErrorIs(t, uploadErrs[i], storage.ErrOrderCreatedByOtherUser)
+			assert.NoError(t, withdrawErrs[i])
+		}
+	}
+}
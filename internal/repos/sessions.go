@@ -0,0 +1,130 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"strconv"
+	"time"
+)
+
+type SessionRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewSessionRepo(pool *pgxpool.Pool) *SessionRepo {
+	return &SessionRepo{pool: pool}
+}
+
+const sessionColumns = "id, user_id, refresh_token_hash, created_at, expires_at, revoked_at"
+
+func scanSession(row pgx.Row) (*models.Session, error) {
+	session := &models.Session{}
+	err := row.Scan(&session.ID, &session.UserID, &session.RefreshTokenHash, &session.CreatedAt, &session.ExpiresAt, &session.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+	return session, nil
+}
+
+// Create starts a session, which is what a successful login or registration
+// amounts to, so it's audited as the user's own action.
+func (sr *SessionRepo) Create(ctx context.Context, session *models.Session) (*models.Session, error) {
+	var created *models.Session
+	err := pgx.BeginFunc(ctx, sr.pool, func(tx pgx.Tx) error {
+		query := "insert into sessions (id, user_id, refresh_token_hash, created_at, expires_at) values ($1, $2, $3, $4, $5) returning " + sessionColumns
+		row := tx.QueryRow(ctx, query, session.ID, session.UserID, session.RefreshTokenHash, session.CreatedAt, session.ExpiresAt)
+		var err error
+		created, err = scanSession(row)
+		if err != nil {
+			return fmt.Errorf("scanSession: %w", err)
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionSessionCreated, models.AuditEntitySession, created.ID).
+			WithChange(nil, map[string]interface{}{"expires_at": created.ExpiresAt})
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID = created.UserID
+		if event.ActorID == 0 {
+			event.ActorID = created.UserID
+		}
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.BeginFunc: %w", err)
+	}
+	return created, nil
+}
+
+func (sr *SessionRepo) GetByID(ctx context.Context, id string) (*models.Session, error) {
+	query := "select " + sessionColumns + " from sessions where id = $1"
+	return scanSession(sr.pool.QueryRow(ctx, query, id))
+}
+
+// Rotate replaces the refresh token hash of an active session. It returns
+// storage.ErrSessionNotFound if the session is unknown, revoked, expired or
+// oldHash doesn't match, so a refresh token can only be used once.
+func (sr *SessionRepo) Rotate(ctx context.Context, id string, oldHash string, newHash string, expiresAt time.Time) (*models.Session, error) {
+	query := `update sessions set refresh_token_hash = $3, expires_at = $4
+              where id = $1 and refresh_token_hash = $2 and revoked_at is null and expires_at > $5
+              returning ` + sessionColumns
+	row := sr.pool.QueryRow(ctx, query, id, oldHash, newHash, expiresAt, time.Now())
+	return scanSession(row)
+}
+
+func (sr *SessionRepo) Revoke(ctx context.Context, id string) error {
+	err := pgx.BeginFunc(ctx, sr.pool, func(tx pgx.Tx) error {
+		query := "update sessions set revoked_at = $2 where id = $1 and revoked_at is null returning user_id"
+		var userID int
+		err := tx.QueryRow(ctx, query, id, time.Now()).Scan(&userID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		event := newAuditEvent(ctx, models.AuditActionSessionRevoked, models.AuditEntitySession, id)
+		event.UserID = userID
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return fmt.Errorf("pgx.BeginFunc: %w", err)
+	}
+	return nil
+}
+
+func (sr *SessionRepo) RevokeAllForUser(ctx context.Context, userID int) ([]string, error) {
+	var ids []string
+	err := pgx.BeginFunc(ctx, sr.pool, func(tx pgx.Tx) error {
+		query := "update sessions set revoked_at = $2 where user_id = $1 and revoked_at is null returning id"
+		rows, err := tx.Query(ctx, query, userID, time.Now())
+		if err != nil {
+			return fmt.Errorf("tx.Query: %w", err)
+		}
+		ids, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return fmt.Errorf("pgx.CollectRows: %w", err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionSessionsRevoked, models.AuditEntityUser, strconv.Itoa(userID)).
+			WithChange(nil, map[string]interface{}{"sessions": ids})
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID = userID
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.BeginFunc: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,53 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"testing"
+	"time"
+)
+
+func TestSessionRepo_Rotate(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	ur := NewUserRepo(pool)
+	sr := NewSessionRepo(pool)
+
+	user, err := ur.Create(ctx, &models.User{
+		Login:        fmt.Sprintf("session-test-%d", time.Now().UnixNano()),
+		EncryptedPwd: "encryptedPwd",
+	})
+	require.NoError(t, err)
+
+	sessionID := fmt.Sprintf("session-%d", time.Now().UnixNano())
+	_, err = sr.Create(ctx, &models.Session{
+		ID:               sessionID,
+		UserID:           user.ID,
+		RefreshTokenHash: "first",
+		CreatedAt:        time.Now(),
+		ExpiresAt:        time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	session, err := sr.Rotate(ctx, sessionID, "first", "second", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, "second", session.RefreshTokenHash)
+
+	_, err = sr.Rotate(ctx, sessionID, "first", "third", time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storage.ErrSessionNotFound)
+
+	ids, err := sr.RevokeAllForUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{sessionID}, ids)
+
+	_, err = sr.Rotate(ctx, sessionID, "second", "third", time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storage.ErrSessionNotFound)
+
+	session, err = sr.GetByID(ctx, sessionID)
+	require.NoError(t, err)
+	assert.False(t, session.Active(time.Now()))
+}
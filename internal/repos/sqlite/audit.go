@@ -0,0 +1,171 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/requestmeta"
+)
+
+const auditColumns = `id, occurred_at, coalesce(actor_id, 0), action, entity_type, entity_id,
+                      coalesce(user_id, 0), coalesce(order_id, 0), coalesce(before, ''), coalesce(after, ''),
+                      coalesce(request_id, ''), coalesce(ip, ''), prev_hash, hash`
+
+type AuditRepo struct {
+	db *sql.DB
+}
+
+func NewAuditRepo(db *sql.DB) *AuditRepo {
+	return &AuditRepo{db: db}
+}
+
+// newAuditEvent starts an event attributed to the authenticated caller and
+// the HTTP request found in ctx, if any.
+func newAuditEvent(ctx context.Context, action string, entityType string, entityID string) *models.AuditEvent {
+	event := models.NewAuditEvent(action, entityType, entityID)
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		event.ActorID = principal.ID
+	}
+	if meta, ok := requestmeta.FromContext(ctx); ok {
+		event.RequestID = meta.RequestID
+		event.IP = meta.IP
+	}
+	return event
+}
+
+// insertAuditEvent appends the event to its user's chain within the caller's
+// transaction, which holds the write lock, so the event is chained to its
+// actual predecessor.
+func insertAuditEvent(ctx context.Context, tx *sql.Tx, event *models.AuditEvent) error {
+	var row *sql.Row
+	if event.UserID == 0 {
+		row = tx.QueryRowContext(ctx, "select hash from audit_events where user_id is null order by id desc limit 1")
+	} else {
+		row = tx.QueryRowContext(ctx, "select hash from audit_events where user_id = $1 order by id desc limit 1", event.UserID)
+	}
+	var prevHash string
+	err := row.Scan(&prevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("row.Scan: %w", err)
+	}
+	event.Chain(prevHash)
+
+	query := `insert into audit_events (occurred_at, actor_id, action, entity_type, entity_id, user_id, order_id,
+                                        before, after, request_id, ip, prev_hash, hash)
+              values ($1, nullif($2, 0), $3, $4, $5, nullif($6, 0), nullif($7, 0),
+                      $8, $9, nullif($10, ''), nullif($11, ''), $12, $13)
+              returning id`
+	row = tx.QueryRowContext(ctx, query, timeArg(event.OccurredAt), event.ActorID, event.Action, event.EntityType, event.EntityID,
+		event.UserID, event.OrderID, jsonOrNil(event.Before), jsonOrNil(event.After), event.RequestID, event.IP,
+		event.PrevHash, event.Hash)
+	if err = row.Scan(&event.ID); err != nil {
+		return fmt.Errorf("row.Scan: %w", err)
+	}
+	return nil
+}
+
+func jsonOrNil(m json.RawMessage) interface{} {
+	if len(m) == 0 {
+		return nil
+	}
+	return string(m)
+}
+
+func scanAuditEvent(row rowScanner) (*models.AuditEvent, error) {
+	e := &models.AuditEvent{}
+	var before, after string
+	err := row.Scan(&e.ID, timestamp(&e.OccurredAt), &e.ActorID, &e.Action, &e.EntityType, &e.EntityID, &e.UserID, &e.OrderID,
+		&before, &after, &e.RequestID, &e.IP, &e.PrevHash, &e.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+	e.OccurredAt = e.OccurredAt.UTC()
+	if before != "" {
+		e.Before = json.RawMessage(before)
+	}
+	if after != "" {
+		e.After = json.RawMessage(after)
+	}
+	return e, nil
+}
+
+// Append records an event that doesn't accompany a change, such as a failed
+// login. Actor and request details missing from the event are taken from ctx.
+func (ar *AuditRepo) Append(ctx context.Context, event *models.AuditEvent) error {
+	fromCtx := newAuditEvent(ctx, event.Action, event.EntityType, event.EntityID)
+	if event.ActorID == 0 {
+		event.ActorID = fromCtx.ActorID
+	}
+	if event.RequestID == "" {
+		event.RequestID = fromCtx.RequestID
+	}
+	if event.IP == "" {
+		event.IP = fromCtx.IP
+	}
+	err := beginFunc(ctx, ar.db, func(tx *sql.Tx) error {
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return fmt.Errorf("beginFunc: %w", err)
+	}
+	return nil
+}
+
+// Query returns the newest events matching the filter. Zero fields don't
+// filter; BeforeID pages back through older events.
+func (ar *AuditRepo) Query(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, error) {
+	query := "select " + auditColumns + ` from audit_events
+              where ($1 = 0 or user_id = $1) and ($2 = 0 or order_id = $2)
+                and ($3 = '' or action = $3) and ($4 = 0 or id < $4)
+              order by id desc limit $5`
+	rows, err := ar.db.QueryContext(ctx, query, filter.UserID, filter.OrderID, filter.Action, filter.BeforeID, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("ar.db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	events := make([]*models.AuditEvent, 0)
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanAuditEvent: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return events, nil
+}
+
+// Verify walks every user's chain and reports the first event whose hash
+// doesn't match its contents or its predecessor.
+func (ar *AuditRepo) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	rows, err := ar.db.QueryContext(ctx, "select "+auditColumns+" from audit_events order by id")
+	if err != nil {
+		return nil, fmt.Errorf("ar.db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	res := &models.AuditVerification{Valid: true}
+	prevHashes := make(map[int]string)
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanAuditEvent: %w", err)
+		}
+		res.Checked++
+		if !e.Verify(prevHashes[e.UserID]) {
+			res.Valid = false
+			res.BrokenAt = e.ID
+			return res, nil
+		}
+		prevHashes[e.UserID] = e.Hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return res, nil
+}
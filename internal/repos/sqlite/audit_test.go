@@ -0,0 +1,86 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/auth"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"github.com/vindosVP/loyalty-system/pkg/requestmeta"
+	"testing"
+	"time"
+)
+
+func TestAuditRepo_RecordsChanges(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(t)
+	ur := NewUserRepo(db)
+	or := NewOrdersRepo(db)
+	lr := NewLedgerRepo(db)
+	ar := NewAuditRepo(db)
+
+	user, err := ur.Create(ctx, &models.User{Login: "audit-test", EncryptedPwd: "encryptedPwd"})
+	require.NoError(t, err)
+
+	reqCtx := auth.WithPrincipal(ctx, &auth.Principal{ID: user.ID})
+	reqCtx = requestmeta.WithMeta(reqCtx, &requestmeta.Meta{RequestID: "someRequest", IP: "192.0.2.1"})
+	orderID := 12345678903
+	_, err = or.Create(reqCtx, &models.Order{ID: orderID, UserID: user.ID, Status: models.OrderStatusNew, UploadedAt: time.Now()})
+	require.NoError(t, err)
+	_, err = or.UpdateOrderStatus(ctx, orderID, "", models.OrderStatusProcessing)
+	require.NoError(t, err)
+	// Another user's events go to their own chain.
+	_, err = ur.Create(ctx, &models.User{Login: "audit-other", EncryptedPwd: "encryptedPwd"})
+	require.NoError(t, err)
+	// Repeated polls that don't change the order aren't audited.
+	_, err = or.UpdateOrderStatus(ctx, orderID, "", models.OrderStatusProcessing)
+	require.NoError(t, err)
+	require.NoError(t, lr.Post(ctx, models.NewAccrualEntry(user.ID, orderID, money.FromInt(100))))
+	_, err = or.UpdateOrder(ctx, orderID, "", models.OrderStatusProcessed, money.FromInt(100))
+	require.NoError(t, err)
+	err = lr.Withdraw(reqCtx, &models.Withdrawal{OrderID: orderID + 1, UserID: user.ID, Sum: money.FromInt(30), ProcessedAt: time.Now()})
+	require.NoError(t, err)
+
+	events, err := ar.Query(ctx, models.AuditFilter{UserID: user.ID, Limit: 10})
+	require.NoError(t, err)
+	actions := make([]string, len(events))
+	for i, e := range events {
+		actions[i] = e.Action
+	}
+	assert.Equal(t, []string{
+		models.AuditActionWithdrawalCreated,
+		models.AuditActionOrderStatusChanged,
+		models.AuditActionAccrualPosted,
+		models.AuditActionOrderStatusChanged,
+		models.AuditActionOrderUploaded,
+		models.AuditActionUserRegistered,
+	}, actions)
+
+	for i, e := range events[:len(events)-1] {
+		assert.Equal(t, events[i+1].Hash, e.PrevHash, "%s isn't chained to the user's previous event", e.Action)
+	}
+	assert.Empty(t, events[len(events)-1].PrevHash)
+
+	uploaded := events[4]
+	assert.Equal(t, user.ID, uploaded.ActorID)
+	assert.Equal(t, orderID, uploaded.OrderID)
+	assert.Equal(t, "someRequest", uploaded.RequestID)
+	assert.Equal(t, "192.0.2.1", uploaded.IP)
+	assert.JSONEq(t, `{"status":"PROCESSING","sum":0}`, string(events[1].Before))
+	assert.JSONEq(t, `{"status":"PROCESSED","sum":100}`, string(events[1].After))
+
+	byOrder, err := ar.Query(ctx, models.AuditFilter{OrderID: orderID, Action: models.AuditActionOrderStatusChanged, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, byOrder, 2)
+
+	_, err = db.ExecContext(ctx, "update audit_events set ip = null where id = $1", uploaded.ID)
+	assert.Error(t, err, "audit_events must be append-only")
+	_, err = db.ExecContext(ctx, "delete from audit_events where id = $1", uploaded.ID)
+	assert.Error(t, err, "audit_events must be append-only")
+
+	res, err := ar.Verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, res.Valid, "chain broken at %d", res.BrokenAt)
+	assert.Equal(t, len(events)+1, res.Checked)
+}
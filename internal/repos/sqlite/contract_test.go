@@ -0,0 +1,28 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/database"
+	"github.com/vindosVP/loyalty-system/internal/storage/storagetest"
+	"path/filepath"
+	"testing"
+)
+
+// testDB returns a migrated database in a file of its own, so tests can run
+// in parallel and concurrent writers go through the file locks.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := database.NewSQLite(context.Background(), database.SQLiteScheme+filepath.Join(t.TempDir(), "gophermart.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestContract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) *storagetest.Backend {
+		db := testDB(t)
+		return &storagetest.Backend{Users: NewUserRepo(db), Orders: NewOrdersRepo(db), Ledger: NewLedgerRepo(db), Tiers: NewTierRepo(db), Idempotency: NewIdempotencyRepo(db), Tx: NewTx(db)}
+	})
+}
@@ -0,0 +1,231 @@
+// Package sqlite implements the storage repos on SQLite, for single-node
+// deployments that don't run PostgreSQL. The queries follow the PostgreSQL
+// repos; modernc.org/sqlite binds $n placeholders by position. Write
+// transactions take the database write lock when they begin (see
+// database.ConnectSQLite), which serializes them the way row locks and
+// advisory locks do in PostgreSQL.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	moderncsqlite "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"strings"
+	"time"
+)
+
+// rowScanner is a *sql.Row or *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// dbtx is what the repos run queries on: the database, or a transaction when
+// they take part in a unit of work.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// beginFunc runs f in a transaction and commits it unless f fails, like
+// pgx.BeginFunc. On a transaction it uses a savepoint, so a failing f only
+// undoes its own changes.
+func beginFunc(ctx context.Context, db dbtx, f func(tx *sql.Tx) error) error {
+	if tx, ok := db.(*sql.Tx); ok {
+		return savepointFunc(ctx, tx, f)
+	}
+	tx, err := db.(*sql.DB).BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("db.BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+	if err = f(tx); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+	return nil
+}
+
+func savepointFunc(ctx context.Context, tx *sql.Tx, f func(tx *sql.Tx) error) (err error) {
+	if _, err = tx.ExecContext(ctx, "savepoint nested"); err != nil {
+		return fmt.Errorf("tx.ExecContext: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			// Rolling back to a savepoint keeps it, so it's released either way.
+			if _, rbErr := tx.ExecContext(ctx, "rollback to nested; release nested"); rbErr != nil {
+				err = errors.Join(err, fmt.Errorf("tx.ExecContext: %w", rbErr))
+			}
+		}
+	}()
+	if err = f(tx); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, "release nested"); err != nil {
+		return fmt.Errorf("tx.ExecContext: %w", err)
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether the insert broke a unique or primary key
+// constraint.
+func isUniqueViolation(err error) bool {
+	var sqliteErr *moderncsqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
+
+// Timestamps are stored as microseconds since the Unix epoch and amounts as
+// hundredths, both as integers. Arguments must be converted explicitly: the
+// driver would write time.Time and money.Amount as text.
+
+func timeArg(t time.Time) int64 {
+	return t.UnixMicro()
+}
+
+func nullTimeArg(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UnixMicro()
+}
+
+func amountArg(a money.Amount) int64 {
+	return int64(a)
+}
+
+type timestampScanner struct {
+	t *time.Time
+}
+
+func timestamp(t *time.Time) sql.Scanner {
+	return timestampScanner{t: t}
+}
+
+func (s timestampScanner) Scan(src interface{}) error {
+	us, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("timestamp: cannot scan %T", src)
+	}
+	*s.t = time.UnixMicro(us)
+	return nil
+}
+
+type nullTimestampScanner struct {
+	t **time.Time
+}
+
+func nullTimestamp(t **time.Time) sql.Scanner {
+	return nullTimestampScanner{t: t}
+}
+
+func (s nullTimestampScanner) Scan(src interface{}) error {
+	if src == nil {
+		*s.t = nil
+		return nil
+	}
+	t := new(time.Time)
+	if err := timestamp(t).Scan(src); err != nil {
+		return err
+	}
+	*s.t = t
+	return nil
+}
+
+type amountScanner struct {
+	a *money.Amount
+}
+
+func amount(a *money.Amount) sql.Scanner {
+	return amountScanner{a: a}
+}
+
+func (s amountScanner) Scan(src interface{}) error {
+	v, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("amount: cannot scan %T", src)
+	}
+	*s.a = money.Amount(v)
+	return nil
+}
+
+// Lists, such as roles, are stored as JSON arrays.
+
+func listArg(list []string) (string, error) {
+	if list == nil {
+		list = []string{}
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %w", err)
+	}
+	return string(data), nil
+}
+
+type listScanner struct {
+	list *[]string
+}
+
+func list(l *[]string) sql.Scanner {
+	return listScanner{list: l}
+}
+
+func (s listScanner) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("list: cannot scan %T", src)
+	}
+	return json.Unmarshal(data, s.list)
+}
+
+// whereClause collects conditions with ? placeholders and numbers them as
+// positional parameters.
+type whereClause struct {
+	conds []string
+	args  []interface{}
+}
+
+func (w *whereClause) add(cond string, args ...interface{}) {
+	for _, arg := range args {
+		w.args = append(w.args, arg)
+		cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(w.args)), 1)
+	}
+	w.conds = append(w.conds, cond)
+}
+
+// arg adds a parameter that isn't part of a condition, like a limit, and
+// returns its placeholder.
+func (w *whereClause) arg(v interface{}) string {
+	w.args = append(w.args, v)
+	return fmt.Sprintf("$%d", len(w.args))
+}
+
+// in adds a parameter for each value and returns the list of placeholders.
+func (w *whereClause) in(values []string) string {
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = w.arg(v)
+	}
+	return "(" + strings.Join(placeholders, ", ") + ")"
+}
+
+func (w *whereClause) String() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return " where " + strings.Join(w.conds, " and ")
+}
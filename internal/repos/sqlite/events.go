@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"sync"
+	"time"
+)
+
+// EventRepo stores user events and hands appended ones to the listeners of
+// this process. SQLite has no LISTEN/NOTIFY, and a single node is the only
+// writer of its database file, so in-process delivery reaches every listener.
+type EventRepo struct {
+	db *sql.DB
+
+	// notifyMu keeps listeners seeing events in commit order.
+	notifyMu    sync.Mutex
+	mu          sync.Mutex
+	listeners   map[int]func(event *models.UserEvent)
+	listenerSeq int
+}
+
+func NewEventRepo(db *sql.DB) *EventRepo {
+	return &EventRepo{db: db, listeners: make(map[int]func(event *models.UserEvent))}
+}
+
+const eventColumns = "seq, user_id, type, subject, data, created_at"
+
+// Append stores the event and passes it to every listener on commit. It
+// returns false without storing anything if the latest event on the same
+// subject carries the same data, as happens when an order is polled again
+// without a status change. Events are numbered per user from a counter that
+// survives pruning, as in PostgreSQL.
+func (er *EventRepo) Append(ctx context.Context, event *models.UserEvent) (bool, error) {
+	er.notifyMu.Lock()
+	defer er.notifyMu.Unlock()
+
+	appended := false
+	err := beginFunc(ctx, er.db, func(tx *sql.Tx) error {
+		query := `insert into user_event_seqs (user_id, seq) values ($1, 1)
+                  on conflict (user_id) do update set seq = seq + 1
+                  returning seq`
+		var seq int64
+		if err := tx.QueryRowContext(ctx, query, event.UserID).Scan(&seq); err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		query = `insert into user_events (user_id, seq, type, subject, data, created_at)
+                 select $1, $2, $3, $4, $5, $6
+                 where coalesce((select data from user_events
+                                 where user_id = $1 and subject = $4
+                                 order by seq desc limit 1), '') <> $5
+                 returning seq`
+		err := tx.QueryRowContext(ctx, query, event.UserID, seq, event.Type, event.Subject, string(event.Data), timeArg(event.CreatedAt)).Scan(&event.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		appended = true
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("beginFunc: %w", err)
+	}
+	if !appended {
+		return false, nil
+	}
+
+	er.mu.Lock()
+	listeners := make([]func(event *models.UserEvent), 0, len(er.listeners))
+	for _, f := range er.listeners {
+		listeners = append(listeners, f)
+	}
+	er.mu.Unlock()
+	for _, f := range listeners {
+		e := *event
+		e.Data = append(json.RawMessage(nil), event.Data...)
+		f(&e)
+	}
+	return true, nil
+}
+
+// ListAfter returns up to limit of the user's events numbered above afterID,
+// oldest first.
+func (er *EventRepo) ListAfter(ctx context.Context, userID int, afterID int64, limit int) ([]*models.UserEvent, error) {
+	query := "select " + eventColumns + " from user_events where user_id = $1 and seq > $2 order by seq limit $3"
+	rows, err := er.db.QueryContext(ctx, query, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("er.db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	events := make([]*models.UserEvent, 0)
+	for rows.Next() {
+		e := &models.UserEvent{}
+		var data string
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Type, &e.Subject, &data, timestamp(&e.CreatedAt)); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		e.Data = json.RawMessage(data)
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return events, nil
+}
+
+func (er *EventRepo) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := er.db.ExecContext(ctx, "delete from user_events where created_at < $1", timeArg(before))
+	if err != nil {
+		return 0, fmt.Errorf("er.db.ExecContext: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("res.RowsAffected: %w", err)
+	}
+	return n, nil
+}
+
+// Listen calls f for every event appended until ctx is cancelled.
+func (er *EventRepo) Listen(ctx context.Context, f func(event *models.UserEvent)) error {
+	er.mu.Lock()
+	er.listenerSeq++
+	id := er.listenerSeq
+	er.listeners[id] = f
+	er.mu.Unlock()
+
+	<-ctx.Done()
+
+	er.mu.Lock()
+	delete(er.listeners, id)
+	er.mu.Unlock()
+	return ctx.Err()
+}
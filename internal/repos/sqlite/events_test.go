@@ -0,0 +1,86 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"testing"
+	"time"
+)
+
+func TestEventRepo(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(t)
+	er := NewEventRepo(db)
+	user, err := NewUserRepo(db).Create(ctx, &models.User{Login: "events-test", EncryptedPwd: "encryptedPwd"})
+	require.NoError(t, err)
+
+	listenCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	received := make(chan *models.UserEvent, 10)
+	listening := make(chan error, 1)
+	go func() {
+		listening <- er.Listen(listenCtx, func(event *models.UserEvent) {
+			received <- event
+		})
+	}()
+	require.Eventually(t, func() bool {
+		er.mu.Lock()
+		defer er.mu.Unlock()
+		return len(er.listeners) == 1
+	}, time.Second, time.Millisecond)
+
+	first, err := models.NewBalanceEvent(user.ID, money.FromInt(100), 0)
+	require.NoError(t, err)
+	appended, err := er.Append(ctx, first)
+	require.NoError(t, err)
+	assert.True(t, appended)
+
+	same, err := models.NewBalanceEvent(user.ID, money.FromInt(100), 0)
+	require.NoError(t, err)
+	appended, err = er.Append(ctx, same)
+	require.NoError(t, err)
+	assert.False(t, appended)
+
+	second, err := models.NewBalanceEvent(user.ID, money.FromInt(50), money.FromInt(50))
+	require.NoError(t, err)
+	appended, err = er.Append(ctx, second)
+	require.NoError(t, err)
+	assert.True(t, appended)
+
+	for _, want := range []*models.UserEvent{first, second} {
+		select {
+		case got := <-received:
+			assert.Equal(t, want.ID, got.ID)
+			assert.JSONEq(t, string(want.Data), string(got.Data))
+		case <-time.After(5 * time.Second):
+			t.Fatal("notification wasn't received")
+		}
+	}
+
+	events, err := er.ListAfter(ctx, user.ID, first.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, second.ID, events[0].ID)
+
+	deleted, err := er.DeleteBefore(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	third, err := models.NewBalanceEvent(user.ID, money.FromInt(10), money.FromInt(90))
+	require.NoError(t, err)
+	_, err = er.Append(ctx, third)
+	require.NoError(t, err)
+	assert.Greater(t, third.ID, second.ID, "ids aren't reused")
+	otherUser, err := NewUserRepo(db).Create(ctx, &models.User{Login: "events-test-other", EncryptedPwd: "encryptedPwd"})
+	require.NoError(t, err)
+	other, err := models.NewBalanceEvent(otherUser.ID, money.FromInt(10), 0)
+	require.NoError(t, err)
+	_, err = er.Append(ctx, other)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, other.ID, "events are numbered per user")
+
+	cancel()
+	assert.ErrorIs(t, <-listening, context.Canceled)
+}
@@ -0,0 +1,534 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnbalancedEntry = errors.New("entry postings do not sum to zero")
+
+type LedgerRepo struct {
+	db dbtx
+}
+
+func NewLedgerRepo(db *sql.DB) *LedgerRepo {
+	return &LedgerRepo{db: db}
+}
+
+const withdrawalQuery = `select e.order_id, e.user_id, -p.amount, e.created_at from ledger_entries e
+              join ledger_postings p on p.entry_id = e.id
+              join ledger_accounts a on a.id = p.account_id and a.user_id = e.user_id`
+
+func scanWithdrawal(row rowScanner) (*models.Withdrawal, error) {
+	w := &models.Withdrawal{}
+	return w, row.Scan(&w.OrderID, &w.UserID, amount(&w.Sum), timestamp(&w.ProcessedAt))
+}
+
+func scanWithdrawals(rows *sql.Rows) ([]*models.Withdrawal, error) {
+	defer rows.Close()
+	withdrawals := make([]*models.Withdrawal, 0)
+	for rows.Next() {
+		w, err := scanWithdrawal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		withdrawals = append(withdrawals, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return withdrawals, nil
+}
+
+// Post records the entry and its postings in one transaction. Posting an entry
+// for an order that already has an entry of the same kind is a no-op.
+func (lr *LedgerRepo) Post(ctx context.Context, entry *models.Entry) error {
+	return beginFunc(ctx, lr.db, func(tx *sql.Tx) error {
+		posted, err := insertEntry(ctx, tx, entry)
+		if err != nil {
+			return fmt.Errorf("insertEntry: %w", err)
+		}
+		if !posted {
+			return nil
+		}
+		event, err := newEntryAuditEvent(ctx, models.AuditActionAccrualPosted, entry, nil,
+			map[string]interface{}{"kind": entry.Kind, "amount": entry.Postings[0].Amount})
+		if err != nil {
+			return fmt.Errorf("newEntryAuditEvent: %w", err)
+		}
+		return insertAuditEvent(ctx, tx, event)
+	})
+}
+
+// Withdraw posts the withdrawal if the user's balance covers it, taking the
+// points from the oldest unexpired lots. The transaction holds the database write lock from the start, so concurrent
+// withdrawals are serialized and can't overdraw the account.
+func (lr *LedgerRepo) Withdraw(ctx context.Context, withdrawal *models.Withdrawal) error {
+	entry := models.NewWithdrawalEntry(withdrawal.UserID, withdrawal.OrderID, withdrawal.Sum)
+	entry.CreatedAt = withdrawal.ProcessedAt
+	return beginFunc(ctx, lr.db, func(tx *sql.Tx) error {
+		balance, err := balance(ctx, tx, withdrawal.UserID, entry.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("balance: %w", err)
+		}
+
+		query := "select user_id from ledger_entries where kind = $1 and order_id = $2"
+		var ownerID int
+		err = tx.QueryRowContext(ctx, query, models.EntryKindWithdrawal, withdrawal.OrderID).Scan(&ownerID)
+		if err == nil {
+			if ownerID == withdrawal.UserID {
+				return storage.ErrOrderAlreadyExists
+			}
+			return storage.ErrOrderCreatedByOtherUser
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+
+		if balance < withdrawal.Sum {
+			return storage.ErrInsufficientFunds
+		}
+
+		posted, err := insertEntry(ctx, tx, entry)
+		if err != nil {
+			return fmt.Errorf("insertEntry: %w", err)
+		}
+		if !posted {
+			return storage.ErrOrderAlreadyExists
+		}
+		webhook, err := models.NewWithdrawalWebhookEvent(withdrawal)
+		if err != nil {
+			return fmt.Errorf("models.NewWithdrawalWebhookEvent: %w", err)
+		}
+		if err = enqueueWebhook(ctx, tx, webhook); err != nil {
+			return fmt.Errorf("enqueueWebhook: %w", err)
+		}
+		event, err := newEntryAuditEvent(ctx, models.AuditActionWithdrawalCreated, entry,
+			map[string]interface{}{"balance": balance},
+			map[string]interface{}{"balance": balance - withdrawal.Sum, "sum": withdrawal.Sum})
+		if err != nil {
+			return fmt.Errorf("newEntryAuditEvent: %w", err)
+		}
+		return insertAuditEvent(ctx, tx, event)
+	})
+}
+
+// Adjust posts a manual balance correction. Like Withdraw it is serialized
+// with other writes, and it refuses a debit that would make the balance negative.
+func (lr *LedgerRepo) Adjust(ctx context.Context, adjustment *models.Adjustment) (money.Amount, error) {
+	entry := models.NewAdjustmentEntry(adjustment)
+	var newBalance money.Amount
+	err := beginFunc(ctx, lr.db, func(tx *sql.Tx) error {
+		balance, err := balance(ctx, tx, adjustment.UserID, entry.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("balance: %w", err)
+		}
+		if balance+adjustment.Amount < 0 {
+			return storage.ErrInsufficientFunds
+		}
+		if _, err = insertEntry(ctx, tx, entry); err != nil {
+			return fmt.Errorf("insertEntry: %w", err)
+		}
+		newBalance = balance + adjustment.Amount
+		event, err := newEntryAuditEvent(ctx, models.AuditActionBalanceAdjusted, entry,
+			map[string]interface{}{"balance": balance},
+			map[string]interface{}{"balance": newBalance, "amount": adjustment.Amount, "reason": adjustment.Reason})
+		if err != nil {
+			return fmt.Errorf("newEntryAuditEvent: %w", err)
+		}
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return newBalance, nil
+}
+
+// balanceQuery sums the user's account less the points past their expiry
+// date that haven't been expired yet.
+const balanceQuery = `select coalesce(sum(p.amount), 0) - (select coalesce(sum(remaining), 0) from ledger_lots
+              where user_id = $2 and remaining > 0 and expires_at <= $3)
+              from ledger_postings p join ledger_accounts a on a.id = p.account_id where a.code = $1`
+
+// balance returns the balance of the user's account that can be spent at the
+// given time within the transaction.
+func balance(ctx context.Context, tx *sql.Tx, userID int, at time.Time) (money.Amount, error) {
+	var balance money.Amount
+	row := tx.QueryRowContext(ctx, balanceQuery, models.UserAccountCode(userID), userID, timeArg(at))
+	if err := row.Scan(amount(&balance)); err != nil {
+		return 0, fmt.Errorf("row.Scan: %w", err)
+	}
+	return balance, nil
+}
+
+// ExpirePoints expires the points of up to limit users whose lots are past
+// their expiry date at now, each user in a transaction of its own, and returns
+// the expiry entries posted.
+func (lr *LedgerRepo) ExpirePoints(ctx context.Context, now time.Time, limit int) ([]*models.Entry, error) {
+	query := `select user_id from ledger_lots where remaining > 0 and expires_at <= $1
+              group by user_id order by user_id limit $2`
+	rows, err := lr.db.QueryContext(ctx, query, timeArg(now), limit)
+	if err != nil {
+		return nil, fmt.Errorf("lr.db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	rows.Close()
+
+	entries := make([]*models.Entry, 0, len(userIDs))
+	for _, userID := range userIDs {
+		entry, err := lr.expireLots(ctx, userID, now)
+		if err != nil {
+			return entries, fmt.Errorf("lr.expireLots: %w", err)
+		}
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// expireLots empties the user's lots that are past their expiry date at now
+// and posts an expiry entry for what they had left. It returns nil if there
+// was nothing to expire.
+func (lr *LedgerRepo) expireLots(ctx context.Context, userID int, now time.Time) (*models.Entry, error) {
+	var entry *models.Entry
+	err := beginFunc(ctx, lr.db, func(tx *sql.Tx) error {
+		query := `select coalesce(sum(remaining), 0) from ledger_lots
+                  where user_id = $1 and remaining > 0 and expires_at <= $2`
+		var expired money.Amount
+		if err := tx.QueryRowContext(ctx, query, userID, timeArg(now)).Scan(amount(&expired)); err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		if expired == 0 {
+			return nil
+		}
+		query = "update ledger_lots set remaining = 0 where user_id = $1 and remaining > 0 and expires_at <= $2"
+		if _, err := tx.ExecContext(ctx, query, userID, timeArg(now)); err != nil {
+			return fmt.Errorf("tx.ExecContext: %w", err)
+		}
+
+		expiry := models.NewExpiryEntry(userID, expired)
+		expiry.CreatedAt = now
+		if _, err := insertEntry(ctx, tx, expiry); err != nil {
+			return fmt.Errorf("insertEntry: %w", err)
+		}
+		event, err := newEntryAuditEvent(ctx, models.AuditActionPointsExpired, expiry, nil,
+			map[string]interface{}{"amount": expired})
+		if err != nil {
+			return fmt.Errorf("newEntryAuditEvent: %w", err)
+		}
+		entry = expiry
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ScheduleExpiry sets the expiry date of the accrual lots that have points left
+// but no expiry date and returns how many there were.
+func (lr *LedgerRepo) ScheduleExpiry(ctx context.Context, expiresAt time.Time) (int64, error) {
+	query := `update ledger_lots set expires_at = $1
+              where remaining > 0 and expires_at is null
+                and entry_id in (select id from ledger_entries where kind = $2)`
+	res, err := lr.db.ExecContext(ctx, query, timeArg(expiresAt), models.EntryKindAccrual)
+	if err != nil {
+		return 0, fmt.Errorf("lr.db.ExecContext: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("res.RowsAffected: %w", err)
+	}
+	return n, nil
+}
+
+// ListExpirations returns the user's points that expire after now, and before
+// until if it's set, soonest first.
+func (lr *LedgerRepo) ListExpirations(ctx context.Context, userID int, now time.Time, until *time.Time) ([]*models.Expiration, error) {
+	w := &whereClause{}
+	w.add("user_id = ?", userID)
+	w.add("remaining > 0")
+	w.add("expires_at > ?", timeArg(now))
+	if until != nil {
+		w.add("expires_at <= ?", timeArg(*until))
+	}
+	query := "select sum(remaining), expires_at from ledger_lots" + w.String() + " group by expires_at order by expires_at"
+	rows, err := lr.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("lr.db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	expirations := make([]*models.Expiration, 0)
+	for rows.Next() {
+		e := &models.Expiration{}
+		if err := rows.Scan(amount(&e.Amount), timestamp(&e.ExpiresAt)); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		expirations = append(expirations, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return expirations, nil
+}
+
+func newEntryAuditEvent(ctx context.Context, action string, entry *models.Entry, before interface{}, after interface{}) (*models.AuditEvent, error) {
+	event, err := newAuditEvent(ctx, action, models.AuditEntityEntry, strconv.FormatInt(entry.ID, 10)).WithChange(before, after)
+	if err != nil {
+		return nil, fmt.Errorf("WithChange: %w", err)
+	}
+	event.UserID, event.OrderID = entry.UserID, entry.OrderID
+	return event, nil
+}
+
+func insertEntry(ctx context.Context, tx *sql.Tx, entry *models.Entry) (bool, error) {
+	if !entry.Balanced() {
+		return false, ErrUnbalancedEntry
+	}
+	query := `insert into ledger_entries (kind, order_id, user_id, created_at, reason, created_by, accrued)
+              values ($1, nullif($2, 0), $3, $4, nullif($5, ''), nullif($6, 0), nullif($7, 0))
+              on conflict (kind, order_id) do nothing returning id`
+	row := tx.QueryRowContext(ctx, query, entry.Kind, entry.OrderID, entry.UserID, timeArg(entry.CreatedAt), entry.Reason, entry.CreatedBy,
+		amountArg(entry.Accrued))
+	err := row.Scan(&entry.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("row.Scan: %w", err)
+	}
+
+	for _, p := range entry.Postings {
+		p.EntryID = entry.ID
+		p.AccountID, err = ensureAccount(ctx, tx, p.AccountCode, entry.UserID)
+		if err != nil {
+			return false, fmt.Errorf("ensureAccount: %w", err)
+		}
+		query = "insert into ledger_postings (entry_id, account_id, amount) values ($1, $2, $3) returning id"
+		row = tx.QueryRowContext(ctx, query, p.EntryID, p.AccountID, amountArg(p.Amount))
+		if err = row.Scan(&p.ID); err != nil {
+			return false, fmt.Errorf("row.Scan: %w", err)
+		}
+	}
+	if err = updateLots(ctx, tx, entry); err != nil {
+		return false, fmt.Errorf("updateLots: %w", err)
+	}
+	return true, nil
+}
+
+// updateLots opens a lot for points the entry credits to the user and takes
+// points it debits from the oldest lots unexpired at the entry's creation.
+// Expiry entries are posted for lots expireLots has already emptied.
+func updateLots(ctx context.Context, tx *sql.Tx, entry *models.Entry) error {
+	if entry.Kind == models.EntryKindExpiry {
+		return nil
+	}
+	for _, p := range entry.Postings {
+		if p.AccountCode != models.UserAccountCode(entry.UserID) {
+			continue
+		}
+		if p.Amount > 0 {
+			query := `insert into ledger_lots (entry_id, user_id, amount, remaining, created_at, expires_at)
+                      values ($1, $2, $3, $3, $4, $5)`
+			_, err := tx.ExecContext(ctx, query, entry.ID, entry.UserID, amountArg(p.Amount), timeArg(entry.CreatedAt), nullTimeArg(entry.ExpiresAt))
+			if err != nil {
+				return fmt.Errorf("tx.ExecContext: %w", err)
+			}
+			continue
+		}
+		if err := consumeLots(ctx, tx, entry.UserID, -p.Amount, entry.CreatedAt); err != nil {
+			return fmt.Errorf("consumeLots: %w", err)
+		}
+	}
+	return nil
+}
+
+// consumeLots takes sum from the user's lots unexpired at the given time,
+// oldest first. The balance checks keep sum within what the lots hold.
+func consumeLots(ctx context.Context, tx *sql.Tx, userID int, sum money.Amount, at time.Time) error {
+	query := `select id, remaining from ledger_lots
+              where user_id = $1 and remaining > 0 and (expires_at is null or expires_at > $2)
+              order by id`
+	rows, err := tx.QueryContext(ctx, query, userID, timeArg(at))
+	if err != nil {
+		return fmt.Errorf("tx.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	type lot struct {
+		id        int64
+		remaining money.Amount
+	}
+	var lots []lot
+	for rows.Next() {
+		var l lot
+		if err := rows.Scan(&l.id, amount(&l.remaining)); err != nil {
+			return fmt.Errorf("rows.Scan: %w", err)
+		}
+		lots = append(lots, l)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows.Err: %w", err)
+	}
+	rows.Close()
+
+	for _, l := range lots {
+		if sum == 0 {
+			break
+		}
+		taken := l.remaining
+		if taken > sum {
+			taken = sum
+		}
+		query = "update ledger_lots set remaining = remaining - $1 where id = $2"
+		if _, err = tx.ExecContext(ctx, query, amountArg(taken), l.id); err != nil {
+			return fmt.Errorf("tx.ExecContext: %w", err)
+		}
+		sum -= taken
+	}
+	return nil
+}
+
+func ensureAccount(ctx context.Context, tx *sql.Tx, code string, userID int) (int, error) {
+	var owner *int
+	if !strings.HasPrefix(code, "system:") {
+		owner = &userID
+	}
+	query := "insert into ledger_accounts (code, user_id) values ($1, $2) on conflict (code) do update set code = excluded.code returning id"
+	var id int
+	if err := tx.QueryRowContext(ctx, query, code, owner).Scan(&id); err != nil {
+		return 0, fmt.Errorf("row.Scan: %w", err)
+	}
+	return id, nil
+}
+
+// GetBalance returns the user's balance less the points past their expiry date
+// that haven't been expired yet.
+func (lr *LedgerRepo) GetBalance(ctx context.Context, userID int) (money.Amount, error) {
+	var balance money.Amount
+	err := lr.db.QueryRowContext(ctx, balanceQuery, models.UserAccountCode(userID), userID, timeArg(time.Now())).Scan(amount(&balance))
+	if err != nil {
+		return 0, fmt.Errorf("row.Scan: %w", err)
+	}
+	return balance, nil
+}
+
+func (lr *LedgerRepo) GetWithdrawnTotal(ctx context.Context, userID int) (money.Amount, error) {
+	query := `select coalesce(sum(-p.amount), 0) from ledger_postings p
+              join ledger_entries e on e.id = p.entry_id
+              join ledger_accounts a on a.id = p.account_id
+              where a.code = $1 and e.kind = $2`
+	var withdrawn money.Amount
+	err := lr.db.QueryRowContext(ctx, query, models.UserAccountCode(userID), models.EntryKindWithdrawal).Scan(amount(&withdrawn))
+	if err != nil {
+		return 0, fmt.Errorf("row.Scan: %w", err)
+	}
+	return withdrawn, nil
+}
+
+// GetAccruedTotal returns what the user accrued for processed orders since the
+// given time, before tier multipliers.
+func (lr *LedgerRepo) GetAccruedTotal(ctx context.Context, userID int, since time.Time) (money.Amount, error) {
+	query := `select coalesce(sum(coalesce(e.accrued, p.amount)), 0) from ledger_entries e
+              join ledger_postings p on p.entry_id = e.id
+              join ledger_accounts a on a.id = p.account_id and a.user_id = e.user_id
+              where e.user_id = $1 and e.kind = $2 and e.created_at >= $3`
+	var accrued money.Amount
+	err := lr.db.QueryRowContext(ctx, query, userID, models.EntryKindAccrual, timeArg(since)).Scan(amount(&accrued))
+	if err != nil {
+		return 0, fmt.Errorf("row.Scan: %w", err)
+	}
+	return accrued, nil
+}
+
+func (lr *LedgerRepo) GetWithdrawal(ctx context.Context, orderID int) (*models.Withdrawal, error) {
+	query := withdrawalQuery + " where e.kind = $1 and e.order_id = $2"
+	w, err := scanWithdrawal(lr.db.QueryRowContext(ctx, query, models.EntryKindWithdrawal, orderID))
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+	return w, nil
+}
+
+func (lr *LedgerRepo) GetWithdrawals(ctx context.Context, userID int) ([]*models.Withdrawal, error) {
+	query := withdrawalQuery + " where e.kind = $1 and e.user_id = $2 order by e.created_at"
+	rows, err := lr.db.QueryContext(ctx, query, models.EntryKindWithdrawal, userID)
+	if err != nil {
+		return nil, fmt.Errorf("lr.db.QueryContext: %w", err)
+	}
+	return scanWithdrawals(rows)
+}
+
+// ListWithdrawals returns up to filter.Limit of the user's withdrawals matching
+// the filter, oldest first, starting after filter.After. The cursor id is the
+// withdrawal's order number.
+func (lr *LedgerRepo) ListWithdrawals(ctx context.Context, userID int, filter models.ListFilter) ([]*models.Withdrawal, error) {
+	w := &whereClause{}
+	w.add("e.kind = ?", models.EntryKindWithdrawal)
+	w.add("e.user_id = ?", userID)
+	if filter.From != nil {
+		w.add("e.created_at >= ?", timeArg(*filter.From))
+	}
+	if filter.To != nil {
+		w.add("e.created_at < ?", timeArg(*filter.To))
+	}
+	if filter.MinAmount != nil {
+		w.add("-p.amount >= ?", amountArg(*filter.MinAmount))
+	}
+	if filter.MaxAmount != nil {
+		w.add("-p.amount <= ?", amountArg(*filter.MaxAmount))
+	}
+	if filter.After != nil {
+		w.add("(e.created_at, e.order_id) > (?, ?)", timeArg(filter.After.Time), filter.After.ID)
+	}
+	query := withdrawalQuery + w.String() + " order by e.created_at, e.order_id limit " + w.arg(filter.Limit)
+
+	rows, err := lr.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("lr.db.QueryContext: %w", err)
+	}
+	return scanWithdrawals(rows)
+}
+
+func (lr *LedgerRepo) GetTrialBalance(ctx context.Context) ([]*models.AccountBalance, error) {
+	query := `select a.id, a.code, a.user_id, coalesce(sum(p.amount), 0) from ledger_accounts a
+              left join ledger_postings p on p.account_id = a.id
+              group by a.id order by a.id`
+	rows, err := lr.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("lr.db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	balances := make([]*models.AccountBalance, 0)
+	for rows.Next() {
+		b := &models.AccountBalance{Account: &models.Account{}}
+		err := rows.Scan(&b.Account.ID, &b.Account.Code, &b.Account.UserID, amount(&b.Balance))
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		balances = append(balances, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return balances, nil
+}
@@ -0,0 +1,315 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"strconv"
+	"time"
+)
+
+type OrdersRepo struct {
+	db dbtx
+}
+
+func NewOrdersRepo(db *sql.DB) *OrdersRepo {
+	return &OrdersRepo{db: db}
+}
+
+const orderColumns = "id, user_id, status, sum, uploaded_at"
+
+func scanOrder(row rowScanner) (*models.Order, error) {
+	order := &models.Order{}
+	return order, row.Scan(&order.ID, &order.UserID, &order.Status, amount(&order.Sum), timestamp(&order.UploadedAt))
+}
+
+func scanOrders(rows *sql.Rows) ([]*models.Order, error) {
+	defer rows.Close()
+	orders := make([]*models.Order, 0)
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return orders, nil
+}
+
+// Create returns storage.ErrOrderAlreadyExists if the number is taken.
+func (or *OrdersRepo) Create(ctx context.Context, order *models.Order) (*models.Order, error) {
+	var resOrder *models.Order
+	err := beginFunc(ctx, or.db, func(tx *sql.Tx) error {
+		query := "insert into orders (id, user_id, status, sum, uploaded_at) values ($1, $2, $3, $4, $5) returning " + orderColumns
+		var err error
+		resOrder, err = scanOrder(tx.QueryRowContext(ctx, query, order.ID, order.UserID, order.Status, amountArg(order.Sum), timeArg(order.UploadedAt)))
+		if isUniqueViolation(err) {
+			return storage.ErrOrderAlreadyExists
+		}
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionOrderUploaded, models.AuditEntityOrder, strconv.Itoa(resOrder.ID)).
+			WithChange(nil, orderState(resOrder))
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID, event.OrderID = resOrder.UserID, resOrder.ID
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("beginFunc: %w", err)
+	}
+	return resOrder, nil
+}
+
+func (or *OrdersRepo) GetByID(ctx context.Context, id int) (*models.Order, error) {
+	query := "select " + orderColumns + " from orders where id = $1"
+	order, err := scanOrder(or.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+	return order, nil
+}
+
+func (or *OrdersRepo) Exists(ctx context.Context, id int) (bool, error) {
+	query := "select exists(select 1 from orders where id = $1)"
+	var exists bool
+	err := or.db.QueryRowContext(ctx, query, id).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("row.Scan: %w", err)
+	}
+	return exists, nil
+}
+
+// NumberOwner needs no lock of its own: transactions are immediate, so they
+// already run one at a time.
+func (or *OrdersRepo) NumberOwner(ctx context.Context, id int) (int, error) {
+	query := `select user_id from orders where id = $1
+              union all
+              select user_id from ledger_entries where kind = $2 and order_id = $1
+              limit 1`
+	var owner int
+	err := or.db.QueryRowContext(ctx, query, id, models.EntryKindWithdrawal).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, storage.ErrOrderNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("row.Scan: %w", err)
+	}
+	return owner, nil
+}
+
+// CountByStatus counts orders in each of the statuses. Statuses without
+// orders are missing from the result.
+func (or *OrdersRepo) CountByStatus(ctx context.Context, statuses ...string) (map[string]int64, error) {
+	counts := make(map[string]int64, len(statuses))
+	if len(statuses) == 0 {
+		return counts, nil
+	}
+	w := &whereClause{}
+	query := "select status, count(*) from orders where status in " + w.in(statuses) + " group by status"
+	rows, err := or.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("or.db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var count int64
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		counts[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return counts, nil
+}
+
+func (or *OrdersRepo) GetUsersOrders(ctx context.Context, userID int) ([]*models.Order, error) {
+	query := "select " + orderColumns + " from orders where user_id = $1 order by uploaded_at"
+	rows, err := or.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("or.db.QueryContext: %w", err)
+	}
+	return scanOrders(rows)
+}
+
+// List returns up to filter.Limit of the user's orders matching the filter,
+// oldest first, starting after filter.After.
+func (or *OrdersRepo) List(ctx context.Context, userID int, filter models.ListFilter) ([]*models.Order, error) {
+	w := &whereClause{}
+	w.add("user_id = ?", userID)
+	if len(filter.Statuses) > 0 {
+		w.conds = append(w.conds, "status in "+w.in(filter.Statuses))
+	}
+	if filter.From != nil {
+		w.add("uploaded_at >= ?", timeArg(*filter.From))
+	}
+	if filter.To != nil {
+		w.add("uploaded_at < ?", timeArg(*filter.To))
+	}
+	if filter.MinAmount != nil {
+		w.add("sum >= ?", amountArg(*filter.MinAmount))
+	}
+	if filter.MaxAmount != nil {
+		w.add("sum <= ?", amountArg(*filter.MaxAmount))
+	}
+	if filter.After != nil {
+		w.add("(uploaded_at, id) > (?, ?)", timeArg(filter.After.Time), filter.After.ID)
+	}
+	query := "select " + orderColumns + " from orders" + w.String() + " order by uploaded_at, id limit " + w.arg(filter.Limit)
+
+	rows, err := or.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("or.db.QueryContext: %w", err)
+	}
+	return scanOrders(rows)
+}
+
+// ClaimUnprocessedOrders leases up to limit unprocessed orders to owner for the
+// given duration. The update holds the write lock, so concurrent callers get
+// disjoint batches; an expired lease makes the order claimable again.
+func (or *OrdersRepo) ClaimUnprocessedOrders(ctx context.Context, owner string, limit int, lease time.Duration) ([]int, error) {
+	now := time.Now()
+	query := `update orders set locked_by = $4, locked_until = $6
+              where id in (
+                  select id from orders
+                  where status in ($1, $2) and (locked_until is null or locked_until < $5)
+                  order by uploaded_at
+                  limit $3
+              )
+              returning id`
+	rows, err := or.db.QueryContext(ctx, query, models.OrderStatusNew, models.OrderStatusProcessing, limit, owner,
+		timeArg(now), timeArg(now.Add(lease)))
+	if err != nil {
+		return nil, fmt.Errorf("or.db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return ids, nil
+}
+
+func (or *OrdersRepo) UpdateOrder(ctx context.Context, id int, owner string, status string, sum money.Amount) (*models.Order, error) {
+	return or.change(ctx, id, models.AuditActionOrderStatusChanged, false, func(order *models.Order, lockedBy string) error {
+		if err := checkLease(order, lockedBy, owner); err != nil {
+			return err
+		}
+		order.Status, order.Sum = status, sum
+		return nil
+	})
+}
+
+func (or *OrdersRepo) UpdateOrderStatus(ctx context.Context, id int, owner string, status string) (*models.Order, error) {
+	return or.change(ctx, id, models.AuditActionOrderStatusChanged, false, func(order *models.Order, lockedBy string) error {
+		if err := checkLease(order, lockedBy, owner); err != nil {
+			return err
+		}
+		order.Status = status
+		return nil
+	})
+}
+
+// Requeue puts an order back to NEW and drops its lease so that the next poll
+// asks the accrual system about it again. Processed orders are left alone.
+func (or *OrdersRepo) Requeue(ctx context.Context, id int) (*models.Order, error) {
+	return or.change(ctx, id, models.AuditActionOrderRequeued, true, func(order *models.Order, _ string) error {
+		if order.Status == models.OrderStatusProcessed {
+			return storage.ErrOrderAlreadyProcessed
+		}
+		order.Status = models.OrderStatusNew
+		return nil
+	})
+}
+
+// change applies f to the order and its lease owner, releases its lease and records the
+// transition in the audit log. Unless always is set, updates that change
+// neither the status nor the sum, like repeated PROCESSING polls, aren't audited.
+func (or *OrdersRepo) change(ctx context.Context, id int, action string, always bool, f func(order *models.Order, lockedBy string) error) (*models.Order, error) {
+	var order *models.Order
+	err := beginFunc(ctx, or.db, func(tx *sql.Tx) error {
+		query := "select " + orderColumns + ", coalesce(locked_by, '') from orders where id = $1"
+		order = &models.Order{}
+		var lockedBy string
+		err := tx.QueryRowContext(ctx, query, id).Scan(&order.ID, &order.UserID, &order.Status, amount(&order.Sum),
+			timestamp(&order.UploadedAt), &lockedBy)
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrOrderNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		before := *order
+		if err = f(order, lockedBy); err != nil {
+			return err
+		}
+
+		query = "update orders set status = $1, sum = $2, locked_by = null, locked_until = null where id = $3"
+		if _, err = tx.ExecContext(ctx, query, order.Status, amountArg(order.Sum), order.ID); err != nil {
+			return fmt.Errorf("tx.ExecContext: %w", err)
+		}
+		if before.Status != order.Status {
+			webhook, err := models.NewOrderWebhookEvent(order, before.Status)
+			if err != nil {
+				return fmt.Errorf("models.NewOrderWebhookEvent: %w", err)
+			}
+			if err = enqueueWebhook(ctx, tx, webhook); err != nil {
+				return fmt.Errorf("enqueueWebhook: %w", err)
+			}
+		}
+		if !always && before.Status == order.Status && before.Sum == order.Sum {
+			return nil
+		}
+		event, err := newAuditEvent(ctx, action, models.AuditEntityOrder, strconv.Itoa(order.ID)).
+			WithChange(orderState(&before), orderState(order))
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID, event.OrderID = order.UserID, order.ID
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("beginFunc: %w", err)
+	}
+	return order, nil
+}
+
+// checkLease refuses an update from the accrual system to an order it has
+// settled already or, unless owner is empty, that's leased to someone other
+// than owner, who polled it under a lease that has run out since.
+func checkLease(order *models.Order, lockedBy string, owner string) error {
+	if order.Final() {
+		return storage.ErrOrderFinal
+	}
+	if owner != "" && lockedBy != owner {
+		return storage.ErrOrderLeaseLost
+	}
+	return nil
+}
+
+func orderState(order *models.Order) map[string]interface{} {
+	return map[string]interface{}{"status": order.Status, "sum": order.Sum}
+}
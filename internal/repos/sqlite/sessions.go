@@ -0,0 +1,138 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"strconv"
+	"time"
+)
+
+type SessionRepo struct {
+	db *sql.DB
+}
+
+func NewSessionRepo(db *sql.DB) *SessionRepo {
+	return &SessionRepo{db: db}
+}
+
+const sessionColumns = "id, user_id, refresh_token_hash, created_at, expires_at, revoked_at"
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	session := &models.Session{}
+	err := row.Scan(&session.ID, &session.UserID, &session.RefreshTokenHash, timestamp(&session.CreatedAt),
+		timestamp(&session.ExpiresAt), nullTimestamp(&session.RevokedAt))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+	return session, nil
+}
+
+// Create starts a session, which is what a successful login or registration
+// amounts to, so it's audited as the user's own action.
+func (sr *SessionRepo) Create(ctx context.Context, session *models.Session) (*models.Session, error) {
+	var created *models.Session
+	err := beginFunc(ctx, sr.db, func(tx *sql.Tx) error {
+		query := "insert into sessions (id, user_id, refresh_token_hash, created_at, expires_at) values ($1, $2, $3, $4, $5) returning " + sessionColumns
+		row := tx.QueryRowContext(ctx, query, session.ID, session.UserID, session.RefreshTokenHash,
+			timeArg(session.CreatedAt), timeArg(session.ExpiresAt))
+		var err error
+		created, err = scanSession(row)
+		if err != nil {
+			return fmt.Errorf("scanSession: %w", err)
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionSessionCreated, models.AuditEntitySession, created.ID).
+			WithChange(nil, map[string]interface{}{"expires_at": created.ExpiresAt})
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID = created.UserID
+		if event.ActorID == 0 {
+			event.ActorID = created.UserID
+		}
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("beginFunc: %w", err)
+	}
+	return created, nil
+}
+
+func (sr *SessionRepo) GetByID(ctx context.Context, id string) (*models.Session, error) {
+	query := "select " + sessionColumns + " from sessions where id = $1"
+	return scanSession(sr.db.QueryRowContext(ctx, query, id))
+}
+
+// Rotate replaces the refresh token hash of an active session. It returns
+// storage.ErrSessionNotFound if the session is unknown, revoked, expired or
+// oldHash doesn't match, so a refresh token can only be used once.
+func (sr *SessionRepo) Rotate(ctx context.Context, id string, oldHash string, newHash string, expiresAt time.Time) (*models.Session, error) {
+	query := `update sessions set refresh_token_hash = $3, expires_at = $4
+              where id = $1 and refresh_token_hash = $2 and revoked_at is null and expires_at > $5
+              returning ` + sessionColumns
+	row := sr.db.QueryRowContext(ctx, query, id, oldHash, newHash, timeArg(expiresAt), timeArg(time.Now()))
+	return scanSession(row)
+}
+
+func (sr *SessionRepo) Revoke(ctx context.Context, id string) error {
+	err := beginFunc(ctx, sr.db, func(tx *sql.Tx) error {
+		query := "update sessions set revoked_at = $2 where id = $1 and revoked_at is null returning user_id"
+		var userID int
+		err := tx.QueryRowContext(ctx, query, id, timeArg(time.Now())).Scan(&userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		event := newAuditEvent(ctx, models.AuditActionSessionRevoked, models.AuditEntitySession, id)
+		event.UserID = userID
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return fmt.Errorf("beginFunc: %w", err)
+	}
+	return nil
+}
+
+func (sr *SessionRepo) RevokeAllForUser(ctx context.Context, userID int) ([]string, error) {
+	var ids []string
+	err := beginFunc(ctx, sr.db, func(tx *sql.Tx) error {
+		query := "update sessions set revoked_at = $2 where user_id = $1 and revoked_at is null returning id"
+		rows, err := tx.QueryContext(ctx, query, userID, timeArg(time.Now()))
+		if err != nil {
+			return fmt.Errorf("tx.QueryContext: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return fmt.Errorf("rows.Scan: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("rows.Err: %w", err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionSessionsRevoked, models.AuditEntityUser, strconv.Itoa(userID)).
+			WithChange(nil, map[string]interface{}{"sessions": ids})
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID = userID
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("beginFunc: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,146 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"strconv"
+	"time"
+)
+
+type TierRepo struct {
+	db dbtx
+}
+
+func NewTierRepo(db *sql.DB) *TierRepo {
+	return &TierRepo{db: db}
+}
+
+const (
+	tierRuleColumns   = "tier, min_accrued, multiplier, updated_at"
+	tierChangeColumns = "id, user_id, tier, accrued, changed_at"
+)
+
+// Multipliers are stored in hundredths, like amounts.
+
+func scanTierRule(row rowScanner) (*models.TierRule, error) {
+	r := &models.TierRule{}
+	err := row.Scan(&r.Tier, amount(&r.MinAccrued), amount(&r.Multiplier), timestamp(&r.UpdatedAt))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrTierNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+	return r, nil
+}
+
+// ListRules returns the tier rules sorted by threshold.
+func (tr *TierRepo) ListRules(ctx context.Context) ([]*models.TierRule, error) {
+	rows, err := tr.db.QueryContext(ctx, "select "+tierRuleColumns+" from tier_rules order by min_accrued")
+	if err != nil {
+		return nil, fmt.Errorf("tr.db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	var rules []*models.TierRule
+	for rows.Next() {
+		r, err := scanTierRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanTierRule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return rules, nil
+}
+
+// UpdateRule changes the threshold and multiplier of the rule's tier.
+func (tr *TierRepo) UpdateRule(ctx context.Context, rule *models.TierRule) (*models.TierRule, error) {
+	var updated *models.TierRule
+	err := beginFunc(ctx, tr.db, func(tx *sql.Tx) error {
+		before, err := scanTierRule(tx.QueryRowContext(ctx, "select "+tierRuleColumns+" from tier_rules where tier = $1", rule.Tier))
+		if err != nil {
+			return err
+		}
+		query := "update tier_rules set min_accrued = $2, multiplier = $3, updated_at = $4 where tier = $1 returning " + tierRuleColumns
+		updated, err = scanTierRule(tx.QueryRowContext(ctx, query, rule.Tier, amountArg(rule.MinAccrued), amountArg(rule.Multiplier), timeArg(time.Now())))
+		if err != nil {
+			return err
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionTierRuleUpdated, models.AuditEntityTier, rule.Tier).
+			WithChange(tierRuleState(before), tierRuleState(updated))
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("beginFunc: %w", err)
+	}
+	return updated, nil
+}
+
+// tierRuleState is what the audit log records of a tier rule.
+func tierRuleState(rule *models.TierRule) map[string]interface{} {
+	return map[string]interface{}{"min_accrued": rule.MinAccrued, "multiplier": rule.Multiplier}
+}
+
+// GetHistory returns the user's tier changes, newest first.
+func (tr *TierRepo) GetHistory(ctx context.Context, userID int) ([]*models.TierChange, error) {
+	query := "select " + tierChangeColumns + " from tier_changes where user_id = $1 order by id desc"
+	rows, err := tr.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("tr.db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	history := make([]*models.TierChange, 0)
+	for rows.Next() {
+		c := &models.TierChange{}
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Tier, amount(&c.Accrued), timestamp(&c.ChangedAt)); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		history = append(history, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return history, nil
+}
+
+// RecordChange adds the change to the user's tier history unless they are in
+// its tier already, and reports whether it did.
+func (tr *TierRepo) RecordChange(ctx context.Context, change *models.TierChange) (bool, error) {
+	recorded := false
+	err := beginFunc(ctx, tr.db, func(tx *sql.Tx) error {
+		var current string
+		err := tx.QueryRowContext(ctx, "select tier from tier_changes where user_id = $1 order by id desc limit 1", change.UserID).Scan(&current)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		if current == change.Tier {
+			return nil
+		}
+		query := "insert into tier_changes (user_id, tier, accrued, changed_at) values ($1, $2, $3, $4) returning id"
+		err = tx.QueryRowContext(ctx, query, change.UserID, change.Tier, amountArg(change.Accrued), timeArg(change.ChangedAt)).Scan(&change.ID)
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionTierChanged, models.AuditEntityUser, strconv.Itoa(change.UserID)).
+			WithChange(map[string]interface{}{"tier": current}, map[string]interface{}{"tier": change.Tier, "accrued": change.Accrued})
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID = change.UserID
+		recorded = true
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return false, fmt.Errorf("beginFunc: %w", err)
+	}
+	return recorded, nil
+}
@@ -0,0 +1,28 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+)
+
+// Tx runs units of work over the user, order, ledger and tier repos in a
+// single SQLite transaction.
+type Tx struct {
+	db *sql.DB
+}
+
+func NewTx(db *sql.DB) *Tx {
+	return &Tx{db: db}
+}
+
+func (t *Tx) WithinTx(ctx context.Context, f func(repos *storage.Repos) error) error {
+	return beginFunc(ctx, t.db, func(tx *sql.Tx) error {
+		return f(&storage.Repos{
+			Users:  &UserRepo{db: tx},
+			Orders: &OrdersRepo{db: tx},
+			Ledger: &LedgerRepo{db: tx},
+			Tiers:  &TierRepo{db: tx},
+		})
+	})
+}
@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"strconv"
+	"strings"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+type UserRepo struct {
+	db dbtx
+}
+
+func NewUserRepo(db *sql.DB) *UserRepo {
+	return &UserRepo{db: db}
+}
+
+const userColumns = "id, login, encrypted_password, roles"
+
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	return user, row.Scan(&user.ID, &user.Login, &user.EncryptedPwd, list(&user.Roles))
+}
+
+// Create returns storage.ErrUserAlreadyExists if the login is taken.
+func (ur *UserRepo) Create(ctx context.Context, user *models.User) (*models.User, error) {
+	roles := user.Roles
+	if len(roles) == 0 {
+		roles = []string{models.RoleCustomer}
+	}
+	rolesArg, err := listArg(roles)
+	if err != nil {
+		return nil, fmt.Errorf("listArg: %w", err)
+	}
+	var resUser *models.User
+	err = beginFunc(ctx, ur.db, func(tx *sql.Tx) error {
+		query := "insert into users (login, encrypted_password, roles) values ($1, $2, $3) returning " + userColumns
+		var err error
+		resUser, err = scanUser(tx.QueryRowContext(ctx, query, user.Login, user.EncryptedPwd, rolesArg))
+		if isUniqueViolation(err) {
+			return storage.ErrUserAlreadyExists
+		}
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionUserRegistered, models.AuditEntityUser, strconv.Itoa(resUser.ID)).
+			WithChange(nil, map[string]interface{}{"login": resUser.Login, "roles": resUser.Roles})
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID = resUser.ID
+		if event.ActorID == 0 {
+			event.ActorID = resUser.ID
+		}
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("beginFunc: %w", err)
+	}
+	return resUser, nil
+}
+
+func (ur *UserRepo) GetByLogin(ctx context.Context, login string) (*models.User, error) {
+	query := "select " + userColumns + " from users where login = $1"
+	user, err := scanUser(ur.db.QueryRowContext(ctx, query, login))
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+	return user, nil
+}
+
+func (ur *UserRepo) GetByID(ctx context.Context, id int) (*models.User, error) {
+	query := "select " + userColumns + " from users where id = $1"
+	user, err := scanUser(ur.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+	return user, nil
+}
+
+func (ur *UserRepo) Exists(ctx context.Context, login string) (bool, error) {
+	query := "select exists(select 1 from users where login = $1)"
+	var exists bool
+	err := ur.db.QueryRowContext(ctx, query, login).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("row.Scan: %w", err)
+	}
+	return exists, nil
+}
+
+// Search returns up to limit users whose login contains the given substring,
+// case-insensitively. Like PostgreSQL's ilike, SQLite's like only folds the
+// case of ASCII letters.
+func (ur *UserRepo) Search(ctx context.Context, login string, limit int) ([]*models.User, error) {
+	query := "select " + userColumns + ` from users
+              where login like '%' || $1 || '%' escape '\' order by id limit $2`
+	users := make([]*models.User, 0)
+	rows, err := ur.db.QueryContext(ctx, query, likeEscaper.Replace(login), limit)
+	if err != nil {
+		return nil, fmt.Errorf("ur.db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return users, nil
+}
+
+// AddRole grants the role to the user. Granting a role the user already has
+// is a no-op. It reports whether the user exists.
+func (ur *UserRepo) AddRole(ctx context.Context, login string, role string) (bool, error) {
+	found := false
+	err := beginFunc(ctx, ur.db, func(tx *sql.Tx) error {
+		var id int
+		var roles []string
+		row := tx.QueryRowContext(ctx, "select id, roles from users where login = $1", login)
+		err := row.Scan(&id, list(&roles))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		found = true
+		for _, r := range roles {
+			if r == role {
+				return nil
+			}
+		}
+
+		newRoles := append(append([]string{}, roles...), role)
+		rolesArg, err := listArg(newRoles)
+		if err != nil {
+			return fmt.Errorf("listArg: %w", err)
+		}
+		if _, err = tx.ExecContext(ctx, "update users set roles = $2 where id = $1", id, rolesArg); err != nil {
+			return fmt.Errorf("tx.ExecContext: %w", err)
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionRoleGranted, models.AuditEntityUser, strconv.Itoa(id)).
+			WithChange(map[string]interface{}{"roles": roles}, map[string]interface{}{"roles": newRoles})
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID = id
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return false, fmt.Errorf("beginFunc: %w", err)
+	}
+	return found, nil
+}
@@ -0,0 +1,278 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"strconv"
+	"time"
+)
+
+type WebhookRepo struct {
+	db *sql.DB
+}
+
+func NewWebhookRepo(db *sql.DB) *WebhookRepo {
+	return &WebhookRepo{db: db}
+}
+
+const (
+	webhookEndpointColumns = "id, user_id, url, secret, events, description, created_at"
+	webhookDeliveryColumns = "d.id, d.endpoint_id, d.event_id, d.event_type, d.payload, d.state, d.attempts, d.next_attempt_at, " +
+		"coalesce(d.last_status, 0), d.last_error, d.created_at, d.delivered_at"
+)
+
+func scanWebhookEndpoint(row rowScanner) (*models.WebhookEndpoint, error) {
+	e := &models.WebhookEndpoint{}
+	err := row.Scan(&e.ID, &e.UserID, &e.URL, &e.Secret, list(&e.Events), &e.Description, timestamp(&e.CreatedAt))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrWebhookEndpointNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+	return e, nil
+}
+
+func scanWebhookDelivery(row rowScanner, extra ...interface{}) (*models.WebhookDelivery, error) {
+	d := &models.WebhookDelivery{}
+	var payload string
+	dest := append([]interface{}{&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &payload, &d.State, &d.Attempts,
+		timestamp(&d.NextAttemptAt), &d.LastStatus, &d.LastError, timestamp(&d.CreatedAt), nullTimestamp(&d.DeliveredAt)}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	d.Payload = json.RawMessage(payload)
+	return d, nil
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]*models.WebhookDelivery, error) {
+	defer rows.Close()
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return deliveries, nil
+}
+
+// enqueueWebhook queues the event for every endpoint subscribed to it in the
+// caller's transaction, so an event is queued if and only if the change that
+// caused it is committed.
+func enqueueWebhook(ctx context.Context, tx *sql.Tx, event *models.WebhookEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	query := `insert into webhook_deliveries (endpoint_id, event_id, event_type, payload, state, next_attempt_at, created_at)
+              select id, $1, $2, $3, $4, $5, $5 from webhook_endpoints
+              where (user_id = $6 or user_id is null) and exists (select 1 from json_each(events) where value = $2)`
+	_, err = tx.ExecContext(ctx, query, event.ID, event.Type, string(payload), models.DeliveryPending, timeArg(time.Now()), event.UserID)
+	if err != nil {
+		return fmt.Errorf("tx.ExecContext: %w", err)
+	}
+	return nil
+}
+
+func (wr *WebhookRepo) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) (*models.WebhookEndpoint, error) {
+	events, err := listArg(endpoint.Events)
+	if err != nil {
+		return nil, fmt.Errorf("listArg: %w", err)
+	}
+	var created *models.WebhookEndpoint
+	err = beginFunc(ctx, wr.db, func(tx *sql.Tx) error {
+		query := "insert into webhook_endpoints (user_id, url, secret, events, description, created_at) values ($1, $2, $3, $4, $5, $6) returning " + webhookEndpointColumns
+		row := tx.QueryRowContext(ctx, query, endpoint.UserID, endpoint.URL, endpoint.Secret, events, endpoint.Description, timeArg(endpoint.CreatedAt))
+		var err error
+		created, err = scanWebhookEndpoint(row)
+		if err != nil {
+			return fmt.Errorf("scanWebhookEndpoint: %w", err)
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionWebhookCreated, models.AuditEntityWebhook, strconv.Itoa(created.ID)).
+			WithChange(nil, webhookEndpointState(created))
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		if created.UserID != nil {
+			event.UserID = *created.UserID
+		}
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("beginFunc: %w", err)
+	}
+	return created, nil
+}
+
+func (wr *WebhookRepo) GetEndpoint(ctx context.Context, id int) (*models.WebhookEndpoint, error) {
+	query := "select " + webhookEndpointColumns + " from webhook_endpoints where id = $1"
+	return scanWebhookEndpoint(wr.db.QueryRowContext(ctx, query, id))
+}
+
+// ListEndpoints returns the user's endpoints, or the partner endpoints if
+// userID is zero.
+func (wr *WebhookRepo) ListEndpoints(ctx context.Context, userID int) ([]*models.WebhookEndpoint, error) {
+	query := "select " + webhookEndpointColumns + " from webhook_endpoints where user_id is $1 order by id"
+	var owner *int
+	if userID != 0 {
+		owner = &userID
+	}
+	rows, err := wr.db.QueryContext(ctx, query, owner)
+	if err != nil {
+		return nil, fmt.Errorf("wr.db.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	var endpoints []*models.WebhookEndpoint
+	for rows.Next() {
+		e, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanWebhookEndpoint: %w", err)
+		}
+		endpoints = append(endpoints, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return endpoints, nil
+}
+
+// DeleteEndpoint removes the endpoint together with its deliveries.
+func (wr *WebhookRepo) DeleteEndpoint(ctx context.Context, id int) error {
+	err := beginFunc(ctx, wr.db, func(tx *sql.Tx) error {
+		query := "delete from webhook_endpoints where id = $1 returning " + webhookEndpointColumns
+		deleted, err := scanWebhookEndpoint(tx.QueryRowContext(ctx, query, id))
+		if err != nil {
+			return err
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionWebhookDeleted, models.AuditEntityWebhook, strconv.Itoa(id)).
+			WithChange(webhookEndpointState(deleted), nil)
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		if deleted.UserID != nil {
+			event.UserID = *deleted.UserID
+		}
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return fmt.Errorf("beginFunc: %w", err)
+	}
+	return nil
+}
+
+// ClaimDeliveries leases up to limit due deliveries to owner, like
+// OrdersRepo.ClaimUnprocessedOrders, and fills in their endpoint's URL and secret.
+func (wr *WebhookRepo) ClaimDeliveries(ctx context.Context, owner string, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	now := time.Now()
+	var deliveries []*models.WebhookDelivery
+	err := beginFunc(ctx, wr.db, func(tx *sql.Tx) error {
+		query := "select " + webhookDeliveryColumns + `, e.url, e.secret
+                  from webhook_deliveries d join webhook_endpoints e on e.id = d.endpoint_id
+                  where d.state = $1 and d.next_attempt_at <= $2 and (d.locked_until is null or d.locked_until < $2)
+                  order by d.next_attempt_at, d.id
+                  limit $3`
+		rows, err := tx.QueryContext(ctx, query, models.DeliveryPending, timeArg(now), limit)
+		if err != nil {
+			return fmt.Errorf("tx.QueryContext: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var url, secret string
+			d, err := scanWebhookDelivery(rows, &url, &secret)
+			if err != nil {
+				return fmt.Errorf("rows.Scan: %w", err)
+			}
+			d.URL, d.Secret = url, secret
+			deliveries = append(deliveries, d)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("rows.Err: %w", err)
+		}
+
+		query = "update webhook_deliveries set locked_by = $2, locked_until = $3 where id = $1"
+		for _, d := range deliveries {
+			if _, err := tx.ExecContext(ctx, query, d.ID, owner, timeArg(now.Add(lease))); err != nil {
+				return fmt.Errorf("tx.ExecContext: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("beginFunc: %w", err)
+	}
+	return deliveries, nil
+}
+
+// UpdateDelivery records the outcome of an attempt and releases the lease.
+func (wr *WebhookRepo) UpdateDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	query := `update webhook_deliveries
+              set state = $2, attempts = $3, next_attempt_at = $4, last_status = nullif($5, 0), last_error = $6,
+                  delivered_at = $7, locked_by = null, locked_until = null
+              where id = $1`
+	_, err := wr.db.ExecContext(ctx, query, d.ID, d.State, d.Attempts, timeArg(d.NextAttemptAt), d.LastStatus, d.LastError,
+		nullTimeArg(d.DeliveredAt))
+	if err != nil {
+		return fmt.Errorf("wr.db.ExecContext: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns deliveries newest first.
+func (wr *WebhookRepo) ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error) {
+	where := &whereClause{}
+	if filter.EndpointID != 0 {
+		where.add("d.endpoint_id = ?", filter.EndpointID)
+	}
+	if filter.State != "" {
+		where.add("d.state = ?", filter.State)
+	}
+	if filter.BeforeID != 0 {
+		where.add("d.id < ?", filter.BeforeID)
+	}
+	query := "select " + webhookDeliveryColumns + " from webhook_deliveries d" + where.String() +
+		" order by d.id desc limit " + where.arg(filter.Limit)
+	rows, err := wr.db.QueryContext(ctx, query, where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("wr.db.QueryContext: %w", err)
+	}
+	return scanWebhookDeliveries(rows)
+}
+
+// ReplayDeadDeliveries puts dead-lettered deliveries back in the queue with a
+// fresh attempt budget. Zero endpointID or deliveryID don't filter. It returns
+// the number of deliveries requeued.
+func (wr *WebhookRepo) ReplayDeadDeliveries(ctx context.Context, endpointID int, deliveryID int64) (int64, error) {
+	where := &whereClause{}
+	where.add("state = ?", models.DeliveryDead)
+	if endpointID != 0 {
+		where.add("endpoint_id = ?", endpointID)
+	}
+	if deliveryID != 0 {
+		where.add("id = ?", deliveryID)
+	}
+	query := "update webhook_deliveries set state = " + where.arg(models.DeliveryPending) +
+		", attempts = 0, next_attempt_at = " + where.arg(timeArg(time.Now())) + where.String()
+	res, err := wr.db.ExecContext(ctx, query, where.args...)
+	if err != nil {
+		return 0, fmt.Errorf("wr.db.ExecContext: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("res.RowsAffected: %w", err)
+	}
+	return n, nil
+}
+
+func webhookEndpointState(e *models.WebhookEndpoint) map[string]interface{} {
+	return map[string]interface{}{"url": e.URL, "events": e.Events}
+}
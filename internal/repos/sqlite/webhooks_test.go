@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"testing"
+	"time"
+)
+
+func TestWebhookRepo_Deliveries(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(t)
+	ur := NewUserRepo(db)
+	lr := NewLedgerRepo(db)
+	wr := NewWebhookRepo(db)
+
+	user, err := ur.Create(ctx, &models.User{Login: "webhooks-test", EncryptedPwd: "encryptedPwd"})
+	require.NoError(t, err)
+	other, err := ur.Create(ctx, &models.User{Login: "webhooks-test-other", EncryptedPwd: "encryptedPwd"})
+	require.NoError(t, err)
+	endpoint, err := wr.CreateEndpoint(ctx, &models.WebhookEndpoint{
+		UserID: &user.ID,
+		URL:    "https://shop.example.com/hooks",
+		Secret: "whsec_user",
+		Events: []string{models.WebhookEventBalanceWithdrawn},
+	})
+	require.NoError(t, err)
+	partner, err := wr.CreateEndpoint(ctx, &models.WebhookEndpoint{
+		URL:    "https://partner.example.com/hooks",
+		Secret: "whsec_partner",
+		Events: []string{models.WebhookEventBalanceWithdrawn},
+	})
+	require.NoError(t, err)
+	_, err = wr.CreateEndpoint(ctx, &models.WebhookEndpoint{
+		UserID: &other.ID,
+		URL:    "https://other.example.com/hooks",
+		Secret: "whsec_other",
+		Events: []string{models.WebhookEventBalanceWithdrawn},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, lr.Post(ctx, models.NewAccrualEntry(user.ID, 1, money.FromInt(100))))
+	require.NoError(t, lr.Withdraw(ctx, &models.Withdrawal{OrderID: 2, UserID: user.ID, Sum: money.FromInt(10), ProcessedAt: time.Now()}))
+
+	claimed, err := wr.ClaimDeliveries(ctx, "worker", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2, "the user's and the partner's endpoints get the event")
+	assert.Equal(t, endpoint.ID, claimed[0].EndpointID)
+	assert.Equal(t, endpoint.Secret, claimed[0].Secret)
+	assert.Equal(t, partner.ID, claimed[1].EndpointID)
+	assert.Equal(t, partner.URL, claimed[1].URL)
+	assert.Equal(t, claimed[0].EventID, claimed[1].EventID)
+
+	// A leased delivery isn't claimed twice.
+	again, err := wr.ClaimDeliveries(ctx, "other-worker", 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, again)
+
+	delivery := claimed[0]
+	delivery.State = models.DeliveryDead
+	delivery.Attempts = 8
+	delivery.LastStatus = 500
+	delivery.LastError = "unexpected status 500"
+	require.NoError(t, wr.UpdateDelivery(ctx, delivery))
+
+	dead, err := wr.ListDeliveries(ctx, models.WebhookDeliveryFilter{EndpointID: endpoint.ID, State: models.DeliveryDead, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, 500, dead[0].LastStatus)
+
+	replayed, err := wr.ReplayDeadDeliveries(ctx, endpoint.ID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), replayed)
+	pending, err := wr.ClaimDeliveries(ctx, "worker", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 0, pending[0].Attempts)
+
+	require.NoError(t, wr.DeleteEndpoint(ctx, endpoint.ID))
+	all, err := wr.ListDeliveries(ctx, models.WebhookDeliveryFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, partner.ID, all[0].EndpointID)
+	_, err = wr.GetEndpoint(ctx, endpoint.ID)
+	assert.Error(t, err)
+}
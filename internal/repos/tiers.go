@@ -0,0 +1,138 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"strconv"
+	"time"
+)
+
+type TierRepo struct {
+	db dbtx
+}
+
+func NewTierRepo(pool *pgxpool.Pool) *TierRepo {
+	return &TierRepo{db: pool}
+}
+
+const (
+	tierRuleColumns   = "tier, min_accrued, multiplier, updated_at"
+	tierChangeColumns = "id, user_id, tier, accrued, changed_at"
+)
+
+func scanTierRule(row pgx.Row) (*models.TierRule, error) {
+	r := &models.TierRule{}
+	err := row.Scan(&r.Tier, &r.MinAccrued, &r.Multiplier, &r.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrTierNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+	return r, nil
+}
+
+// ListRules returns the tier rules sorted by threshold.
+func (tr *TierRepo) ListRules(ctx context.Context) ([]*models.TierRule, error) {
+	rows, err := tr.db.Query(ctx, "select "+tierRuleColumns+" from tier_rules order by min_accrued")
+	if err != nil {
+		return nil, fmt.Errorf("tr.db.Query: %w", err)
+	}
+	rules, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.TierRule, error) {
+		return scanTierRule(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows: %w", err)
+	}
+	return rules, nil
+}
+
+// UpdateRule changes the threshold and multiplier of the rule's tier.
+func (tr *TierRepo) UpdateRule(ctx context.Context, rule *models.TierRule) (*models.TierRule, error) {
+	var updated *models.TierRule
+	err := pgx.BeginFunc(ctx, tr.db, func(tx pgx.Tx) error {
+		before, err := scanTierRule(tx.QueryRow(ctx, "select "+tierRuleColumns+" from tier_rules where tier = $1 for update", rule.Tier))
+		if err != nil {
+			return err
+		}
+		query := "update tier_rules set min_accrued = $2, multiplier = $3, updated_at = $4 where tier = $1 returning " + tierRuleColumns
+		updated, err = scanTierRule(tx.QueryRow(ctx, query, rule.Tier, rule.MinAccrued, rule.Multiplier, time.Now()))
+		if err != nil {
+			return err
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionTierRuleUpdated, models.AuditEntityTier, rule.Tier).
+			WithChange(tierRuleState(before), tierRuleState(updated))
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.BeginFunc: %w", err)
+	}
+	return updated, nil
+}
+
+// tierRuleState is what the audit log records of a tier rule.
+func tierRuleState(rule *models.TierRule) map[string]interface{} {
+	return map[string]interface{}{"min_accrued": rule.MinAccrued, "multiplier": rule.Multiplier}
+}
+
+// GetHistory returns the user's tier changes, newest first.
+func (tr *TierRepo) GetHistory(ctx context.Context, userID int) ([]*models.TierChange, error) {
+	query := "select " + tierChangeColumns + " from tier_changes where user_id = $1 order by id desc"
+	rows, err := tr.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("tr.db.Query: %w", err)
+	}
+	history, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.TierChange, error) {
+		c := &models.TierChange{}
+		return c, row.Scan(&c.ID, &c.UserID, &c.Tier, &c.Accrued, &c.ChangedAt)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows: %w", err)
+	}
+	return history, nil
+}
+
+// RecordChange adds the change to the user's tier history unless they are in
+// its tier already, and reports whether it did. The user's lock is taken first
+// so concurrent evaluations don't record the same change twice.
+func (tr *TierRepo) RecordChange(ctx context.Context, change *models.TierChange) (bool, error) {
+	recorded := false
+	err := pgx.BeginFunc(ctx, tr.db, func(tx pgx.Tx) error {
+		if err := lockUser(ctx, tx, change.UserID); err != nil {
+			return fmt.Errorf("lockUser: %w", err)
+		}
+		var current string
+		err := tx.QueryRow(ctx, "select tier from tier_changes where user_id = $1 order by id desc limit 1", change.UserID).Scan(&current)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		if current == change.Tier {
+			return nil
+		}
+		query := "insert into tier_changes (user_id, tier, accrued, changed_at) values ($1, $2, $3, $4) returning id"
+		err = tx.QueryRow(ctx, query, change.UserID, change.Tier, change.Accrued, change.ChangedAt).Scan(&change.ID)
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionTierChanged, models.AuditEntityUser, strconv.Itoa(change.UserID)).
+			WithChange(map[string]interface{}{"tier": current}, map[string]interface{}{"tier": change.Tier, "accrued": change.Accrued})
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID = change.UserID
+		recorded = true
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return false, fmt.Errorf("pgx.BeginFunc: %w", err)
+	}
+	return recorded, nil
+}
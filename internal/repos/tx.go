@@ -0,0 +1,49 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+)
+
+// uniqueViolation is the SQLSTATE of an insert that breaks a unique constraint.
+const uniqueViolation = "23505"
+
+// dbtx is what the repos run queries on: the pool, or a transaction when they
+// take part in a unit of work. pgx.BeginFunc on a transaction starts a
+// savepoint, so the repos' own transactions nest in the unit of work.
+type dbtx interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// Tx runs units of work over the user, order, ledger and tier repos in a
+// single PostgreSQL transaction.
+type Tx struct {
+	pool *pgxpool.Pool
+}
+
+func NewTx(pool *pgxpool.Pool) *Tx {
+	return &Tx{pool: pool}
+}
+
+func (t *Tx) WithinTx(ctx context.Context, f func(repos *storage.Repos) error) error {
+	return pgx.BeginFunc(ctx, t.pool, func(tx pgx.Tx) error {
+		return f(&storage.Repos{
+			Users:  &UserRepo{db: tx},
+			Orders: &OrdersRepo{db: tx},
+			Ledger: &LedgerRepo{db: tx},
+			Tiers:  &TierRepo{db: tx},
+		})
+	})
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
@@ -2,37 +2,65 @@ package repos
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"strconv"
+	"strings"
 )
 
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 type UserRepo struct {
-	pool *pgxpool.Pool
+	db dbtx
 }
 
 func NewUserRepo(pool *pgxpool.Pool) *UserRepo {
-	return &UserRepo{pool: pool}
+	return &UserRepo{db: pool}
 }
 
+// Create returns storage.ErrUserAlreadyExists if the login is taken.
 func (ur *UserRepo) Create(ctx context.Context, user *models.User) (*models.User, error) {
-	query := "insert into users (login, encryptedPassword) values ($1, $2)"
-	_, err := ur.pool.Exec(ctx, query, user.Login, user.EncryptedPwd)
-	if err != nil {
-		return nil, fmt.Errorf("ur.pool.Exec: %w", err)
+	roles := user.Roles
+	if len(roles) == 0 {
+		roles = []string{models.RoleCustomer}
 	}
-	resUser, err := ur.GetByLogin(ctx, user.Login)
+	resUser := &models.User{}
+	err := pgx.BeginFunc(ctx, ur.db, func(tx pgx.Tx) error {
+		query := "insert into users (login, encryptedPassword, roles) values ($1, $2, $3) returning id, login, encryptedPassword, roles"
+		row := tx.QueryRow(ctx, query, user.Login, user.EncryptedPwd, roles)
+		err := row.Scan(&resUser.ID, &resUser.Login, &resUser.EncryptedPwd, &resUser.Roles)
+		if isUniqueViolation(err) {
+			return storage.ErrUserAlreadyExists
+		}
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionUserRegistered, models.AuditEntityUser, strconv.Itoa(resUser.ID)).
+			WithChange(nil, map[string]interface{}{"login": resUser.Login, "roles": resUser.Roles})
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID = resUser.ID
+		if event.ActorID == 0 {
+			event.ActorID = resUser.ID
+		}
+		return insertAuditEvent(ctx, tx, event)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("ur.GetUserByLogin: %w", err)
+		return nil, fmt.Errorf("pgx.BeginFunc: %w", err)
 	}
 	return resUser, nil
 }
 
 func (ur *UserRepo) GetByLogin(ctx context.Context, login string) (*models.User, error) {
-	query := "select id, login, encryptedPassword from users where login = $1"
-	row := ur.pool.QueryRow(ctx, query, login)
+	query := "select id, login, encryptedPassword, roles from users where login = $1"
+	row := ur.db.QueryRow(ctx, query, login)
 	user := &models.User{}
-	err := row.Scan(&user.ID, &user.Login, &user.EncryptedPwd)
+	err := row.Scan(&user.ID, &user.Login, &user.EncryptedPwd, &user.Roles)
 	if err != nil {
 		return nil, fmt.Errorf("row.Scan: %w", err)
 	}
@@ -40,10 +68,13 @@ func (ur *UserRepo) GetByLogin(ctx context.Context, login string) (*models.User,
 }
 
 func (ur *UserRepo) GetByID(ctx context.Context, id int) (*models.User, error) {
-	query := "select id, login, encryptedPassword from users where id = $1"
-	row := ur.pool.QueryRow(ctx, query, id)
+	query := "select id, login, encryptedPassword, roles from users where id = $1"
+	row := ur.db.QueryRow(ctx, query, id)
 	user := &models.User{}
-	err := row.Scan(&user.ID, &user.Login, &user.EncryptedPwd)
+	err := row.Scan(&user.ID, &user.Login, &user.EncryptedPwd, &user.Roles)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrUserNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("row.Scan: %w", err)
 	}
@@ -52,7 +83,7 @@ func (ur *UserRepo) GetByID(ctx context.Context, id int) (*models.User, error) {
 
 func (ur *UserRepo) Exists(ctx context.Context, login string) (bool, error) {
 	query := "select exists(select 1 from users where login = $1)"
-	row := ur.pool.QueryRow(ctx, query, login)
+	row := ur.db.QueryRow(ctx, query, login)
 	var exists bool
 	err := row.Scan(&exists)
 	if err != nil {
@@ -60,3 +91,68 @@ func (ur *UserRepo) Exists(ctx context.Context, login string) (bool, error) {
 	}
 	return exists, nil
 }
+
+// Search returns up to limit users whose login contains the given substring,
+// case-insensitively.
+func (ur *UserRepo) Search(ctx context.Context, login string, limit int) ([]*models.User, error) {
+	query := `select id, login, encryptedPassword, roles from users
+              where login ilike '%' || $1 || '%' order by id limit $2`
+	users := make([]*models.User, 0)
+	rows, err := ur.db.Query(ctx, query, likeEscaper.Replace(login), limit)
+	if err != nil {
+		return nil, fmt.Errorf("ur.db.Query: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		user := &models.User{}
+		err := rows.Scan(&user.ID, &user.Login, &user.EncryptedPwd, &user.Roles)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: %w", err)
+	}
+	return users, nil
+}
+
+// AddRole grants the role to the user. Granting a role the user already has
+// is a no-op. It reports whether the user exists.
+func (ur *UserRepo) AddRole(ctx context.Context, login string, role string) (bool, error) {
+	found := false
+	err := pgx.BeginFunc(ctx, ur.db, func(tx pgx.Tx) error {
+		var id int
+		var roles []string
+		row := tx.QueryRow(ctx, "select id, roles from users where login = $1 for update", login)
+		err := row.Scan(&id, &roles)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("row.Scan: %w", err)
+		}
+		found = true
+		for _, r := range roles {
+			if r == role {
+				return nil
+			}
+		}
+
+		newRoles := append(append([]string{}, roles...), role)
+		if _, err = tx.Exec(ctx, "update users set roles = $2 where id = $1", id, newRoles); err != nil {
+			return fmt.Errorf("tx.Exec: %w", err)
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionRoleGranted, models.AuditEntityUser, strconv.Itoa(id)).
+			WithChange(map[string]interface{}{"roles": roles}, map[string]interface{}{"roles": newRoles})
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		event.UserID = id
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return false, fmt.Errorf("pgx.BeginFunc: %w", err)
+	}
+	return found, nil
+}
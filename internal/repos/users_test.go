@@ -0,0 +1,52 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"testing"
+	"time"
+)
+
+func TestUserRepo_Roles(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	ur := NewUserRepo(pool)
+
+	prefix := fmt.Sprintf("roles_test-%d", time.Now().UnixNano())
+	user, err := ur.Create(ctx, &models.User{Login: prefix + "-a", EncryptedPwd: "encryptedPwd"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.RoleCustomer}, user.Roles)
+	_, err = ur.Create(ctx, &models.User{Login: prefix + "-b", EncryptedPwd: "encryptedPwd"})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		found, err := ur.AddRole(ctx, user.Login, models.RoleSupport)
+		require.NoError(t, err)
+		assert.True(t, found)
+	}
+	user, err = ur.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.RoleCustomer, models.RoleSupport}, user.Roles)
+
+	found, err := ur.AddRole(ctx, prefix+"-missing", models.RoleAdmin)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	users, err := ur.Search(ctx, prefix, 10)
+	require.NoError(t, err)
+	assert.Len(t, users, 2)
+	// The underscore must match literally, not as a wildcard.
+	users, err = ur.Search(ctx, "roles_test-", 1)
+	require.NoError(t, err)
+	assert.Len(t, users, 1)
+	users, err = ur.Search(ctx, "roles%test", 10)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	_, err = ur.GetByID(ctx, -1)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+}
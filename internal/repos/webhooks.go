@@ -0,0 +1,246 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"strconv"
+	"time"
+)
+
+type WebhookRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewWebhookRepo(pool *pgxpool.Pool) *WebhookRepo {
+	return &WebhookRepo{pool: pool}
+}
+
+const (
+	webhookEndpointColumns = "id, user_id, url, secret, events, description, created_at"
+	webhookDeliveryColumns = "d.id, d.endpoint_id, d.event_id, d.event_type, d.payload, d.state, d.attempts, d.next_attempt_at, " +
+		"coalesce(d.last_status, 0), d.last_error, d.created_at, d.delivered_at"
+)
+
+func scanWebhookEndpoint(row pgx.Row) (*models.WebhookEndpoint, error) {
+	e := &models.WebhookEndpoint{}
+	err := row.Scan(&e.ID, &e.UserID, &e.URL, &e.Secret, &e.Events, &e.Description, &e.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrWebhookEndpointNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan: %w", err)
+	}
+	return e, nil
+}
+
+func scanWebhookDelivery(row pgx.Row, extra ...interface{}) (*models.WebhookDelivery, error) {
+	d := &models.WebhookDelivery{}
+	var payload string
+	dest := append([]interface{}{&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &payload, &d.State, &d.Attempts,
+		&d.NextAttemptAt, &d.LastStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	d.Payload = json.RawMessage(payload)
+	return d, nil
+}
+
+// enqueueWebhook queues the event for every endpoint subscribed to it in the
+// caller's transaction, so an event is queued if and only if the change that
+// caused it is committed.
+func enqueueWebhook(ctx context.Context, tx pgx.Tx, event *models.WebhookEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	query := `insert into webhook_deliveries (endpoint_id, event_id, event_type, payload, state, next_attempt_at, created_at)
+              select id, $1, $2, $3, $4, $5, $5 from webhook_endpoints
+              where (user_id = $6 or user_id is null) and $2 = any(events)`
+	_, err = tx.Exec(ctx, query, event.ID, event.Type, string(payload), models.DeliveryPending, time.Now(), event.UserID)
+	if err != nil {
+		return fmt.Errorf("tx.Exec: %w", err)
+	}
+	return nil
+}
+
+func (wr *WebhookRepo) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) (*models.WebhookEndpoint, error) {
+	var created *models.WebhookEndpoint
+	err := pgx.BeginFunc(ctx, wr.pool, func(tx pgx.Tx) error {
+		query := "insert into webhook_endpoints (user_id, url, secret, events, description, created_at) values ($1, $2, $3, $4, $5, $6) returning " + webhookEndpointColumns
+		row := tx.QueryRow(ctx, query, endpoint.UserID, endpoint.URL, endpoint.Secret, endpoint.Events, endpoint.Description, endpoint.CreatedAt)
+		var err error
+		created, err = scanWebhookEndpoint(row)
+		if err != nil {
+			return fmt.Errorf("scanWebhookEndpoint: %w", err)
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionWebhookCreated, models.AuditEntityWebhook, strconv.Itoa(created.ID)).
+			WithChange(nil, webhookEndpointState(created))
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		if created.UserID != nil {
+			event.UserID = *created.UserID
+		}
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.BeginFunc: %w", err)
+	}
+	return created, nil
+}
+
+func (wr *WebhookRepo) GetEndpoint(ctx context.Context, id int) (*models.WebhookEndpoint, error) {
+	query := "select " + webhookEndpointColumns + " from webhook_endpoints where id = $1"
+	return scanWebhookEndpoint(wr.pool.QueryRow(ctx, query, id))
+}
+
+// ListEndpoints returns the user's endpoints, or the partner endpoints if
+// userID is zero.
+func (wr *WebhookRepo) ListEndpoints(ctx context.Context, userID int) ([]*models.WebhookEndpoint, error) {
+	query := "select " + webhookEndpointColumns + " from webhook_endpoints where user_id is not distinct from $1 order by id"
+	var owner *int
+	if userID != 0 {
+		owner = &userID
+	}
+	rows, err := wr.pool.Query(ctx, query, owner)
+	if err != nil {
+		return nil, fmt.Errorf("wr.pool.Query: %w", err)
+	}
+	endpoints, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.WebhookEndpoint, error) {
+		return scanWebhookEndpoint(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows: %w", err)
+	}
+	return endpoints, nil
+}
+
+// DeleteEndpoint removes the endpoint together with its deliveries.
+func (wr *WebhookRepo) DeleteEndpoint(ctx context.Context, id int) error {
+	err := pgx.BeginFunc(ctx, wr.pool, func(tx pgx.Tx) error {
+		query := "delete from webhook_endpoints where id = $1 returning " + webhookEndpointColumns
+		deleted, err := scanWebhookEndpoint(tx.QueryRow(ctx, query, id))
+		if err != nil {
+			return err
+		}
+		event, err := newAuditEvent(ctx, models.AuditActionWebhookDeleted, models.AuditEntityWebhook, strconv.Itoa(id)).
+			WithChange(webhookEndpointState(deleted), nil)
+		if err != nil {
+			return fmt.Errorf("WithChange: %w", err)
+		}
+		if deleted.UserID != nil {
+			event.UserID = *deleted.UserID
+		}
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return fmt.Errorf("pgx.BeginFunc: %w", err)
+	}
+	return nil
+}
+
+// ClaimDeliveries leases up to limit due deliveries to owner, like
+// OrdersRepo.ClaimUnprocessedOrders, and fills in their endpoint's URL and secret.
+func (wr *WebhookRepo) ClaimDeliveries(ctx context.Context, owner string, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	query := `with claimable as (
+                  select id from webhook_deliveries
+                  where state = $1 and next_attempt_at <= $2 and (locked_until is null or locked_until < $2)
+                  order by next_attempt_at
+                  limit $3
+                  for update skip locked
+              ), claimed as (
+                  update webhook_deliveries d set locked_by = $4, locked_until = $2 + $5 * interval '1 millisecond'
+                  from claimable where d.id = claimable.id
+                  returning d.*
+              )
+              select ` + webhookDeliveryColumns + `, e.url, e.secret
+              from claimed d join webhook_endpoints e on e.id = d.endpoint_id`
+	rows, err := wr.pool.Query(ctx, query, models.DeliveryPending, time.Now(), limit, owner, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("wr.pool.Query: %w", err)
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.WebhookDelivery, error) {
+		var url, secret string
+		d, err := scanWebhookDelivery(row, &url, &secret)
+		if err != nil {
+			return nil, err
+		}
+		d.URL, d.Secret = url, secret
+		return d, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows: %w", err)
+	}
+	return deliveries, nil
+}
+
+// UpdateDelivery records the outcome of an attempt and releases the lease.
+func (wr *WebhookRepo) UpdateDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	query := `update webhook_deliveries
+              set state = $2, attempts = $3, next_attempt_at = $4, last_status = nullif($5, 0), last_error = $6,
+                  delivered_at = $7, locked_by = null, locked_until = null
+              where id = $1`
+	_, err := wr.pool.Exec(ctx, query, d.ID, d.State, d.Attempts, d.NextAttemptAt, d.LastStatus, d.LastError, d.DeliveredAt)
+	if err != nil {
+		return fmt.Errorf("wr.pool.Exec: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns deliveries newest first.
+func (wr *WebhookRepo) ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error) {
+	where := &whereClause{}
+	if filter.EndpointID != 0 {
+		where.add("d.endpoint_id = ?", filter.EndpointID)
+	}
+	if filter.State != "" {
+		where.add("d.state = ?", filter.State)
+	}
+	if filter.BeforeID != 0 {
+		where.add("d.id < ?", filter.BeforeID)
+	}
+	query := "select " + webhookDeliveryColumns + " from webhook_deliveries d" + where.String() +
+		" order by d.id desc limit " + where.arg(filter.Limit)
+	rows, err := wr.pool.Query(ctx, query, where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("wr.pool.Query: %w", err)
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.WebhookDelivery, error) {
+		return scanWebhookDelivery(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows: %w", err)
+	}
+	return deliveries, nil
+}
+
+// ReplayDeadDeliveries puts dead-lettered deliveries back in the queue with a
+// fresh attempt budget. Zero endpointID or deliveryID don't filter. It returns
+// the number of deliveries requeued.
+func (wr *WebhookRepo) ReplayDeadDeliveries(ctx context.Context, endpointID int, deliveryID int64) (int64, error) {
+	where := &whereClause{}
+	where.add("state = ?", models.DeliveryDead)
+	if endpointID != 0 {
+		where.add("endpoint_id = ?", endpointID)
+	}
+	if deliveryID != 0 {
+		where.add("id = ?", deliveryID)
+	}
+	query := "update webhook_deliveries set state = " + where.arg(models.DeliveryPending) +
+		", attempts = 0, next_attempt_at = " + where.arg(time.Now()) + where.String()
+	tag, err := wr.pool.Exec(ctx, query, where.args...)
+	if err != nil {
+		return 0, fmt.Errorf("wr.pool.Exec: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func webhookEndpointState(e *models.WebhookEndpoint) map[string]interface{} {
+	return map[string]interface{}{"url": e.URL, "events": e.Events}
+}
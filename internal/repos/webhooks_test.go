@@ -0,0 +1,85 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"testing"
+	"time"
+)
+
+func TestWebhookRepo_Deliveries(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	ur := NewUserRepo(pool)
+	lr := NewLedgerRepo(pool)
+	wr := NewWebhookRepo(pool)
+
+	user, err := ur.Create(ctx, &models.User{
+		Login:        fmt.Sprintf("webhooks-test-%d", time.Now().UnixNano()),
+		EncryptedPwd: "encryptedPwd",
+	})
+	require.NoError(t, err)
+	secret, err := models.NewWebhookSecret()
+	require.NoError(t, err)
+	endpoint, err := wr.CreateEndpoint(ctx, &models.WebhookEndpoint{
+		UserID: &user.ID,
+		URL:    "https://shop.example.com/hooks",
+		Secret: secret,
+		Events: []string{models.WebhookEventBalanceWithdrawn},
+	})
+	require.NoError(t, err)
+
+	orderID := int(time.Now().UnixNano() / 1000)
+	require.NoError(t, lr.Post(ctx, models.NewAccrualEntry(user.ID, orderID, money.FromInt(100))))
+	require.NoError(t, lr.Withdraw(ctx, &models.Withdrawal{
+		OrderID:     orderID + 1,
+		UserID:      user.ID,
+		Sum:         money.FromInt(10),
+		ProcessedAt: time.Now(),
+	}))
+
+	claimed, err := wr.ClaimDeliveries(ctx, "worker", 1000, time.Minute)
+	require.NoError(t, err)
+	var delivery *models.WebhookDelivery
+	for _, d := range claimed {
+		if d.EndpointID == endpoint.ID {
+			delivery = d
+		}
+	}
+	require.NotNil(t, delivery, "withdrawal wasn't enqueued")
+	assert.Equal(t, models.WebhookEventBalanceWithdrawn, delivery.EventType)
+	assert.Equal(t, endpoint.URL, delivery.URL)
+	assert.Equal(t, endpoint.Secret, delivery.Secret)
+
+	// A leased delivery isn't claimed twice.
+	claimed, err = wr.ClaimDeliveries(ctx, "other-worker", 1000, time.Minute)
+	require.NoError(t, err)
+	for _, d := range claimed {
+		assert.NotEqual(t, delivery.ID, d.ID)
+	}
+
+	delivery.State = models.DeliveryDead
+	delivery.Attempts = 8
+	delivery.LastStatus = 500
+	delivery.LastError = "unexpected status 500"
+	require.NoError(t, wr.UpdateDelivery(ctx, delivery))
+
+	dead, err := wr.ListDeliveries(ctx, models.WebhookDeliveryFilter{EndpointID: endpoint.ID, State: models.DeliveryDead, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, 500, dead[0].LastStatus)
+
+	replayed, err := wr.ReplayDeadDeliveries(ctx, endpoint.ID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), replayed)
+	pending, err := wr.ListDeliveries(ctx, models.WebhookDeliveryFilter{EndpointID: endpoint.ID, State: models.DeliveryPending, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 0, pending[0].Attempts)
+
+	require.NoError(t, wr.DeleteEndpoint(ctx, endpoint.ID))
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vindosVP/loyalty-system/internal/database"
+	"github.com/vindosVP/loyalty-system/internal/events"
+	"github.com/vindosVP/loyalty-system/internal/health"
+	"github.com/vindosVP/loyalty-system/internal/metrics"
+	"github.com/vindosVP/loyalty-system/internal/repos"
+	"github.com/vindosVP/loyalty-system/internal/repos/memory"
+	"github.com/vindosVP/loyalty-system/internal/repos/sqlite"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// backend is the storage selected by DATABASE_URI: its repos, the readiness
+// checks and metrics of whatever it runs on, and how to close it.
+type backend struct {
+	users       storage.UserRepo
+	orders      storage.OrderRepo
+	ledger      storage.LedgerRepo
+	sessions    storage.SessionRepo
+	audit       storage.AuditRepo
+	idempotency storage.IdempotencyRepo
+	webhooks    storage.WebhookRepo
+	tiers       storage.TierRepo
+	tx          storage.Tx
+	events      events.Store
+	checks      func(c *health.Checker)
+	collectors  []prometheus.Collector
+	close       func()
+}
+
+func openBackend(ctx context.Context, uri string) (*backend, error) {
+	switch {
+	case uri == memory.URI:
+		return newMemoryBackend(), nil
+	case database.IsSQLite(uri):
+		return openSQLiteBackend(ctx, uri)
+	default:
+		return openPostgresBackend(ctx, uri)
+	}
+}
+
+// openPostgresBackend connects to PostgreSQL and applies pending migrations.
+func openPostgresBackend(ctx context.Context, uri string) (*backend, error) {
+	pool, err := database.New(ctx, uri)
+	if err != nil {
+		return nil, fmt.Errorf("database.New: %w", err)
+	}
+	migrator, err := database.NewMigrator(pool)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("database.NewMigrator: %w", err)
+	}
+	return &backend{
+		users:       repos.NewUserRepo(pool),
+		orders:      repos.NewOrdersRepo(pool),
+		ledger:      repos.NewLedgerRepo(pool),
+		sessions:    repos.NewSessionRepo(pool),
+		audit:       repos.NewAuditRepo(pool),
+		idempotency: repos.NewIdempotencyRepo(pool),
+		webhooks:    repos.NewWebhookRepo(pool),
+		tiers:       repos.NewTierRepo(pool),
+		tx:          repos.NewTx(pool),
+		events:      repos.NewEventRepo(pool),
+		checks: func(c *health.Checker) {
+			c.Register("database", true, health.Ping(pool))
+			c.Register("migrations", true, health.Migrations(migrator.Pending))
+		},
+		collectors: []prometheus.Collector{metrics.NewPoolCollector(pool)},
+		close:      pool.Close,
+	}, nil
+}
+
+// openSQLiteBackend opens the SQLite database file and applies pending
+// migrations. Only one instance may use the file.
+func openSQLiteBackend(ctx context.Context, uri string) (*backend, error) {
+	db, err := database.NewSQLite(ctx, uri)
+	if err != nil {
+		return nil, fmt.Errorf("database.NewSQLite: %w", err)
+	}
+	migrator, err := database.NewSQLiteMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database.NewSQLiteMigrator: %w", err)
+	}
+	return &backend{
+		users:       sqlite.NewUserRepo(db),
+		orders:      sqlite.NewOrdersRepo(db),
+		ledger:      sqlite.NewLedgerRepo(db),
+		sessions:    sqlite.NewSessionRepo(db),
+		audit:       sqlite.NewAuditRepo(db),
+		idempotency: sqlite.NewIdempotencyRepo(db),
+		webhooks:    sqlite.NewWebhookRepo(db),
+		tiers:       sqlite.NewTierRepo(db),
+		tx:          sqlite.NewTx(db),
+		events:      sqlite.NewEventRepo(db),
+		checks: func(c *health.Checker) {
+			c.Register("database", true, health.Ping(sqlPinger{db}))
+			c.Register("migrations", true, health.Migrations(migrator.Pending))
+		},
+		close: func() {
+			if err := db.Close(); err != nil {
+				logger.Log.Error("Failed to close the database", zap.Error(err))
+			}
+		},
+	}, nil
+}
+
+// sqlPinger adapts *sql.DB to health.Pinger.
+type sqlPinger struct {
+	db *sql.DB
+}
+
+func (p sqlPinger) Ping(ctx context.Context) error {
+	return p.db.PingContext(ctx)
+}
+
+// newMemoryBackend keeps everything in process memory, which is lost on exit.
+func newMemoryBackend() *backend {
+	logger.Log.Warn("Using the in-memory database, nothing will survive a restart")
+	db := memory.New()
+	return &backend{
+		users:       memory.NewUserRepo(db),
+		orders:      memory.NewOrdersRepo(db),
+		ledger:      memory.NewLedgerRepo(db),
+		sessions:    memory.NewSessionRepo(db),
+		audit:       memory.NewAuditRepo(db),
+		idempotency: memory.NewIdempotencyRepo(db),
+		webhooks:    memory.NewWebhookRepo(db),
+		tiers:       memory.NewTierRepo(db),
+		tx:          memory.NewTx(db),
+		events:      memory.NewEventRepo(db),
+		checks: func(c *health.Checker) {
+			c.Register("database", true, health.Ping(db))
+		},
+		close: func() {},
+	}
+}
@@ -2,56 +2,294 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	chim "github.com/go-chi/chi/v5/middleware"
 	"github.com/vindosVP/loyalty-system/cmd/gophermart/config"
-	"github.com/vindosVP/loyalty-system/internal/database"
+	"github.com/vindosVP/loyalty-system/internal/accrual"
+	"github.com/vindosVP/loyalty-system/internal/events"
 	"github.com/vindosVP/loyalty-system/internal/handlers"
+	"github.com/vindosVP/loyalty-system/internal/health"
+	"github.com/vindosVP/loyalty-system/internal/metrics"
 	"github.com/vindosVP/loyalty-system/internal/middleware"
+	"github.com/vindosVP/loyalty-system/internal/models"
 	"github.com/vindosVP/loyalty-system/internal/processor"
-	"github.com/vindosVP/loyalty-system/internal/repos"
 	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/internal/tracing"
+	"github.com/vindosVP/loyalty-system/internal/webhooks"
 	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"github.com/vindosVP/loyalty-system/pkg/tokens"
 	"go.uber.org/zap"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 )
 
-func Run(cfg *config.Config) error {
-	ctx := context.Background()
-	pool, err := database.New(ctx, cfg.DBURI)
+const (
+	purgeInterval = time.Hour
+	// expiryInterval is how often points past their expiry date are expired.
+	// Until then they are already left out of balances.
+	expiryInterval = time.Hour
+	// readinessTimeout bounds the dependency checks of a single /readyz probe.
+	readinessTimeout = 2 * time.Second
+	// eventRetention bounds how long after a disconnect an event stream can
+	// be resumed with Last-Event-ID.
+	eventRetention = 24 * time.Hour
+)
+
+// Run serves the API until ctx is cancelled, then drains in-flight requests,
+// stops the accrual processor and waits for its workers before closing the
+// database.
+func Run(ctx context.Context, cfg *config.Config) error {
+	shutdownTracing, err := tracing.Setup(ctx, cfg.TraceExporter)
+	if err != nil {
+		return fmt.Errorf("tracing.Setup: %w", err)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Log.Error("Failed to flush traces", zap.Error(err))
+		}
+	}()
+
+	b, err := openBackend(ctx, cfg.DBURI)
 	if err != nil {
-		return fmt.Errorf("database.New: %w", err)
+		return fmt.Errorf("openBackend: %w", err)
 	}
-	defer pool.Close()
+	defer b.close()
 
-	ur := repos.NewUserRepo(pool)
-	or := repos.NewOrdersRepo(pool)
-	s := storage.New(ur, or)
+	bus := events.NewBus(b.events)
+	s := storage.New(b.users, b.orders, b.ledger, b.sessions, b.audit, b.idempotency, b.webhooks, b.tiers, b.tx, bus)
+	s.Expiry = models.ExpiryPolicy{Months: cfg.PointsExpiry}
+	scheduled, err := s.ScheduleExpiry(ctx)
+	if err != nil {
+		return fmt.Errorf("s.ScheduleExpiry: %w", err)
+	}
+	if scheduled > 0 {
+		logger.Log.Info("Scheduled expiry of accrued points", zap.Int64("lots", scheduled))
+	}
+	tokenCfg := tokens.Config{
+		Secret:     cfg.JWTSecret,
+		AccessTTL:  cfg.AccessTokenTTL,
+		RefreshTTL: cfg.RefreshTokenTTL,
+	}
 
 	r := chi.NewRouter()
-	r.Use(chim.Logger, chim.Compress(5))
+	r.Use(middleware.Tracing, chim.RequestID, middleware.Metrics, chim.Logger, chim.Compress(5), middleware.StripIdentityHeaders, middleware.RequestMeta)
 	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(200)
 	})
-	r.Post("/api/user/register", handlers.Register(s, cfg.JWTSecret))
-	r.Post("/api/user/login", handlers.Login(s, cfg.JWTSecret))
+	checker := health.New(readinessTimeout)
+	r.Get("/healthz", handlers.Liveness())
+	r.Get("/readyz", handlers.Readiness(checker))
+	r.Post("/api/user/register", handlers.Register(s, tokenCfg))
+	r.Post("/api/user/login", handlers.Login(s, tokenCfg))
+	r.Post("/api/user/token/refresh", handlers.RefreshToken(s, tokenCfg))
+	a := middleware.NewAuthenticator(cfg.JWTSecret, s)
 	r.Group(func(r chi.Router) {
-		a := middleware.NewAuthenticator(cfg.JWTSecret)
 		r.Use(a.WithAuth)
-		r.Post("/api/user/orders", handlers.CreateOrder(s))
+		r.Post("/api/user/logout", handlers.Logout(s))
+		r.Post("/api/user/logout/all", handlers.LogoutAll(s))
+		idempotent := middleware.Idempotency(s, cfg.IdempotencyTTL, cfg.IdempotencyLease)
+		r.With(idempotent).Post("/api/user/orders", handlers.CreateOrder(s))
 		r.Get("/api/user/orders", handlers.GetOrderList(s))
+		r.Get("/api/user/orders/events", handlers.OrderEvents(bus))
 		r.Get("/api/user/balance", handlers.GetUsersBalance(s))
-		r.Post("/api/user/balance/withdraw", handlers.WithdrawOrder(s))
+		r.Get("/api/user/balance/expirations", handlers.GetUsersExpirations(s))
+		r.With(idempotent).Post("/api/user/balance/withdraw", handlers.WithdrawOrder(s))
 		r.Get("/api/user/withdrawals", handlers.GetUsersWithdrawals(s))
+		r.Get("/api/user/tier", handlers.GetUserTier(s))
+		r.Post("/api/user/webhooks", handlers.CreateWebhook(s))
+		r.Get("/api/user/webhooks", handlers.GetWebhooks(s))
+		r.Delete("/api/user/webhooks/{id}", handlers.DeleteWebhook(s))
 	})
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(a.WithAuth, middleware.RequireRole(models.RoleSupport, models.RoleAdmin))
+		r.Get("/users", handlers.SearchUsers(s))
+		r.Get("/users/{id}/orders", handlers.GetUserOrders(s))
+		r.Get("/users/{id}/withdrawals", handlers.GetUserWithdrawals(s))
+		r.Post("/orders/{number}/requeue", handlers.RequeueOrder(s))
+		r.Get("/audit", handlers.QueryAuditEvents(s))
+		r.Get("/webhooks", handlers.GetPartnerWebhooks(s))
+		r.Get("/webhooks/deliveries", handlers.GetWebhookDeliveries(s))
+		r.Get("/tiers", handlers.GetTierRules(s))
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireRole(models.RoleAdmin))
+			r.Post("/users/{id}/adjustments", handlers.CreateAdjustment(s))
+			r.Get("/audit/verify", handlers.VerifyAuditLog(s))
+			r.Post("/webhooks", handlers.CreatePartnerWebhook(s))
+			r.Delete("/webhooks/{id}", handlers.AdminDeleteWebhook(s))
+			r.Post("/webhooks/{id}/replay", handlers.ReplayWebhookEndpoint(s))
+			r.Post("/webhooks/deliveries/{id}/replay", handlers.ReplayWebhookDelivery(s))
+			r.Put("/tiers/{tier}", handlers.UpdateTierRule(s))
+		})
+	})
+
+	metrics.Registry.MustRegister(append(b.collectors,
+		metrics.NewBacklogCollector(s, models.OrderStatusNew, models.OrderStatusProcessing))...)
+	admin := chi.NewRouter()
+	admin.Handle("/metrics", metrics.Handler())
 
-	p := processor.New(cfg.RequestInterval, cfg.AccrualSysAddr, s)
-	go p.Run()
-	logger.Log.Info("Server started", zap.String("Address", cfg.RunAddr))
-	err = http.ListenAndServe(cfg.RunAddr, r)
+	l, err := net.Listen("tcp", cfg.RunAddr)
+	if err != nil {
+		return fmt.Errorf("net.Listen: %w", err)
+	}
+	adminL, err := net.Listen("tcp", cfg.AdminAddr)
 	if err != nil {
-		return fmt.Errorf("http.ListenAndServe: %w", err)
+		l.Close()
+		return fmt.Errorf("net.Listen: %w", err)
+	}
+
+	processorCtx, stopProcessor := context.WithCancel(ctx)
+	defer stopProcessor()
+	p := processor.New(cfg.RequestInterval, cfg.AccrualSysAddr, cfg.BatchSize, cfg.LeaseTimeout, s)
+	p.Done = processorCtx.Done()
+	p.Events = bus
+	b.checks(checker)
+	// A poll holding its orders longer than their lease is stuck.
+	checker.Register("processor", true, health.Tick(p.LastTick, cfg.RequestInterval*time.Second+cfg.LeaseTimeout))
+	checker.Register("accrual", false, health.Ping(accrual.NewClient(cfg.AccrualSysAddr)))
+	// On shutdown /readyz reports draining for DrainDelay before the listeners
+	// close, so the orchestrator stops routing requests here first.
+	serveCtx, stopServing := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopServing()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-serveCtx.Done():
+			return
+		}
+		checker.Drain()
+		logger.Log.Info("Draining", zap.Duration("drainDelay", cfg.DrainDelay))
+		select {
+		case <-time.After(cfg.DrainDelay):
+		case <-serveCtx.Done():
+		}
+		stopServing()
+	}()
+
+	d := webhooks.New(s, cfg.WebhookAttempts, cfg.WebhookTimeout)
+	d.Done = processorCtx.Done()
+	wg := sync.WaitGroup{}
+	wg.Add(6)
+	go func() {
+		defer wg.Done()
+		p.Run()
+	}()
+	go func() {
+		defer wg.Done()
+		d.Run()
+	}()
+	go func() {
+		defer wg.Done()
+		purgeExpired(processorCtx, s, bus, purgeInterval)
+	}()
+	go func() {
+		defer wg.Done()
+		expirePoints(processorCtx, s, expiryInterval)
+	}()
+	// Stopping the bus ends the event streams, which the server would
+	// otherwise wait for until the drain timeout.
+	busCtx, stopBus := context.WithCancel(ctx)
+	defer stopBus()
+	go func() {
+		defer wg.Done()
+		bus.Run(busCtx)
+	}()
+
+	go func() {
+		defer wg.Done()
+		if err := serve(serveCtx, &http.Server{Handler: admin}, adminL, cfg.ShutdownTimeout); err != nil {
+			logger.Log.Error("Admin server failed", zap.Error(err))
+		}
+	}()
+
+	logger.Log.Info("Server started", zap.String("Address", cfg.RunAddr), zap.String("AdminAddress", cfg.AdminAddr))
+	err = serve(serveCtx, &http.Server{Handler: r}, l, cfg.ShutdownTimeout)
+	stopServing()
+	stopBus()
+	stopProcessor()
+	wg.Wait()
+	logger.Log.Info("Accrual processor and webhook dispatcher stopped")
+	if err != nil {
+		return fmt.Errorf("serve: %w", err)
+	}
+	return nil
+}
+
+// purgeExpired deletes expired idempotency keys and events older than
+// eventRetention every interval until ctx is cancelled. Expired keys are
+// ignored anyway, this only bounds the tables.
+func purgeExpired(ctx context.Context, s *storage.Storage, bus *events.Bus, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		deleted, err := s.PurgeExpiredIdempotencyKeys(ctx)
+		if err != nil {
+			logger.Log.Error("Failed to purge expired idempotency keys", zap.Error(err))
+		} else {
+			logger.Log.Debug("Purged expired idempotency keys", zap.Int64("deleted", deleted))
+		}
+		deleted, err = bus.Prune(ctx, time.Now().Add(-eventRetention))
+		if err != nil {
+			logger.Log.Error("Failed to prune events", zap.Error(err))
+		} else {
+			logger.Log.Debug("Pruned events", zap.Int64("deleted", deleted))
+		}
+	}
+}
+
+// expirePoints expires points past their expiry date every interval until ctx
+// is cancelled.
+func expirePoints(ctx context.Context, s *storage.Storage, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		expired, err := s.ExpirePoints(ctx)
+		if err != nil {
+			logger.Log.Error("Failed to expire points", zap.Error(err))
+		} else {
+			logger.Log.Debug("Expired points", zap.Stringer("expired", expired))
+		}
+	}
+}
+
+// serve runs srv on l until ctx is cancelled and then shuts it down, giving
+// in-flight requests up to drainTimeout to complete.
+func serve(ctx context.Context, srv *http.Server, l net.Listener, drainTimeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(l)
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("srv.Serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	logger.Log.Info("Shutting down server", zap.Duration("drainTimeout", drainTimeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("srv.Shutdown: %w", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("srv.Serve: %w", err)
 	}
+	logger.Log.Info("Server stopped")
 	return nil
 }
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServe_DrainsInFlightRequests(t *testing.T) {
+	const requests = 20
+
+	var started sync.WaitGroup
+	started.Add(requests)
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started.Done()
+		<-release
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("done"))
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	url := "http://" + l.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, &http.Server{Handler: handler}, l, 5*time.Second)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	var finished sync.WaitGroup
+	statuses := make(chan int, requests)
+	for i := 0; i < requests; i++ {
+		finished.Add(1)
+		go func() {
+			defer finished.Done()
+			resp, err := client.Get(url)
+			if err != nil {
+				t.Errorf("request dropped: %v", err)
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, "done", string(body))
+			statuses <- resp.StatusCode
+		}()
+	}
+
+	started.Wait()
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = client.Get(url)
+	assert.Error(t, err, "new connections must be refused while draining")
+
+	close(release)
+	finished.Wait()
+	close(statuses)
+	count := 0
+	for status := range statuses {
+		assert.Equal(t, http.StatusOK, status)
+		count++
+	}
+	assert.Equal(t, requests, count)
+
+	select {
+	case err := <-served:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after shutdown")
+	}
+}
+
+func TestServe_DrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, &http.Server{Handler: handler}, l, 50*time.Millisecond)
+	}()
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	<-started
+	cancel()
+	assert.ErrorIs(t, <-served, context.DeadlineExceeded)
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage/mocks"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"testing"
+)
+
+func TestStorage_AdjustBalance(t *testing.T) {
+	unexpectedError := errors.New("unexpected error")
+
+	type userRepoGetByIDMock struct {
+		needed bool
+		result *models.User
+		err    error
+	}
+	type ledgerRepoAdjustMock struct {
+		needed bool
+		result money.Amount
+		err    error
+	}
+	type want struct {
+		result money.Amount
+		err    error
+	}
+
+	adjustment := &models.Adjustment{UserID: 1, Amount: money.FromInt(-20), Reason: "duplicate accrual", CreatedBy: 2}
+	tests := []struct {
+		name                 string
+		userRepoGetByIDMock  userRepoGetByIDMock
+		ledgerRepoAdjustMock ledgerRepoAdjustMock
+		want                 want
+	}{
+		{
+			name: "ok",
+			userRepoGetByIDMock: userRepoGetByIDMock{
+				needed: true,
+				result: &models.User{ID: 1, Login: "testUser"},
+				err:    nil,
+			},
+			ledgerRepoAdjustMock: ledgerRepoAdjustMock{
+				needed: true,
+				result: money.FromInt(80),
+				err:    nil,
+			},
+			want: want{
+				result: money.FromInt(80),
+				err:    nil,
+			},
+		},
+		{
+			name: "user not found",
+			userRepoGetByIDMock: userRepoGetByIDMock{
+				needed: true,
+				result: nil,
+				err:    ErrUserNotFound,
+			},
+			ledgerRepoAdjustMock: ledgerRepoAdjustMock{
+				needed: false,
+			},
+			want: want{
+				result: 0,
+				err:    ErrUserNotFound,
+			},
+		},
+		{
+			name: "insufficient funds",
+			userRepoGetByIDMock: userRepoGetByIDMock{
+				needed: true,
+				result: &models.User{ID: 1, Login: "testUser"},
+				err:    nil,
+			},
+			ledgerRepoAdjustMock: ledgerRepoAdjustMock{
+				needed: true,
+				result: 0,
+				err:    ErrInsufficientFunds,
+			},
+			want: want{
+				result: 0,
+				err:    ErrInsufficientFunds,
+			},
+		},
+		{
+			name: "adjust unexpected error",
+			userRepoGetByIDMock: userRepoGetByIDMock{
+				needed: true,
+				result: &models.User{ID: 1, Login: "testUser"},
+				err:    nil,
+			},
+			ledgerRepoAdjustMock: ledgerRepoAdjustMock{
+				needed: true,
+				result: 0,
+				err:    unexpectedError,
+			},
+			want: want{
+				result: 0,
+				err:    unexpectedError,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := mocks.NewUserRepo(t)
+			ledgerRepo := mocks.NewLedgerRepo(t)
+			s := New(userRepo, mocks.NewOrderRepo(t), ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
+
+			if tt.userRepoGetByIDMock.needed {
+				userRepo.On("GetByID", mock.Anything, adjustment.UserID).Return(tt.userRepoGetByIDMock.result, tt.userRepoGetByIDMock.err)
+			}
+			if tt.ledgerRepoAdjustMock.needed {
+				ledgerRepo.On("Adjust", mock.Anything, adjustment).Return(tt.ledgerRepoAdjustMock.result, tt.ledgerRepoAdjustMock.err)
+			}
+
+			result, err := s.AdjustBalance(context.Background(), adjustment)
+			assert.Equal(t, tt.want.result, result)
+			if tt.want.err != nil {
+				assert.ErrorIs(t, err, tt.want.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStorage_RequeueOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  *models.Order
+		err     error
+		wantErr error
+	}{
+		{
+			name:    "ok",
+			result:  &models.Order{ID: 7703824164, UserID: 1, Status: models.OrderStatusNew},
+			err:     nil,
+			wantErr: nil,
+		},
+		{
+			name:    "not found",
+			result:  nil,
+			err:     ErrOrderNotFound,
+			wantErr: ErrOrderNotFound,
+		},
+		{
+			name:    "already processed",
+			result:  nil,
+			err:     ErrOrderAlreadyProcessed,
+			wantErr: ErrOrderAlreadyProcessed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderRepo := mocks.NewOrderRepo(t)
+			s := New(mocks.NewUserRepo(t), orderRepo, mocks.NewLedgerRepo(t), mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
+			orderRepo.On("Requeue", mock.Anything, 7703824164).Return(tt.result, tt.err)
+
+			result, err := s.RequeueOrder(context.Background(), 7703824164)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.result, result)
+		})
+	}
+}
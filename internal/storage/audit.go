@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/tracing"
+)
+
+// Changes are audited by the repos in the same transaction. RecordAuditEvent
+// is for security events that don't change anything, like a failed login.
+func (s *Storage) RecordAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.RecordAuditEvent")
+	defer span.End()
+	err := s.auditRepo.Append(ctx, event)
+	if err != nil {
+		return fmt.Errorf("s.auditRepo.Append: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) QueryAuditEvents(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.QueryAuditEvents")
+	defer span.End()
+	events, err := s.auditRepo.Query(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("s.auditRepo.Query: %w", err)
+	}
+	return events, nil
+}
+
+func (s *Storage) VerifyAuditLog(ctx context.Context) (*models.AuditVerification, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.VerifyAuditLog")
+	defer span.End()
+	res, err := s.auditRepo.Verify(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.auditRepo.Verify: %w", err)
+	}
+	return res, nil
+}
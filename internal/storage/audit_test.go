@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage/mocks"
+	"testing"
+)
+
+func TestStorage_AuditEvents(t *testing.T) {
+	ctx := context.Background()
+	auditRepo := mocks.NewAuditRepo(t)
+	s := New(mocks.NewUserRepo(t), mocks.NewOrderRepo(t), mocks.NewLedgerRepo(t), mocks.NewSessionRepo(t), auditRepo, mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
+
+	event := models.NewAuditEvent(models.AuditActionLoginFailed, models.AuditEntityUser, "someLogin")
+	unexpectedError := errors.New("unexpected error")
+	auditRepo.On("Append", mock.Anything, event).Return(unexpectedError).Once()
+	assert.ErrorIs(t, s.RecordAuditEvent(ctx, event), unexpectedError)
+
+	filter := models.AuditFilter{UserID: 1, Limit: 10}
+	auditRepo.On("Query", mock.Anything, filter).Return([]*models.AuditEvent{event}, nil).Once()
+	events, err := s.QueryAuditEvents(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, []*models.AuditEvent{event}, events)
+
+	auditRepo.On("Verify", mock.Anything).Return(&models.AuditVerification{Valid: false, Checked: 3, BrokenAt: 3}, nil).Once()
+	res, err := s.VerifyAuditLog(ctx)
+	require.NoError(t, err)
+	assert.False(t, res.Valid)
+	assert.EqualValues(t, 3, res.BrokenAt)
+}
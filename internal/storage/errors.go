@@ -7,4 +7,13 @@ var (
 	ErrUserNotFound            = errors.New("user not found")
 	ErrOrderAlreadyExists      = errors.New("order already exists")
 	ErrOrderCreatedByOtherUser = errors.New("order created by other user")
+	ErrInsufficientFunds       = errors.New("insufficient funds")
+	ErrSessionNotFound         = errors.New("session not found")
+	ErrOrderNotFound           = errors.New("order not found")
+	ErrOrderAlreadyProcessed   = errors.New("order already processed")
+	ErrOrderFinal              = errors.New("order already processed or invalid")
+	ErrOrderLeaseLost          = errors.New("order leased to another worker")
+	ErrWebhookEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrTierNotFound            = errors.New("tier not found")
 )
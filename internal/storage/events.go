@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Events are published after the change is stored and a failure to publish
+// doesn't fail the change: a client that misses one still sees the change on
+// its next read.
+
+func (s *Storage) publishOrder(ctx context.Context, order *models.Order) {
+	if s.events == nil {
+		return
+	}
+	event, err := models.NewOrderEvent(order)
+	if err == nil {
+		err = s.events.Publish(context.WithoutCancel(ctx), event)
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("Failed to publish order event", zap.Int("orderId", order.ID), zap.Error(err))
+	}
+}
+
+func (s *Storage) publishBalance(ctx context.Context, userID int) {
+	if s.events == nil {
+		return
+	}
+	if err := s.publishBalanceEvent(context.WithoutCancel(ctx), userID); err != nil {
+		logger.FromContext(ctx).Error("Failed to publish balance event", zap.Int("userID", userID), zap.Error(err))
+	}
+}
+
+func (s *Storage) publishBalanceEvent(ctx context.Context, userID int) error {
+	current, err := s.ledgerRepo.GetBalance(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("s.ledgerRepo.GetBalance: %w", err)
+	}
+	withdrawn, err := s.ledgerRepo.GetWithdrawnTotal(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("s.ledgerRepo.GetWithdrawnTotal: %w", err)
+	}
+	event, err := models.NewBalanceEvent(userID, current, withdrawn)
+	if err != nil {
+		return fmt.Errorf("models.NewBalanceEvent: %w", err)
+	}
+	if err := s.events.Publish(ctx, event); err != nil {
+		return fmt.Errorf("s.events.Publish: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage/mocks"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"testing"
+	"time"
+)
+
+func TestStorage_PublishesEvents(t *testing.T) {
+	ctx := context.Background()
+	orderRepo := mocks.NewOrderRepo(t)
+	ledgerRepo := mocks.NewLedgerRepo(t)
+	pub := mocks.NewEventPublisher(t)
+	s := New(mocks.NewUserRepo(t), orderRepo, ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, pub)
+
+	order := &models.Order{ID: 12345678903, UserID: 1, Status: models.OrderStatusNew, UploadedAt: time.Now()}
+	orderRepo.On("NumberOwner", mock.Anything, order.ID).Return(0, ErrOrderNotFound)
+	orderRepo.On("Create", mock.Anything, order).Return(order, nil)
+	pub.On("Publish", mock.Anything, mock.MatchedBy(func(e *models.UserEvent) bool {
+		return e.UserID == 1 && e.Type == models.UserEventOrder && e.Subject == "order:12345678903"
+	})).Return(nil).Once()
+	_, err := s.CreateOrder(ctx, order)
+	require.NoError(t, err)
+
+	withdrawal := &models.Withdrawal{OrderID: 2377225624, UserID: 1, Sum: money.FromInt(100)}
+	orderRepo.On("NumberOwner", mock.Anything, withdrawal.OrderID).Return(0, ErrOrderNotFound)
+	ledgerRepo.On("Withdraw", mock.Anything, withdrawal).Return(nil)
+	ledgerRepo.On("GetWithdrawal", mock.Anything, withdrawal.OrderID).Return(withdrawal, nil)
+	ledgerRepo.On("GetBalance", mock.Anything, 1).Return(money.FromInt(400), nil)
+	ledgerRepo.On("GetWithdrawnTotal", mock.Anything, 1).Return(money.FromInt(100), nil)
+	pub.On("Publish", mock.Anything, mock.MatchedBy(func(e *models.UserEvent) bool {
+		return e.UserID == 1 && e.Type == models.UserEventBalance && string(e.Data) == `{"current":400,"withdrawn":100}`
+	})).Return(errors.New("unexpected error")).Once()
+	_, err = s.CreateWithdrawal(ctx, withdrawal)
+	assert.NoError(t, err, "a failure to publish must not fail the withdrawal")
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/metrics"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/tracing"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"time"
+)
+
+// expiryBatchSize is the number of users whose points are expired per call to
+// the repo.
+const expiryBatchSize = 100
+
+// ExpirePoints posts expiry entries for all points past their expiry date and
+// returns the total expired.
+func (s *Storage) ExpirePoints(ctx context.Context) (money.Amount, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.ExpirePoints")
+	defer span.End()
+	now := time.Now()
+	var total money.Amount
+	for {
+		entries, err := s.ledgerRepo.ExpirePoints(ctx, now, expiryBatchSize)
+		for _, entry := range entries {
+			expired := -entry.Postings[0].Amount
+			total += expired
+			metrics.PointsExpired.Add(expired.Float64())
+			s.publishBalance(ctx, entry.UserID)
+		}
+		if err != nil {
+			return total, fmt.Errorf("s.ledgerRepo.ExpirePoints: %w", err)
+		}
+		if len(entries) < expiryBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// ScheduleExpiry starts the clock under the expiry policy for accrued points
+// that don't expire yet, like those accrued before it was set. They expire a
+// policy period from now rather than from when they were accrued, so nothing
+// expires retroactively. It returns the number of lots scheduled.
+func (s *Storage) ScheduleExpiry(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.ScheduleExpiry")
+	defer span.End()
+	expiresAt := s.Expiry.ExpiresAt(time.Now())
+	if expiresAt == nil {
+		return 0, nil
+	}
+	scheduled, err := s.ledgerRepo.ScheduleExpiry(ctx, *expiresAt)
+	if err != nil {
+		return 0, fmt.Errorf("s.ledgerRepo.ScheduleExpiry: %w", err)
+	}
+	return scheduled, nil
+}
+
+// GetUsersExpirations returns the user's points that expire from now on,
+// before until if it's set, soonest first.
+func (s *Storage) GetUsersExpirations(ctx context.Context, userID int, until *time.Time) ([]*models.Expiration, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.GetUsersExpirations")
+	defer span.End()
+	expirations, err := s.ledgerRepo.ListExpirations(ctx, userID, time.Now(), until)
+	if err != nil {
+		return nil, fmt.Errorf("s.ledgerRepo.ListExpirations: %w", err)
+	}
+	return expirations, nil
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage/mocks"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"testing"
+	"time"
+)
+
+func TestStorage_ExpirePoints(t *testing.T) {
+	unexpectedError := errors.New("unexpected error")
+	expiryEntries := func(n int) []*models.Entry {
+		entries := make([]*models.Entry, n)
+		for i := range entries {
+			entries[i] = models.NewExpiryEntry(i+1, money.FromInt(1))
+		}
+		return entries
+	}
+
+	tests := []struct {
+		name      string
+		batches   [][]*models.Entry
+		err       error
+		wantTotal money.Amount
+	}{
+		{
+			name:      "nothing to expire",
+			batches:   [][]*models.Entry{{}},
+			wantTotal: 0,
+		},
+		{
+			name:      "single batch",
+			batches:   [][]*models.Entry{expiryEntries(3)},
+			wantTotal: money.FromInt(3),
+		},
+		{
+			name:      "several batches",
+			batches:   [][]*models.Entry{expiryEntries(expiryBatchSize), expiryEntries(2)},
+			wantTotal: money.FromInt(expiryBatchSize + 2),
+		},
+		{
+			name:      "repo error",
+			batches:   [][]*models.Entry{expiryEntries(1)},
+			err:       unexpectedError,
+			wantTotal: money.FromInt(1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledgerRepo := mocks.NewLedgerRepo(t)
+			s := New(mocks.NewUserRepo(t), mocks.NewOrderRepo(t), ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
+			for i, batch := range tt.batches {
+				var err error
+				if i == len(tt.batches)-1 {
+					err = tt.err
+				}
+				ledgerRepo.On("ExpirePoints", mock.Anything, mock.Anything, expiryBatchSize).Return(batch, err).Once()
+			}
+
+			total, err := s.ExpirePoints(context.Background())
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantTotal, total)
+		})
+	}
+}
+
+func TestStorage_UpdateOrderAppliesExpiryPolicy(t *testing.T) {
+	orderRepo := mocks.NewOrderRepo(t)
+	ledgerRepo := mocks.NewLedgerRepo(t)
+	tierRepo := mocks.NewTierRepo(t)
+	s := New(mocks.NewUserRepo(t), orderRepo, ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), tierRepo, nil, nil)
+	s.Expiry = models.ExpiryPolicy{Months: 12}
+	order := &models.Order{ID: 7703824164, UserID: 1, Status: models.OrderStatusProcessed, Sum: money.FromInt(500)}
+	orderRepo.On("GetByID", mock.Anything, order.ID).Return(order, nil)
+	tierRepo.On("ListRules", mock.Anything).Return(nil, nil)
+	ledgerRepo.On("Post", mock.Anything, mock.MatchedBy(func(e *models.Entry) bool {
+		return e.ExpiresAt != nil && e.ExpiresAt.Equal(e.CreatedAt.AddDate(1, 0, 0))
+	})).Return(nil)
+	orderRepo.On("UpdateOrder", mock.Anything, order.ID, "worker", order.Status, order.Sum).Return(order, nil)
+
+	_, err := s.UpdateOrder(context.Background(), order.ID, "worker", order.Status, order.Sum)
+	require.NoError(t, err)
+}
+
+func TestStorage_GetUsersExpirations(t *testing.T) {
+	ledgerRepo := mocks.NewLedgerRepo(t)
+	s := New(mocks.NewUserRepo(t), mocks.NewOrderRepo(t), ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
+	until := time.Now().Add(time.Hour)
+	expirations := []*models.Expiration{{Amount: money.FromInt(5), ExpiresAt: until}}
+	ledgerRepo.On("ListExpirations", mock.Anything, 1, mock.Anything, &until).Return(expirations, nil)
+
+	result, err := s.GetUsersExpirations(context.Background(), 1, &until)
+	require.NoError(t, err)
+	assert.Equal(t, expirations, result)
+}
+
+func TestStorage_ScheduleExpiry(t *testing.T) {
+	ledgerRepo := mocks.NewLedgerRepo(t)
+	s := New(mocks.NewUserRepo(t), mocks.NewOrderRepo(t), ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
+
+	// Without a policy nothing is scheduled.
+	scheduled, err := s.ScheduleExpiry(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), scheduled)
+
+	s.Expiry = models.ExpiryPolicy{Months: 12}
+	start := time.Now()
+	ledgerRepo.On("ScheduleExpiry", mock.Anything, mock.MatchedBy(func(expiresAt time.Time) bool {
+		return !expiresAt.Before(start.AddDate(1, 0, 0)) && expiresAt.Before(start.AddDate(1, 0, 0).Add(time.Minute))
+	})).Return(int64(3), nil)
+	scheduled, err = s.ScheduleExpiry(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), scheduled)
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/tracing"
+	"time"
+)
+
+// ReserveIdempotencyKey returns the stored key and whether this call reserved
+// it. A key that wasn't reserved belongs to an earlier request, finished or not.
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.ReserveIdempotencyKey")
+	defer span.End()
+	stored, reserved, err := s.idemRepo.Reserve(ctx, key)
+	if err != nil {
+		return nil, false, fmt.Errorf("s.idemRepo.Reserve: %w", err)
+	}
+	return stored, reserved, nil
+}
+
+func (s *Storage) CompleteIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.CompleteIdempotencyKey")
+	defer span.End()
+	err := s.idemRepo.Complete(ctx, key)
+	if err != nil {
+		return fmt.Errorf("s.idemRepo.Complete: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.ReleaseIdempotencyKey")
+	defer span.End()
+	err := s.idemRepo.Release(ctx, key)
+	if err != nil {
+		return fmt.Errorf("s.idemRepo.Release: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.PurgeExpiredIdempotencyKeys")
+	defer span.End()
+	deleted, err := s.idemRepo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("s.idemRepo.DeleteExpired: %w", err)
+	}
+	return deleted, nil
+}
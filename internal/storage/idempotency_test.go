@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage/mocks"
+	"testing"
+)
+
+func TestStorage_IdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	idemRepo := mocks.NewIdempotencyRepo(t)
+	s := New(mocks.NewUserRepo(t), mocks.NewOrderRepo(t), mocks.NewLedgerRepo(t), mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), idemRepo, mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
+
+	key := &models.IdempotencyKey{UserID: 1, Key: "someKey", Fingerprint: "someFingerprint"}
+	idemRepo.On("Reserve", mock.Anything, key).Return(key, true, nil).Once()
+	stored, reserved, err := s.ReserveIdempotencyKey(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	assert.Equal(t, key, stored)
+
+	unexpectedError := errors.New("unexpected error")
+	idemRepo.On("Complete", mock.Anything, key).Return(unexpectedError).Once()
+	assert.ErrorIs(t, s.CompleteIdempotencyKey(ctx, key), unexpectedError)
+
+	idemRepo.On("Release", mock.Anything, key).Return(nil).Once()
+	assert.NoError(t, s.ReleaseIdempotencyKey(ctx, key))
+
+	idemRepo.On("DeleteExpired", mock.Anything, mock.AnythingOfType("time.Time")).Return(int64(2), nil).Once()
+	deleted, err := s.PurgeExpiredIdempotencyKeys(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, deleted)
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage/mocks"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"testing"
+	"time"
+)
+
+func TestStorage_ListUsersOrders(t *testing.T) {
+	now := time.Now()
+	orders := []*models.Order{
+		{ID: 1, UserID: 1, Status: models.OrderStatusNew, UploadedAt: now},
+		{ID: 2, UserID: 1, Status: models.OrderStatusNew, UploadedAt: now.Add(time.Second)},
+		{ID: 3, UserID: 1, Status: models.OrderStatusNew, UploadedAt: now.Add(2 * time.Second)},
+	}
+
+	tests := []struct {
+		name       string
+		limit      int
+		repoResult []*models.Order
+		wantOrders []*models.Order
+		wantNext   *models.Cursor
+	}{
+		{
+			name:       "more pages",
+			limit:      2,
+			repoResult: orders,
+			wantOrders: orders[:2],
+			wantNext:   &models.Cursor{Time: orders[1].UploadedAt, ID: 2},
+		},
+		{
+			name:       "last page",
+			limit:      3,
+			repoResult: orders,
+			wantOrders: orders,
+			wantNext:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderRepo := mocks.NewOrderRepo(t)
+			s := New(mocks.NewUserRepo(t), orderRepo, mocks.NewLedgerRepo(t), mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
+			orderRepo.On("List", mock.Anything, 1, models.ListFilter{Limit: tt.limit + 1}).Return(tt.repoResult, nil)
+
+			page, err := s.ListUsersOrders(context.Background(), 1, models.ListFilter{Limit: tt.limit})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantOrders, page.Orders)
+			assert.Equal(t, tt.wantNext, page.Next)
+		})
+	}
+}
+
+func TestStorage_ListUsersWithdrawals(t *testing.T) {
+	now := time.Now()
+	withdrawals := []*models.Withdrawal{
+		{OrderID: 1, UserID: 1, Sum: money.FromInt(10), ProcessedAt: now},
+		{OrderID: 2, UserID: 1, Sum: money.FromInt(20), ProcessedAt: now.Add(time.Second)},
+	}
+	ledgerRepo := mocks.NewLedgerRepo(t)
+	s := New(mocks.NewUserRepo(t), mocks.NewOrderRepo(t), ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
+	ledgerRepo.On("ListWithdrawals", mock.Anything, 1, models.ListFilter{Limit: 2}).Return(withdrawals, nil)
+
+	page, err := s.ListUsersWithdrawals(context.Background(), 1, models.ListFilter{Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, withdrawals[:1], page.Withdrawals)
+	assert.Equal(t, &models.Cursor{Time: now, ID: 1}, page.Next)
+}
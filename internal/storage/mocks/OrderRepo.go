@@ -4,9 +4,11 @@ package mocks
 
 import (
 	context "context"
+	time "time"
 
 	mock "github.com/stretchr/testify/mock"
 	models "github.com/vindosVP/loyalty-system/internal/models"
+	money "github.com/vindosVP/loyalty-system/pkg/money"
 )
 
 // OrderRepo is an autogenerated mock type for the OrderRepo type
@@ -14,6 +16,65 @@ type OrderRepo struct {
 	mock.Mock
 }
 
+// ClaimUnprocessedOrders provides a mock function with given fields: ctx, owner, limit, lease
+func (_m *OrderRepo) ClaimUnprocessedOrders(ctx context.Context, owner string, limit int, lease time.Duration) ([]int, error) {
+	ret := _m.Called(ctx, owner, limit, lease)
+
+	var r0 []int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, time.Duration) ([]int, error)); ok {
+		return rf(ctx, owner, limit, lease)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, time.Duration) []int); ok {
+		r0 = rf(ctx, owner, limit, lease)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, time.Duration) error); ok {
+		r1 = rf(ctx, owner, limit, lease)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountByStatus provides a mock function with given fields: ctx, statuses
+func (_m *OrderRepo) CountByStatus(ctx context.Context, statuses ...string) (map[string]int64, error) {
+	_va := make([]interface{}, len(statuses))
+	for _i := range statuses {
+		_va[_i] = statuses[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 map[string]int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, ...string) (map[string]int64, error)); ok {
+		return rf(ctx, statuses...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, ...string) map[string]int64); ok {
+		r0 = rf(ctx, statuses...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]int64)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, ...string) error); ok {
+		r1 = rf(ctx, statuses...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Create provides a mock function with given fields: ctx, order
 func (_m *OrderRepo) Create(ctx context.Context, order *models.Order) (*models.Order, error) {
 	ret := _m.Called(ctx, order)
@@ -90,45 +151,21 @@ func (_m *OrderRepo) GetByID(ctx context.Context, id int) (*models.Order, error)
 	return r0, r1
 }
 
-// GetUnprocessedOrders provides a mock function with given fields: ctx
-func (_m *OrderRepo) GetUnprocessedOrders(ctx context.Context) ([]int, error) {
-	ret := _m.Called(ctx)
-
-	var r0 []int
-	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context) ([]int, error)); ok {
-		return rf(ctx)
-	}
-	if rf, ok := ret.Get(0).(func(context.Context) []int); ok {
-		r0 = rf(ctx)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]int)
-		}
-	}
-
-	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = rf(ctx)
-	} else {
-		r1 = ret.Error(1)
-	}
-
-	return r0, r1
-}
-
-// GetUsersCurrentBalance provides a mock function with given fields: ctx, userID
-func (_m *OrderRepo) GetUsersCurrentBalance(ctx context.Context, userID int) (float64, error) {
+// GetUsersOrders provides a mock function with given fields: ctx, userID
+func (_m *OrderRepo) GetUsersOrders(ctx context.Context, userID int) ([]*models.Order, error) {
 	ret := _m.Called(ctx, userID)
 
-	var r0 float64
+	var r0 []*models.Order
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, int) (float64, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]*models.Order, error)); ok {
 		return rf(ctx, userID)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, int) float64); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, int) []*models.Order); ok {
 		r0 = rf(ctx, userID)
 	} else {
-		r0 = ret.Get(0).(float64)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Order)
+		}
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
@@ -140,25 +177,25 @@ func (_m *OrderRepo) GetUsersCurrentBalance(ctx context.Context, userID int) (fl
 	return r0, r1
 }
 
-// GetUsersOrders provides a mock function with given fields: ctx, userID
-func (_m *OrderRepo) GetUsersOrders(ctx context.Context, userID int) ([]*models.Order, error) {
-	ret := _m.Called(ctx, userID)
+// List provides a mock function with given fields: ctx, userID, filter
+func (_m *OrderRepo) List(ctx context.Context, userID int, filter models.ListFilter) ([]*models.Order, error) {
+	ret := _m.Called(ctx, userID, filter)
 
 	var r0 []*models.Order
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, int) ([]*models.Order, error)); ok {
-		return rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, int, models.ListFilter) ([]*models.Order, error)); ok {
+		return rf(ctx, userID, filter)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, int) []*models.Order); ok {
-		r0 = rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, int, models.ListFilter) []*models.Order); ok {
+		r0 = rf(ctx, userID, filter)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*models.Order)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
-		r1 = rf(ctx, userID)
+	if rf, ok := ret.Get(1).(func(context.Context, int, models.ListFilter) error); ok {
+		r1 = rf(ctx, userID, filter)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -166,25 +203,23 @@ func (_m *OrderRepo) GetUsersOrders(ctx context.Context, userID int) ([]*models.
 	return r0, r1
 }
 
-// GetUsersWithdrawals provides a mock function with given fields: ctx, userID
-func (_m *OrderRepo) GetUsersWithdrawals(ctx context.Context, userID int) ([]*models.Order, error) {
-	ret := _m.Called(ctx, userID)
+// NumberOwner provides a mock function with given fields: ctx, id
+func (_m *OrderRepo) NumberOwner(ctx context.Context, id int) (int, error) {
+	ret := _m.Called(ctx, id)
 
-	var r0 []*models.Order
+	var r0 int
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, int) ([]*models.Order, error)); ok {
-		return rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, int) (int, error)); ok {
+		return rf(ctx, id)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, int) []*models.Order); ok {
-		r0 = rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, int) int); ok {
+		r0 = rf(ctx, id)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]*models.Order)
-		}
+		r0 = ret.Get(0).(int)
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
-		r1 = rf(ctx, userID)
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -192,23 +227,25 @@ func (_m *OrderRepo) GetUsersWithdrawals(ctx context.Context, userID int) ([]*mo
 	return r0, r1
 }
 
-// GetUsersWithdrawnBalance provides a mock function with given fields: ctx, userID
-func (_m *OrderRepo) GetUsersWithdrawnBalance(ctx context.Context, userID int) (float64, error) {
-	ret := _m.Called(ctx, userID)
+// Requeue provides a mock function with given fields: ctx, id
+func (_m *OrderRepo) Requeue(ctx context.Context, id int) (*models.Order, error) {
+	ret := _m.Called(ctx, id)
 
-	var r0 float64
+	var r0 *models.Order
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, int) (float64, error)); ok {
-		return rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, int) (*models.Order, error)); ok {
+		return rf(ctx, id)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, int) float64); ok {
-		r0 = rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, int) *models.Order); ok {
+		r0 = rf(ctx, id)
 	} else {
-		r0 = ret.Get(0).(float64)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Order)
+		}
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
-		r1 = rf(ctx, userID)
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -216,25 +253,25 @@ func (_m *OrderRepo) GetUsersWithdrawnBalance(ctx context.Context, userID int) (
 	return r0, r1
 }
 
-// UpdateOrder provides a mock function with given fields: ctx, id, status, sum
-func (_m *OrderRepo) UpdateOrder(ctx context.Context, id int, status string, sum float64) (*models.Order, error) {
-	ret := _m.Called(ctx, id, status, sum)
+// UpdateOrder provides a mock function with given fields: ctx, id, owner, status, sum
+func (_m *OrderRepo) UpdateOrder(ctx context.Context, id int, owner string, status string, sum money.Amount) (*models.Order, error) {
+	ret := _m.Called(ctx, id, owner, status, sum)
 
 	var r0 *models.Order
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, int, string, float64) (*models.Order, error)); ok {
-		return rf(ctx, id, status, sum)
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string, money.Amount) (*models.Order, error)); ok {
+		return rf(ctx, id, owner, status, sum)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, int, string, float64) *models.Order); ok {
-		r0 = rf(ctx, id, status, sum)
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string, money.Amount) *models.Order); ok {
+		r0 = rf(ctx, id, owner, status, sum)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.Order)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, int, string, float64) error); ok {
-		r1 = rf(ctx, id, status, sum)
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, string, money.Amount) error); ok {
+		r1 = rf(ctx, id, owner, status, sum)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -242,25 +279,25 @@ func (_m *OrderRepo) UpdateOrder(ctx context.Context, id int, status string, sum
 	return r0, r1
 }
 
-// UpdateOrderStatus provides a mock function with given fields: ctx, id, status
-func (_m *OrderRepo) UpdateOrderStatus(ctx context.Context, id int, status string) (*models.Order, error) {
-	ret := _m.Called(ctx, id, status)
+// UpdateOrderStatus provides a mock function with given fields: ctx, id, owner, status
+func (_m *OrderRepo) UpdateOrderStatus(ctx context.Context, id int, owner string, status string) (*models.Order, error) {
+	ret := _m.Called(ctx, id, owner, status)
 
 	var r0 *models.Order
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, int, string) (*models.Order, error)); ok {
-		return rf(ctx, id, status)
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string) (*models.Order, error)); ok {
+		return rf(ctx, id, owner, status)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, int, string) *models.Order); ok {
-		r0 = rf(ctx, id, status)
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string) *models.Order); ok {
+		r0 = rf(ctx, id, owner, status)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.Order)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, int, string) error); ok {
-		r1 = rf(ctx, id, status)
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, string) error); ok {
+		r1 = rf(ctx, id, owner, status)
 	} else {
 		r1 = ret.Error(1)
 	}
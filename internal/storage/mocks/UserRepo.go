@@ -14,6 +14,30 @@ type UserRepo struct {
 	mock.Mock
 }
 
+// AddRole provides a mock function with given fields: ctx, login, role
+func (_m *UserRepo) AddRole(ctx context.Context, login string, role string) (bool, error) {
+	ret := _m.Called(ctx, login, role)
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (bool, error)); ok {
+		return rf(ctx, login, role)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) bool); ok {
+		r0 = rf(ctx, login, role)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, login, role)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Create provides a mock function with given fields: ctx, user
 func (_m *UserRepo) Create(ctx context.Context, user *models.User) (*models.User, error) {
 	ret := _m.Called(ctx, user)
@@ -116,6 +140,32 @@ func (_m *UserRepo) GetByLogin(ctx context.Context, login string) (*models.User,
 	return r0, r1
 }
 
+// Search provides a mock function with given fields: ctx, login, limit
+func (_m *UserRepo) Search(ctx context.Context, login string, limit int) ([]*models.User, error) {
+	ret := _m.Called(ctx, login, limit)
+
+	var r0 []*models.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) ([]*models.User, error)); ok {
+		return rf(ctx, login, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) []*models.User); ok {
+		r0 = rf(ctx, login, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, login, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type mockConstructorTestingTNewUserRepo interface {
 	mock.TestingT
 	Cleanup(func())
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/tracing"
+	"sync"
+	"time"
+)
+
+// sessionCacheTTL bounds how long a session revoked by another instance may
+// still be accepted here. Revocations made by this instance apply at once.
+const sessionCacheTTL = 30 * time.Second
+
+type sessionCacheEntry struct {
+	active    bool
+	expiresAt time.Time
+}
+
+type sessionCache struct {
+	mu        sync.RWMutex
+	ttl       time.Duration
+	entries   map[string]sessionCacheEntry
+	lastSweep time.Time
+}
+
+func newSessionCache(ttl time.Duration) *sessionCache {
+	return &sessionCache{ttl: ttl, entries: make(map[string]sessionCacheEntry)}
+}
+
+func (c *sessionCache) get(id string, now time.Time) (active bool, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[id]
+	if !ok || !now.Before(entry.expiresAt) {
+		return false, false
+	}
+	return entry.active, true
+}
+
+func (c *sessionCache) set(id string, active bool, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := time.Now(); now.Sub(c.lastSweep) >= c.ttl {
+		for key, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, key)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.entries[id] = sessionCacheEntry{active: active, expiresAt: expiresAt}
+}
+
+func (s *Storage) CreateSession(ctx context.Context, session *models.Session) (*models.Session, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.CreateSession")
+	defer span.End()
+	newSession, err := s.sessionRepo.Create(ctx, session)
+	if err != nil {
+		return nil, fmt.Errorf("s.sessionRepo.Create: %w", err)
+	}
+	return newSession, nil
+}
+
+// RotateSession swaps the refresh token of a session. It returns
+// ErrSessionNotFound if the old token has already been used or the session
+// is no longer active.
+func (s *Storage) RotateSession(ctx context.Context, id string, oldHash string, newHash string, expiresAt time.Time) (*models.Session, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.RotateSession")
+	defer span.End()
+	session, err := s.sessionRepo.Rotate(ctx, id, oldHash, newHash, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("s.sessionRepo.Rotate: %w", err)
+	}
+	return session, nil
+}
+
+func (s *Storage) RevokeSession(ctx context.Context, id string) error {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.RevokeSession")
+	defer span.End()
+	err := s.sessionRepo.Revoke(ctx, id)
+	if err != nil {
+		return fmt.Errorf("s.sessionRepo.Revoke: %w", err)
+	}
+	s.sessionCache.set(id, false, time.Now().Add(s.sessionCache.ttl))
+	return nil
+}
+
+func (s *Storage) RevokeUserSessions(ctx context.Context, userID int) error {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.RevokeUserSessions")
+	defer span.End()
+	ids, err := s.sessionRepo.RevokeAllForUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("s.sessionRepo.RevokeAllForUser: %w", err)
+	}
+	for _, id := range ids {
+		s.sessionCache.set(id, false, time.Now().Add(s.sessionCache.ttl))
+	}
+	return nil
+}
+
+func (s *Storage) IsSessionActive(ctx context.Context, id string) (bool, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.IsSessionActive")
+	defer span.End()
+	now := time.Now()
+	if active, ok := s.sessionCache.get(id, now); ok {
+		return active, nil
+	}
+	session, err := s.sessionRepo.GetByID(ctx, id)
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return false, fmt.Errorf("s.sessionRepo.GetByID: %w", err)
+	}
+	active := session != nil && session.Active(now)
+	cacheUntil := now.Add(s.sessionCache.ttl)
+	if active && session.ExpiresAt.Before(cacheUntil) {
+		cacheUntil = session.ExpiresAt
+	}
+	s.sessionCache.set(id, active, cacheUntil)
+	return active, nil
+}
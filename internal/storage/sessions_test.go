@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage/mocks"
+	"testing"
+	"time"
+)
+
+func TestStorage_IsSessionActive(t *testing.T) {
+	unexpectedError := errors.New("unexpected error")
+	revokedAt := time.Now().Add(-time.Minute)
+
+	type sessionRepoGetByIDMock struct {
+		needed bool
+		result *models.Session
+		err    error
+	}
+	type want struct {
+		result bool
+		err    error
+	}
+
+	tests := []struct {
+		name                   string
+		sessionRepoGetByIDMock sessionRepoGetByIDMock
+		want                   want
+	}{
+		{
+			name: "active",
+			sessionRepoGetByIDMock: sessionRepoGetByIDMock{
+				needed: true,
+				result: &models.Session{ID: "someSession", UserID: 1, ExpiresAt: time.Now().Add(time.Hour)},
+				err:    nil,
+			},
+			want: want{result: true, err: nil},
+		},
+		{
+			name: "revoked",
+			sessionRepoGetByIDMock: sessionRepoGetByIDMock{
+				needed: true,
+				result: &models.Session{ID: "someSession", UserID: 1, ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt},
+				err:    nil,
+			},
+			want: want{result: false, err: nil},
+		},
+		{
+			name: "expired",
+			sessionRepoGetByIDMock: sessionRepoGetByIDMock{
+				needed: true,
+				result: &models.Session{ID: "someSession", UserID: 1, ExpiresAt: time.Now().Add(-time.Hour)},
+				err:    nil,
+			},
+			want: want{result: false, err: nil},
+		},
+		{
+			name: "not found",
+			sessionRepoGetByIDMock: sessionRepoGetByIDMock{
+				needed: true,
+				result: nil,
+				err:    ErrSessionNotFound,
+			},
+			want: want{result: false, err: nil},
+		},
+		{
+			name: "sessionRepo.GetByID unexpected error",
+			sessionRepoGetByIDMock: sessionRepoGetByIDMock{
+				needed: true,
+				result: nil,
+				err:    unexpectedError,
+			},
+			want: want{result: false, err: unexpectedError},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sessionRepo := mocks.NewSessionRepo(t)
+			s := New(mocks.NewUserRepo(t), mocks.NewOrderRepo(t), mocks.NewLedgerRepo(t), sessionRepo, mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
+			if tt.sessionRepoGetByIDMock.needed {
+				sessionRepo.On("GetByID", mock.Anything, "someSession").Return(tt.sessionRepoGetByIDMock.result, tt.sessionRepoGetByIDMock.err).Once()
+			}
+
+			result, err := s.IsSessionActive(ctx, "someSession")
+			if tt.want.err != nil {
+				assert.ErrorIs(t, err, tt.want.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.result, result)
+
+			// The second check is served from the cache.
+			result, err = s.IsSessionActive(ctx, "someSession")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.result, result)
+		})
+	}
+}
+
+func TestStorage_RevokeSession(t *testing.T) {
+	ctx := context.Background()
+	sessionRepo := mocks.NewSessionRepo(t)
+	s := New(mocks.NewUserRepo(t), mocks.NewOrderRepo(t), mocks.NewLedgerRepo(t), sessionRepo, mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
+	sessionRepo.On("GetByID", mock.Anything, "someSession").Return(&models.Session{ID: "someSession", UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}, nil).Once()
+	sessionRepo.On("Revoke", mock.Anything, "someSession").Return(nil).Once()
+	sessionRepo.On("RevokeAllForUser", mock.Anything, 1).Return([]string{"otherSession"}, nil).Once()
+
+	active, err := s.IsSessionActive(ctx, "someSession")
+	require.NoError(t, err)
+	assert.True(t, active)
+
+	require.NoError(t, s.RevokeSession(ctx, "someSession"))
+	active, err = s.IsSessionActive(ctx, "someSession")
+	require.NoError(t, err)
+	assert.False(t, active)
+
+	require.NoError(t, s.RevokeUserSessions(ctx, 1))
+	active, err = s.IsSessionActive(ctx, "otherSession")
+	require.NoError(t, err)
+	assert.False(t, active)
+}
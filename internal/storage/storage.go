@@ -2,8 +2,15 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/metrics"
 	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/tracing"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"go.uber.org/zap"
+	"time"
 )
 
 //go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=UserRepo
@@ -12,6 +19,8 @@ type UserRepo interface {
 	GetByLogin(ctx context.Context, login string) (*models.User, error)
 	GetByID(ctx context.Context, id int) (*models.User, error)
 	Exists(ctx context.Context, login string) (bool, error)
+	Search(ctx context.Context, login string, limit int) ([]*models.User, error)
+	AddRole(ctx context.Context, login string, role string) (bool, error)
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=OrderRepo
@@ -19,40 +28,173 @@ type OrderRepo interface {
 	Create(ctx context.Context, order *models.Order) (*models.Order, error)
 	GetByID(ctx context.Context, id int) (*models.Order, error)
 	Exists(ctx context.Context, id int) (bool, error)
+	// NumberOwner returns the user an order number belongs to, whether it was
+	// uploaded as an order or spent on a withdrawal, or ErrOrderNotFound if
+	// it's free. Within a transaction no one else can take the number until
+	// the transaction ends.
+	NumberOwner(ctx context.Context, id int) (int, error)
 	GetUsersOrders(ctx context.Context, userID int) ([]*models.Order, error)
-	GetUsersCurrentBalance(ctx context.Context, userID int) (float64, error)
-	GetUsersWithdrawnBalance(ctx context.Context, userID int) (float64, error)
-	GetUsersWithdrawals(ctx context.Context, userID int) ([]*models.Order, error)
-	GetUnprocessedOrders(ctx context.Context) ([]int, error)
-	UpdateOrder(ctx context.Context, id int, status string, sum float64) (*models.Order, error)
-	UpdateOrderStatus(ctx context.Context, id int, status string) (*models.Order, error)
+	List(ctx context.Context, userID int, filter models.ListFilter) ([]*models.Order, error)
+	ClaimUnprocessedOrders(ctx context.Context, owner string, limit int, lease time.Duration) ([]int, error)
+	UpdateOrder(ctx context.Context, id int, owner string, status string, sum money.Amount) (*models.Order, error)
+	UpdateOrderStatus(ctx context.Context, id int, owner string, status string) (*models.Order, error)
+	Requeue(ctx context.Context, id int) (*models.Order, error)
+	CountByStatus(ctx context.Context, statuses ...string) (map[string]int64, error)
 }
 
-type Storage struct {
-	userRepo  UserRepo
-	orderRepo OrderRepo
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=LedgerRepo
+type LedgerRepo interface {
+	Post(ctx context.Context, entry *models.Entry) error
+	GetBalance(ctx context.Context, userID int) (money.Amount, error)
+	GetWithdrawnTotal(ctx context.Context, userID int) (money.Amount, error)
+	Withdraw(ctx context.Context, withdrawal *models.Withdrawal) error
+	Adjust(ctx context.Context, adjustment *models.Adjustment) (money.Amount, error)
+	GetWithdrawal(ctx context.Context, orderID int) (*models.Withdrawal, error)
+	GetWithdrawals(ctx context.Context, userID int) ([]*models.Withdrawal, error)
+	ListWithdrawals(ctx context.Context, userID int, filter models.ListFilter) ([]*models.Withdrawal, error)
+	GetTrialBalance(ctx context.Context) ([]*models.AccountBalance, error)
+	ExpirePoints(ctx context.Context, now time.Time, limit int) ([]*models.Entry, error)
+	ScheduleExpiry(ctx context.Context, expiresAt time.Time) (int64, error)
+	ListExpirations(ctx context.Context, userID int, now time.Time, until *time.Time) ([]*models.Expiration, error)
+	GetAccruedTotal(ctx context.Context, userID int, since time.Time) (money.Amount, error)
 }
 
-func New(ur UserRepo, or OrderRepo) *Storage {
-	return &Storage{userRepo: ur, orderRepo: or}
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=SessionRepo
+type SessionRepo interface {
+	Create(ctx context.Context, session *models.Session) (*models.Session, error)
+	GetByID(ctx context.Context, id string) (*models.Session, error)
+	Rotate(ctx context.Context, id string, oldHash string, newHash string, expiresAt time.Time) (*models.Session, error)
+	Revoke(ctx context.Context, id string) error
+	RevokeAllForUser(ctx context.Context, userID int) ([]string, error)
 }
 
-func (s *Storage) CreateUser(ctx context.Context, user *models.User) (*models.User, error) {
-	userExists, err := s.userRepo.Exists(ctx, user.Login)
-	if err != nil {
-		return nil, fmt.Errorf("s.userRepo.Exists: %w", err)
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=AuditRepo
+type AuditRepo interface {
+	Append(ctx context.Context, event *models.AuditEvent) error
+	Query(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, error)
+	Verify(ctx context.Context) (*models.AuditVerification, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=IdempotencyRepo
+type IdempotencyRepo interface {
+	Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error)
+	Complete(ctx context.Context, key *models.IdempotencyKey) error
+	Release(ctx context.Context, key *models.IdempotencyKey) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=WebhookRepo
+type WebhookRepo interface {
+	CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) (*models.WebhookEndpoint, error)
+	GetEndpoint(ctx context.Context, id int) (*models.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, userID int) ([]*models.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, id int) error
+	ClaimDeliveries(ctx context.Context, owner string, limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error)
+	ReplayDeadDeliveries(ctx context.Context, endpointID int, deliveryID int64) (int64, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=TierRepo
+type TierRepo interface {
+	ListRules(ctx context.Context) ([]*models.TierRule, error)
+	UpdateRule(ctx context.Context, rule *models.TierRule) (*models.TierRule, error)
+	GetHistory(ctx context.Context, userID int) ([]*models.TierChange, error)
+	RecordChange(ctx context.Context, change *models.TierChange) (bool, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=EventPublisher
+type EventPublisher interface {
+	Publish(ctx context.Context, event *models.UserEvent) error
+}
+
+// Repos are the repos a unit of work runs on.
+type Repos struct {
+	Users  UserRepo
+	Orders OrderRepo
+	Ledger LedgerRepo
+	Tiers  TierRepo
+}
+
+// Tx runs units of work atomically. Repos begin their own transactions as
+// usual; within a unit of work those are nested in it.
+type Tx interface {
+	// WithinTx runs f with repos bound to a new transaction, which is
+	// committed if f returns nil and rolled back otherwise.
+	WithinTx(ctx context.Context, f func(repos *Repos) error) error
+}
+
+type Storage struct {
+	// Expiry is applied to points accrued for processed orders.
+	Expiry models.ExpiryPolicy
+
+	userRepo     UserRepo
+	orderRepo    OrderRepo
+	ledgerRepo   LedgerRepo
+	sessionRepo  SessionRepo
+	auditRepo    AuditRepo
+	idemRepo     IdempotencyRepo
+	webhookRepo  WebhookRepo
+	tierRepo     TierRepo
+	tx           Tx
+	events       EventPublisher
+	sessionCache *sessionCache
+}
+
+func New(ur UserRepo, or OrderRepo, lr LedgerRepo, sr SessionRepo, ar AuditRepo, ir IdempotencyRepo, wr WebhookRepo, tr TierRepo, tx Tx, pub EventPublisher) *Storage {
+	return &Storage{
+		userRepo:     ur,
+		orderRepo:    or,
+		ledgerRepo:   lr,
+		sessionRepo:  sr,
+		auditRepo:    ar,
+		idemRepo:     ir,
+		webhookRepo:  wr,
+		tierRepo:     tr,
+		tx:           tx,
+		events:       pub,
+		sessionCache: newSessionCache(sessionCacheTTL),
 	}
-	if userExists {
-		return nil, ErrUserAlreadyExists
+}
+
+// withinTx runs f as a unit of work. Without a Tx, as in unit tests, f runs
+// on the storage's own repos.
+func (s *Storage) withinTx(ctx context.Context, f func(repos *Repos) error) error {
+	if s.tx == nil {
+		return f(&Repos{Users: s.userRepo, Orders: s.orderRepo, Ledger: s.ledgerRepo, Tiers: s.tierRepo})
 	}
-	newUser, err := s.userRepo.Create(ctx, user)
+	return s.tx.WithinTx(ctx, f)
+}
+
+func (s *Storage) CreateUser(ctx context.Context, user *models.User) (*models.User, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.CreateUser")
+	defer span.End()
+	var newUser *models.User
+	err := s.withinTx(ctx, func(repos *Repos) error {
+		userExists, err := repos.Users.Exists(ctx, user.Login)
+		if err != nil {
+			return fmt.Errorf("s.userRepo.Exists: %w", err)
+		}
+		if userExists {
+			return ErrUserAlreadyExists
+		}
+		// A concurrent registration of the same login fails here with
+		// ErrUserAlreadyExists.
+		newUser, err = repos.Users.Create(ctx, user)
+		if err != nil {
+			return fmt.Errorf("s.userRepo.Create: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("s.userRepo.Create: %w", err)
+		return nil, err
 	}
 	return newUser, nil
 }
 
 func (s *Storage) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.GetUserByLogin")
+	defer span.End()
 	userExists, err := s.userRepo.Exists(ctx, login)
 	if err != nil {
 		return nil, fmt.Errorf("s.userRepo.Exists: %w", err)
@@ -67,30 +209,66 @@ func (s *Storage) GetUserByLogin(ctx context.Context, login string) (*models.Use
 	return user, nil
 }
 
-func (s *Storage) CreateOrder(ctx context.Context, order *models.Order) (*models.Order, error) {
-	orderExists, err := s.orderRepo.Exists(ctx, order.ID)
+func (s *Storage) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.GetUserByID")
+	defer span.End()
+	user, err := s.userRepo.GetByID(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("s.orderRepo.Exists: %w", err)
+		return nil, fmt.Errorf("s.userRepo.GetByID: %w", err)
 	}
-	if orderExists {
-		existingOrder, err := s.orderRepo.GetByID(ctx, order.ID)
-		if err != nil {
-			return nil, fmt.Errorf("s.orderRepo.GetByID: %w", err)
+	return user, nil
+}
+
+func (s *Storage) CreateOrder(ctx context.Context, order *models.Order) (*models.Order, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.CreateOrder")
+	defer span.End()
+	var newOrder *models.Order
+	err := s.withinTx(ctx, func(repos *Repos) error {
+		if err := checkNumberFree(ctx, repos, order.ID, order.UserID); err != nil {
+			return err
 		}
-		if existingOrder.UserID == order.UserID {
-			return nil, ErrOrderAlreadyExists
-		} else {
-			return nil, ErrOrderCreatedByOtherUser
+		var err error
+		newOrder, err = repos.Orders.Create(ctx, order)
+		if errors.Is(err, ErrOrderAlreadyExists) {
+			// Uploaded concurrently, possibly by someone else. The failed
+			// insert only rolled back its own nested transaction.
+			if err := checkNumberFree(ctx, repos, order.ID, order.UserID); err != nil {
+				return err
+			}
+			return ErrOrderAlreadyExists
 		}
-	}
-	newOrder, err := s.orderRepo.Create(ctx, order)
+		if err != nil {
+			return fmt.Errorf("s.orderRepo.Create: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("s.orderRepo.Create: %w", err)
+		return nil, err
 	}
+	s.publishOrder(ctx, newOrder)
 	return newOrder, nil
 }
 
+// checkNumberFree returns ErrOrderAlreadyExists if the order number is taken
+// by the user and ErrOrderCreatedByOtherUser if it's taken by someone else.
+// Orders and withdrawals share the numbers, so either can take one.
+func checkNumberFree(ctx context.Context, repos *Repos, id int, userID int) error {
+	owner, err := repos.Orders.NumberOwner(ctx, id)
+	if errors.Is(err, ErrOrderNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("s.orderRepo.NumberOwner: %w", err)
+	}
+	if owner == userID {
+		return ErrOrderAlreadyExists
+	}
+	return ErrOrderCreatedByOtherUser
+}
+
 func (s *Storage) GetUsersOrders(ctx context.Context, userID int) ([]*models.Order, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.GetUsersOrders")
+	defer span.End()
 	orders, err := s.orderRepo.GetUsersOrders(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("s.orderRepo.GetUsersOrders: %w", err)
@@ -98,50 +276,255 @@ func (s *Storage) GetUsersOrders(ctx context.Context, userID int) ([]*models.Ord
 	return orders, nil
 }
 
-func (s *Storage) GetUsersCurrentBalance(ctx context.Context, userID int) (float64, error) {
-	balance, err := s.orderRepo.GetUsersCurrentBalance(ctx, userID)
+// ListUsersOrders returns a page of the user's orders. The repo is asked for
+// one extra row to find out whether there's a next page.
+func (s *Storage) ListUsersOrders(ctx context.Context, userID int, filter models.ListFilter) (*models.OrdersPage, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.ListUsersOrders")
+	defer span.End()
+	limit := filter.Limit
+	filter.Limit++
+	orders, err := s.orderRepo.List(ctx, userID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("s.orderRepo.List: %w", err)
+	}
+	page := &models.OrdersPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		last := page.Orders[limit-1]
+		page.Next = &models.Cursor{Time: last.UploadedAt, ID: last.ID}
+	}
+	return page, nil
+}
+
+// CreateWithdrawal checks the balance and records the withdrawal atomically.
+// It returns ErrInsufficientFunds if the user's balance doesn't cover the sum,
+// and ErrOrderAlreadyExists or ErrOrderCreatedByOtherUser if the number was
+// already uploaded as an order or withdrawn against.
+func (s *Storage) CreateWithdrawal(ctx context.Context, withdrawal *models.Withdrawal) (*models.Withdrawal, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.CreateWithdrawal")
+	defer span.End()
+	var newWithdrawal *models.Withdrawal
+	err := s.withinTx(ctx, func(repos *Repos) error {
+		if err := checkNumberFree(ctx, repos, withdrawal.OrderID, withdrawal.UserID); err != nil {
+			return err
+		}
+		err := repos.Ledger.Withdraw(ctx, withdrawal)
+		if err != nil {
+			return fmt.Errorf("s.ledgerRepo.Withdraw: %w", err)
+		}
+		newWithdrawal, err = repos.Ledger.GetWithdrawal(ctx, withdrawal.OrderID)
+		if err != nil {
+			return fmt.Errorf("s.ledgerRepo.GetWithdrawal: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return 0, fmt.Errorf("s.orderRepo.GetUsersBalance: %w", err)
+		return nil, err
+	}
+	metrics.PointsWithdrawn.Add(withdrawal.Sum.Float64())
+	s.publishBalance(ctx, withdrawal.UserID)
+	return newWithdrawal, nil
+}
+
+func (s *Storage) GetUsersCurrentBalance(ctx context.Context, userID int) (money.Amount, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.GetUsersCurrentBalance")
+	defer span.End()
+	balance, err := s.ledgerRepo.GetBalance(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("s.ledgerRepo.GetBalance: %w", err)
 	}
 	return balance, nil
 }
 
-func (s *Storage) GetUsersWithdrawnBalance(ctx context.Context, userID int) (float64, error) {
-	balance, err := s.orderRepo.GetUsersWithdrawnBalance(ctx, userID)
+func (s *Storage) GetUsersWithdrawnBalance(ctx context.Context, userID int) (money.Amount, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.GetUsersWithdrawnBalance")
+	defer span.End()
+	balance, err := s.ledgerRepo.GetWithdrawnTotal(ctx, userID)
 	if err != nil {
-		return 0, fmt.Errorf("s.orderRepo.GetUsersWithdrawnBalance: %w", err)
+		return 0, fmt.Errorf("s.ledgerRepo.GetWithdrawnTotal: %w", err)
 	}
 	return balance, nil
 }
 
-func (s *Storage) GetUsersWithdrawals(ctx context.Context, userID int) ([]*models.Order, error) {
-	withdrawals, err := s.orderRepo.GetUsersWithdrawals(ctx, userID)
+func (s *Storage) GetUsersWithdrawals(ctx context.Context, userID int) ([]*models.Withdrawal, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.GetUsersWithdrawals")
+	defer span.End()
+	withdrawals, err := s.ledgerRepo.GetWithdrawals(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("s.orderRepo.GetUsersWithdrawals: %w", err)
+		return nil, fmt.Errorf("s.ledgerRepo.GetWithdrawals: %w", err)
 	}
 	return withdrawals, nil
 }
 
-func (s *Storage) GetUnprocessedOrders(ctx context.Context) ([]int, error) {
-	ids, err := s.orderRepo.GetUnprocessedOrders(ctx)
+// ListUsersWithdrawals returns a page of the user's withdrawals, see ListUsersOrders.
+func (s *Storage) ListUsersWithdrawals(ctx context.Context, userID int, filter models.ListFilter) (*models.WithdrawalsPage, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.ListUsersWithdrawals")
+	defer span.End()
+	limit := filter.Limit
+	filter.Limit++
+	withdrawals, err := s.ledgerRepo.ListWithdrawals(ctx, userID, filter)
 	if err != nil {
-		return nil, fmt.Errorf("s.orderRepo.GetUnprocessedOrders: %w", err)
+		return nil, fmt.Errorf("s.ledgerRepo.ListWithdrawals: %w", err)
+	}
+	page := &models.WithdrawalsPage{Withdrawals: withdrawals}
+	if len(withdrawals) > limit {
+		page.Withdrawals = withdrawals[:limit]
+		last := page.Withdrawals[limit-1]
+		page.Next = &models.Cursor{Time: last.ProcessedAt, ID: last.OrderID}
+	}
+	return page, nil
+}
+
+func (s *Storage) GetTrialBalance(ctx context.Context) ([]*models.AccountBalance, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.GetTrialBalance")
+	defer span.End()
+	balances, err := s.ledgerRepo.GetTrialBalance(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.ledgerRepo.GetTrialBalance: %w", err)
+	}
+	return balances, nil
+}
+
+func (s *Storage) ClaimUnprocessedOrders(ctx context.Context, owner string, limit int, lease time.Duration) ([]int, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.ClaimUnprocessedOrders")
+	defer span.End()
+	ids, err := s.orderRepo.ClaimUnprocessedOrders(ctx, owner, limit, lease)
+	if err != nil {
+		return nil, fmt.Errorf("s.orderRepo.ClaimUnprocessedOrders: %w", err)
 	}
 	return ids, nil
 }
 
-func (s *Storage) UpdateOrder(ctx context.Context, id int, status string, sum float64) (*models.Order, error) {
-	order, err := s.orderRepo.UpdateOrder(ctx, id, status, sum)
+// UpdateOrder records what the accrual system says about an order leased to
+// owner and credits its accrual. It returns ErrOrderFinal if the order is
+// already settled and ErrOrderLeaseLost if it's leased to someone else by now;
+// an empty owner skips the lease check.
+func (s *Storage) UpdateOrder(ctx context.Context, id int, owner string, status string, sum money.Amount) (*models.Order, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.UpdateOrder")
+	defer span.End()
+	// The accrual, the status change and the tier it leads to are committed
+	// together, so an order is never processed without its points or
+	// credited twice. The order row is locked first and then the user, which
+	// every ledger write takes before the user's account, so nothing locks
+	// them the other way round.
+	accrued := status == models.OrderStatusProcessed && sum > 0
+	var order *models.Order
+	var change *models.TierChange
+	err := s.withinTx(ctx, func(repos *Repos) error {
+		credited := sum
+		if accrued {
+			owned, err := repos.Orders.GetByID(ctx, id)
+			if err != nil {
+				return fmt.Errorf("s.orderRepo.GetByID: %w", err)
+			}
+			// The accrual is multiplied at the tier the user had before it.
+			tier, _, err := tierFor(ctx, repos, owned.UserID)
+			if err != nil {
+				return fmt.Errorf("tierFor: %w", err)
+			}
+			if tier != nil {
+				if credited, err = tier.Apply(sum); err != nil {
+					return fmt.Errorf("tier.Apply: %w", err)
+				}
+			}
+		}
+		var err error
+		order, err = repos.Orders.UpdateOrder(ctx, id, owner, status, credited)
+		if err != nil {
+			return fmt.Errorf("s.orderRepo.Update: %w", err)
+		}
+		if !accrued {
+			return nil
+		}
+		entry := models.NewAccrualEntry(order.UserID, order.ID, credited)
+		entry.Accrued = sum
+		entry.ExpiresAt = s.Expiry.ExpiresAt(entry.CreatedAt)
+		if err = repos.Ledger.Post(ctx, entry); err != nil {
+			return fmt.Errorf("s.ledgerRepo.Post: %w", err)
+		}
+		if change, err = evaluateTier(ctx, repos, order.UserID); err != nil {
+			return fmt.Errorf("evaluateTier: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("s.orderRepo.Update: %w", err)
+		return nil, err
+	}
+	if accrued {
+		metrics.PointsAccrued.Add(order.Sum.Float64())
+		s.publishBalance(ctx, order.UserID)
+	}
+	if change != nil {
+		metrics.TierChanges.WithLabelValues(change.Tier).Inc()
+		logger.FromContext(ctx).Info("Tier changed", zap.Int("userId", change.UserID), zap.String("tier", change.Tier))
 	}
 	return order, nil
 }
 
-func (s *Storage) UpdateOrderStatus(ctx context.Context, id int, status string) (*models.Order, error) {
-	order, err := s.orderRepo.UpdateOrderStatus(ctx, id, status)
+func (s *Storage) CountOrdersByStatus(ctx context.Context, statuses ...string) (map[string]int64, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.CountOrdersByStatus")
+	defer span.End()
+	counts, err := s.orderRepo.CountByStatus(ctx, statuses...)
+	if err != nil {
+		return nil, fmt.Errorf("s.orderRepo.CountByStatus: %w", err)
+	}
+	return counts, nil
+}
+
+// UpdateOrderStatus is UpdateOrder for statuses that come without an accrual.
+func (s *Storage) UpdateOrderStatus(ctx context.Context, id int, owner string, status string) (*models.Order, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.UpdateOrderStatus")
+	defer span.End()
+	order, err := s.orderRepo.UpdateOrderStatus(ctx, id, owner, status)
 	if err != nil {
 		return nil, fmt.Errorf("s.orderRepo.UpdateOrderStatus: %w", err)
 	}
 	return order, nil
 }
+
+func (s *Storage) SearchUsers(ctx context.Context, login string, limit int) ([]*models.User, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.SearchUsers")
+	defer span.End()
+	users, err := s.userRepo.Search(ctx, login, limit)
+	if err != nil {
+		return nil, fmt.Errorf("s.userRepo.Search: %w", err)
+	}
+	return users, nil
+}
+
+// RequeueOrder makes the order eligible for the next accrual poll. It returns
+// ErrOrderNotFound or ErrOrderAlreadyProcessed if there's nothing to requeue.
+func (s *Storage) RequeueOrder(ctx context.Context, id int) (*models.Order, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.RequeueOrder")
+	defer span.End()
+	order, err := s.orderRepo.Requeue(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("s.orderRepo.Requeue: %w", err)
+	}
+	s.publishOrder(ctx, order)
+	return order, nil
+}
+
+// AdjustBalance posts the adjustment and returns the user's new balance. It
+// returns ErrInsufficientFunds if a debit exceeds the balance.
+func (s *Storage) AdjustBalance(ctx context.Context, adjustment *models.Adjustment) (money.Amount, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.AdjustBalance")
+	defer span.End()
+	var balance money.Amount
+	err := s.withinTx(ctx, func(repos *Repos) error {
+		_, err := repos.Users.GetByID(ctx, adjustment.UserID)
+		if err != nil {
+			return fmt.Errorf("s.userRepo.GetByID: %w", err)
+		}
+		balance, err = repos.Ledger.Adjust(ctx, adjustment)
+		if err != nil {
+			return fmt.Errorf("s.ledgerRepo.Adjust: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.publishBalance(ctx, adjustment.UserID)
+	return balance, nil
+}
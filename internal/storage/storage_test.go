@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/vindosVP/loyalty-system/internal/models"
 	"github.com/vindosVP/loyalty-system/internal/storage/mocks"
+	"github.com/vindosVP/loyalty-system/pkg/money"
 	"testing"
 	"time"
 )
@@ -150,7 +151,8 @@ func TestStorage_CreateUser(t *testing.T) {
 			ctx := context.Background()
 			userRepo := mocks.NewUserRepo(t)
 			orderRepo := mocks.NewOrderRepo(t)
-			s := New(userRepo, orderRepo)
+			ledgerRepo := mocks.NewLedgerRepo(t)
+			s := New(userRepo, orderRepo, ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
 
 			if tt.userRepoExistsMock.needed {
 				userRepo.On("Exists", mock.Anything, tt.args.user.Login).Return(tt.userRepoExistsMock.result, tt.userRepoExistsMock.err)
@@ -296,7 +298,8 @@ func TestStorage_GetUserByLogin(t *testing.T) {
 			ctx := context.Background()
 			userRepo := mocks.NewUserRepo(t)
 			orderRepo := mocks.NewOrderRepo(t)
-			s := New(userRepo, orderRepo)
+			ledgerRepo := mocks.NewLedgerRepo(t)
+			s := New(userRepo, orderRepo, ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
 
 			if tt.userRepoExistsMock.needed {
 				userRepo.On("Exists", mock.Anything, tt.args.login).Return(tt.userRepoExistsMock.result, tt.userRepoExistsMock.err)
@@ -323,14 +326,9 @@ func TestStorage_CreateOrder(t *testing.T) {
 	unexpectedError := errors.New("unexpected error")
 	currentTime := time.Now()
 
-	type OrderRepoExistsMock struct {
+	type OrderRepoNumberOwnerMock struct {
 		needed bool
-		result bool
-		err    error
-	}
-	type OrderRepoGetByIDMock struct {
-		needed bool
-		result *models.Order
+		result int
 		err    error
 	}
 	type OrderRepoCreateMock struct {
@@ -346,223 +344,92 @@ func TestStorage_CreateOrder(t *testing.T) {
 		err    error
 	}
 
+	order := &models.Order{
+		ID:         1,
+		UserID:     1,
+		Status:     models.OrderStatusNew,
+		Sum:        0,
+		UploadedAt: currentTime,
+	}
 	tests := []struct {
-		name                 string
-		orderRepoExistsMock  OrderRepoExistsMock
-		orderRepoGetByIDMock OrderRepoGetByIDMock
-		orderRepoCreateMock  OrderRepoCreateMock
-		args                 args
-		want                 want
+		name                     string
+		orderRepoNumberOwnerMock OrderRepoNumberOwnerMock
+		// orderRepoOwnerAfterCreateMock is the owner looked up after the
+		// insert found the number taken.
+		orderRepoOwnerAfterCreateMock OrderRepoNumberOwnerMock
+		orderRepoCreateMock           OrderRepoCreateMock
+		args                          args
+		want                          want
 	}{
 		{
 			name: "ok",
-			orderRepoExistsMock: OrderRepoExistsMock{
+			orderRepoNumberOwnerMock: OrderRepoNumberOwnerMock{
 				needed: true,
-				result: false,
-				err:    nil,
-			},
-			orderRepoGetByIDMock: OrderRepoGetByIDMock{
-				needed: false,
-				result: nil,
-				err:    nil,
+				err:    ErrOrderNotFound,
 			},
 			orderRepoCreateMock: OrderRepoCreateMock{
 				needed: true,
-				result: &models.Order{
-					ID:         1,
-					UserID:     1,
-					Status:     models.OrderStatusNew,
-					Sum:        0,
-					UploadedAt: currentTime,
-				},
-				err: nil,
-			},
-			args: args{
-				order: &models.Order{
-					ID:         1,
-					UserID:     1,
-					Status:     models.OrderStatusNew,
-					Sum:        0,
-					UploadedAt: currentTime,
-				},
-			},
-			want: want{
-				result: &models.Order{
-					ID:         1,
-					UserID:     1,
-					Status:     models.OrderStatusNew,
-					Sum:        0,
-					UploadedAt: currentTime,
-				},
-				err: nil,
+				result: order,
 			},
+			args: args{order: order},
+			want: want{result: order},
 		},
 		{
 			name: "order already exists",
-			orderRepoExistsMock: OrderRepoExistsMock{
-				needed: true,
-				result: true,
-				err:    nil,
-			},
-			orderRepoGetByIDMock: OrderRepoGetByIDMock{
+			orderRepoNumberOwnerMock: OrderRepoNumberOwnerMock{
 				needed: true,
-				result: &models.Order{
-					ID:         1,
-					UserID:     1,
-					Status:     models.OrderStatusNew,
-					Sum:        0,
-					UploadedAt: currentTime,
-				},
-				err: nil,
-			},
-			orderRepoCreateMock: OrderRepoCreateMock{
-				needed: false,
-				result: nil,
-				err:    nil,
-			},
-			args: args{
-				order: &models.Order{
-					ID:         1,
-					UserID:     1,
-					Status:     models.OrderStatusNew,
-					Sum:        0,
-					UploadedAt: currentTime,
-				},
-			},
-			want: want{
-				result: nil,
-				err:    ErrOrderAlreadyExists,
+				result: 1,
 			},
+			args: args{order: order},
+			want: want{err: ErrOrderAlreadyExists},
 		},
 		{
 			name: "order already created by other user",
-			orderRepoExistsMock: OrderRepoExistsMock{
-				needed: true,
-				result: true,
-				err:    nil,
-			},
-			orderRepoGetByIDMock: OrderRepoGetByIDMock{
+			orderRepoNumberOwnerMock: OrderRepoNumberOwnerMock{
 				needed: true,
-				result: &models.Order{
-					ID:         1,
-					UserID:     2,
-					Status:     models.OrderStatusNew,
-					Sum:        0,
-					UploadedAt: currentTime,
-				},
-				err: nil,
-			},
-			orderRepoCreateMock: OrderRepoCreateMock{
-				needed: false,
-				result: nil,
-				err:    nil,
-			},
-			args: args{
-				order: &models.Order{
-					ID:         1,
-					UserID:     1,
-					Status:     models.OrderStatusNew,
-					Sum:        0,
-					UploadedAt: currentTime,
-				},
-			},
-			want: want{
-				result: nil,
-				err:    ErrOrderCreatedByOtherUser,
+				result: 2,
 			},
+			args: args{order: order},
+			want: want{err: ErrOrderCreatedByOtherUser},
 		},
 		{
-			name: "orderRepo.Exists unexpected error",
-			orderRepoExistsMock: OrderRepoExistsMock{
+			name: "order uploaded concurrently by other user",
+			orderRepoNumberOwnerMock: OrderRepoNumberOwnerMock{
 				needed: true,
-				result: false,
-				err:    unexpectedError,
+				err:    ErrOrderNotFound,
 			},
-			orderRepoGetByIDMock: OrderRepoGetByIDMock{
-				needed: false,
-				result: nil,
-				err:    nil,
+			orderRepoOwnerAfterCreateMock: OrderRepoNumberOwnerMock{
+				needed: true,
+				result: 2,
 			},
 			orderRepoCreateMock: OrderRepoCreateMock{
-				needed: false,
-				result: nil,
-				err:    nil,
-			},
-			args: args{
-				order: &models.Order{
-					ID:         1,
-					UserID:     1,
-					Status:     models.OrderStatusNew,
-					Sum:        0,
-					UploadedAt: currentTime,
-				},
-			},
-			want: want{
-				result: nil,
-				err:    unexpectedError,
+				needed: true,
+				err:    ErrOrderAlreadyExists,
 			},
+			args: args{order: order},
+			want: want{err: ErrOrderCreatedByOtherUser},
 		},
 		{
-			name: "orderRepo.GetByID unexpected error",
-			orderRepoExistsMock: OrderRepoExistsMock{
-				needed: true,
-				result: true,
-				err:    nil,
-			},
-			orderRepoGetByIDMock: OrderRepoGetByIDMock{
+			name: "orderRepo.NumberOwner unexpected error",
+			orderRepoNumberOwnerMock: OrderRepoNumberOwnerMock{
 				needed: true,
-				result: nil,
-				err:    unexpectedError,
-			},
-			orderRepoCreateMock: OrderRepoCreateMock{
-				needed: false,
-				result: nil,
-				err:    nil,
-			},
-			args: args{
-				order: &models.Order{
-					ID:         1,
-					UserID:     1,
-					Status:     models.OrderStatusNew,
-					Sum:        0,
-					UploadedAt: currentTime,
-				},
-			},
-			want: want{
-				result: nil,
 				err:    unexpectedError,
 			},
+			args: args{order: order},
+			want: want{err: unexpectedError},
 		},
 		{
 			name: "orderRepo.Create unexpected error",
-			orderRepoExistsMock: OrderRepoExistsMock{
+			orderRepoNumberOwnerMock: OrderRepoNumberOwnerMock{
 				needed: true,
-				result: false,
-				err:    nil,
-			},
-			orderRepoGetByIDMock: OrderRepoGetByIDMock{
-				needed: false,
-				result: nil,
-				err:    nil,
+				err:    ErrOrderNotFound,
 			},
 			orderRepoCreateMock: OrderRepoCreateMock{
 				needed: true,
-				result: nil,
-				err:    unexpectedError,
-			},
-			args: args{
-				order: &models.Order{
-					ID:         1,
-					UserID:     1,
-					Status:     models.OrderStatusNew,
-					Sum:        0,
-					UploadedAt: currentTime,
-				},
-			},
-			want: want{
-				result: nil,
 				err:    unexpectedError,
 			},
+			args: args{order: order},
+			want: want{err: unexpectedError},
 		},
 	}
 
@@ -571,12 +438,13 @@ func TestStorage_CreateOrder(t *testing.T) {
 			ctx := context.Background()
 			userRepo := mocks.NewUserRepo(t)
 			orderRepo := mocks.NewOrderRepo(t)
-			s := New(userRepo, orderRepo)
-			if tt.orderRepoExistsMock.needed {
-				orderRepo.On("Exists", mock.Anything, tt.args.order.ID).Return(tt.orderRepoExistsMock.result, tt.orderRepoExistsMock.err)
+			ledgerRepo := mocks.NewLedgerRepo(t)
+			s := New(userRepo, orderRepo, ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
+			if tt.orderRepoNumberOwnerMock.needed {
+				orderRepo.On("NumberOwner", mock.Anything, tt.args.order.ID).Return(tt.orderRepoNumberOwnerMock.result, tt.orderRepoNumberOwnerMock.err).Once()
 			}
-			if tt.orderRepoGetByIDMock.needed {
-				orderRepo.On("GetByID", mock.Anything, tt.args.order.ID).Return(tt.orderRepoGetByIDMock.result, tt.orderRepoGetByIDMock.err)
+			if tt.orderRepoOwnerAfterCreateMock.needed {
+				orderRepo.On("NumberOwner", mock.Anything, tt.args.order.ID).Return(tt.orderRepoOwnerAfterCreateMock.result, tt.orderRepoOwnerAfterCreateMock.err).Once()
 			}
 			if tt.orderRepoCreateMock.needed {
 				orderRepo.On("Create", mock.Anything, tt.args.order).Return(tt.orderRepoCreateMock.result, tt.orderRepoCreateMock.err)
@@ -684,7 +552,8 @@ func TestStorage_GetUsersOrders(t *testing.T) {
 			ctx := context.Background()
 			userRepo := mocks.NewUserRepo(t)
 			orderRepo := mocks.NewOrderRepo(t)
-			s := New(userRepo, orderRepo)
+			ledgerRepo := mocks.NewLedgerRepo(t)
+			s := New(userRepo, orderRepo, ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
 
 			if tt.orderRepoGetUsersOrdersMock.needed {
 				orderRepo.On("GetUsersOrders", mock.Anything, tt.args.userID).Return(tt.orderRepoGetUsersOrdersMock.result, tt.orderRepoGetUsersOrdersMock.err)
@@ -705,43 +574,43 @@ func TestStorage_GetUsersOrders(t *testing.T) {
 func TestStorage_GetUsersCurrentBalance(t *testing.T) {
 	unexpectedError := errors.New("unexpected error")
 
-	type orderRepoGetUsersCurrentBalanceMock struct {
+	type ledgerRepoGetBalanceMock struct {
 		needed bool
-		result float64
+		result money.Amount
 		err    error
 	}
 	type args struct {
 		userID int
 	}
 	type want struct {
-		result float64
+		result money.Amount
 		err    error
 	}
 
 	tests := []struct {
-		name                                string
-		orderRepoGetUsersCurrentBalanceMock orderRepoGetUsersCurrentBalanceMock
-		args                                args
-		want                                want
+		name                     string
+		ledgerRepoGetBalanceMock ledgerRepoGetBalanceMock
+		args                     args
+		want                     want
 	}{
 		{
 			name: "ok",
-			orderRepoGetUsersCurrentBalanceMock: orderRepoGetUsersCurrentBalanceMock{
+			ledgerRepoGetBalanceMock: ledgerRepoGetBalanceMock{
 				needed: true,
-				result: 512.3,
+				result: money.MustParse("512.3"),
 				err:    nil,
 			},
 			args: args{
 				userID: 1,
 			},
 			want: want{
-				result: 512.3,
+				result: money.MustParse("512.3"),
 				err:    nil,
 			},
 		},
 		{
-			name: "orderRepo.GetUsersCurrentBalance unexpected error",
-			orderRepoGetUsersCurrentBalanceMock: orderRepoGetUsersCurrentBalanceMock{
+			name: "ledgerRepo.GetBalance unexpected error",
+			ledgerRepoGetBalanceMock: ledgerRepoGetBalanceMock{
 				needed: true,
 				result: 0,
 				err:    unexpectedError,
@@ -761,10 +630,11 @@ func TestStorage_GetUsersCurrentBalance(t *testing.T) {
 			ctx := context.Background()
 			userRepo := mocks.NewUserRepo(t)
 			orderRepo := mocks.NewOrderRepo(t)
-			s := New(userRepo, orderRepo)
+			ledgerRepo := mocks.NewLedgerRepo(t)
+			s := New(userRepo, orderRepo, ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
 
-			if tt.orderRepoGetUsersCurrentBalanceMock.needed {
-				orderRepo.On("GetUsersCurrentBalance", mock.Anything, tt.args.userID).Return(tt.orderRepoGetUsersCurrentBalanceMock.result, tt.orderRepoGetUsersCurrentBalanceMock.err)
+			if tt.ledgerRepoGetBalanceMock.needed {
+				ledgerRepo.On("GetBalance", mock.Anything, tt.args.userID).Return(tt.ledgerRepoGetBalanceMock.result, tt.ledgerRepoGetBalanceMock.err)
 			}
 
 			result, err := s.GetUsersCurrentBalance(ctx, tt.args.userID)
@@ -781,43 +651,43 @@ func TestStorage_GetUsersCurrentBalance(t *testing.T) {
 func TestStorage_GetUsersWithdrawnBalance(t *testing.T) {
 	unexpectedError := errors.New("unexpected error")
 
-	type orderRepoGetUsersWithdrawnBalanceMock struct {
+	type ledgerRepoGetWithdrawnTotalMock struct {
 		needed bool
-		result float64
+		result money.Amount
 		err    error
 	}
 	type args struct {
 		userID int
 	}
 	type want struct {
-		result float64
+		result money.Amount
 		err    error
 	}
 
 	tests := []struct {
-		name                                  string
-		orderRepoGetUsersWithdrawnBalanceMock orderRepoGetUsersWithdrawnBalanceMock
-		args                                  args
-		want                                  want
+		name                            string
+		ledgerRepoGetWithdrawnTotalMock ledgerRepoGetWithdrawnTotalMock
+		args                            args
+		want                            want
 	}{
 		{
 			name: "ok",
-			orderRepoGetUsersWithdrawnBalanceMock: orderRepoGetUsersWithdrawnBalanceMock{
+			ledgerRepoGetWithdrawnTotalMock: ledgerRepoGetWithdrawnTotalMock{
 				needed: true,
-				result: 512.3,
+				result: money.MustParse("512.3"),
 				err:    nil,
 			},
 			args: args{
 				userID: 1,
 			},
 			want: want{
-				result: 512.3,
+				result: money.MustParse("512.3"),
 				err:    nil,
 			},
 		},
 		{
-			name: "orderRepo.GetUsersWithdrawnBalance unexpected error",
-			orderRepoGetUsersWithdrawnBalanceMock: orderRepoGetUsersWithdrawnBalanceMock{
+			name: "ledgerRepo.GetWithdrawnTotal unexpected error",
+			ledgerRepoGetWithdrawnTotalMock: ledgerRepoGetWithdrawnTotalMock{
 				needed: true,
 				result: 0,
 				err:    unexpectedError,
@@ -837,10 +707,11 @@ func TestStorage_GetUsersWithdrawnBalance(t *testing.T) {
 			ctx := context.Background()
 			userRepo := mocks.NewUserRepo(t)
 			orderRepo := mocks.NewOrderRepo(t)
-			s := New(userRepo, orderRepo)
+			ledgerRepo := mocks.NewLedgerRepo(t)
+			s := New(userRepo, orderRepo, ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
 
-			if tt.orderRepoGetUsersWithdrawnBalanceMock.needed {
-				orderRepo.On("GetUsersWithdrawnBalance", mock.Anything, tt.args.userID).Return(tt.orderRepoGetUsersWithdrawnBalanceMock.result, tt.orderRepoGetUsersWithdrawnBalanceMock.err)
+			if tt.ledgerRepoGetWithdrawnTotalMock.needed {
+				ledgerRepo.On("GetWithdrawnTotal", mock.Anything, tt.args.userID).Return(tt.ledgerRepoGetWithdrawnTotalMock.result, tt.ledgerRepoGetWithdrawnTotalMock.err)
 			}
 
 			result, err := s.GetUsersWithdrawnBalance(ctx, tt.args.userID)
@@ -858,43 +729,41 @@ func TestStorage_GetUsersWithdrawals(t *testing.T) {
 	unexpectedError := errors.New("unexpected error")
 	currentTime := time.Now()
 
-	type orderRepoGetUsersWithdrawalsMock struct {
+	type ledgerRepoGetWithdrawalsMock struct {
 		needed bool
-		result []*models.Order
+		result []*models.Withdrawal
 		err    error
 	}
 	type args struct {
 		userID int
 	}
 	type want struct {
-		result []*models.Order
+		result []*models.Withdrawal
 		err    error
 	}
 
 	tests := []struct {
-		name                             string
-		orderRepoGetUsersWithdrawalsMock orderRepoGetUsersWithdrawalsMock
-		args                             args
-		want                             want
+		name                         string
+		ledgerRepoGetWithdrawalsMock ledgerRepoGetWithdrawalsMock
+		args                         args
+		want                         want
 	}{
 		{
 			name: "ok",
-			orderRepoGetUsersWithdrawalsMock: orderRepoGetUsersWithdrawalsMock{
+			ledgerRepoGetWithdrawalsMock: ledgerRepoGetWithdrawalsMock{
 				needed: true,
-				result: []*models.Order{
+				result: []*models.Withdrawal{
 					{
-						ID:         1,
-						UserID:     1,
-						Status:     models.OrderStatusProcessed,
-						Sum:        100,
-						UploadedAt: currentTime,
+						OrderID:     1,
+						UserID:      1,
+						Sum:         money.FromInt(100),
+						ProcessedAt: currentTime,
 					},
 					{
-						ID:         2,
-						UserID:     1,
-						Status:     models.OrderStatusProcessed,
-						Sum:        200,
-						UploadedAt: currentTime,
+						OrderID:     2,
+						UserID:      1,
+						Sum:         money.FromInt(200),
+						ProcessedAt: currentTime,
 					},
 				},
 				err: nil,
@@ -903,28 +772,26 @@ func TestStorage_GetUsersWithdrawals(t *testing.T) {
 				userID: 1,
 			},
 			want: want{
-				result: []*models.Order{
+				result: []*models.Withdrawal{
 					{
-						ID:         1,
-						UserID:     1,
-						Status:     models.OrderStatusProcessed,
-						Sum:        100,
-						UploadedAt: currentTime,
+						OrderID:     1,
+						UserID:      1,
+						Sum:         money.FromInt(100),
+						ProcessedAt: currentTime,
 					},
 					{
-						ID:         2,
-						UserID:     1,
-						Status:     models.OrderStatusProcessed,
-						Sum:        200,
-						UploadedAt: currentTime,
+						OrderID:     2,
+						UserID:      1,
+						Sum:         money.FromInt(200),
+						ProcessedAt: currentTime,
 					},
 				},
 				err: nil,
 			},
 		},
 		{
-			name: "orderRepo.GetUsersWithdrawals unexpected error",
-			orderRepoGetUsersWithdrawalsMock: orderRepoGetUsersWithdrawalsMock{
+			name: "ledgerRepo.GetWithdrawals unexpected error",
+			ledgerRepoGetWithdrawalsMock: ledgerRepoGetWithdrawalsMock{
 				needed: true,
 				result: nil,
 				err:    unexpectedError,
@@ -944,10 +811,11 @@ func TestStorage_GetUsersWithdrawals(t *testing.T) {
 			ctx := context.Background()
 			userRepo := mocks.NewUserRepo(t)
 			orderRepo := mocks.NewOrderRepo(t)
-			s := New(userRepo, orderRepo)
+			ledgerRepo := mocks.NewLedgerRepo(t)
+			s := New(userRepo, orderRepo, ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
 
-			if tt.orderRepoGetUsersWithdrawalsMock.needed {
-				orderRepo.On("GetUsersWithdrawals", mock.Anything, tt.args.userID).Return(tt.orderRepoGetUsersWithdrawalsMock.result, tt.orderRepoGetUsersWithdrawalsMock.err)
+			if tt.ledgerRepoGetWithdrawalsMock.needed {
+				ledgerRepo.On("GetWithdrawals", mock.Anything, tt.args.userID).Return(tt.ledgerRepoGetWithdrawalsMock.result, tt.ledgerRepoGetWithdrawalsMock.err)
 			}
 
 			result, err := s.GetUsersWithdrawals(ctx, tt.args.userID)
@@ -961,3 +829,382 @@ func TestStorage_GetUsersWithdrawals(t *testing.T) {
 		})
 	}
 }
+
+func TestStorage_CreateWithdrawal(t *testing.T) {
+	unexpectedError := errors.New("unexpected error")
+	currentTime := time.Now()
+
+	type ledgerRepoWithdrawMock struct {
+		needed bool
+		err    error
+	}
+	type ledgerRepoGetWithdrawalMock struct {
+		needed bool
+		result *models.Withdrawal
+		err    error
+	}
+	type args struct {
+		withdrawal *models.Withdrawal
+	}
+	type want struct {
+		result *models.Withdrawal
+		err    error
+	}
+
+	tests := []struct {
+		name string
+		// numberOwner is who the order number belongs to, 0 if it's free.
+		numberOwner                 int
+		ledgerRepoWithdrawMock      ledgerRepoWithdrawMock
+		ledgerRepoGetWithdrawalMock ledgerRepoGetWithdrawalMock
+		args                        args
+		want                        want
+	}{
+		{
+			name: "ok",
+			ledgerRepoWithdrawMock: ledgerRepoWithdrawMock{
+				needed: true,
+				err:    nil,
+			},
+			ledgerRepoGetWithdrawalMock: ledgerRepoGetWithdrawalMock{
+				needed: true,
+				result: &models.Withdrawal{
+					OrderID:     2377225624,
+					UserID:      1,
+					Sum:         money.FromInt(100),
+					ProcessedAt: currentTime,
+				},
+				err: nil,
+			},
+			args: args{
+				withdrawal: &models.Withdrawal{
+					OrderID:     2377225624,
+					UserID:      1,
+					Sum:         money.FromInt(100),
+					ProcessedAt: currentTime,
+				},
+			},
+			want: want{
+				result: &models.Withdrawal{
+					OrderID:     2377225624,
+					UserID:      1,
+					Sum:         money.FromInt(100),
+					ProcessedAt: currentTime,
+				},
+				err: nil,
+			},
+		},
+		{
+			name: "insufficient funds",
+			ledgerRepoWithdrawMock: ledgerRepoWithdrawMock{
+				needed: true,
+				err:    ErrInsufficientFunds,
+			},
+			ledgerRepoGetWithdrawalMock: ledgerRepoGetWithdrawalMock{
+				needed: false,
+				result: nil,
+				err:    nil,
+			},
+			args: args{
+				withdrawal: &models.Withdrawal{
+					OrderID:     2377225624,
+					UserID:      1,
+					Sum:         money.FromInt(100),
+					ProcessedAt: currentTime,
+				},
+			},
+			want: want{
+				result: nil,
+				err:    ErrInsufficientFunds,
+			},
+		},
+		{
+			name: "withdrawal already exists",
+			ledgerRepoWithdrawMock: ledgerRepoWithdrawMock{
+				needed: true,
+				err:    ErrOrderAlreadyExists,
+			},
+			ledgerRepoGetWithdrawalMock: ledgerRepoGetWithdrawalMock{
+				needed: false,
+				result: nil,
+				err:    nil,
+			},
+			args: args{
+				withdrawal: &models.Withdrawal{
+					OrderID:     2377225624,
+					UserID:      1,
+					Sum:         money.FromInt(100),
+					ProcessedAt: currentTime,
+				},
+			},
+			want: want{
+				result: nil,
+				err:    ErrOrderAlreadyExists,
+			},
+		},
+		{
+			name: "ledgerRepo.GetWithdrawal unexpected error",
+			ledgerRepoWithdrawMock: ledgerRepoWithdrawMock{
+				needed: true,
+				err:    nil,
+			},
+			ledgerRepoGetWithdrawalMock: ledgerRepoGetWithdrawalMock{
+				needed: true,
+				result: nil,
+				err:    unexpectedError,
+			},
+			args: args{
+				withdrawal: &models.Withdrawal{
+					OrderID:     2377225624,
+					UserID:      1,
+					Sum:         money.FromInt(100),
+					ProcessedAt: currentTime,
+				},
+			},
+			want: want{
+				result: nil,
+				err:    unexpectedError,
+			},
+		},
+		{
+			name:        "number uploaded as an order",
+			numberOwner: 1,
+			args: args{
+				withdrawal: &models.Withdrawal{
+					OrderID:     2377225624,
+					UserID:      1,
+					Sum:         money.FromInt(100),
+					ProcessedAt: currentTime,
+				},
+			},
+			want: want{
+				result: nil,
+				err:    ErrOrderAlreadyExists,
+			},
+		},
+		{
+			name:        "number uploaded as an order by other user",
+			numberOwner: 2,
+			args: args{
+				withdrawal: &models.Withdrawal{
+					OrderID:     2377225624,
+					UserID:      1,
+					Sum:         money.FromInt(100),
+					ProcessedAt: currentTime,
+				},
+			},
+			want: want{
+				result: nil,
+				err:    ErrOrderCreatedByOtherUser,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			userRepo := mocks.NewUserRepo(t)
+			orderRepo := mocks.NewOrderRepo(t)
+			ledgerRepo := mocks.NewLedgerRepo(t)
+			s := New(userRepo, orderRepo, ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), nil, nil)
+			if tt.numberOwner == 0 {
+				orderRepo.On("NumberOwner", mock.Anything, tt.args.withdrawal.OrderID).Return(0, ErrOrderNotFound)
+			} else {
+				orderRepo.On("NumberOwner", mock.Anything, tt.args.withdrawal.OrderID).Return(tt.numberOwner, nil)
+			}
+			if tt.ledgerRepoWithdrawMock.needed {
+				ledgerRepo.On("Withdraw", mock.Anything, tt.args.withdrawal).Return(tt.ledgerRepoWithdrawMock.err)
+			}
+			if tt.ledgerRepoGetWithdrawalMock.needed {
+				ledgerRepo.On("GetWithdrawal", mock.Anything, tt.args.withdrawal.OrderID).Return(tt.ledgerRepoGetWithdrawalMock.result, tt.ledgerRepoGetWithdrawalMock.err)
+			}
+
+			result, err := s.CreateWithdrawal(ctx, tt.args.withdrawal)
+			if tt.want.result != nil {
+				assert.Equal(t, tt.want.result, result)
+				assert.NoError(t, err)
+			}
+			if tt.want.err != nil {
+				assert.ErrorIs(t, err, tt.want.err)
+			}
+		})
+	}
+}
+
+func TestStorage_UpdateOrder(t *testing.T) {
+	unexpectedError := errors.New("unexpected error")
+	currentTime := time.Now()
+
+	type ledgerRepoPostMock struct {
+		needed bool
+		err    error
+	}
+	type orderRepoUpdateOrderMock struct {
+		needed bool
+		result *models.Order
+		err    error
+	}
+	type args struct {
+		id     int
+		status string
+		sum    money.Amount
+	}
+	type want struct {
+		result *models.Order
+		err    error
+	}
+
+	tests := []struct {
+		name                     string
+		ledgerRepoPostMock       ledgerRepoPostMock
+		orderRepoUpdateOrderMock orderRepoUpdateOrderMock
+		args                     args
+		want                     want
+	}{
+		{
+			name: "processed with accrual",
+			ledgerRepoPostMock: ledgerRepoPostMock{
+				needed: true,
+				err:    nil,
+			},
+			orderRepoUpdateOrderMock: orderRepoUpdateOrderMock{
+				needed: true,
+				result: &models.Order{
+					ID:         7703824164,
+					UserID:     1,
+					Status:     models.OrderStatusProcessed,
+					Sum:        money.FromInt(500),
+					UploadedAt: currentTime,
+				},
+				err: nil,
+			},
+			args: args{
+				id:     7703824164,
+				status: models.OrderStatusProcessed,
+				sum:    money.FromInt(500),
+			},
+			want: want{
+				result: &models.Order{
+					ID:         7703824164,
+					UserID:     1,
+					Status:     models.OrderStatusProcessed,
+					Sum:        money.FromInt(500),
+					UploadedAt: currentTime,
+				},
+				err: nil,
+			},
+		},
+		{
+			name: "processed without accrual",
+			ledgerRepoPostMock: ledgerRepoPostMock{
+				needed: false,
+				err:    nil,
+			},
+			orderRepoUpdateOrderMock: orderRepoUpdateOrderMock{
+				needed: true,
+				result: &models.Order{
+					ID:         7703824164,
+					UserID:     1,
+					Status:     models.OrderStatusProcessed,
+					Sum:        0,
+					UploadedAt: currentTime,
+				},
+				err: nil,
+			},
+			args: args{
+				id:     7703824164,
+				status: models.OrderStatusProcessed,
+				sum:    0,
+			},
+			want: want{
+				result: &models.Order{
+					ID:         7703824164,
+					UserID:     1,
+					Status:     models.OrderStatusProcessed,
+					Sum:        0,
+					UploadedAt: currentTime,
+				},
+				err: nil,
+			},
+		},
+		{
+			name: "ledgerRepo.Post unexpected error",
+			ledgerRepoPostMock: ledgerRepoPostMock{
+				needed: true,
+				err:    unexpectedError,
+			},
+			orderRepoUpdateOrderMock: orderRepoUpdateOrderMock{
+				needed: true,
+				result: &models.Order{
+					ID:         7703824164,
+					UserID:     1,
+					Status:     models.OrderStatusProcessed,
+					Sum:        money.FromInt(500),
+					UploadedAt: currentTime,
+				},
+				err: nil,
+			},
+			args: args{
+				id:     7703824164,
+				status: models.OrderStatusProcessed,
+				sum:    money.FromInt(500),
+			},
+			want: want{
+				result: nil,
+				err:    unexpectedError,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			userRepo := mocks.NewUserRepo(t)
+			orderRepo := mocks.NewOrderRepo(t)
+			ledgerRepo := mocks.NewLedgerRepo(t)
+			tierRepo := mocks.NewTierRepo(t)
+			s := New(userRepo, orderRepo, ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), tierRepo, nil, nil)
+			if tt.ledgerRepoPostMock.needed {
+				orderRepo.On("GetByID", mock.Anything, tt.args.id).Return(tt.orderRepoUpdateOrderMock.result, nil)
+				tierRepo.On("ListRules", mock.Anything).Return(nil, nil)
+				ledgerRepo.On("Post", mock.Anything, mock.MatchedBy(func(e *models.Entry) bool {
+					return e.Kind == models.EntryKindAccrual && e.OrderID == tt.args.id && e.Balanced()
+				})).Return(tt.ledgerRepoPostMock.err)
+			}
+			if tt.orderRepoUpdateOrderMock.needed {
+				orderRepo.On("UpdateOrder", mock.Anything, tt.args.id, "worker", tt.args.status, tt.args.sum).Return(tt.orderRepoUpdateOrderMock.result, tt.orderRepoUpdateOrderMock.err)
+			}
+
+			result, err := s.UpdateOrder(ctx, tt.args.id, "worker", tt.args.status, tt.args.sum)
+			if tt.want.result != nil {
+				assert.Equal(t, tt.want.result, result)
+				assert.NoError(t, err)
+			}
+			if tt.want.err != nil {
+				assert.ErrorIs(t, err, tt.want.err)
+			}
+		})
+	}
+}
+
+// txRepos is a Tx that runs units of work on fixed repos.
+type txRepos Repos
+
+func (tx *txRepos) WithinTx(ctx context.Context, f func(repos *Repos) error) error {
+	return f((*Repos)(tx))
+}
+
+func TestStorage_CreateOrderWithinTx(t *testing.T) {
+	ctx := context.Background()
+	order := &models.Order{ID: 1, UserID: 1, Status: models.OrderStatusNew, UploadedAt: time.Now()}
+	txOrderRepo := mocks.NewOrderRepo(t)
+	txOrderRepo.On("NumberOwner", mock.Anything, order.ID).Return(0, ErrOrderNotFound)
+	txOrderRepo.On("Create", mock.Anything, order).Return(order, nil)
+	tx := &txRepos{Users: mocks.NewUserRepo(t), Orders: txOrderRepo, Ledger: mocks.NewLedgerRepo(t)}
+
+	// The storage's own repos expect no calls.
+	s := New(mocks.NewUserRepo(t), mocks.NewOrderRepo(t), mocks.NewLedgerRepo(t), mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), mocks.NewTierRepo(t), tx, nil)
+	result, err := s.CreateOrder(ctx, order)
+	assert.NoError(t, err)
+	assert.Equal(t, order, result)
+}
@@ -0,0 +1,926 @@
+// Package storagetest is a contract test suite for the storage repos. Every
+// backend runs it, so they all keep the semantics the storage layer relies on.
+// Logins and order numbers are unique per run, so the suite can share a
+// database with other tests.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Backend is the set of repos under test.
+type Backend struct {
+	Users       storage.UserRepo
+	Orders      storage.OrderRepo
+	Ledger      storage.LedgerRepo
+	Tiers       storage.TierRepo
+	Idempotency storage.IdempotencyRepo
+	Tx          storage.Tx
+}
+
+var seq atomic.Int64
+
+func init() {
+	seq.Store(time.Now().UnixNano() / 1000)
+}
+
+// nextID returns a number no other test in this process or earlier runs got.
+func nextID() int {
+	return int(seq.Add(1))
+}
+
+// Run runs the suite. newBackend is called once per test.
+func Run(t *testing.T, newBackend func(t *testing.T) *Backend) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, b *Backend)
+	}{
+		{"UserUniqueness", testUserUniqueness},
+		{"UserLookup", testUserLookup},
+		{"UserSearch", testUserSearch},
+		{"UserAddRole", testUserAddRole},
+		{"OrderUniqueness", testOrderUniqueness},
+		{"OrderNumberOwner", testOrderNumberOwner},
+		{"OrdersByUploadedAt", testOrdersByUploadedAt},
+		{"OrderList", testOrderList},
+		{"OrderClaim", testOrderClaim},
+		{"OrderUpdate", testOrderUpdate},
+		{"OrderUpdateLease", testOrderUpdateLease},
+		{"OrderRequeue", testOrderRequeue},
+		{"OrderCountByStatus", testOrderCountByStatus},
+		{"Balance", testBalance},
+		{"WithdrawalOwnership", testWithdrawalOwnership},
+		{"Adjust", testAdjust},
+		{"ConcurrentWithdrawals", testConcurrentWithdrawals},
+		{"LotsConsumedOldestFirst", testLotsConsumedOldestFirst},
+		{"ExpirePoints", testExpirePoints},
+		{"ScheduleExpiry", testScheduleExpiry},
+		{"AccruedTotal", testAccruedTotal},
+		{"TierRules", testTierRules},
+		{"TierHistory", testTierHistory},
+		{"IdempotencyLease", testIdempotencyLease},
+		{"TxCommit", testTxCommit},
+		{"TxRollback", testTxRollback},
+		{"TxNestedFailure", testTxNestedFailure},
+		{"ConcurrentOrderUploads", testConcurrentOrderUploads},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newBackend(t))
+		})
+	}
+}
+
+func createUser(t *testing.T, b *Backend) *models.User {
+	user, err := b.Users.Create(context.Background(), &models.User{
+		Login:        fmt.Sprintf("contract-%d", nextID()),
+		EncryptedPwd: "encryptedPwd",
+	})
+	require.NoError(t, err)
+	return user
+}
+
+func createOrder(t *testing.T, b *Backend, userID int, uploadedAt time.Time) *models.Order {
+	order, err := b.Orders.Create(context.Background(), &models.Order{
+		ID:         nextID(),
+		UserID:     userID,
+		Status:     models.OrderStatusNew,
+		UploadedAt: uploadedAt,
+	})
+	require.NoError(t, err)
+	return order
+}
+
+func orderIDs(orders []*models.Order) []int {
+	ids := make([]int, 0, len(orders))
+	for _, o := range orders {
+		ids = append(ids, o.ID)
+	}
+	return ids
+}
+
+func testUserUniqueness(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	assert.NotZero(t, user.ID)
+	assert.Equal(t, []string{models.RoleCustomer}, user.Roles)
+
+	_, err := b.Users.Create(ctx, &models.User{Login: user.Login, EncryptedPwd: "other"})
+	assert.ErrorIs(t, err, storage.ErrUserAlreadyExists)
+	other := createUser(t, b)
+	assert.NotEqual(t, user.ID, other.ID)
+}
+
+func testUserLookup(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+
+	byLogin, err := b.Users.GetByLogin(ctx, user.Login)
+	require.NoError(t, err)
+	assert.Equal(t, user, byLogin)
+	byID, err := b.Users.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user, byID)
+	_, err = b.Users.GetByID(ctx, -1)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	exists, err := b.Users.Exists(ctx, user.Login)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = b.Users.Exists(ctx, user.Login+"-missing")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func testUserSearch(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("Search-%d", nextID())
+	var created []*models.User
+	for _, suffix := range []string{"a", "b", "c"} {
+		user, err := b.Users.Create(ctx, &models.User{Login: prefix + "-" + suffix, EncryptedPwd: "encryptedPwd"})
+		require.NoError(t, err)
+		created = append(created, user)
+	}
+
+	found, err := b.Users.Search(ctx, "search-"+prefix[len("Search-"):], 2)
+	require.NoError(t, err)
+	assert.Equal(t, created[:2], found)
+	found, err = b.Users.Search(ctx, prefix+"-%", 10)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func testUserAddRole(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+
+	found, err := b.Users.AddRole(ctx, user.Login, models.RoleAdmin)
+	require.NoError(t, err)
+	assert.True(t, found)
+	found, err = b.Users.AddRole(ctx, user.Login, models.RoleAdmin)
+	require.NoError(t, err)
+	assert.True(t, found)
+	got, err := b.Users.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.RoleCustomer, models.RoleAdmin}, got.Roles)
+
+	found, err = b.Users.AddRole(ctx, user.Login+"-missing", models.RoleAdmin)
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func testOrderUniqueness(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	order := createOrder(t, b, user.ID, time.Now())
+
+	_, err := b.Orders.Create(ctx, &models.Order{ID: order.ID, UserID: user.ID, Status: models.OrderStatusNew, UploadedAt: time.Now()})
+	assert.ErrorIs(t, err, storage.ErrOrderAlreadyExists)
+
+	got, err := b.Orders.GetByID(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, order.ID, got.ID)
+	assert.Equal(t, user.ID, got.UserID)
+	assert.Equal(t, models.OrderStatusNew, got.Status)
+	assert.True(t, order.UploadedAt.Equal(got.UploadedAt))
+	_, err = b.Orders.GetByID(ctx, -1)
+	assert.ErrorIs(t, err, storage.ErrOrderNotFound)
+
+	exists, err := b.Orders.Exists(ctx, order.ID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = b.Orders.Exists(ctx, -1)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func testOrderNumberOwner(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	order := createOrder(t, b, user.ID, time.Now())
+	owner, err := b.Orders.NumberOwner(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, owner)
+
+	other := createUser(t, b)
+	require.NoError(t, b.Ledger.Post(ctx, models.NewAccrualEntry(other.ID, nextID(), money.FromInt(10))))
+	withdrawalID := nextID()
+	require.NoError(t, b.Ledger.Withdraw(ctx, &models.Withdrawal{OrderID: withdrawalID, UserID: other.ID, Sum: money.FromInt(5), ProcessedAt: time.Now()}))
+	owner, err = b.Orders.NumberOwner(ctx, withdrawalID)
+	require.NoError(t, err)
+	assert.Equal(t, other.ID, owner, "a withdrawal takes its number as well")
+
+	_, err = b.Orders.NumberOwner(ctx, nextID())
+	assert.ErrorIs(t, err, storage.ErrOrderNotFound)
+
+	err = b.Tx.WithinTx(ctx, func(repos *storage.Repos) error {
+		owner, err := repos.Orders.NumberOwner(ctx, withdrawalID)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, other.ID, owner)
+		_, err = repos.Orders.NumberOwner(ctx, nextID())
+		assert.ErrorIs(t, err, storage.ErrOrderNotFound)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testOrdersByUploadedAt(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	other := createUser(t, b)
+	now := time.Now()
+	latest := createOrder(t, b, user.ID, now)
+	earliest := createOrder(t, b, user.ID, now.Add(-2*time.Hour))
+	middle := createOrder(t, b, user.ID, now.Add(-time.Hour))
+	createOrder(t, b, other.ID, now.Add(-90*time.Minute))
+
+	orders, err := b.Orders.GetUsersOrders(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []int{earliest.ID, middle.ID, latest.ID}, orderIDs(orders))
+
+	orders, err = b.Orders.GetUsersOrders(ctx, createUser(t, b).ID)
+	require.NoError(t, err)
+	assert.NotNil(t, orders)
+	assert.Empty(t, orders)
+}
+
+func testOrderList(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	var orders []*models.Order
+	for i := 0; i < 5; i++ {
+		orders = append(orders, createOrder(t, b, user.ID, base.Add(time.Duration(i)*time.Minute)))
+	}
+	_, err := b.Orders.UpdateOrder(ctx, orders[1].ID, "", models.OrderStatusProcessed, money.FromInt(10))
+	require.NoError(t, err)
+	_, err = b.Orders.UpdateOrder(ctx, orders[3].ID, "", models.OrderStatusProcessed, money.FromInt(30))
+	require.NoError(t, err)
+
+	page, err := b.Orders.List(ctx, user.ID, models.ListFilter{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int{orders[0].ID, orders[1].ID}, orderIDs(page))
+	last := page[len(page)-1]
+	page, err = b.Orders.List(ctx, user.ID, models.ListFilter{Limit: 2, After: &models.Cursor{Time: last.UploadedAt, ID: last.ID}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{orders[2].ID, orders[3].ID}, orderIDs(page))
+
+	page, err = b.Orders.List(ctx, user.ID, models.ListFilter{Limit: 10, Statuses: []string{models.OrderStatusProcessed}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{orders[1].ID, orders[3].ID}, orderIDs(page))
+
+	from, to := base.Add(time.Minute), base.Add(3*time.Minute)
+	page, err = b.Orders.List(ctx, user.ID, models.ListFilter{Limit: 10, From: &from, To: &to})
+	require.NoError(t, err)
+	assert.Equal(t, []int{orders[1].ID, orders[2].ID}, orderIDs(page))
+
+	min, max := money.FromInt(20), money.FromInt(30)
+	page, err = b.Orders.List(ctx, user.ID, models.ListFilter{Limit: 10, MinAmount: &min, MaxAmount: &max})
+	require.NoError(t, err)
+	assert.Equal(t, []int{orders[3].ID}, orderIDs(page))
+}
+
+func testOrderClaim(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	mine := make(map[int]bool)
+	for i := 0; i < 3; i++ {
+		mine[createOrder(t, b, user.ID, time.Now()).ID] = true
+	}
+	processed := createOrder(t, b, user.ID, time.Now())
+	_, err := b.Orders.UpdateOrder(ctx, processed.ID, "", models.OrderStatusProcessed, money.FromInt(1))
+	require.NoError(t, err)
+
+	// Other tests may leave claimable orders behind, so claim everything and
+	// look for ours.
+	const all = 1 << 20
+	claimedIDs := func(owner string) map[int]bool {
+		ids, err := b.Orders.ClaimUnprocessedOrders(ctx, owner, all, time.Minute)
+		require.NoError(t, err)
+		claimed := make(map[int]bool)
+		for _, id := range ids {
+			if mine[id] || id == processed.ID {
+				claimed[id] = true
+			}
+		}
+		return claimed
+	}
+	assert.Equal(t, mine, claimedIDs("first"))
+	assert.Empty(t, claimedIDs("second"), "leased orders must not be claimed again")
+
+	var released int
+	for id := range mine {
+		released = id
+		break
+	}
+	_, err = b.Orders.UpdateOrderStatus(ctx, released, "first", models.OrderStatusProcessing)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]bool{released: true}, claimedIDs("third"), "an update releases the lease")
+}
+
+func testOrderUpdate(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	order := createOrder(t, b, user.ID, time.Now())
+
+	updated, err := b.Orders.UpdateOrderStatus(ctx, order.ID, "", models.OrderStatusProcessing)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusProcessing, updated.Status)
+	updated, err = b.Orders.UpdateOrder(ctx, order.ID, "", models.OrderStatusProcessed, money.MustParse("729.98"))
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusProcessed, updated.Status)
+	assert.Equal(t, money.MustParse("729.98"), updated.Sum)
+
+	got, err := b.Orders.GetByID(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusProcessed, got.Status)
+	assert.Equal(t, money.MustParse("729.98"), got.Sum)
+
+	_, err = b.Orders.UpdateOrder(ctx, -1, "", models.OrderStatusProcessed, 0)
+	assert.ErrorIs(t, err, storage.ErrOrderNotFound)
+}
+
+// testOrderUpdateLease checks that only the lease owner updates an order and
+// that nobody changes it once it's settled.
+func testOrderUpdateLease(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	order := createOrder(t, b, user.ID, time.Now())
+	ids, err := b.Orders.ClaimUnprocessedOrders(ctx, "stale", 1<<20, -time.Minute)
+	require.NoError(t, err)
+	require.Contains(t, ids, order.ID)
+	ids, err = b.Orders.ClaimUnprocessedOrders(ctx, "current", 1<<20, time.Minute)
+	require.NoError(t, err)
+	require.Contains(t, ids, order.ID, "an expired lease is claimed again")
+
+	_, err = b.Orders.UpdateOrder(ctx, order.ID, "stale", models.OrderStatusProcessed, money.FromInt(10))
+	assert.ErrorIs(t, err, storage.ErrOrderLeaseLost)
+	_, err = b.Orders.UpdateOrderStatus(ctx, order.ID, "stale", models.OrderStatusInvalid)
+	assert.ErrorIs(t, err, storage.ErrOrderLeaseLost)
+	updated, err := b.Orders.UpdateOrder(ctx, order.ID, "current", models.OrderStatusProcessed, money.FromInt(20))
+	require.NoError(t, err)
+	assert.Equal(t, money.FromInt(20), updated.Sum)
+
+	_, err = b.Orders.UpdateOrder(ctx, order.ID, "", models.OrderStatusProcessed, money.FromInt(30))
+	assert.ErrorIs(t, err, storage.ErrOrderFinal)
+	_, err = b.Orders.UpdateOrderStatus(ctx, order.ID, "", models.OrderStatusProcessing)
+	assert.ErrorIs(t, err, storage.ErrOrderFinal)
+	got, err := b.Orders.GetByID(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusProcessed, got.Status)
+	assert.Equal(t, money.FromInt(20), got.Sum)
+
+	invalid := createOrder(t, b, user.ID, time.Now())
+	_, err = b.Orders.UpdateOrderStatus(ctx, invalid.ID, "", models.OrderStatusInvalid)
+	require.NoError(t, err)
+	_, err = b.Orders.UpdateOrder(ctx, invalid.ID, "", models.OrderStatusProcessed, money.FromInt(5))
+	assert.ErrorIs(t, err, storage.ErrOrderFinal)
+}
+
+func testOrderRequeue(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	order := createOrder(t, b, user.ID, time.Now())
+
+	_, err := b.Orders.UpdateOrderStatus(ctx, order.ID, "", models.OrderStatusInvalid)
+	require.NoError(t, err)
+	requeued, err := b.Orders.Requeue(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusNew, requeued.Status)
+
+	_, err = b.Orders.UpdateOrder(ctx, order.ID, "", models.OrderStatusProcessed, money.FromInt(5))
+	require.NoError(t, err)
+	_, err = b.Orders.Requeue(ctx, order.ID)
+	assert.ErrorIs(t, err, storage.ErrOrderAlreadyProcessed)
+	_, err = b.Orders.Requeue(ctx, -1)
+	assert.ErrorIs(t, err, storage.ErrOrderNotFound)
+}
+
+func testOrderCountByStatus(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	before, err := b.Orders.CountByStatus(ctx, models.OrderStatusNew, models.OrderStatusInvalid)
+	require.NoError(t, err)
+
+	createOrder(t, b, user.ID, time.Now())
+	createOrder(t, b, user.ID, time.Now())
+	invalid := createOrder(t, b, user.ID, time.Now())
+	_, err = b.Orders.UpdateOrderStatus(ctx, invalid.ID, "", models.OrderStatusInvalid)
+	require.NoError(t, err)
+
+	after, err := b.Orders.CountByStatus(ctx, models.OrderStatusNew, models.OrderStatusInvalid)
+	require.NoError(t, err)
+	// Other tests may run concurrently, so only check for at least our orders.
+	assert.GreaterOrEqual(t, after[models.OrderStatusNew]-before[models.OrderStatusNew], int64(2))
+	assert.GreaterOrEqual(t, after[models.OrderStatusInvalid]-before[models.OrderStatusInvalid], int64(1))
+	assert.NotContains(t, after, models.OrderStatusProcessed)
+}
+
+func testBalance(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	balance, err := b.Ledger.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(0), balance)
+
+	accrualOrder := nextID()
+	require.NoError(t, b.Ledger.Post(ctx, models.NewAccrualEntry(user.ID, accrualOrder, money.FromInt(100))))
+	require.NoError(t, b.Ledger.Post(ctx, models.NewAccrualEntry(user.ID, accrualOrder, money.FromInt(100))), "reposting is a no-op")
+	require.NoError(t, b.Ledger.Post(ctx, models.NewAccrualEntry(user.ID, nextID(), money.MustParse("50.5"))))
+
+	first := &models.Withdrawal{OrderID: nextID(), UserID: user.ID, Sum: money.FromInt(30), ProcessedAt: time.Now().Add(-time.Minute)}
+	second := &models.Withdrawal{OrderID: nextID(), UserID: user.ID, Sum: money.MustParse("0.5"), ProcessedAt: time.Now()}
+	require.NoError(t, b.Ledger.Withdraw(ctx, first))
+	require.NoError(t, b.Ledger.Withdraw(ctx, second))
+	err = b.Ledger.Withdraw(ctx, &models.Withdrawal{OrderID: nextID(), UserID: user.ID, Sum: money.FromInt(121), ProcessedAt: time.Now()})
+	assert.ErrorIs(t, err, storage.ErrInsufficientFunds)
+	err = b.Ledger.Withdraw(ctx, &models.Withdrawal{OrderID: first.OrderID, UserID: user.ID, Sum: money.FromInt(1), ProcessedAt: time.Now()})
+	assert.ErrorIs(t, err, storage.ErrOrderAlreadyExists)
+
+	balance, err = b.Ledger.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromInt(120), balance)
+	withdrawn, err := b.Ledger.GetWithdrawnTotal(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParse("30.5"), withdrawn)
+
+	withdrawals, err := b.Ledger.GetWithdrawals(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, withdrawals, 2)
+	assert.Equal(t, first.OrderID, withdrawals[0].OrderID)
+	assert.Equal(t, money.FromInt(30), withdrawals[0].Sum)
+	assert.Equal(t, second.OrderID, withdrawals[1].OrderID)
+	got, err := b.Ledger.GetWithdrawal(ctx, second.OrderID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.UserID)
+	assert.Equal(t, money.MustParse("0.5"), got.Sum)
+
+	min := money.FromInt(1)
+	listed, err := b.Ledger.ListWithdrawals(ctx, user.ID, models.ListFilter{Limit: 10, MinAmount: &min})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, first.OrderID, listed[0].OrderID)
+}
+
+func testWithdrawalOwnership(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	owner := createUser(t, b)
+	other := createUser(t, b)
+	require.NoError(t, b.Ledger.Post(ctx, models.NewAccrualEntry(owner.ID, nextID(), money.FromInt(10))))
+	require.NoError(t, b.Ledger.Post(ctx, models.NewAccrualEntry(other.ID, nextID(), money.FromInt(10))))
+
+	orderID := nextID()
+	require.NoError(t, b.Ledger.Withdraw(ctx, &models.Withdrawal{OrderID: orderID, UserID: owner.ID, Sum: money.FromInt(1), ProcessedAt: time.Now()}))
+	err := b.Ledger.Withdraw(ctx, &models.Withdrawal{OrderID: orderID, UserID: other.ID, Sum: money.FromInt(1), ProcessedAt: time.Now()})
+	assert.ErrorIs(t, err, storage.ErrOrderCreatedByOtherUser)
+
+	balance, err := b.Ledger.GetBalance(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromInt(10), balance)
+}
+
+func testAdjust(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	require.NoError(t, b.Ledger.Post(ctx, models.NewAccrualEntry(user.ID, nextID(), money.FromInt(10))))
+
+	balance, err := b.Ledger.Adjust(ctx, &models.Adjustment{UserID: user.ID, Amount: money.FromInt(5), Reason: "goodwill"})
+	require.NoError(t, err)
+	assert.Equal(t, money.FromInt(15), balance)
+	_, err = b.Ledger.Adjust(ctx, &models.Adjustment{UserID: user.ID, Amount: money.FromInt(-16), Reason: "clawback"})
+	assert.ErrorIs(t, err, storage.ErrInsufficientFunds)
+	balance, err = b.Ledger.Adjust(ctx, &models.Adjustment{UserID: user.ID, Amount: money.FromInt(-15), Reason: "clawback"})
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(0), balance)
+
+	balance, err = b.Ledger.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(0), balance)
+	withdrawn, err := b.Ledger.GetWithdrawnTotal(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(0), withdrawn, "adjustments aren't withdrawals")
+}
+
+func testConcurrentWithdrawals(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	require.NoError(t, b.Ledger.Post(ctx, models.NewAccrualEntry(user.ID, nextID(), money.FromInt(20))))
+
+	const attempts = 50
+	var succeeded, rejected atomic.Int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(orderID int) {
+			defer wg.Done()
+			err := b.Ledger.Withdraw(ctx, &models.Withdrawal{OrderID: orderID, UserID: user.ID, Sum: money.FromInt(1), ProcessedAt: time.Now()})
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case errors.Is(err, storage.ErrInsufficientFunds):
+				rejected.Add(1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(nextID())
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(20), succeeded.Load())
+	assert.Equal(t, int32(attempts-20), rejected.Load())
+	balance, err := b.Ledger.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(0), balance)
+}
+
+// postAccrual posts an accrual whose points expire at expiresAt, or never if
+// it's nil.
+func postAccrual(t *testing.T, b *Backend, userID int, sum money.Amount, expiresAt *time.Time) {
+	entry := models.NewAccrualEntry(userID, nextID(), sum)
+	entry.ExpiresAt = expiresAt
+	require.NoError(t, b.Ledger.Post(context.Background(), entry))
+}
+
+func testLotsConsumedOldestFirst(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	now := time.Now().Truncate(time.Microsecond)
+	first, second := now.Add(time.Hour), now.Add(2*time.Hour)
+	postAccrual(t, b, user.ID, money.FromInt(10), &first)
+	postAccrual(t, b, user.ID, money.FromInt(10), &second)
+	postAccrual(t, b, user.ID, money.FromInt(5), nil)
+
+	require.NoError(t, b.Ledger.Withdraw(ctx, &models.Withdrawal{OrderID: nextID(), UserID: user.ID, Sum: money.FromInt(12), ProcessedAt: time.Now()}))
+	expirations, err := b.Ledger.ListExpirations(ctx, user.ID, now, nil)
+	require.NoError(t, err)
+	require.Len(t, expirations, 1, "the first lot is used up")
+	assert.Equal(t, money.FromInt(8), expirations[0].Amount)
+	assert.True(t, second.Equal(expirations[0].ExpiresAt))
+
+	_, err = b.Ledger.Adjust(ctx, &models.Adjustment{UserID: user.ID, Amount: money.FromInt(-3), Reason: "clawback"})
+	require.NoError(t, err)
+	until := now.Add(90 * time.Minute)
+	expirations, err = b.Ledger.ListExpirations(ctx, user.ID, now, &until)
+	require.NoError(t, err)
+	assert.Empty(t, expirations)
+	expirations, err = b.Ledger.ListExpirations(ctx, user.ID, now, nil)
+	require.NoError(t, err)
+	require.Len(t, expirations, 1)
+	assert.Equal(t, money.FromInt(5), expirations[0].Amount)
+
+	balance, err := b.Ledger.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromInt(10), balance, "five expiring and five that never expire")
+}
+
+func testExpirePoints(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	postAccrual(t, b, user.ID, money.FromInt(10), &past)
+	postAccrual(t, b, user.ID, money.FromInt(10), &future)
+
+	balance, err := b.Ledger.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromInt(10), balance, "points past their expiry date don't count")
+	err = b.Ledger.Withdraw(ctx, &models.Withdrawal{OrderID: nextID(), UserID: user.ID, Sum: money.FromInt(11), ProcessedAt: time.Now()})
+	assert.ErrorIs(t, err, storage.ErrInsufficientFunds)
+	require.NoError(t, b.Ledger.Withdraw(ctx, &models.Withdrawal{OrderID: nextID(), UserID: user.ID, Sum: money.FromInt(4), ProcessedAt: time.Now()}))
+
+	expired := func() money.Amount {
+		entries, err := b.Ledger.ExpirePoints(ctx, time.Now(), 1000)
+		require.NoError(t, err)
+		var total money.Amount
+		for _, e := range entries {
+			if e.UserID == user.ID {
+				assert.Equal(t, models.EntryKindExpiry, e.Kind)
+				total -= e.Postings[0].Amount
+			}
+		}
+		return total
+	}
+	assert.Equal(t, money.FromInt(10), expired(), "the expired lot wasn't touched by the withdrawal")
+	assert.Equal(t, money.Amount(0), expired(), "expiring twice is a no-op")
+
+	balance, err = b.Ledger.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromInt(6), balance)
+	expirations, err := b.Ledger.ListExpirations(ctx, user.ID, time.Now(), nil)
+	require.NoError(t, err)
+	require.Len(t, expirations, 1)
+	assert.Equal(t, money.FromInt(6), expirations[0].Amount)
+	balances, err := b.Ledger.GetTrialBalance(ctx)
+	require.NoError(t, err)
+	for _, ab := range balances {
+		if ab.Account.Code == models.UserAccountCode(user.ID) {
+			assert.Equal(t, money.FromInt(6), ab.Balance, "the expiry entry debited the account")
+		}
+	}
+}
+
+func testScheduleExpiry(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	postAccrual(t, b, user.ID, money.FromInt(10), nil)
+	_, err := b.Ledger.Adjust(ctx, &models.Adjustment{UserID: user.ID, Amount: money.FromInt(5), Reason: "goodwill"})
+	require.NoError(t, err)
+
+	now := time.Now()
+	expiresAt := now.AddDate(1, 0, 0).Truncate(time.Microsecond)
+	scheduled, err := b.Ledger.ScheduleExpiry(ctx, expiresAt)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, scheduled, int64(1))
+	expirations, err := b.Ledger.ListExpirations(ctx, user.ID, now, nil)
+	require.NoError(t, err)
+	require.Len(t, expirations, 1, "adjustments never expire")
+	assert.Equal(t, money.FromInt(10), expirations[0].Amount)
+	assert.True(t, expiresAt.Equal(expirations[0].ExpiresAt), "%s != %s", expiresAt, expirations[0].ExpiresAt)
+
+	later := expiresAt.AddDate(1, 0, 0)
+	_, err = b.Ledger.ScheduleExpiry(ctx, later)
+	require.NoError(t, err)
+	expirations, err = b.Ledger.ListExpirations(ctx, user.ID, now, nil)
+	require.NoError(t, err)
+	require.Len(t, expirations, 1)
+	assert.True(t, expiresAt.Equal(expirations[0].ExpiresAt), "scheduled lots keep their expiry date")
+}
+
+func testAccruedTotal(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	now := time.Now()
+	old := models.NewAccrualEntry(user.ID, nextID(), money.FromInt(1000))
+	old.CreatedAt = now.AddDate(-1, 0, -1)
+	require.NoError(t, b.Ledger.Post(ctx, old))
+	postAccrual(t, b, user.ID, money.FromInt(300), nil)
+	postAccrual(t, b, user.ID, money.MustParse("0.5"), nil)
+	multiplied := models.NewAccrualEntry(user.ID, nextID(), money.FromInt(150))
+	multiplied.Accrued = money.FromInt(100)
+	require.NoError(t, b.Ledger.Post(ctx, multiplied))
+	_, err := b.Ledger.Adjust(ctx, &models.Adjustment{UserID: user.ID, Amount: money.FromInt(50), Reason: "goodwill"})
+	require.NoError(t, err)
+	require.NoError(t, b.Ledger.Withdraw(ctx, &models.Withdrawal{OrderID: nextID(), UserID: user.ID, Sum: money.FromInt(100), ProcessedAt: now}))
+
+	accrued, err := b.Ledger.GetAccruedTotal(ctx, user.ID, models.TierWindowStart(now))
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParse("400.5"), accrued, "only accruals in the window count, spent or not and before multipliers")
+	accrued, err = b.Ledger.GetAccruedTotal(ctx, user.ID, now.AddDate(-2, 0, 0))
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParse("1400.5"), accrued)
+	accrued, err = b.Ledger.GetAccruedTotal(ctx, createUser(t, b).ID, models.TierWindowStart(now))
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(0), accrued)
+}
+
+// testTierRules changes the rules shared by the whole database, so it puts
+// them back when it's done.
+func testTierRules(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	rules, err := b.Tiers.ListRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, len(models.Tiers))
+	for i, rule := range rules {
+		assert.Equal(t, models.Tiers[i], rule.Tier, "sorted by threshold")
+	}
+	assert.NoError(t, models.ValidateTierRules(rules))
+	gold := *rules[2]
+	t.Cleanup(func() {
+		_, err := b.Tiers.UpdateRule(context.Background(), &gold)
+		assert.NoError(t, err)
+	})
+
+	update := &models.TierRule{Tier: models.TierGold, MinAccrued: gold.MinAccrued + money.FromInt(1), Multiplier: money.MustParse("1.75")}
+	updated, err := b.Tiers.UpdateRule(ctx, update)
+	require.NoError(t, err)
+	assert.Equal(t, update.MinAccrued, updated.MinAccrued)
+	assert.Equal(t, update.Multiplier, updated.Multiplier)
+	assert.False(t, updated.UpdatedAt.IsZero())
+	rules, err = b.Tiers.ListRules(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, update.Multiplier, rules[2].Multiplier)
+
+	_, err = b.Tiers.UpdateRule(ctx, &models.TierRule{Tier: "PLATINUM", MinAccrued: money.FromInt(1), Multiplier: money.FromInt(1)})
+	assert.ErrorIs(t, err, storage.ErrTierNotFound)
+}
+
+func testTierHistory(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	history, err := b.Tiers.GetHistory(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	record := func(tier string, accrued money.Amount) bool {
+		recorded, err := b.Tiers.RecordChange(ctx, models.NewTierChange(user.ID, tier, accrued))
+		require.NoError(t, err)
+		return recorded
+	}
+	assert.True(t, record(models.TierBronze, money.FromInt(10)))
+	assert.False(t, record(models.TierBronze, money.FromInt(20)), "the user is in the tier already")
+	assert.True(t, record(models.TierSilver, money.FromInt(1200)))
+	assert.True(t, record(models.TierBronze, money.FromInt(900)), "tiers can go down")
+	assert.True(t, record(models.TierSilver, money.FromInt(1000)))
+	assert.True(t, record(models.TierBronze, money.FromInt(10)))
+
+	history, err = b.Tiers.GetHistory(ctx, user.ID)
+	require.NoError(t, err)
+	tiers := make([]string, len(history))
+	for i, c := range history {
+		tiers[i] = c.Tier
+		assert.Equal(t, user.ID, c.UserID)
+	}
+	assert.Equal(t, []string{models.TierBronze, models.TierSilver, models.TierBronze, models.TierSilver, models.TierBronze}, tiers, "newest first")
+	assert.Equal(t, money.FromInt(1000), history[1].Accrued)
+	assert.False(t, history[0].ChangedAt.IsZero())
+
+	other, err := b.Tiers.GetHistory(ctx, createUser(t, b).ID)
+	require.NoError(t, err)
+	assert.Empty(t, other)
+}
+
+func testIdempotencyLease(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	newKey := func(fingerprint string, at time.Time) *models.IdempotencyKey {
+		return &models.IdempotencyKey{
+			UserID:      user.ID,
+			Key:         "leaseKey",
+			Fingerprint: fingerprint,
+			CreatedAt:   at,
+			ExpiresAt:   at.Add(time.Hour),
+			LockedUntil: at.Add(time.Minute),
+		}
+	}
+	now := time.Now()
+	first, reserved, err := b.Idempotency.Reserve(ctx, newKey("fp", now))
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	_, reserved, err = b.Idempotency.Reserve(ctx, newKey("fp", now.Add(30*time.Second)))
+	require.NoError(t, err)
+	assert.False(t, reserved, "a live lease holds the key")
+
+	later := now.Add(2 * time.Minute)
+	stored, reserved, err := b.Idempotency.Reserve(ctx, newKey("other", later))
+	require.NoError(t, err)
+	assert.False(t, reserved, "a different request can't take the key over")
+	assert.Equal(t, "fp", stored.Fingerprint)
+
+	second, reserved, err := b.Idempotency.Reserve(ctx, newKey("fp", later))
+	require.NoError(t, err)
+	require.True(t, reserved, "a retry takes over a key left in progress past its lease")
+
+	// The first request finishing late touches nothing of the retry's.
+	require.NoError(t, b.Idempotency.Release(ctx, first))
+	first.StatusCode = http.StatusOK
+	require.NoError(t, b.Idempotency.Complete(ctx, first))
+	stored, reserved, err = b.Idempotency.Reserve(ctx, newKey("fp", later.Add(time.Second)))
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.False(t, stored.Completed())
+
+	second.StatusCode = http.StatusAccepted
+	require.NoError(t, b.Idempotency.Complete(ctx, second))
+	stored, reserved, err = b.Idempotency.Reserve(ctx, newKey("fp", later.Add(2*time.Minute)))
+	require.NoError(t, err)
+	assert.False(t, reserved, "a completed key outlives its lease")
+	assert.Equal(t, http.StatusAccepted, stored.StatusCode)
+}
+
+func testTxCommit(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	var user *models.User
+	var order *models.Order
+	err := b.Tx.WithinTx(ctx, func(repos *storage.Repos) error {
+		var err error
+		user, err = repos.Users.Create(ctx, &models.User{Login: fmt.Sprintf("contract-%d", nextID()), EncryptedPwd: "encryptedPwd"})
+		if err != nil {
+			return err
+		}
+		order, err = repos.Orders.Create(ctx, &models.Order{ID: nextID(), UserID: user.ID, Status: models.OrderStatusNew, UploadedAt: time.Now()})
+		if err != nil {
+			return err
+		}
+		if err = repos.Ledger.Post(ctx, models.NewAccrualEntry(user.ID, order.ID, money.FromInt(10))); err != nil {
+			return err
+		}
+		// The transaction sees its own changes.
+		balance, err := repos.Ledger.GetBalance(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, money.FromInt(10), balance)
+		return nil
+	})
+	require.NoError(t, err)
+
+	got, err := b.Orders.GetByID(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.UserID)
+	balance, err := b.Ledger.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromInt(10), balance)
+}
+
+func testTxRollback(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	owner := createUser(t, b)
+	login := fmt.Sprintf("contract-%d", nextID())
+	orderID := nextID()
+	errAbort := errors.New("abort")
+	err := b.Tx.WithinTx(ctx, func(repos *storage.Repos) error {
+		if _, err := repos.Users.Create(ctx, &models.User{Login: login, EncryptedPwd: "encryptedPwd"}); err != nil {
+			return err
+		}
+		if _, err := repos.Orders.Create(ctx, &models.Order{ID: orderID, UserID: owner.ID, Status: models.OrderStatusNew, UploadedAt: time.Now()}); err != nil {
+			return err
+		}
+		if err := repos.Ledger.Post(ctx, models.NewAccrualEntry(owner.ID, orderID, money.FromInt(10))); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+
+	exists, err := b.Users.Exists(ctx, login)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = b.Orders.Exists(ctx, orderID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	balance, err := b.Ledger.GetBalance(ctx, owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(0), balance)
+}
+
+func testTxNestedFailure(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	user := createUser(t, b)
+	taken := createOrder(t, b, user.ID, time.Now())
+	orderID := nextID()
+	err := b.Tx.WithinTx(ctx, func(repos *storage.Repos) error {
+		_, err := repos.Orders.Create(ctx, &models.Order{ID: taken.ID, UserID: user.ID, Status: models.OrderStatusNew, UploadedAt: time.Now()})
+		assert.ErrorIs(t, err, storage.ErrOrderAlreadyExists)
+		// The failed insert doesn't abort the transaction.
+		if _, err = repos.Orders.GetByID(ctx, taken.ID); err != nil {
+			return err
+		}
+		_, err = repos.Orders.Create(ctx, &models.Order{ID: orderID, UserID: user.ID, Status: models.OrderStatusNew, UploadedAt: time.Now()})
+		return err
+	})
+	require.NoError(t, err)
+
+	exists, err := b.Orders.Exists(ctx, orderID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func testConcurrentOrderUploads(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	orderID := nextID()
+	users := make([]*models.User, 10)
+	for i := range users {
+		users[i] = createUser(t, b)
+	}
+
+	var succeeded, rejected atomic.Int32
+	wg := sync.WaitGroup{}
+	for _, user := range users {
+		wg.Add(1)
+		go func(userID int) {
+			defer wg.Done()
+			err := b.Tx.WithinTx(ctx, func(repos *storage.Repos) error {
+				_, err := repos.Orders.Create(ctx, &models.Order{ID: orderID, UserID: userID, Status: models.OrderStatusNew, UploadedAt: time.Now()})
+				return err
+			})
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case errors.Is(err, storage.ErrOrderAlreadyExists):
+				rejected.Add(1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(user.ID)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), succeeded.Load())
+	assert.Equal(t, int32(len(users)-1), rejected.Load())
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/tracing"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"sort"
+	"time"
+)
+
+// ListTierRules returns the tier rules sorted by threshold.
+func (s *Storage) ListTierRules(ctx context.Context) ([]*models.TierRule, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.ListTierRules")
+	defer span.End()
+	rules, err := s.tierRepo.ListRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.tierRepo.ListRules: %w", err)
+	}
+	return rules, nil
+}
+
+// UpdateTierRule changes the threshold and multiplier of a tier. The tiers
+// must stay in order of their thresholds. Users keep their tier until it's
+// re-evaluated on their next accrual.
+func (s *Storage) UpdateTierRule(ctx context.Context, rule *models.TierRule) (*models.TierRule, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.UpdateTierRule")
+	defer span.End()
+	rules, err := s.tierRepo.ListRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.tierRepo.ListRules: %w", err)
+	}
+	found := false
+	for i, r := range rules {
+		if r.Tier == rule.Tier {
+			rules[i] = rule
+			found = true
+		}
+	}
+	if !found {
+		return nil, ErrTierNotFound
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].MinAccrued < rules[j].MinAccrued
+	})
+	if err = models.ValidateTierRules(rules); err != nil {
+		return nil, err
+	}
+	updated, err := s.tierRepo.UpdateRule(ctx, rule)
+	if err != nil {
+		return nil, fmt.Errorf("s.tierRepo.UpdateRule: %w", err)
+	}
+	return updated, nil
+}
+
+// tierFor returns the tier the user's accruals in the tier window reach, or
+// nil if there are no tiers, and what they accrued in the window.
+func tierFor(ctx context.Context, repos *Repos, userID int) (*models.TierRule, money.Amount, error) {
+	rules, err := repos.Tiers.ListRules(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("s.tierRepo.ListRules: %w", err)
+	}
+	if len(rules) == 0 {
+		return nil, 0, nil
+	}
+	accrued, err := repos.Ledger.GetAccruedTotal(ctx, userID, models.TierWindowStart(time.Now()))
+	if err != nil {
+		return nil, 0, fmt.Errorf("s.ledgerRepo.GetAccruedTotal: %w", err)
+	}
+	return models.TierFor(rules, accrued), accrued, nil
+}
+
+// evaluateTier moves the user to the tier their accruals in the tier window
+// reach. It returns the change recorded in their tier history, or nil if
+// their tier stays the same.
+func evaluateTier(ctx context.Context, repos *Repos, userID int) (*models.TierChange, error) {
+	tier, accrued, err := tierFor(ctx, repos, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tier == nil {
+		return nil, nil
+	}
+	change := models.NewTierChange(userID, tier.Tier, accrued)
+	recorded, err := repos.Tiers.RecordChange(ctx, change)
+	if err != nil {
+		return nil, fmt.Errorf("s.tierRepo.RecordChange: %w", err)
+	}
+	if !recorded {
+		return nil, nil
+	}
+	return change, nil
+}
+
+// GetUserTier returns the user's tier, what they accrued for processed orders
+// in the tier window and their tier history.
+func (s *Storage) GetUserTier(ctx context.Context, userID int) (*models.TierStatus, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.GetUserTier")
+	defer span.End()
+	accrued, err := s.ledgerRepo.GetAccruedTotal(ctx, userID, models.TierWindowStart(time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("s.ledgerRepo.GetAccruedTotal: %w", err)
+	}
+	return s.tierStatus(ctx, userID, accrued)
+}
+
+func (s *Storage) tierStatus(ctx context.Context, userID int, accrued money.Amount) (*models.TierStatus, error) {
+	rules, err := s.tierRepo.ListRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.tierRepo.ListRules: %w", err)
+	}
+	history, err := s.tierRepo.GetHistory(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("s.tierRepo.GetHistory: %w", err)
+	}
+	return models.NewTierStatus(rules, accrued, history), nil
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage/mocks"
+	"github.com/vindosVP/loyalty-system/pkg/money"
+	"testing"
+)
+
+func testTierRules() []*models.TierRule {
+	return []*models.TierRule{
+		{Tier: models.TierBronze, MinAccrued: 0, Multiplier: money.FromInt(1)},
+		{Tier: models.TierSilver, MinAccrued: money.FromInt(1000), Multiplier: money.MustParse("1.25")},
+		{Tier: models.TierGold, MinAccrued: money.FromInt(5000), Multiplier: money.MustParse("1.5")},
+	}
+}
+
+func TestStorage_UpdateTierRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    *models.TierRule
+		wantErr error
+	}{
+		{
+			name: "ok",
+			rule: &models.TierRule{Tier: models.TierGold, MinAccrued: money.FromInt(4000), Multiplier: money.MustParse("1.75")},
+		},
+		{
+			name:    "unknown tier",
+			rule:    &models.TierRule{Tier: "PLATINUM", MinAccrued: money.FromInt(9000), Multiplier: money.FromInt(2)},
+			wantErr: ErrTierNotFound,
+		},
+		{
+			name:    "below the tier under it",
+			rule:    &models.TierRule{Tier: models.TierGold, MinAccrued: money.FromInt(500), Multiplier: money.MustParse("1.75")},
+			wantErr: models.ErrTierRulesOutOfOrder,
+		},
+		{
+			name:    "bronze above zero",
+			rule:    &models.TierRule{Tier: models.TierBronze, MinAccrued: money.FromInt(10), Multiplier: money.FromInt(1)},
+			wantErr: models.ErrTierRulesOutOfOrder,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tierRepo := mocks.NewTierRepo(t)
+			s := New(mocks.NewUserRepo(t), mocks.NewOrderRepo(t), mocks.NewLedgerRepo(t), mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), tierRepo, nil, nil)
+			tierRepo.On("ListRules", mock.Anything).Return(testTierRules(), nil)
+			if tt.wantErr == nil {
+				tierRepo.On("UpdateRule", mock.Anything, tt.rule).Return(tt.rule, nil)
+			}
+
+			updated, err := s.UpdateTierRule(context.Background(), tt.rule)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.rule, updated)
+		})
+	}
+}
+
+func TestStorage_UpdateOrderAppliesTier(t *testing.T) {
+	unexpectedError := errors.New("unexpected error")
+
+	tests := []struct {
+		name         string
+		before       money.Amount
+		after        money.Amount
+		accrual      money.Amount
+		wantCredited money.Amount
+		recorded     bool
+		recordErr    error
+		wantErr      error
+	}{
+		{
+			name:         "bronze",
+			before:       money.FromInt(100),
+			after:        money.FromInt(200),
+			accrual:      money.FromInt(100),
+			wantCredited: money.FromInt(100),
+		},
+		{
+			name:         "silver from the window",
+			before:       money.FromInt(1200),
+			after:        money.MustParse("1929.98"),
+			accrual:      money.MustParse("729.98"),
+			wantCredited: money.MustParse("912.48"),
+		},
+		{
+			name:         "moved up at the old tier",
+			before:       money.FromInt(4900),
+			after:        money.FromInt(5100),
+			accrual:      money.FromInt(200),
+			wantCredited: money.FromInt(250),
+			recorded:     true,
+		},
+		{
+			name:         "record error",
+			before:       money.FromInt(4900),
+			after:        money.FromInt(5100),
+			accrual:      money.FromInt(200),
+			wantCredited: money.FromInt(250),
+			recordErr:    unexpectedError,
+			wantErr:      unexpectedError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderRepo := mocks.NewOrderRepo(t)
+			ledgerRepo := mocks.NewLedgerRepo(t)
+			tierRepo := mocks.NewTierRepo(t)
+			s := New(mocks.NewUserRepo(t), orderRepo, ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), tierRepo, nil, nil)
+			order := &models.Order{ID: 7703824164, UserID: 1, Status: models.OrderStatusProcessed, Sum: tt.wantCredited}
+			orderRepo.On("GetByID", mock.Anything, order.ID).Return(&models.Order{ID: order.ID, UserID: 1}, nil)
+			tierRepo.On("ListRules", mock.Anything).Return(testTierRules(), nil)
+			ledgerRepo.On("GetAccruedTotal", mock.Anything, 1, mock.Anything).Return(tt.before, nil).Once()
+			orderRepo.On("UpdateOrder", mock.Anything, order.ID, "worker", models.OrderStatusProcessed, tt.wantCredited).Return(order, nil)
+			ledgerRepo.On("Post", mock.Anything, mock.MatchedBy(func(e *models.Entry) bool {
+				return e.Accrued == tt.accrual && e.Postings[0].Amount == tt.wantCredited && e.Balanced()
+			})).Return(nil)
+			ledgerRepo.On("GetAccruedTotal", mock.Anything, 1, mock.Anything).Return(tt.after, nil).Once()
+			tierRepo.On("RecordChange", mock.Anything, mock.MatchedBy(func(c *models.TierChange) bool {
+				return c.UserID == 1 && c.Accrued == tt.after
+			})).Return(tt.recorded, tt.recordErr)
+
+			result, err := s.UpdateOrder(context.Background(), order.ID, "worker", models.OrderStatusProcessed, tt.accrual)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, order, result)
+		})
+	}
+}
+
+func TestStorage_GetUserTier(t *testing.T) {
+	ledgerRepo := mocks.NewLedgerRepo(t)
+	tierRepo := mocks.NewTierRepo(t)
+	s := New(mocks.NewUserRepo(t), mocks.NewOrderRepo(t), ledgerRepo, mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), mocks.NewWebhookRepo(t), tierRepo, nil, nil)
+	history := []*models.TierChange{models.NewTierChange(1, models.TierSilver, money.FromInt(1000))}
+	ledgerRepo.On("GetAccruedTotal", mock.Anything, 1, mock.Anything).Return(money.FromInt(1500), nil)
+	tierRepo.On("ListRules", mock.Anything).Return(testTierRules(), nil)
+	tierRepo.On("GetHistory", mock.Anything, 1).Return(history, nil)
+
+	status, err := s.GetUserTier(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.TierSilver, status.Current.Tier)
+	assert.Equal(t, models.TierGold, status.Next.Tier)
+	assert.Equal(t, money.FromInt(1500), status.Accrued)
+	assert.Equal(t, history, status.History)
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/tracing"
+	"time"
+)
+
+func (s *Storage) CreateWebhookEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) (*models.WebhookEndpoint, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.CreateWebhookEndpoint")
+	defer span.End()
+	created, err := s.webhookRepo.CreateEndpoint(ctx, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s.webhookRepo.CreateEndpoint: %w", err)
+	}
+	return created, nil
+}
+
+// ListWebhookEndpoints returns the user's endpoints, or the partner endpoints
+// if userID is zero.
+func (s *Storage) ListWebhookEndpoints(ctx context.Context, userID int) ([]*models.WebhookEndpoint, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.ListWebhookEndpoints")
+	defer span.End()
+	endpoints, err := s.webhookRepo.ListEndpoints(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("s.webhookRepo.ListEndpoints: %w", err)
+	}
+	return endpoints, nil
+}
+
+// DeleteUsersWebhookEndpoint deletes one of the user's endpoints. It returns
+// ErrWebhookEndpointNotFound for an endpoint the user doesn't own.
+func (s *Storage) DeleteUsersWebhookEndpoint(ctx context.Context, userID int, id int) error {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.DeleteUsersWebhookEndpoint")
+	defer span.End()
+	endpoint, err := s.webhookRepo.GetEndpoint(ctx, id)
+	if err != nil {
+		return fmt.Errorf("s.webhookRepo.GetEndpoint: %w", err)
+	}
+	if endpoint.UserID == nil || *endpoint.UserID != userID {
+		return ErrWebhookEndpointNotFound
+	}
+	return s.DeleteWebhookEndpoint(ctx, id)
+}
+
+func (s *Storage) DeleteWebhookEndpoint(ctx context.Context, id int) error {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.DeleteWebhookEndpoint")
+	defer span.End()
+	err := s.webhookRepo.DeleteEndpoint(ctx, id)
+	if err != nil {
+		return fmt.Errorf("s.webhookRepo.DeleteEndpoint: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) ClaimWebhookDeliveries(ctx context.Context, owner string, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.ClaimWebhookDeliveries")
+	defer span.End()
+	deliveries, err := s.webhookRepo.ClaimDeliveries(ctx, owner, limit, lease)
+	if err != nil {
+		return nil, fmt.Errorf("s.webhookRepo.ClaimDeliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (s *Storage) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.UpdateWebhookDelivery")
+	defer span.End()
+	err := s.webhookRepo.UpdateDelivery(ctx, delivery)
+	if err != nil {
+		return fmt.Errorf("s.webhookRepo.UpdateDelivery: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) ListWebhookDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.ListWebhookDeliveries")
+	defer span.End()
+	deliveries, err := s.webhookRepo.ListDeliveries(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("s.webhookRepo.ListDeliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// ReplayWebhookDeliveries requeues dead-lettered deliveries of the endpoint,
+// or the single delivery if deliveryID isn't zero.
+func (s *Storage) ReplayWebhookDeliveries(ctx context.Context, endpointID int, deliveryID int64) (int64, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Storage.ReplayWebhookDeliveries")
+	defer span.End()
+	replayed, err := s.webhookRepo.ReplayDeadDeliveries(ctx, endpointID, deliveryID)
+	if err != nil {
+		return 0, fmt.Errorf("s.webhookRepo.ReplayDeadDeliveries: %w", err)
+	}
+	return replayed, nil
+}
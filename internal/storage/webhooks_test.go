@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/storage/mocks"
+	"testing"
+)
+
+func TestStorage_DeleteUsersWebhookEndpoint(t *testing.T) {
+	owner := 1
+	unexpectedError := errors.New("unexpected error")
+
+	type getEndpointMock struct {
+		result *models.WebhookEndpoint
+		err    error
+	}
+	tests := []struct {
+		name            string
+		getEndpointMock getEndpointMock
+		deleteNeeded    bool
+		wantErr         error
+	}{
+		{
+			name:            "own endpoint",
+			getEndpointMock: getEndpointMock{result: &models.WebhookEndpoint{ID: 10, UserID: &owner}},
+			deleteNeeded:    true,
+		},
+		{
+			name:            "other user's endpoint",
+			getEndpointMock: getEndpointMock{result: &models.WebhookEndpoint{ID: 10, UserID: new(int)}},
+			wantErr:         ErrWebhookEndpointNotFound,
+		},
+		{
+			name:            "partner endpoint",
+			getEndpointMock: getEndpointMock{result: &models.WebhookEndpoint{ID: 10}},
+			wantErr:         ErrWebhookEndpointNotFound,
+		},
+		{
+			name:            "not found",
+			getEndpointMock: getEndpointMock{err: ErrWebhookEndpointNotFound},
+			wantErr:         ErrWebhookEndpointNotFound,
+		},
+		{
+			name:            "unexpected error",
+			getEndpointMock: getEndpointMock{err: unexpectedError},
+			wantErr:         unexpectedError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhookRepo := mocks.NewWebhookRepo(t)
+			s := New(mocks.NewUserRepo(t), mocks.NewOrderRepo(t), mocks.NewLedgerRepo(t), mocks.NewSessionRepo(t), mocks.NewAuditRepo(t), mocks.NewIdempotencyRepo(t), webhookRepo, mocks.NewTierRepo(t), nil, nil)
+			webhookRepo.On("GetEndpoint", mock.Anything, 10).Return(tt.getEndpointMock.result, tt.getEndpointMock.err)
+			if tt.deleteNeeded {
+				webhookRepo.On("DeleteEndpoint", mock.Anything, 10).Return(nil)
+			}
+
+			err := s.DeleteUsersWebhookEndpoint(context.Background(), owner, 10)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), ExporterNone)
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), "zipkin")
+	assert.ErrorIs(t, err, ErrUnknownExporter)
+}
+
+func TestStdoutExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exp, err := newStdoutExporter(&buf)
+	require.NoError(t, err)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+
+	_, span := tp.Tracer("test").Start(context.Background(), "Storage.CreateWithdrawal")
+	span.End()
+	require.NoError(t, tp.Shutdown(context.Background()))
+	assert.Contains(t, buf.String(), `"Name":"Storage.CreateWithdrawal"`)
+}
+
+func TestQuerySpanName(t *testing.T) {
+	assert.Equal(t, "db.select", querySpanName("\n  SELECT id from orders"))
+	assert.Equal(t, "db.with", querySpanName("with claimable as (select 1) select * from claimable"))
+	assert.Equal(t, "db.query", querySpanName(""))
+}
@@ -0,0 +1,194 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/pkg/logger"
+	"github.com/vindosVP/loyalty-system/pkg/netguard"
+	"github.com/vindosVP/loyalty-system/pkg/worker"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	EventHeader    = "X-Gophermart-Event"
+	DeliveryHeader = "X-Gophermart-Delivery"
+
+	retryBase      = 30 * time.Second
+	retryMax       = 6 * time.Hour
+	maxErrorLength = 500
+	concurrency    = 10
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Storage
+type Storage interface {
+	ClaimWebhookDeliveries(ctx context.Context, owner string, limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+}
+
+// Dispatcher sends queued webhook deliveries. A failed delivery is retried with
+// exponential backoff and dead-lettered after MaxAttempts attempts.
+type Dispatcher struct {
+	Interval     time.Duration
+	BatchSize    int
+	LeaseTimeout time.Duration
+	MaxAttempts  int
+	WorkerID     string
+	Done         <-chan struct{}
+	Storage      Storage
+	Client       *http.Client
+	retryDelay   func(attempt int) time.Duration
+}
+
+func New(storage Storage, maxAttempts int, timeout time.Duration) *Dispatcher {
+	return &Dispatcher{
+		Interval:     5 * time.Second,
+		BatchSize:    100,
+		LeaseTimeout: timeout + time.Minute,
+		MaxAttempts:  maxAttempts,
+		WorkerID:     worker.NewID(),
+		Storage:      storage,
+		Client:       newClient(timeout, netguard.Control),
+		retryDelay:   worker.Backoff{Base: retryBase, Max: retryMax}.Delay,
+	}
+}
+
+// newClient returns the client deliveries are sent with. control vets every
+// address it connects to; endpoints are registered by users, so it keeps them
+// from reaching internal services. Proxies are bypassed for the same reason.
+func newClient(timeout time.Duration, control func(network, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		// A redirect counts as a failure rather than sending the signed
+		// payload somewhere the endpoint owner didn't register.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Run delivers due webhooks until Done is closed. Deliveries interrupted by
+// shutdown keep their lease and are sent again after it expires.
+func (d *Dispatcher) Run() {
+	tick := time.NewTicker(d.Interval)
+	defer tick.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-d.Done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			d.deliverDue(ctx)
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	deliveries, err := d.Storage.ClaimWebhookDeliveries(ctx, d.WorkerID, d.BatchSize, d.LeaseTimeout)
+	if err != nil {
+		logger.Log.Error("Failed to claim webhook deliveries", zap.Error(err))
+		return
+	}
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for _, delivery := range deliveries {
+		delivery := delivery
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			d.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	delivery.Attempts++
+	status, err := d.send(ctx, delivery)
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	delivery.LastStatus = status
+	fields := []zap.Field{
+		zap.Int64("delivery", delivery.ID),
+		zap.Int("endpoint", delivery.EndpointID),
+		zap.Int("attempts", delivery.Attempts),
+	}
+	switch {
+	case err == nil:
+		delivery.State = models.DeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		logger.Log.Info("Webhook delivered", fields...)
+	case delivery.Attempts >= d.MaxAttempts:
+		delivery.State = models.DeliveryDead
+		delivery.LastError = truncate(err.Error(), maxErrorLength)
+		logger.Log.Error("Webhook dead-lettered", append(fields, zap.Error(err))...)
+	default:
+		delivery.NextAttemptAt = now.Add(d.retryDelay(delivery.Attempts - 1))
+		delivery.LastError = truncate(err.Error(), maxErrorLength)
+		logger.Log.Warn("Webhook delivery failed", append(fields, zap.Time("nextAttemptAt", delivery.NextAttemptAt), zap.Error(err))...)
+	}
+	if err := d.Storage.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		logger.Log.Error("Failed to update webhook delivery", zap.Int64("delivery", delivery.ID), zap.Error(err))
+	}
+}
+
+// send posts the payload and returns the response status, which is zero if
+// no response was received. Any status other than 2xx is an error.
+func (d *Dispatcher) send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("http.NewRequestWithContext: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gophermart-webhooks")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("d.Client.Do: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,153 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vindosVP/loyalty-system/internal/models"
+	"github.com/vindosVP/loyalty-system/internal/webhooks/mocks"
+	"github.com/vindosVP/loyalty-system/pkg/netguard"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDispatcher_deliver(t *testing.T) {
+	secret := "whsec_test"
+	payload := json.RawMessage(`{"id":"someEvent","type":"order.status_changed"}`)
+
+	type want struct {
+		state     string
+		attempts  int
+		status    int
+		retry     bool
+		lastError bool
+	}
+	tests := []struct {
+		name         string
+		endpointCode int
+		attempts     int
+		want         want
+	}{
+		{
+			name:         "delivered",
+			endpointCode: http.StatusNoContent,
+			want:         want{state: models.DeliveryPending, attempts: 1, status: http.StatusNoContent},
+		},
+		{
+			name:         "failed",
+			endpointCode: http.StatusServiceUnavailable,
+			attempts:     1,
+			want:         want{state: models.DeliveryPending, attempts: 2, status: http.StatusServiceUnavailable, retry: true, lastError: true},
+		},
+		{
+			name:         "dead-lettered",
+			endpointCode: http.StatusInternalServerError,
+			attempts:     2,
+			want:         want{state: models.DeliveryDead, attempts: 3, status: http.StatusInternalServerError, lastError: true},
+		},
+		{
+			name:         "redirect is a failure",
+			endpointCode: http.StatusFound,
+			want:         want{state: models.DeliveryPending, attempts: 1, status: http.StatusFound, retry: true, lastError: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, string(payload), string(body))
+				assert.Equal(t, models.WebhookEventOrderStatusChanged, r.Header.Get(EventHeader))
+				assert.Equal(t, "7", r.Header.Get(DeliveryHeader))
+				assert.True(t, Verify(secret, r.Header.Get(SignatureHeader), body, time.Minute, time.Now()))
+				if tt.endpointCode == http.StatusFound {
+					w.Header().Set("Location", "http://127.0.0.1:1/elsewhere")
+				}
+				w.WriteHeader(tt.endpointCode)
+			}))
+			defer srv.Close()
+
+			storage := mocks.NewStorage(t)
+			d := New(storage, 3, time.Second)
+			d.Client = newClient(time.Second, nil)
+			d.retryDelay = func(attempt int) time.Duration { return time.Minute }
+
+			delivery := &models.WebhookDelivery{
+				ID:         7,
+				EndpointID: 1,
+				EventType:  models.WebhookEventOrderStatusChanged,
+				Payload:    payload,
+				State:      models.DeliveryPending,
+				Attempts:   tt.attempts,
+				URL:        srv.URL,
+				Secret:     secret,
+			}
+			storage.On("UpdateWebhookDelivery", mock.Anything, delivery).Return(nil)
+
+			start := time.Now()
+			d.deliver(context.Background(), delivery)
+			if tt.endpointCode < 300 {
+				tt.want.state = models.DeliveryDelivered
+				require.NotNil(t, delivery.DeliveredAt)
+			}
+			assert.Equal(t, tt.want.state, delivery.State)
+			assert.Equal(t, tt.want.attempts, delivery.Attempts)
+			assert.Equal(t, tt.want.status, delivery.LastStatus)
+			assert.Equal(t, tt.want.lastError, delivery.LastError != "")
+			if tt.want.retry {
+				assert.WithinDuration(t, start.Add(time.Minute), delivery.NextAttemptAt, time.Second)
+			}
+		})
+	}
+}
+
+func TestDispatcher_deliverDue(t *testing.T) {
+	received := make(chan string, 3)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(DeliveryHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	storage := mocks.NewStorage(t)
+	d := New(storage, 3, time.Second)
+	d.Client = newClient(time.Second, nil)
+	deliveries := []*models.WebhookDelivery{
+		{ID: 1, URL: srv.URL, Payload: json.RawMessage(`{}`)},
+		{ID: 2, URL: srv.URL, Payload: json.RawMessage(`{}`)},
+		{ID: 3, URL: srv.URL, Payload: json.RawMessage(`{}`)},
+	}
+	storage.On("ClaimWebhookDeliveries", mock.Anything, d.WorkerID, d.BatchSize, d.LeaseTimeout).Return(deliveries, nil)
+	storage.On("UpdateWebhookDelivery", mock.Anything, mock.MatchedBy(func(delivery *models.WebhookDelivery) bool {
+		return delivery.State == models.DeliveryDelivered
+	})).Return(nil).Times(3)
+
+	d.deliverDue(context.Background())
+	close(received)
+	var ids []string
+	for id := range received {
+		ids = append(ids, id)
+	}
+	assert.ElementsMatch(t, []string{"1", "2", "3"}, ids)
+}
+
+func TestDispatcher_deliverRefusesPrivateAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("delivery reached a loopback address")
+	}))
+	defer srv.Close()
+
+	storage := mocks.NewStorage(t)
+	d := New(storage, 3, time.Second)
+	delivery := &models.WebhookDelivery{ID: 1, URL: srv.URL, Payload: json.RawMessage(`{}`), State: models.DeliveryPending}
+	storage.On("UpdateWebhookDelivery", mock.Anything, delivery).Return(nil)
+
+	d.deliver(context.Background(), delivery)
+	assert.Equal(t, models.DeliveryPending, delivery.State)
+	assert.Contains(t, delivery.LastError, netguard.ErrForbiddenAddress.Error())
+}
@@ -0,0 +1,48 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries "t=<unix time>,v1=<signature>", where the signature
+// is the hex HMAC-SHA256 of "<unix time>.<body>" keyed with the endpoint secret.
+// Receivers should reject old timestamps to prevent replays.
+const SignatureHeader = "X-Gophermart-Signature"
+
+func Sign(secret string, ts time.Time, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", ts.Unix(), signature(secret, ts.Unix(), body))
+}
+
+// Verify checks a signature header made by Sign and that it isn't older than
+// tolerance.
+func Verify(secret string, header string, body []byte, tolerance time.Duration, now time.Time) bool {
+	var ts int64
+	var sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			ts, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			sig = value
+		}
+	}
+	if ts == 0 || sig == "" || now.Sub(time.Unix(ts, 0)) > tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(signature(secret, ts, body)))
+}
+
+func signature(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,20 @@
+package webhooks
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	ts := time.Unix(1709294400, 0)
+	body := []byte(`{"id":"1"}`)
+	header := Sign("whsec_test", ts, body)
+	assert.Equal(t, "t=1709294400,v1=", header[:16])
+
+	assert.True(t, Verify("whsec_test", header, body, 5*time.Minute, ts.Add(time.Minute)))
+	assert.False(t, Verify("whsec_other", header, body, 5*time.Minute, ts))
+	assert.False(t, Verify("whsec_test", header, []byte(`{"id":"2"}`), 5*time.Minute, ts))
+	assert.False(t, Verify("whsec_test", header, body, 5*time.Minute, ts.Add(10*time.Minute)))
+	assert.False(t, Verify("whsec_test", "v1=abc", body, 5*time.Minute, ts))
+}
@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPrincipalFromContext(t *testing.T) {
+	_, ok := PrincipalFromContext(context.Background())
+	assert.False(t, ok)
+
+	want := &Principal{ID: 1, Login: "someLogin", Roles: []string{"customer"}, SessionID: "someSession"}
+	got, ok := PrincipalFromContext(WithPrincipal(context.Background(), want))
+	assert.True(t, ok)
+	assert.Equal(t, want, got)
+	assert.True(t, got.HasRole("customer"))
+	assert.False(t, got.HasRole("admin"))
+	assert.False(t, got.HasScope("orders:write"))
+}
@@ -0,0 +1,17 @@
+package logger
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// FromContext returns Log with the trace and span ids of the span in ctx, so
+// log lines can be matched with traces. Without a span it returns Log.
+func FromContext(ctx context.Context) *zap.Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return Log
+	}
+	return Log.With(zap.Stringer("traceId", sc.TraceID()), zap.Stringer("spanId", sc.SpanID()))
+}
@@ -0,0 +1,31 @@
+package logger
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	defer func(l *zap.Logger) { Log = l }(Log)
+	Log = zap.New(core)
+
+	FromContext(context.Background()).Info("no span")
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	FromContext(trace.ContextWithSpanContext(context.Background(), sc)).Info("with span")
+
+	entries := logs.All()
+	assert.Empty(t, entries[0].ContextMap())
+	assert.Equal(t, map[string]interface{}{
+		"traceId": "01000000000000000000000000000000",
+		"spanId":  "0200000000000000",
+	}, entries[1].ContextMap())
+}
@@ -0,0 +1,170 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgtype"
+	"math/big"
+	"strconv"
+)
+
+var (
+	ErrInvalidAmount = errors.New("invalid amount")
+	ErrOverflow      = errors.New("amount overflows")
+)
+
+const scale = 100
+
+// Amount is a number of loyalty points with a fixed precision of two decimal
+// places, stored as an integer number of hundredths.
+type Amount int64
+
+func FromInt(units int64) Amount {
+	return Amount(units * scale)
+}
+
+// Parse parses a decimal number such as "729.98" or "1e2". Values with more
+// than two decimal places are rounded half away from zero.
+func Parse(s string) (Amount, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	return fromRat(r)
+}
+
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func fromRat(r *big.Rat) (Amount, error) {
+	scaled := new(big.Rat).Mul(r, big.NewRat(scale, 1))
+	num, den := scaled.Num(), scaled.Denom()
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	}
+	if !q.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return Amount(q.Int64()), nil
+}
+
+func (a Amount) Rat() *big.Rat {
+	return big.NewRat(int64(a), scale)
+}
+
+// Mul multiplies the amount by factor, read as a plain decimal number such as
+// 1.25 rather than as points. The product is rounded half away from zero.
+func (a Amount) Mul(factor Amount) (Amount, error) {
+	return fromRat(new(big.Rat).Mul(a.Rat(), factor.Rat()))
+}
+
+func (a Amount) Float64() float64 {
+	return float64(a) / scale
+}
+
+// String formats the amount the way a float64 would be encoded to JSON:
+// 100, 100.5 or 100.05.
+func (a Amount) String() string {
+	sign := ""
+	abs := int64(a)
+	if abs < 0 {
+		sign = "-"
+		abs = -abs
+	}
+	units, cents := abs/scale, abs%scale
+	switch {
+	case cents == 0:
+		return fmt.Sprintf("%s%d", sign, units)
+	case cents%10 == 0:
+		return fmt.Sprintf("%s%d.%d", sign, units, cents/10)
+	default:
+		return fmt.Sprintf("%s%d.%02d", sign, units, cents)
+	}
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts JSON numbers as well as numbers quoted as strings.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func (a Amount) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(a)), Exp: -2, Valid: true}, nil
+}
+
+func (a *Amount) ScanNumeric(v pgtype.Numeric) error {
+	if !v.Valid {
+		return fmt.Errorf("%w: cannot scan NULL", ErrInvalidAmount)
+	}
+	if v.NaN || v.InfinityModifier != pgtype.Finite {
+		return fmt.Errorf("%w: %v", ErrInvalidAmount, v)
+	}
+	r := new(big.Rat).SetInt(v.Int)
+	pow := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(v.Exp))), nil))
+	if v.Exp > 0 {
+		r.Mul(r, pow)
+	} else {
+		r.Quo(r, pow)
+	}
+	parsed, err := fromRat(r)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+func (a *Amount) Scan(src any) error {
+	var err error
+	var parsed Amount
+	switch v := src.(type) {
+	case int64:
+		parsed, err = fromRat(new(big.Rat).SetInt64(v))
+	case float64:
+		parsed, err = Parse(strconv.FormatFloat(v, 'f', -1, 64))
+	case string:
+		parsed, err = Parse(v)
+	case []byte:
+		parsed, err = Parse(string(v))
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidAmount, src)
+	}
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func abs(n int32) int32 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,180 @@
+package money
+
+import (
+	"encoding/json"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	type want struct {
+		amount Amount
+		err    error
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  want
+	}{
+		{
+			name:  "integer",
+			input: "100",
+			want:  want{amount: 10000},
+		},
+		{
+			name:  "two decimals",
+			input: "729.98",
+			want:  want{amount: 72998},
+		},
+		{
+			name:  "exponent",
+			input: "1.5e2",
+			want:  want{amount: 15000},
+		},
+		{
+			name:  "rounds half away from zero",
+			input: "0.005",
+			want:  want{amount: 1},
+		},
+		{
+			name:  "rounds negative half away from zero",
+			input: "-0.005",
+			want:  want{amount: -1},
+		},
+		{
+			name:  "rounds down",
+			input: "0.004",
+			want:  want{amount: 0},
+		},
+		{
+			name:  "invalid",
+			input: "abc",
+			want:  want{err: ErrInvalidAmount},
+		},
+		{
+			name:  "overflow",
+			input: "1e30",
+			want:  want{err: ErrOverflow},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, err := Parse(tt.input)
+			if tt.want.err != nil {
+				assert.ErrorIs(t, err, tt.want.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.amount, amount)
+		})
+	}
+}
+
+func TestAmount_String(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount Amount
+		want   string
+	}{
+		{name: "zero", amount: 0, want: "0"},
+		{name: "integer", amount: FromInt(100), want: "100"},
+		{name: "one decimal", amount: MustParse("100.5"), want: "100.5"},
+		{name: "two decimals", amount: MustParse("100.05"), want: "100.05"},
+		{name: "negative", amount: MustParse("-0.5"), want: "-0.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.amount.String())
+		})
+	}
+}
+
+func TestAmount_Mul(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount Amount
+		factor Amount
+		want   Amount
+	}{
+		{name: "one", amount: MustParse("729.98"), factor: FromInt(1), want: MustParse("729.98")},
+		{name: "fraction", amount: FromInt(500), factor: MustParse("1.25"), want: MustParse("625")},
+		{name: "rounds half away from zero", amount: MustParse("0.02"), factor: MustParse("1.25"), want: MustParse("0.03")},
+		{name: "rounds down", amount: MustParse("0.01"), factor: MustParse("1.4"), want: MustParse("0.01")},
+		{name: "zero", amount: FromInt(100), factor: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.amount.Mul(tt.factor)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := FromInt(1 << 50).Mul(FromInt(1 << 20))
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestAmount_JSON(t *testing.T) {
+	type payload struct {
+		Sum     Amount `json:"sum"`
+		Accrual Amount `json:"accrual,omitempty"`
+	}
+
+	data, err := json.Marshal(payload{Sum: MustParse("729.98")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"sum": 729.98}`, string(data))
+
+	var got payload
+	err = json.Unmarshal([]byte(`{"sum": 0.1, "accrual": "0.2"}`), &got)
+	require.NoError(t, err)
+	assert.Equal(t, MustParse("0.3"), got.Sum+got.Accrual)
+}
+
+func TestAmount_Numeric(t *testing.T) {
+	tests := []struct {
+		name    string
+		numeric pgtype.Numeric
+		want    Amount
+	}{
+		{
+			name:    "two decimals",
+			numeric: pgtype.Numeric{Int: big.NewInt(72998), Exp: -2, Valid: true},
+			want:    MustParse("729.98"),
+		},
+		{
+			name:    "positive exponent",
+			numeric: pgtype.Numeric{Int: big.NewInt(5), Exp: 2, Valid: true},
+			want:    FromInt(500),
+		},
+		{
+			name:    "more decimals",
+			numeric: pgtype.Numeric{Int: big.NewInt(12345), Exp: -3, Valid: true},
+			want:    MustParse("12.35"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Amount
+			err := got.ScanNumeric(tt.numeric)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			n, err := got.NumericValue()
+			require.NoError(t, err)
+			var roundTrip Amount
+			require.NoError(t, roundTrip.ScanNumeric(n))
+			assert.Equal(t, got, roundTrip)
+		})
+	}
+
+	var got Amount
+	assert.ErrorIs(t, got.ScanNumeric(pgtype.Numeric{}), ErrInvalidAmount)
+}
@@ -0,0 +1,58 @@
+// Package netguard keeps outgoing requests to user-supplied URLs away from
+// the hosts and networks the service itself can reach but its users shouldn't.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+)
+
+var ErrForbiddenAddress = errors.New("address is not public")
+
+// sharedAddressSpace is the carrier-grade NAT range, which isn't reachable
+// from the internet either.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublic reports whether ip is a unicast address outside of the loopback,
+// private, link-local and unspecified ranges.
+func IsPublic(ip net.IP) bool {
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil && (ip4[0] == 0 || sharedAddressSpace.Contains(ip4)) {
+		return false
+	}
+	return true
+}
+
+// IsPublicHost reports whether host, a name or an IP address, may be public.
+// Names other than localhost can only be checked once they are resolved, by
+// dialing with Control.
+func IsPublicHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		return IsPublic(ip)
+	}
+	return true
+}
+
+// Control is a net.Dialer Control func that refuses to connect to addresses
+// that aren't public. It runs after name resolution, so a name can't be
+// re-pointed at an internal address between a check and the connection.
+func Control(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("net.SplitHostPort: %w", err)
+	}
+	if !IsPublic(net.ParseIP(host)) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package netguard
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "93.184.216.34", want: true},
+		{ip: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{ip: "127.0.0.1", want: false},
+		{ip: "::1", want: false},
+		{ip: "10.1.2.3", want: false},
+		{ip: "172.16.0.1", want: false},
+		{ip: "192.168.1.1", want: false},
+		{ip: "169.254.169.254", want: false},
+		{ip: "fe80::1", want: false},
+		{ip: "fd00::1", want: false},
+		{ip: "0.0.0.0", want: false},
+		{ip: "0.1.2.3", want: false},
+		{ip: "::", want: false},
+		{ip: "100.64.0.1", want: false},
+		{ip: "::ffff:127.0.0.1", want: false},
+		{ip: "224.0.0.1", want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, IsPublic(net.ParseIP(tt.ip)), tt.ip)
+	}
+}
+
+func TestIsPublicHost(t *testing.T) {
+	assert.True(t, IsPublicHost("shop.example.com"))
+	assert.True(t, IsPublicHost("93.184.216.34"))
+	assert.False(t, IsPublicHost("localhost"))
+	assert.False(t, IsPublicHost("api.LOCALHOST."))
+	assert.False(t, IsPublicHost("[::1]"))
+	assert.False(t, IsPublicHost("169.254.169.254"))
+}
+
+func TestControl(t *testing.T) {
+	assert.NoError(t, Control("tcp4", "93.184.216.34:443", nil))
+	assert.ErrorIs(t, Control("tcp4", "127.0.0.1:8080", nil), ErrForbiddenAddress)
+	assert.ErrorIs(t, Control("tcp6", "[::1]:8080", nil), ErrForbiddenAddress)
+}
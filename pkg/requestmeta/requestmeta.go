@@ -0,0 +1,20 @@
+package requestmeta
+
+import "context"
+
+// Meta describes the HTTP request a change originated from.
+type Meta struct {
+	RequestID string
+	IP        string
+}
+
+type metaKey struct{}
+
+func WithMeta(ctx context.Context, m *Meta) context.Context {
+	return context.WithValue(ctx, metaKey{}, m)
+}
+
+func FromContext(ctx context.Context) (*Meta, bool) {
+	m, ok := ctx.Value(metaKey{}).(*Meta)
+	return m, ok && m != nil
+}
@@ -0,0 +1,9 @@
+package tokens
+
+import "time"
+
+type Config struct {
+	Secret     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
@@ -3,15 +3,26 @@ package tokens
 import (
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
-	"strconv"
 )
 
-func JWTClaims(id int, login string, exp int64) jwt.MapClaims {
-	return jwt.MapClaims{
+func JWTClaims(id int, login string, exp int64, roles ...string) jwt.MapClaims {
+	claims := jwt.MapClaims{
 		"id":    id,
 		"login": login,
 		"exp":   exp,
 	}
+	if len(roles) > 0 {
+		claims["roles"] = roles
+	}
+	return claims
+}
+
+// SessionClaims are the claims of an access token bound to a session, so it
+// stops being accepted once the session is revoked.
+func SessionClaims(id int, login string, sessionID string, exp int64, roles ...string) jwt.MapClaims {
+	claims := JWTClaims(id, login, exp, roles...)
+	claims["sid"] = sessionID
+	return claims
 }
 
 func CreateJWT(claims jwt.MapClaims, secret string) (string, error) {
@@ -23,37 +34,53 @@ func CreateJWT(claims jwt.MapClaims, secret string) (string, error) {
 	return tokenString, nil
 }
 
-func IsAuthorized(token string, secret string) (bool, error) {
-	_, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secret), nil
-	})
-	if err != nil {
-		return false, err
-	}
-	return true, nil
+type Claims struct {
+	ID        int
+	Login     string
+	SessionID string
+	Roles     []string
+	Scopes    []string
 }
 
-func ExtractID(requestToken string, secret string) (string, error) {
+// ParseClaims validates the token and returns its claims in one pass.
+func ParseClaims(requestToken string, secret string) (*Claims, error) {
 	token, err := jwt.Parse(requestToken, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(secret), nil
 	})
-
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
 
-	if !ok && !token.Valid {
-		return "", fmt.Errorf("invalid token")
+	id, ok := mapClaims["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("invalid token: missing id")
 	}
+	claims := &Claims{ID: int(id)}
+	claims.Login, _ = mapClaims["login"].(string)
+	claims.SessionID, _ = mapClaims["sid"].(string)
+	claims.Roles = stringSlice(mapClaims["roles"])
+	claims.Scopes = stringSlice(mapClaims["scopes"])
+	return claims, nil
+}
 
-	id := claims["id"].(float64)
-	return strconv.FormatFloat(id, 'f', 0, 64), nil
+func stringSlice(v interface{}) []string {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	res := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			res = append(res, s)
+		}
+	}
+	return res
 }
@@ -2,23 +2,33 @@ package tokens
 
 import (
 	"github.com/stretchr/testify/assert"
-	"strconv"
+	"github.com/stretchr/testify/require"
 	"testing"
 	"time"
 )
 
-func TestExtractID(t *testing.T) {
-	userID := 1
-	userLogin := "someLogin"
+func TestParseClaims(t *testing.T) {
 	jwtSecret := "superSecret"
+	claims := SessionClaims(1, "someLogin", "someSession", time.Now().Add(time.Hour).Unix(), "customer", "support")
+	token, err := CreateJWT(claims, jwtSecret)
+	require.NoError(t, err)
 
-	token, err := CreateJWT(JWTClaims(userID, userLogin, time.Now().Add(time.Hour*72).Unix()), jwtSecret)
-	assert.NoError(t, err)
+	got, err := ParseClaims(token, jwtSecret)
+	require.NoError(t, err)
+	assert.Equal(t, &Claims{
+		ID:        1,
+		Login:     "someLogin",
+		SessionID: "someSession",
+		Roles:     []string{"customer", "support"},
+		Scopes:    nil,
+	}, got)
 
-	strID, err := ExtractID(token, jwtSecret)
-	assert.NoError(t, err)
+	_, err = ParseClaims(token, "otherSecret")
+	assert.Error(t, err)
 
-	id, err := strconv.Atoi(strID)
-	assert.NoError(t, err)
-	assert.Equal(t, userID, id)
+	token, err = CreateJWT(JWTClaims(1, "someLogin", time.Now().Add(time.Hour).Unix()), jwtSecret)
+	require.NoError(t, err)
+	got, err = ParseClaims(token, jwtSecret)
+	require.NoError(t, err)
+	assert.Empty(t, got.SessionID, "not bound to a session")
 }
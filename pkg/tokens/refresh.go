@@ -0,0 +1,53 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// RefreshToken is handed to the client as "<session id>.<secret>". Only the
+// hash of the secret is stored server side.
+type RefreshToken struct {
+	SessionID string
+	Secret    string
+}
+
+func NewSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("rand.Read: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func NewRefreshToken(sessionID string) (*RefreshToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("rand.Read: %w", err)
+	}
+	return &RefreshToken{SessionID: sessionID, Secret: base64.RawURLEncoding.EncodeToString(b)}, nil
+}
+
+func ParseRefreshToken(token string) (*RefreshToken, error) {
+	sessionID, secret, ok := strings.Cut(token, ".")
+	if !ok || sessionID == "" || secret == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	return &RefreshToken{SessionID: sessionID, Secret: secret}, nil
+}
+
+func (t *RefreshToken) String() string {
+	return t.SessionID + "." + t.Secret
+}
+
+func (t *RefreshToken) Hash() string {
+	sum := sha256.Sum256([]byte(t.Secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,28 @@
+package tokens
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRefreshToken(t *testing.T) {
+	sessionID, err := NewSessionID()
+	require.NoError(t, err)
+	token, err := NewRefreshToken(sessionID)
+	require.NoError(t, err)
+
+	parsed, err := ParseRefreshToken(token.String())
+	require.NoError(t, err)
+	assert.Equal(t, token, parsed)
+	assert.Equal(t, token.Hash(), parsed.Hash())
+
+	other, err := NewRefreshToken(sessionID)
+	require.NoError(t, err)
+	assert.NotEqual(t, token.Hash(), other.Hash())
+
+	for _, invalid := range []string{"", "abc", ".secret", "session."} {
+		_, err := ParseRefreshToken(invalid)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken, invalid)
+	}
+}
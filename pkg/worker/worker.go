@@ -0,0 +1,39 @@
+// Package worker has what the background workers share: an identity to take
+// leases under and the backoff between retries.
+package worker
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// NewID identifies this process as the owner of the leases it takes.
+func NewID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+}
+
+// Backoff is a delay that doubles with every attempt from Base up to Max.
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay returns the delay before the given attempt, counted from zero, with
+// "equal jitter": half of the delay is fixed and half is random.
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Base
+	for i := 0; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		d = b.Max
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,22 @@
+package worker
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	b := Backoff{Base: 30 * time.Second, Max: 6 * time.Hour}
+	for attempt := 0; attempt < 40; attempt++ {
+		want := b.Max
+		if attempt < 20 && b.Base<<attempt < b.Max {
+			want = b.Base << attempt
+		}
+		for i := 0; i < 100; i++ {
+			d := b.Delay(attempt)
+			assert.GreaterOrEqual(t, d, want/2)
+			assert.LessOrEqual(t, d, want)
+		}
+	}
+}